@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix starts every api key, telling them apart from JWTs in the Authorization header.
+const APIKeyPrefix = "todo_"
+
+const apiKeySize = 32     // random bytes of a key
+const apiKeyShownSize = 8 // characters of a key, after its prefix, kept to tell it apart
+
+// NewAPIKey creates a new random api key, returning it along with the hash it is kept as
+// and the start of it kept to tell it apart.
+func NewAPIKey() (key string, hash string, prefix string, err error) {
+	by := make([]byte, apiKeySize)
+	if _, err := rand.Read(by); nil != err {
+		return "", "", "", err
+	}
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(by)
+	return key, HashAPIKey(key), key[:len(APIKeyPrefix)+apiKeyShownSize], nil
+}
+
+// HashAPIKey gets the hash an api key is kept as. Keys are random, so a single sha256 is enough to protect them.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// isAPIKey checks the credential of a bearer Authorization header is an api key, rather than a JWT.
+func isAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
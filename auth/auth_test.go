@@ -0,0 +1,232 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"gatso/auth"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSecret = "test secret"
+
+// signToken creates a token of the given claims, signed with the key by the algorithm.
+func signToken(t *testing.T, alg string, kid string, key interface{}, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": kid})
+	body, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	hash := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}[alg[2:]]
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var sig []byte
+	var err error
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(hash.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if alg[:2] == "PS" {
+			sig, err = rsa.SignPSS(rand.Reader, k, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+	}
+	if nil != err {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// keySetJSON creates the JWKS of the public keys of the given RSA and ECDSA keys.
+func keySetJSON(rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) []byte {
+	enc := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	by, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": enc(rsaKey.N), "e": enc(big.NewInt(int64(rsaKey.E)))},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": enc(ecKey.X), "y": enc(ecKey.Y)},
+		{"kty": "oct", "kid": "secret", "k": "c2VjcmV0"},
+	}})
+	return by
+}
+
+func TestVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if nil != err {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(path, keySetJSON(rsaKey, ecKey), 0600); nil != err {
+		t.Fatal(err)
+	}
+	keys, err := auth.NewKeySet(path, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(keys.Keys("")) != 2 {
+		t.Fatalf("Expected the RSA and EC keys read from the key set, found %d", len(keys.Keys("")))
+	}
+
+	now := time.Now()
+	valid := map[string]interface{}{"sub": "123", "exp": now.Add(time.Hour).Unix()}
+	v := auth.NewVerifier(testSecret, keys, "", "")
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"HS256", signToken(t, "HS256", "", []byte(testSecret), valid), true},
+		{"HS512", signToken(t, "HS512", "", []byte(testSecret), valid), true},
+		{"RS256", signToken(t, "RS256", "rsa", rsaKey, valid), true},
+		{"PS384", signToken(t, "PS384", "", rsaKey, valid), true},
+		{"ES256", signToken(t, "ES256", "ec", ecKey, valid), true},
+		{"wrong secret", signToken(t, "HS256", "", []byte("guessed"), valid), false},
+		{"unknown key", signToken(t, "ES256", "", otherKey, valid), false},
+		{"wrong key id", signToken(t, "RS256", "ec", rsaKey, valid), false},
+		{"wrong curve size", signToken(t, "ES384", "ec", ecKey, valid), false},
+		{"expired", signToken(t, "HS256", "", []byte(testSecret),
+			map[string]interface{}{"sub": "123", "exp": now.Add(-time.Hour).Unix()}), false},
+		{"not yet valid", signToken(t, "HS256", "", []byte(testSecret),
+			map[string]interface{}{"sub": "123", "nbf": now.Add(time.Hour).Unix()}), false},
+		{"unsigned", base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+			base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"123"}`)) + ".", false},
+		{"malformed", "not.a-token", false},
+	}
+	for _, tt := range tests {
+		claims, err := v.Verify(tt.token, now)
+		if tt.valid && nil != err {
+			t.Errorf("Expected %s token to be valid, found %v", tt.name, err)
+		} else if !tt.valid && nil == err {
+			t.Errorf("Expected %s token to be refused, found claims %v", tt.name, claims)
+		}
+	}
+
+	// the issuer and audience must match when given
+	v = auth.NewVerifier(testSecret, nil, "issuer", "todo")
+	claims := map[string]interface{}{"sub": 123, "iss": "issuer", "aud": []string{"other", "todo"}}
+	if _, err := v.Verify(signToken(t, "HS256", "", []byte(testSecret), claims), now); nil != err {
+		t.Errorf("Expected token for the audience to be valid, found %v", err)
+	}
+	claims["aud"] = "other"
+	if _, err := v.Verify(signToken(t, "HS256", "", []byte(testSecret), claims), now); nil == err {
+		t.Errorf("Expected token for another audience to be refused")
+	}
+	if _, err := v.Verify(signToken(t, "RS256", "rsa", rsaKey, valid), now); nil == err {
+		t.Errorf("Expected token signed by an RSA key to be refused without a key set")
+	}
+}
+
+func TestKeySetUrl(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if nil != err {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Write(keySetJSON(rsaKey, ecKey))
+	}))
+	defer srv.Close()
+
+	keys, err := auth.NewKeySet(srv.URL, srv.Client())
+	if nil != err {
+		t.Fatal(err)
+	}
+	v := auth.NewVerifier("", keys, "", "")
+	claims, err := v.Verify(signToken(t, "ES256", "ec", ecKey, map[string]interface{}{"sub": 456}), time.Now())
+	if nil != err {
+		t.Fatal(err)
+	}
+	if id, err := claims.UserId("sub"); nil != err || id != 456 {
+		t.Errorf("Expected the token of user 456, found %d, %v", id, err)
+	}
+	// an unknown key id isn't fetched again straight away
+	if _, err := v.Verify(signToken(t, "ES256", "rotated", ecKey, map[string]interface{}{"sub": 456}), time.Now()); nil == err {
+		t.Errorf("Expected token signed with an unknown key id to be refused")
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("Expected the key set to be fetched once, found %d", n)
+	}
+}
+
+func TestAuthenticatorHandler(t *testing.T) {
+	handler := func(insecure bool) http.Handler {
+		a := auth.NewAuthenticator(auth.NewVerifier(testSecret, nil, "", ""), nil, "uid", insecure)
+		return a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := auth.OwnerId(r.Context())
+			if !ok {
+				t.Error("Expected an authenticated owner")
+			}
+			fmt.Fprint(w, id)
+		}))
+	}
+	token := signToken(t, "HS256", "", []byte(testSecret), map[string]interface{}{"sub": "1", "uid": 123})
+
+	tests := []struct {
+		name     string
+		insecure bool
+		header   string
+		query    string
+		status   int
+		owner    string
+	}{
+		{"token", false, "Bearer " + token, "", http.StatusOK, "123"},
+		{"token over owner", true, "Bearer " + token, "?owner=456", http.StatusOK, "123"},
+		{"no token", false, "", "?owner=456", http.StatusUnauthorized, ""},
+		{"bad token", true, "Bearer " + token + "x", "?owner=456", http.StatusUnauthorized, ""},
+		{"basic", false, "Basic dXNlcjpwYXNz", "", http.StatusUnauthorized, ""},
+		{"insecure owner", true, "", "?owner=456", http.StatusOK, "456"},
+		{"insecure bad owner", true, "", "?owner=me", http.StatusUnprocessableEntity, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/todo"+tt.query, nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		handler(tt.insecure).ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("Expected %s request to respond %d, found %d: %s", tt.name, tt.status, rec.Code, rec.Body)
+			continue
+		}
+		if tt.status == http.StatusOK && rec.Body.String() != tt.owner {
+			t.Errorf("Expected %s request by owner %s, found %s", tt.name, tt.owner, rec.Body)
+		}
+		if tt.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Expected %s request to be challenged for a token", tt.name)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keySetRefresh is the least time between fetching the keys of a key set url again, for a key id it doesn't have.
+const keySetRefresh = time.Minute
+
+const maxKeySetSize = 1024 * 1024 // bytes
+
+// Key is a public key of a key set, with the id and algorithm it is given there, if any.
+type Key struct {
+	ID  string
+	Alg string
+	Key crypto.PublicKey
+}
+
+// KeySet holds the RSA and ECDSA public keys of a JWKS, read from a local file or fetched from a url.
+// Keys fetched from a url are fetched again when a token is signed with a key id the set doesn't have,
+// so keys the issuer rotates in are picked up.
+type KeySet struct {
+	source  string
+	client  *http.Client
+	mu      sync.Mutex
+	keys    []Key
+	fetched time.Time
+}
+
+// NewKeySet reads the key set from the given source, a url when it starts with http:// or https://,
+// otherwise the path of a local file. Urls are fetched with the given client.
+func NewKeySet(source string, client *http.Client) (*KeySet, error) {
+	ks := &KeySet{source: source, client: client, fetched: time.Now()}
+	if err := ks.load(); nil != err {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Keys gets the keys with the given id, or every key when the id is empty.
+func (ks *KeySet) Keys(kid string) []Key {
+	ks.mu.Lock()
+	keys := ks.find(kid)
+	refresh := len(keys) == 0 && kid != "" && ks.isUrl() && time.Since(ks.fetched) >= keySetRefresh
+	if refresh {
+		ks.fetched = time.Now()
+	}
+	ks.mu.Unlock()
+
+	if refresh && nil == ks.load() {
+		ks.mu.Lock()
+		keys = ks.find(kid)
+		ks.mu.Unlock()
+	}
+	return keys
+}
+
+func (ks *KeySet) find(kid string) []Key {
+	var keys []Key
+	for _, k := range ks.keys {
+		if kid == "" || k.ID == kid {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (ks *KeySet) isUrl() bool {
+	return strings.HasPrefix(ks.source, "http://") || strings.HasPrefix(ks.source, "https://")
+}
+
+// load reads the keys from the source, replacing those the set has.
+func (ks *KeySet) load() error {
+	var by []byte
+	var err error
+	if ks.isUrl() {
+		by, err = ks.fetch()
+	} else {
+		by, err = ioutil.ReadFile(ks.source)
+	}
+	if nil != err {
+		return err
+	}
+	keys, err := ParseKeySet(by)
+	if nil != err {
+		return err
+	}
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+	return nil
+}
+
+func (ks *KeySet) fetch() ([]byte, error) {
+	resp, err := ks.client.Get(ks.source)
+	if nil != err {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+		return nil, fmt.Errorf("key set %s responded %s", ks.source, resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxKeySetSize))
+}
+
+// jwk is a json web key, holding the fields of the RSA and EC keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseKeySet reads the signing keys of a JWKS, {"keys": [...]}. Keys of other types, or for other uses, are left out.
+func ParseKeySet(by []byte) ([]Key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(by, &set); nil != err {
+		return nil, fmt.Errorf("failed to read key set %v", err)
+	}
+	var keys []Key
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch k.Kty {
+		case "RSA":
+			key, err = rsaKey(k)
+		case "EC":
+			key, err = ecKey(k)
+		default:
+			continue
+		}
+		if nil != err {
+			return nil, fmt.Errorf("failed to read key %q %v", k.Kid, err)
+		}
+		keys = append(keys, Key{ID: k.Kid, Alg: k.Alg, Key: key})
+	}
+	return keys, nil
+}
+
+func rsaKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := readInt(k.N)
+	if nil != err {
+		return nil, err
+	}
+	e, err := readInt(k.E)
+	if nil != err {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() < 2 || e.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid exponent")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func ecKey(k jwk) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := readInt(k.X)
+	if nil != err {
+		return nil, err
+	}
+	y, err := readInt(k.Y)
+	if nil != err {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// readInt reads the base64url encoded big endian bytes of an unsigned integer.
+func readInt(s string) (*big.Int, error) {
+	by, err := base64.RawURLEncoding.DecodeString(s)
+	if nil != err {
+		return nil, err
+	}
+	if len(by) == 0 {
+		return nil, fmt.Errorf("missing key value")
+	}
+	return new(big.Int).SetBytes(by), nil
+}
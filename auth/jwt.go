@@ -0,0 +1,237 @@
+// Package auth authenticates the requests made to the service, resolving the id of the owner making each of them.
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockSkew is the leeway given to the times in a token, for clocks that differ with the issuers.
+const clockSkew = time.Minute
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are the claims of a verified token, with numbers read as json.Number.
+type Claims map[string]interface{}
+
+// Verifier verifies the signature and times of bearer JWTs, signed either with a HMAC secret
+// or with one of the RSA or ECDSA keys of a key set.
+type Verifier struct {
+	secret   []byte
+	keys     *KeySet
+	issuer   string
+	audience string
+}
+
+// NewVerifier creates a Verifier of the tokens signed with the given HMAC secret, or by the keys of the given set.
+// Either may be empty. When an issuer or audience is given, tokens must have been issued by and for them.
+func NewVerifier(secret string, keys *KeySet, issuer string, audience string) *Verifier {
+	return &Verifier{secret: []byte(secret), keys: keys, issuer: issuer, audience: audience}
+}
+
+// Verify checks the token was signed by one of the verifiers keys and is valid at the given time, returning its claims.
+func (v Verifier) Verify(token string, at time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w, expected three parts", ErrInvalidToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodePart(parts[0], &header); nil != err {
+		return nil, fmt.Errorf("%w, header %v", ErrInvalidToken, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if nil != err {
+		return nil, fmt.Errorf("%w, signature %v", ErrInvalidToken, err)
+	}
+	if err := v.verifySignature(header.Alg, header.Kid, []byte(parts[0]+"."+parts[1]), sig); nil != err {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodePart(parts[1], &claims); nil != err {
+		return nil, fmt.Errorf("%w, claims %v", ErrInvalidToken, err)
+	}
+	if err := v.checkClaims(claims, at); nil != err {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verifySignature checks the signature of the signed header and claims with the key the algorithm uses.
+// HMAC algorithms use the secret, the others the keys of the set with the given key id, or any of them without one.
+func (v Verifier) verifySignature(alg string, kid string, signed []byte, sig []byte) error {
+	hash := algHash(alg)
+	if hash == 0 {
+		return fmt.Errorf("%w, unsupported algorithm %q", ErrInvalidToken, alg)
+	}
+
+	if strings.HasPrefix(alg, "HS") {
+		if len(v.secret) == 0 {
+			return fmt.Errorf("%w, no secret to verify %s", ErrInvalidToken, alg)
+		}
+		mac := hmac.New(hash.New, v.secret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return fmt.Errorf("%w, signature does not match", ErrInvalidToken)
+		}
+		return nil
+	}
+	if nil == v.keys {
+		return fmt.Errorf("%w, no keys to verify %s", ErrInvalidToken, alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	for _, key := range v.keys.Keys(kid) {
+		if key.Alg != "" && key.Alg != alg {
+			continue
+		}
+		if verifyKey(alg, hash, key.Key, digest, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w, signature does not match any key", ErrInvalidToken)
+}
+
+// algHash gets the hash the algorithm signs with, or zero when it isn't one of the supported
+// HMAC, RSA PKCS #1 v1.5, RSA PSS or ECDSA algorithms.
+func algHash(alg string) crypto.Hash {
+	if len(alg) != 5 {
+		return 0
+	}
+	switch alg[:2] {
+	case "HS", "RS", "PS", "ES":
+	default:
+		return 0
+	}
+	switch alg[2:] {
+	case "256":
+		return crypto.SHA256
+	case "384":
+		return crypto.SHA384
+	case "512":
+		return crypto.SHA512
+	}
+	return 0
+}
+
+// verifyKey checks the signature of the digest with the public key, when it is the type of key the algorithm uses.
+func verifyKey(alg string, hash crypto.Hash, key crypto.PublicKey, digest []byte, sig []byte) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return nil == rsa.VerifyPKCS1v15(k, hash, digest, sig)
+		case "PS":
+			return nil == rsa.VerifyPSS(k, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || len(sig) != 2*size || curveHashes[k.Curve.Params().Name] != hash {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+	return false
+}
+
+// curveHashes are the hashes each curve is used with, ES256 using P-256 and so on.
+var curveHashes = map[string]crypto.Hash{"P-256": crypto.SHA256, "P-384": crypto.SHA384, "P-521": crypto.SHA512}
+
+// checkClaims checks the token is valid at the given time, and was issued by and for the verifiers issuer and audience.
+func (v Verifier) checkClaims(claims Claims, at time.Time) error {
+	if exp, ok, err := claims.time("exp"); nil != err {
+		return err
+	} else if ok && !at.Before(exp.Add(clockSkew)) {
+		return fmt.Errorf("%w, expired at %s", ErrInvalidToken, exp.Format(time.RFC3339))
+	}
+	if nbf, ok, err := claims.time("nbf"); nil != err {
+		return err
+	} else if ok && at.Add(clockSkew).Before(nbf) {
+		return fmt.Errorf("%w, not valid until %s", ErrInvalidToken, nbf.Format(time.RFC3339))
+	}
+	if v.issuer != "" && claims["iss"] != v.issuer {
+		return fmt.Errorf("%w, not issued by %s", ErrInvalidToken, v.issuer)
+	}
+	if v.audience != "" && !claims.hasAudience(v.audience) {
+		return fmt.Errorf("%w, not issued for %s", ErrInvalidToken, v.audience)
+	}
+	return nil
+}
+
+// UserId reads the named claim as a user id, either a json number or a string of one.
+func (c Claims) UserId(name string) (int, error) {
+	var s string
+	switch v := c[name].(type) {
+	case json.Number:
+		s = v.String()
+	case string:
+		s = v
+	case nil:
+		return -1, fmt.Errorf("%w, missing %s claim", ErrInvalidToken, name)
+	}
+	id, err := strconv.Atoi(s)
+	if nil != err {
+		return -1, fmt.Errorf("%w, failed to read %s claim as a user ID", ErrInvalidToken, name)
+	}
+	return id, nil
+}
+
+// time reads the named claim as a NumericDate, the seconds since the epoch, reporting if the token has it.
+func (c Claims) time(name string) (time.Time, bool, error) {
+	v, ok := c[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%w, %s claim is not a number", ErrInvalidToken, name)
+	}
+	secs, err := n.Float64()
+	if nil != err {
+		return time.Time{}, false, fmt.Errorf("%w, %s claim %v", ErrInvalidToken, name, err)
+	}
+	return time.Unix(int64(secs), 0), true, nil
+}
+
+// hasAudience checks the aud claim, either a single audience or a list of them, has the given audience.
+func (c Claims) hasAudience(audience string) bool {
+	switch aud := c["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodePart decodes the base64url encoded json of a part of a token, reading numbers as json.Number.
+func decodePart(part string, v interface{}) error {
+	by, err := base64.RawURLEncoding.DecodeString(part)
+	if nil != err {
+		return err
+	}
+	d := json.NewDecoder(bytes.NewReader(by))
+	d.UseNumber()
+	return d.Decode(v)
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gatso/data"
+	"gatso/model"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParamOwnerId is the legacy header, or query parameter, giving the owner id, trusted only in insecure mode.
+const ParamOwnerId = "owner"
+
+const headerAuthorization = "Authorization"
+const schemeBearer = "Bearer"
+
+// keyUsedInterval is the least time between recording the last use of an api key, rather than on every request.
+const keyUsedInterval = time.Minute
+
+var ErrMissingToken = errors.New("missing bearer token")
+var ErrInvalidKey = errors.New("invalid api key")
+
+type contextKey int
+
+const identityKey contextKey = 0
+
+// identity is who a request was authenticated as, and the scope it may act in.
+type identity struct {
+	ownerId int
+	scope   string
+}
+
+// failure is why a request couldn't be authenticated, and the status to refuse it with.
+type failure struct {
+	status int
+	err    error
+}
+
+// Authenticator is the middleware resolving the owner making each request from the bearer JWT, or api key,
+// it is made with. In insecure mode, requests made without either may give the owner id with the legacy owner
+// parameter instead.
+type Authenticator struct {
+	verifier   *Verifier
+	keys       data.APIKeyStore
+	ownerClaim string
+	insecure   bool
+}
+
+// NewAuthenticator creates an Authenticator verifying tokens with the given verifier, which may be nil when only
+// api keys or the owner parameter are accepted, and reading the owner id from the named claim.
+// Api keys are looked up in the given store, which may be nil when they aren't accepted.
+// Insecure trusts the owner parameter of requests without a token, and is only for development.
+func NewAuthenticator(verifier *Verifier, keys data.APIKeyStore, ownerClaim string, insecure bool) *Authenticator {
+	return &Authenticator{verifier: verifier, keys: keys, ownerClaim: ownerClaim, insecure: insecure}
+}
+
+// Handler authenticates each request before passing it to the next handler, with the owner id in its context.
+// Requests without a valid token or key are refused as 401 Unauthorized,
+// and those outside the scope of their key as 403 Forbidden.
+func (a Authenticator) Handler(next http.Handler) http.Handler {
+	return a.Identify(Require(next))
+}
+
+// Identify resolves who made each request before passing it to the next handler, with the owner id in its context,
+// or why they couldn't be resolved, for Require to refuse it later. Handlers between the two, such as rate limits,
+// can tell authenticated requests from those that aren't.
+func (a Authenticator) Identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, status, err := a.authenticate(r)
+		var value interface{} = id
+		if nil != err {
+			value = failure{status: status, err: err}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey, value)))
+	})
+}
+
+// Require refuses each request Identify couldn't authenticate, as 401 Unauthorized unless it failed for another
+// reason, and those outside the scope of their key as 403 Forbidden, before passing the rest to the next handler.
+func Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id identity
+		switch v := r.Context().Value(identityKey).(type) {
+		case identity:
+			id = v
+		case failure:
+			if v.status == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", schemeBearer)
+			}
+			http.Error(w, v.err.Error(), v.status)
+			return
+		default:
+			w.Header().Set("WWW-Authenticate", schemeBearer)
+			http.Error(w, ErrMissingToken.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !model.ScopeAllows(id.scope, methodScope(r.Method)) {
+			http.Error(w, fmt.Sprintf("Scope %s does not allow %s requests", id.scope, r.Method), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// OwnerId gets the id of the owner the request context was authenticated as, reporting if it was.
+func OwnerId(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(identityKey).(identity)
+	return id.ownerId, ok
+}
+
+// Scope gets the scope the request context was authenticated with, empty if it wasn't.
+// Requests made with a token, rather than an api key, have every scope.
+func Scope(ctx context.Context) string {
+	id, _ := ctx.Value(identityKey).(identity)
+	return id.scope
+}
+
+// methodScope gets the scope needed to make a request with the given method, only reading needs no more than
+// ScopeRead.
+func methodScope(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return model.ScopeRead
+	default:
+		return model.ScopeReadWrite
+	}
+}
+
+// authenticate resolves who made the request from its bearer token or api key, or its owner parameter in
+// insecure mode, along with the status to refuse it with when they can't be.
+func (a Authenticator) authenticate(r *http.Request) (identity, int, error) {
+	header := r.Header.Get(headerAuthorization)
+	if header == "" {
+		if !a.insecure {
+			return identity{}, http.StatusUnauthorized, ErrMissingToken
+		}
+		ownerId, err := ownerParam(r)
+		if nil != err {
+			return identity{}, http.StatusUnprocessableEntity, err
+		}
+		return identity{ownerId: ownerId, scope: model.ScopeAdmin}, http.StatusOK, nil
+	}
+	scheme, credential, _ := strings.Cut(header, " ")
+	credential = strings.TrimSpace(credential)
+	if !strings.EqualFold(scheme, schemeBearer) || credential == "" {
+		return identity{}, http.StatusUnauthorized, fmt.Errorf("%w, expected a bearer token", ErrInvalidToken)
+	}
+	if isAPIKey(credential) {
+		return a.authenticateKey(r.Context(), credential)
+	}
+
+	if nil == a.verifier {
+		return identity{}, http.StatusUnauthorized, fmt.Errorf("%w, no keys to verify it", ErrInvalidToken)
+	}
+	claims, err := a.verifier.Verify(credential, time.Now())
+	if nil != err {
+		return identity{}, http.StatusUnauthorized, err
+	}
+	ownerId, err := claims.UserId(a.ownerClaim)
+	if nil != err {
+		return identity{}, http.StatusUnauthorized, err
+	}
+	return identity{ownerId: ownerId, scope: model.ScopeAdmin}, http.StatusOK, nil
+}
+
+// authenticateKey looks up the api key by its hash, recording when it was used.
+func (a Authenticator) authenticateKey(ctx context.Context, credential string) (identity, int, error) {
+	if nil == a.keys {
+		return identity{}, http.StatusUnauthorized, fmt.Errorf("%w, api keys are not accepted", ErrInvalidKey)
+	}
+	key, err := a.keys.KeyByHash(ctx, HashAPIKey(credential))
+	if nil != err {
+		return identity{}, http.StatusInternalServerError, err
+	}
+	if nil == key {
+		return identity{}, http.StatusUnauthorized, fmt.Errorf("%w, key not known", ErrInvalidKey)
+	}
+	if now := time.Now(); nil == key.LastUsed || now.Sub(*key.LastUsed) >= keyUsedInterval {
+		if err := a.keys.KeyUsed(ctx, key.ID, now); nil != err {
+			log.Printf("Failed to record the use of api key %s: %v", key.ID, err)
+		}
+	}
+	return identity{ownerId: key.Owner, scope: key.Scope}, http.StatusOK, nil
+}
+
+// ownerParam reads the owner id from a header named [ParamOwnerId], or failing that, the query parameter.
+func ownerParam(r *http.Request) (int, error) {
+	if s, hasOwner := r.Header[ParamOwnerId]; hasOwner {
+		return strconv.Atoi(s[0])
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get(ParamOwnerId))
+	if nil != err {
+		return -1, fmt.Errorf("Failed to read parameter %s as an owner ID", ParamOwnerId)
+	}
+	return id, nil
+}
@@ -71,12 +71,27 @@ func (cf Config) ReadInt(key string, value int) int {
 		return value
 	}
 
-	i, ok := v.(int)
-	if !ok {
+	// json numbers are read as float64
+	switch i := v.(type) {
+	case int:
+		return i
+	case float64:
+		return int(i)
+	default:
 		return value
 	}
+}
 
-	return i
+func (cf Config) ReadFloat(key string, value float64) float64 {
+	v, ok := cf[key]
+	if !ok {
+		return value
+	}
+	f, ok := v.(float64)
+	if !ok {
+		return value
+	}
+	return f
 }
 
 func (cf Config) ReadBool(key string, value bool) bool {
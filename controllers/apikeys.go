@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"gatso/auth"
+	"gatso/data"
+	"gatso/model"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const paramKeyId = "keyId"
+
+const maxKeyName = 200 // characters
+
+type APIKeysController struct {
+	keys data.APIKeyStore
+}
+
+// NewAPIKeysController creates the controller of the api keys in the key store.
+func NewAPIKeysController(keys data.APIKeyStore) *APIKeysController {
+	return &APIKeysController{keys: keys}
+}
+
+// Keys lists, creates or revokes the api keys of the owner, depending on the method.
+// Keys may only be managed with a token, or a key with the admin scope.
+func (c APIKeysController) Keys(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if !model.ScopeAllows(auth.Scope(r.Context()), model.ScopeAdmin) {
+		http.Error(w, fmt.Sprintf("Api keys can only be managed with the %s scope", model.ScopeAdmin), http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		c.listKeys(ownerId, w, r)
+	case http.MethodPost:
+		c.createKey(ownerId, w, r)
+	case http.MethodDelete:
+		c.revokeKey(ownerId, w, r)
+	default:
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+	}
+}
+
+// listKeys retrieves the keys of the owner, oldest first, without the keys themselves.
+func (c APIKeysController) listKeys(ownerId int, w http.ResponseWriter, r *http.Request) {
+	keys, err := c.keys.Keys(r.Context(), ownerId)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if nil == keys {
+		keys = []*model.APIKey{}
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// createKey creates a new key with the name and scope given in the request body, returning it with the key itself,
+// which is only ever given here.
+func (c APIKeysController) createKey(ownerId int, w http.ResponseWriter, r *http.Request) {
+	by, err := ioutil.ReadAll(r.Body)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	var key model.APIKey
+	if err := json.Unmarshal(by, &key); nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err := validateKey(&key); nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	secret, hash, prefix, err := auth.NewAPIKey()
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	key = model.APIKey{Owner: ownerId, Name: key.Name, Scope: key.Scope, Prefix: prefix, Hash: hash,
+		Created: time.Now()}
+	if key.ID, err = c.keys.AddKey(r.Context(), key); nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	key.Key = secret
+	writeJSON(w, http.StatusCreated, key)
+}
+
+// revokeKey removes the key given by the keyId parameter, if the owner owns it, so it can no longer be used.
+func (c APIKeysController) revokeKey(ownerId int, w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get(paramKeyId)
+	revoked, err := c.keys.RevokeKey(r.Context(), ownerId, id)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, fmt.Sprintf("api key %s not known", id), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func validateKey(key *model.APIKey) error {
+	if key.Name == "" {
+		return fmt.Errorf("An api key must have a name")
+	}
+	if len([]rune(key.Name)) > maxKeyName {
+		return fmt.Errorf("Api key name is longer than %d characters", maxKeyName)
+	}
+	if !model.ValidScope(key.Scope) {
+		return fmt.Errorf("Unknown api key scope %q, expected %s, %s or %s", key.Scope, model.ScopeRead,
+			model.ScopeReadWrite, model.ScopeAdmin)
+	}
+	return nil
+}
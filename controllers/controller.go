@@ -2,29 +2,47 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"gatso/auth"
 	"gatso/data"
 	"gatso/model"
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
-const paramOwnerId = "owner"
 const paramTaskId = "taskId"
+const paramLimit = "limit"
+const paramCursor = "cursor"
+const paramSort = "sort"
+const paramStatus = "status"
+const paramList = "list"
+const headerETag = "ETag"
+const headerIfMatch = "If-Match"
+const headerNextCursor = "X-Next-Cursor"
+const headerLink = "Link"
 
 type TaskController struct {
-	data data.Datastore
+	data    data.Datastore
+	lists   data.ListStore
+	groups  data.GroupStore
+	urgency model.UrgencyWeights
 }
 
-func NewTaskController(data data.Datastore) *TaskController {
-	return &TaskController{data: data}
+// NewTaskController creates the controller of the tasks in the datastore, kept in the lists of the list store,
+// and shared with the groups of the group store, scoring their urgency with the given weights.
+func NewTaskController(data data.Datastore, lists data.ListStore, groups data.GroupStore,
+	urgency model.UrgencyWeights) *TaskController {
+	return &TaskController{data: data, lists: lists, groups: groups, urgency: urgency}
 }
 
 func (c TaskController) Tasks(w http.ResponseWriter, r *http.Request) {
 
 	// request requires the ownerId parameter
-	ownerId, err := c.getOwnerId(r)
+	ownerId, err := getOwnerId(r)
 	if nil != err {
 		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 		return;
@@ -51,10 +69,11 @@ func (c TaskController) Tasks(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// OthersTasks retrieves all the task the given ownerId does NOT own, but has been granted view access.
+// OthersTasks retrieves all the task the given ownerId does NOT own, but has been shared with them,
+// or with any of the groups they are a member of, each with the role they have on it.
 func (c TaskController) OthersTasks(w http.ResponseWriter, r *http.Request) {
 	// request requires the ownerId parameter
-	ownerId, err := c.getOwnerId(r)
+	ownerId, err := getOwnerId(r)
 	if nil != err {
 		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 		return;
@@ -65,29 +84,35 @@ func (c TaskController) OthersTasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tasks, err := c.data.GetOthersTasks(ownerId)
+	opts, err := c.getListOptions(r)
 	if nil != err {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if nil == tasks {
-		http.Error(w, fmt.Sprintf("user %d not known", ownerId), http.StatusNotFound)
+	// groups are read as they are now, so membership changes take effect straight away
+	if opts.Groups, err = data.MemberGroups(r.Context(), c.groups, ownerId); nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	by, err := json.Marshal(tasks)
+	page, err := c.data.GetOthersTasks(r.Context(), ownerId, opts)
 	if nil != err {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.listError(w, err)
 		return
 	}
-
-	w.WriteHeader(http.StatusOK)
-	w.Write(by)
-
+	if nil == page.Tasks && opts.Cursor == "" {
+		http.Error(w, fmt.Sprintf("user %d not known", ownerId), http.StatusNotFound)
+		return
+	}
+	now := time.Now()
+	for _, task := range page.Tasks {
+		task.Role = model.MemberRoleOf(task, ownerId, opts.Groups, now)
+	}
+	c.writePage(w, r, page, opts)
 }
 
 func (c TaskController) Find(w http.ResponseWriter, r *http.Request) {
-	ownerId, err := c.getOwnerId(r)
+	ownerId, err := getOwnerId(r)
 	if nil != err {
 		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 		return;
@@ -96,6 +121,11 @@ func (c TaskController) Find(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "No query task in body found", http.StatusUnprocessableEntity)
 		return;
 	}
+	opts, err := c.getListOptions(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	by, err := ioutil.ReadAll(r.Body)
 	if nil != err {
@@ -110,30 +140,169 @@ func (c TaskController) Find(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tasks, err := c.data.FindTasks(ownerId, query)
+	page, err := c.data.FindTasks(r.Context(), ownerId, query, opts)
 	if nil != err {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		c.listError(w, err)
 		return
 	}
 
-	if len(tasks) == 0 {
+	if len(page.Tasks) == 0 && opts.Cursor == "" {
 		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 		return
 	}
+	c.writePage(w, r, page, opts)
+}
 
-	by, err = json.Marshal(tasks)
+// Trash retrieves a page of the tasks the given ownerId has deleted, which have not yet been purged.
+func (c TaskController) Trash(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
 	if nil != err {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	opts, err := c.getListOptions(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := c.data.GetTrash(r.Context(), ownerId, opts)
+	if nil != err {
+		c.listError(w, err)
+		return
+	}
+	c.writePage(w, r, page, opts)
+}
+
+// Restore moves the task given by the taskid parameter out of the trash, if the owner owns it or is one of its admins.
+// A task which would take its owner over their quota is refused as 403 Forbidden.
+// It no longer depends on the tasks deleted while it was in the trash.
+func (c TaskController) Restore(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
 
+	taskId := r.URL.Query().Get(paramTaskId)
+	if taskId == "" {
+		http.Error(w, fmt.Sprintf("Missing %s parameter", paramTaskId), http.StatusBadRequest)
+		return
+	}
+
+	restored, err := c.data.RestoreTask(r.Context(), ownerId, taskId)
+	if errors.Is(err, data.ErrQuotaExceeded) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !restored {
+		http.Error(w, fmt.Sprintf("task %s not in the trash", taskId), http.StatusNotFound)
+		return
+	}
+	if err := data.DropRemovedBlockers(r.Context(), c.data, taskId); nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
-	w.Write(by)
+}
+
+// Archive retrieves a page of the given ownerIds long expired tasks, moved to the archive.
+// An optional query task in the body filters them as Find does.
+func (c TaskController) Archive(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	opts, err := c.getListOptions(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var query model.Task
+	if nil != r.Body {
+		by, err := ioutil.ReadAll(r.Body)
+		if nil != err {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(by) > 0 {
+			if err := json.Unmarshal(by, &query); nil != err {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	page, err := c.data.GetArchive(r.Context(), ownerId, query, opts)
+	if nil != err {
+		c.listError(w, err)
+		return
+	}
+	c.writePage(w, r, page, opts)
+}
+
+// Unarchive moves the task given by the taskid parameter out of the owners archive, back to their todo list.
+// A task which would take the owner over their quota is refused as 403 Forbidden.
+// It no longer depends on the tasks deleted while it was in the archive.
+func (c TaskController) Unarchive(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	taskId := r.URL.Query().Get(paramTaskId)
+	if taskId == "" {
+		http.Error(w, fmt.Sprintf("Missing %s parameter", paramTaskId), http.StatusBadRequest)
+		return
+	}
 
+	unarchived, err := c.data.UnarchiveTask(r.Context(), ownerId, taskId)
+	if errors.Is(err, data.ErrQuotaExceeded) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !unarchived {
+		http.Error(w, fmt.Sprintf("task %s not in the archive", taskId), http.StatusNotFound)
+		return
+	}
+	if err := data.DropRemovedBlockers(r.Context(), c.data, taskId); nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
 }
 
 func (c TaskController) Users(w http.ResponseWriter, r *http.Request) {
-	users, err := c.data.Users()
+	users, err := c.data.Users(r.Context())
 	if nil != err {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -152,61 +321,143 @@ func (c TaskController) Users(w http.ResponseWriter, r *http.Request) {
 	w.Write(by)
 }
 
-// getTasks retrieves all the tasks belonging to the given ownerId.
+// getTasks retrieves a page of the tasks belonging to the given ownerId.
 // Only tasks owned by the ownerId are returned.
+// If a taskid parameter is given, only that task is returned, if the owner owns it or has it shared with them,
+// or a task it is a subtask of.
 func (c TaskController) getTasks(ownerId int, w http.ResponseWriter, r *http.Request) {
+	if taskId := r.URL.Query().Get(paramTaskId); taskId != "" {
+		c.getTask(ownerId, taskId, w, r)
+		return
+	}
 
-	tasks, err := c.data.GetTasks(ownerId)
+	opts, err := c.getListOptions(r)
 	if nil != err {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if nil == tasks {
+
+	page, err := c.data.GetTasks(r.Context(), ownerId, opts)
+	if nil != err {
+		c.listError(w, err)
+		return
+	}
+	if nil == page.Tasks && opts.Cursor == "" {
 		http.Error(w, fmt.Sprintf("user %d not known", ownerId), http.StatusNotFound)
 		return
 	}
+	c.writePage(w, r, page, opts)
+}
 
-	by, err := json.Marshal(tasks)
+// getTask retrieves a single task, with its version as the ETag, and the progress of its subtasks.
+func (c TaskController) getTask(ownerId int, taskId string, w http.ResponseWriter, r *http.Request) {
+	task := c.data.GetTask(r.Context(), taskId)
+	readable, err := c.readable(r.Context(), task, ownerId)
 	if nil != err {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if !readable {
+		http.Error(w, fmt.Sprintf("task %s not known", taskId), http.StatusNotFound)
+		return
+	}
+	task.Urgency = c.urgency.Urgency(task, time.Now())
+	if err := c.setProgress(r.Context(), task); nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
+	by, err := json.Marshal(task)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(headerETag, formatETag(task.Version))
 	w.WriteHeader(http.StatusOK)
 	w.Write(by)
-
 }
 
 // createTask will insert a new task under the given owners id.
 // the request body must contain a json encoded Task to insert.
 // The new task MUST have a title, and an expiry time in the future.
 // _id and created times specified in the object are ignored and replaced with the new objects values.
+// It is put in the default list, unless given one of the owners lists which isn't archived.
+// Owners who already have as many tasks as they may are refused with 403 Forbidden.
 func (c TaskController) createTask(ownerId int, w http.ResponseWriter, r *http.Request) {
 
 	by, err := ioutil.ReadAll(r.Body)
 	if nil != err {
 		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
 	}
 
 	var task model.Task
 	err = json.Unmarshal(by, &task)
 	if nil != err {
 		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if _, err := model.TaskReminders(&task); nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := model.TaskRecurrence(&task); nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkStatus(task.Status); nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !model.ValidPriority(task.Priority) {
+		http.Error(w, fmt.Sprintf("Unknown priority %d, expected %d to %d", task.Priority, model.PriorityNone,
+			model.PriorityHigh), http.StatusBadRequest)
+		return
+	}
+
+	err = data.CheckList(r.Context(), c.lists, &task, true)
+	if nil == err {
+		err = data.CheckGroups(r.Context(), c.groups, &task)
+	}
+	if errors.Is(err, data.ErrInvalidList) || errors.Is(err, data.ErrInvalidGroup) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	id, err := c.data.AddTask(ownerId, task)
+	id, err := c.data.AddTask(r.Context(), ownerId, task)
+	if errors.Is(err, data.ErrInvalidParent) || errors.Is(err, data.ErrInvalidDependency) ||
+		errors.Is(err, data.ErrInvalidACL) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, data.ErrQuotaExceeded) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 	if nil != err {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set(headerETag, formatETag(1))
 	w.WriteHeader(http.StatusCreated)
 	w.Write([]byte(id))
 }
 
 // updateTask updates the task with the _id of the task object given in the request body.
-// the body MUST contain a json encoded Task object which, if already existing, must belong to the ownerId.
+// the body MUST contain a json encoded Task object which, if already existing, must belong to the ownerId,
+// or be shared with them as an editor or admin. Only its owner and admins may change who it is shared with.
 // If the task already exists, it is replaced with the given object.  If it doesn't exist, it is created.
+// An If-Match header, with the ETag of the task, only replaces the task if it hasn't changed since.
 func (c TaskController) updateTask(ownerId int, w http.ResponseWriter, r *http.Request) {
+	version, ok := getIfMatch(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+		return
+	}
 
 	by, err := ioutil.ReadAll(r.Body)
 	if nil != err {
@@ -221,18 +472,71 @@ func (c TaskController) updateTask(ownerId int, w http.ResponseWriter, r *http.R
 		return
 	}
 
-	err = c.data.UpdateTask(ownerId, task)
+	if _, err := model.TaskReminders(&task); nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := model.TaskRecurrence(&task); nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkStatus(task.Status); nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !model.ValidPriority(task.Priority) {
+		http.Error(w, fmt.Sprintf("Unknown priority %d, expected %d to %d", task.Priority, model.PriorityNone,
+			model.PriorityHigh), http.StatusBadRequest)
+		return
+	}
+
+	err = data.CheckList(r.Context(), c.lists, &task, false)
+	if nil == err {
+		err = data.CheckGroups(r.Context(), c.groups, &task)
+	}
+	if errors.Is(err, data.ErrInvalidList) || errors.Is(err, data.ErrInvalidGroup) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	task.Version = version
+	version, err = c.data.UpdateTask(r.Context(), ownerId, task)
+	if err == data.ErrVersionConflict {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	if errors.Is(err, data.ErrStatusChange) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, data.ErrAccessDenied) || errors.Is(err, data.ErrQuotaExceeded) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 	if nil != err {
 		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 		return
 	}
 
+	w.Header().Set(headerETag, formatETag(version))
 	w.WriteHeader(http.StatusOK)
 }
 
-// deleteTask deletes a task, belonging to the given ownerId.
-// the request MUST contain a query parameter containing the taskid and that task must be owned by the given owner id.
+// deleteTask moves a task, belonging to the given ownerId, to the trash.
+// the request MUST contain a query parameter containing the taskid and that task must be owned by the given owner id,
+// or shared with them as an admin.
+// An If-Match header, with the ETag of the task, only deletes the task if it hasn't changed since.
+// Its subtasks are deleted with it, unless the subtasks parameter is "orphan", leaving them as top level tasks.
 func (c TaskController) deleteTask(ownerId int, w http.ResponseWriter, r *http.Request) {
+	version, ok := getIfMatch(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+		return
+	}
 
 	taskId := r.URL.Query().Get(paramTaskId)
 	if taskId == "" {
@@ -240,28 +544,140 @@ func (c TaskController) deleteTask(ownerId int, w http.ResponseWriter, r *http.R
 		return
 	}
 
-	if !c.data.DeleteTask(ownerId, taskId) {
+	orphan := r.URL.Query().Get(paramSubtasks) == subtasksOrphan
+	deleted, err := data.DeleteTaskTree(r.Context(), c.data, ownerId, taskId, version, orphan)
+	if err == data.ErrVersionConflict {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
 		w.WriteHeader(http.StatusNoContent) // Nothing deleted
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
-// getOwnerId attempts to read the owner ID from a header named [paramOwnerId].
-// If not found in the header, it looks on the query URL for the same named parameter
-func (c TaskController) getOwnerId(r *http.Request) (int, error) {
-	if s, hasOwner := r.Header[paramOwnerId]; hasOwner {
-		id, err := strconv.Atoi(s[0])
-		if nil != err {
-			return -1, err
+// getOwnerId reads the owner ID the request was authenticated as by the [auth.Authenticator] middleware.
+func getOwnerId(r *http.Request) (int, error) {
+	id, ok := auth.OwnerId(r.Context())
+	if !ok {
+		return -1, fmt.Errorf("Request has no authenticated owner ID")
+	}
+	return id, nil
+}
+
+// getListOptions reads the [paramLimit], [paramCursor], [paramSort], [paramStatus] and [paramList] query parameters,
+// selecting the page of a listing to return, its order, and the list it is taken from.
+// The statuses are comma separated, or "all" for every status, done tasks are otherwise left out of the todo list.
+func (c TaskController) getListOptions(r *http.Request) (data.ListOptions, error) {
+	var opts data.ListOptions
+	q := r.URL.Query()
+	if s := q.Get(paramLimit); s != "" {
+		limit, err := strconv.Atoi(s)
+		if nil != err || limit < 1 {
+			return opts, fmt.Errorf("Failed to read parameter %s as a positive number", paramLimit)
+		}
+		opts.Limit = limit
+	}
+	opts.Cursor = q.Get(paramCursor)
+	opts.List = q.Get(paramList)
+
+	sort, err := data.ParseSort(q.Get(paramSort))
+	if nil != err {
+		return opts, err
+	}
+	opts.Sort = sort
+	opts.Urgency = c.urgency
+
+	if s := q.Get(paramStatus); s == statusAll {
+		opts.Status = model.Statuses
+	} else if s != "" {
+		for _, status := range strings.Split(s, ",") {
+			status = strings.TrimSpace(status)
+			if status == "" {
+				continue
+			}
+			if err := checkStatus(status); nil != err {
+				return opts, err
+			}
+			opts.Status = append(opts.Status, status)
 		}
-		return id, nil;
 	}
+	return opts, nil
+}
+
+// statusAll lists tasks of every status.
+const statusAll = "all"
+
+// checkStatus checks a status given is one of the model statuses.
+func checkStatus(status string) error {
+	if status != "" && !model.ValidStatus(status) {
+		return fmt.Errorf("Unknown status %q, expected one of %s", status, strings.Join(model.Statuses, ", "))
+	}
+	return nil
+}
+
+// listError reports a failed listing, a bad cursor or too many tasks to sort is the callers fault,
+// anything else is ours.
+func (c TaskController) listError(w http.ResponseWriter, err error) {
+	if err == data.ErrInvalidCursor || errors.Is(err, data.ErrTooManyToSort) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
 
-	s := r.URL.Query().Get(paramOwnerId)
-	id, err := strconv.Atoi(s)
+// writePage writes the tasks of the page as a json array, along with the urgency of each task.
+// When there are more tasks, the cursor to the following page is given in the X-Next-Cursor header,
+// and the url of that page as the next Link.
+// Tasks sorted by urgency were already scored, as of the first page of the listing.
+func (c TaskController) writePage(w http.ResponseWriter, r *http.Request, page model.TaskPage, opts data.ListOptions) {
+	if !opts.Sort.ByUrgency() {
+		now := time.Now()
+		for _, task := range page.Tasks {
+			task.Urgency = c.urgency.Urgency(task, now)
+		}
+	}
+	tasks := page.Tasks
+	if nil == tasks {
+		tasks = []*model.Task{}
+	}
+	by, err := json.Marshal(tasks)
 	if nil != err {
-		return -1, fmt.Errorf("Failed to read parameter %s as an owner ID", paramOwnerId)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if page.Next != "" {
+		query := r.URL.Query()
+		query.Set(paramCursor, page.Next)
+		w.Header().Set(headerNextCursor, page.Next)
+		w.Header().Set(headerLink, fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, query.Encode()))
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(by)
+}
+
+// getIfMatch reads the task version from the If-Match header, zero if there is none, or it matches any version.
+// Returns false if the header can't match any version of a task.
+func getIfMatch(r *http.Request) (int, bool) {
+	s := strings.TrimSpace(r.Header.Get(headerIfMatch))
+	if s == "" || s == "*" {
+		return 0, true
 	}
-	return id, nil;
+	s = strings.TrimPrefix(s, "W/")
+	version, err := strconv.Atoi(strings.Trim(s, `"`))
+	if nil != err || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
+// formatETag gives the ETag of the given version of a task.
+func formatETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
 }
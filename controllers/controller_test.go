@@ -1,53 +1,89 @@
 package controllers_test
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"gatso/auth"
 	"gatso/controllers"
 	"gatso/data"
 	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 )
 
-const testDBUri = "mongodb://localhost:27017"
 const testOwnerId = 123
 
 var testTaskId string
+var testStore data.Datastore
 var srv *http.Server
 
 //initControllerTest will drop the database and create a single test task, belonging to owner 123.
 func initControllerTest() {
 
-	ms, err := data.NewMongoDataStore(testDBUri)
-	if nil != err {
-		panic(err)
-	}
-	ms.Drop()
+	history := data.NewMemoryHistoryStore()
+	bus := data.NewEventBus(10)
+	webhooks := data.NewMemoryWebhookStore()
+	lists := data.NewMemoryListStore()
+	groups := data.NewMemoryGroupStore()
+	apiKeys := data.NewMemoryAPIKeyStore()
+	ms := data.NewWebhookDataStore(
+		data.NewEventDataStore(data.NewHistoryDataStore(data.NewMemoryDataStore(), history), bus), webhooks, groups)
 
 	task, err := createTestTask([]byte(`{ "owner": 123, "title": "Test Task" }`))
 	if nil != err {
 		panic(err)
 	}
-	testTaskId, err = ms.AddTask(testOwnerId, *task)
+	testTaskId, err = ms.AddTask(context.Background(), testOwnerId, *task)
 	if nil != err {
 		panic(err)
 	}
+	testStore = ms
 
-	ctrl := controllers.NewTaskController(ms)
+	ctrl := controllers.NewTaskController(ms, lists, groups, model.DefaultUrgencyWeights)
 	mux := http.NewServeMux()
 	mux.HandleFunc("/test", ctrl.Tasks)
 	mux.HandleFunc("/testothers", ctrl.OthersTasks)
+	mux.HandleFunc("/testtrash", ctrl.Trash)
+	mux.HandleFunc("/testtrash/restore", ctrl.Restore)
+	mux.HandleFunc("/testarchive", ctrl.Archive)
+	mux.HandleFunc("/testarchive/restore", ctrl.Unarchive)
+	mux.HandleFunc("/testoccurrences", ctrl.Occurrences)
+	mux.HandleFunc("/testcomplete", ctrl.Complete)
+	mux.HandleFunc("/testsubtasks", ctrl.Subtasks)
+	mux.HandleFunc("/testtree", ctrl.Tree)
+	mux.HandleFunc("/testdependencies", ctrl.Dependencies)
+	mux.HandleFunc("/testlists", controllers.NewListsController(lists, ms).Lists)
+	mux.HandleFunc("/testhistory", controllers.NewHistoryController(history, ms, groups).History)
+	mux.HandleFunc("/testevents", controllers.NewEventsController(bus, ms, groups).Events)
+	groupsCtrl := controllers.NewGroupsController(groups)
+	mux.HandleFunc("/testgroups", groupsCtrl.Groups)
+	mux.HandleFunc("/testgroups/members", groupsCtrl.Members)
+	mux.HandleFunc("/testapikeys", controllers.NewAPIKeysController(apiKeys).Keys)
+	webhooksCtrl := controllers.NewWebhooksController(webhooks, data.NewWebhookHosts([]string{"localhost"}))
+	mux.HandleFunc("/testwebhooks", webhooksCtrl.Webhooks)
+	mux.HandleFunc("/testwebhooks/deliveries", webhooksCtrl.Deliveries)
 
+	// the tests give the owner by the owner parameter, only trusted in insecure mode
 	srv = &http.Server{
 		Addr:    ":8008",
-		Handler: mux,
+		Handler: auth.NewAuthenticator(nil, apiKeys, "sub", true).Handler(mux),
+	}
+	// Listen before returning so the tests don't race the server starting up.
+	ln, err := net.Listen("tcp", srv.Addr)
+	if nil != err {
+		panic(err)
 	}
 	go func() {
-		if err := srv.ListenAndServe(); nil != err {
+		if err := srv.Serve(ln); nil != err {
 			if err != http.ErrServerClosed {
 				panic(err)
 			}
@@ -61,6 +97,8 @@ func endTest() {
 			panic(err)
 		}
 	}
+	// drop keep-alive connections to the closed server, so the next test doesn't reuse them
+	http.DefaultClient.CloseIdleConnections()
 }
 
 func TestTaskControllerTasksGet(t *testing.T) {
@@ -164,6 +202,48 @@ func TestTaskControllerTasksPost(t *testing.T) {
 		return
 	}
 
+	// a body which isn't a task is refused, with only the reason it can't be read
+	resp, err = http.Post(fmt.Sprintf("http://localhost:8008/test?owner=%d", testOwnerId),
+		"application/json", bytes.NewBufferString("not a task"))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Failed to get expected response.  Expected %s, found %s",
+			http.StatusText(http.StatusUnprocessableEntity), http.StatusText(resp.StatusCode))
+	}
+	if strings.Count(string(by), "\n") != 1 {
+		t.Errorf("Expected a single error refusing a body which isn't a task, found %q", by)
+	}
+
+}
+
+func TestTaskControllerTasksReminders(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	for _, tt := range []struct {
+		reminders string
+		status    int
+	}{
+		{`["1d", "2h30m", "0"]`, http.StatusCreated},
+		{`["soon"]`, http.StatusBadRequest},
+		{`["-1h"]`, http.StatusBadRequest},
+	} {
+		resp, err := http.Post(fmt.Sprintf("http://localhost:8008/test?owner=%d", testOwnerId), "application/json",
+			bytes.NewReader([]byte(`{"owner": 123, "title": "Reminded", "reminders": `+tt.reminders+`}`)))
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("Expected response %s for reminders %s, found %s", http.StatusText(tt.status), tt.reminders, resp.Status)
+		}
+	}
 }
 
 func TestTaskControllerTasksPut(t *testing.T) {
@@ -318,6 +398,1625 @@ func TestTaskControllerTasksDelete(t *testing.T) {
 	}
 }
 
+func TestTaskControllerTasksPaged(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	task, err := createTestTask([]byte(`{"owner": 123, "title": "another test"}`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err := json.Marshal(task)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp, err := http.Post("http://localhost:8008/test?owner=123", "application/json", bytes.NewReader(by))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+
+	// first page holds one task, as a json array, and a cursor to the next in the headers
+	resp, err = http.Get("http://localhost:8008/test?owner=123&limit=1")
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	var tasks []*model.Task
+	if err := json.Unmarshal(by, &tasks); nil != err {
+		t.Error(err)
+		return
+	}
+	next := resp.Header.Get("X-Next-Cursor")
+	if len(tasks) != 1 || next == "" {
+		t.Errorf("Expected one task and a next cursor, found %d tasks and cursor %q", len(tasks), next)
+		return
+	}
+	link := fmt.Sprintf(`</test?cursor=%s&limit=1&owner=123>; rel="next"`, url.QueryEscape(next))
+	if resp.Header.Get("Link") != link {
+		t.Errorf("Expected the next page linked as %s, found %s", link, resp.Header.Get("Link"))
+		return
+	}
+	firstId := tasks[0].Id()
+
+	resp, err = http.Get("http://localhost:8008/test?owner=123&limit=1&cursor=" + url.QueryEscape(next))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	tasks = nil
+	if err := json.Unmarshal(by, &tasks); nil != err {
+		t.Error(err)
+		return
+	}
+	next = resp.Header.Get("X-Next-Cursor")
+	if len(tasks) != 1 || tasks[0].Id() == firstId || next != "" || resp.Header.Get("Link") != "" {
+		t.Errorf("Expected the other task on the last page, found %d tasks and cursor %q", len(tasks), next)
+		return
+	}
+
+	// bad paging parameters are rejected
+	for _, params := range []string{"cursor=madeup", "limit=none", "limit=-1", "sort=labels", "sort=title,title"} {
+		resp, err = http.Get("http://localhost:8008/test?owner=123&" + params)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected response %s for %s, found %s",
+				http.StatusText(http.StatusBadRequest), params, http.StatusText(resp.StatusCode))
+		}
+	}
+}
+
+func TestTaskControllerTasksSorted(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	for _, title := range []string{"b task", "a task"} {
+		by, err := json.Marshal(model.Task{Owner: testOwnerId, Title: title})
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp, err := http.Post("http://localhost:8008/test?owner=123", "application/json", bytes.NewReader(by))
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get("http://localhost:8008/test?owner=123&sort=-title")
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	tasks, err := readTasks(by)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(tasks) != 3 || tasks[0].Title != "b task" || tasks[1].Title != "a task" || tasks[2].Title != "Test Task" {
+		t.Errorf("Expected tasks sorted by title, descending, found %d tasks", len(tasks))
+		return
+	}
+}
+
+func TestTaskControllerTasksUrgency(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	for _, body := range []string{
+		`{"owner": 123, "title": "high", "priority": 3}`,
+		`{"owner": 123, "title": "overdue", "priority": 1, "expires": "2020-01-01T00:00:00Z"}`,
+	} {
+		resp, err := http.Post("http://localhost:8008/test?owner=123", "application/json", strings.NewReader(body))
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("Expected task %s to be created, found %s", body, resp.Status)
+			return
+		}
+	}
+
+	// the urgency is given with every task listed, whatever the sort
+	for _, sort := range []string{"urgency", "title"} {
+		resp, err := http.Get("http://localhost:8008/test?owner=123&sort=" + sort)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		by, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		tasks, err := readTasks(by)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		var titles []string
+		var urgency float64
+		for _, task := range tasks {
+			titles = append(titles, task.Title)
+			if task.Title == "high" {
+				urgency = task.Urgency
+			}
+		}
+		want := "[Test Task high overdue]"
+		if sort == "urgency" {
+			want = "[overdue high Test Task]"
+		}
+		if fmt.Sprint(titles) != want || urgency < model.DefaultUrgencyWeights.Priority {
+			t.Errorf("Expected tasks sorted by %s to be %s with their urgency, found %s, high at %v", sort, want, titles,
+				urgency)
+		}
+	}
+
+	tests := []struct {
+		method string
+		query  string
+		body   string
+	}{
+		{http.MethodPost, "", `{"owner": 123, "title": "bad", "priority": 4}`},
+		{http.MethodGet, "&sort=-urgency", ""},
+		{http.MethodGet, "&sort=urgency,title", ""},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, "http://localhost:8008/test?owner=123"+tt.query, strings.NewReader(tt.body))
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected %s %q %s to give %d, found %d", tt.method, tt.query, tt.body, http.StatusBadRequest,
+				resp.StatusCode)
+		}
+	}
+}
+
+func TestTaskControllerTasksIfMatch(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:8008/test?owner=%d&taskId=%s", testOwnerId, testTaskId))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `"1"` {
+		t.Errorf("Expected task with ETag \"1\", found %s with ETag %s", resp.Status, resp.Header.Get("ETag"))
+		return
+	}
+	var task model.Task
+	if err := json.Unmarshal(by, &task); nil != err {
+		t.Error(err)
+		return
+	}
+
+	// a change based on the current version succeeds, based on an old one fails
+	for _, tt := range []struct {
+		method  string
+		ifMatch string
+		status  int
+		etag    string
+	}{
+		{http.MethodPut, `"1"`, http.StatusOK, `"2"`},
+		{http.MethodPut, `"1"`, http.StatusPreconditionFailed, ""},
+		{http.MethodPut, `W/"2"`, http.StatusOK, `"3"`},
+		{http.MethodPut, `madeup`, http.StatusPreconditionFailed, ""},
+		{http.MethodDelete, `"2"`, http.StatusPreconditionFailed, ""},
+		{http.MethodDelete, `"3"`, http.StatusOK, ""},
+	} {
+		by, err := json.Marshal(&task)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		req, err := http.NewRequest(tt.method,
+			fmt.Sprintf("http://localhost:8008/test?owner=%d&taskId=%s", testOwnerId, testTaskId), bytes.NewReader(by))
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		req.Header.Set("If-Match", tt.ifMatch)
+		resp, err := http.DefaultClient.Do(req)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || resp.Header.Get("ETag") != tt.etag {
+			t.Errorf("Expected %s with If-Match %s to give %d with ETag %q, found %d with ETag %q",
+				tt.method, tt.ifMatch, tt.status, tt.etag, resp.StatusCode, resp.Header.Get("ETag"))
+			return
+		}
+	}
+}
+
+func TestTaskControllerTrash(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	req, err := http.NewRequest(http.MethodDelete,
+		fmt.Sprintf("http://localhost:8008/test?owner=%d&taskId=%s", testOwnerId, testTaskId), nil)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected delete to succeed, found %s", resp.Status)
+		return
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:8008/testtrash?owner=%d", testOwnerId))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	tasks, err := readTasks(by)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(tasks) != 1 || tasks[0].Id() != testTaskId {
+		t.Errorf("Expected deleted task %s in the trash, found %d tasks", testTaskId, len(tasks))
+		return
+	}
+
+	// restore it, a second restore finds nothing to restore
+	for _, status := range []int{http.StatusOK, http.StatusNotFound} {
+		resp, err = http.Post(fmt.Sprintf("http://localhost:8008/testtrash/restore?owner=%d&taskId=%s",
+			testOwnerId, testTaskId), "application/json", nil)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("Expected restore response %s, found %s", http.StatusText(status), resp.Status)
+			return
+		}
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:8008/test?owner=%d", testOwnerId))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if tasks, err = readTasks(by); nil != err || len(tasks) != 1 {
+		t.Errorf("Expected restored task %s to be listed, found %d tasks", testTaskId, len(tasks))
+		return
+	}
+}
+
+func TestTaskControllerArchive(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	expired := model.Task{Owner: testOwnerId, Title: "Expired Task", Expires: time.Now().Add(-time.Hour)}
+	id, err := testStore.AddTask(context.Background(), testOwnerId, expired)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if n, err := testStore.ArchiveTasks(context.Background(), time.Now()); nil != err || n != 1 {
+		t.Errorf("Expected task %s to be archived, found %d, %v", id, n, err)
+		return
+	}
+
+	// listed in the archive when it matches the query, and nowhere else
+	tests := []struct {
+		query string
+		count int
+	}{
+		{"", 1},
+		{`{"title": "Expired Task"}`, 1},
+		{`{"title": "Test Task"}`, 0},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet,
+			fmt.Sprintf("http://localhost:8008/testarchive?owner=%d", testOwnerId), strings.NewReader(tt.query))
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		by, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		tasks, err := readTasks(by)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		if len(tasks) != tt.count || (tt.count > 0 && tasks[0].Id() != id) {
+			t.Errorf("Expected archive query %q to find %d tasks, found %d", tt.query, tt.count, len(tasks))
+			return
+		}
+	}
+	resp, err := http.Get(fmt.Sprintf("http://localhost:8008/test?owner=%d", testOwnerId))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if tasks, err := readTasks(by); nil != err || len(tasks) != 1 || tasks[0].Id() != testTaskId {
+		t.Errorf("Expected archived task %s not to be listed, found %d tasks", id, len(tasks))
+		return
+	}
+
+	// unarchive it, a second unarchive finds nothing to unarchive
+	for _, status := range []int{http.StatusOK, http.StatusNotFound} {
+		resp, err = http.Post(fmt.Sprintf("http://localhost:8008/testarchive/restore?owner=%d&taskId=%s",
+			testOwnerId, id), "application/json", nil)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("Expected unarchive response %s, found %s", http.StatusText(status), resp.Status)
+			return
+		}
+	}
+	if task := testStore.GetTask(context.Background(), id); nil == task || nil != task.Archived {
+		t.Errorf("Expected task %s to be unarchived", id)
+		return
+	}
+}
+
+func TestTaskControllerOccurrences(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	start := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	resp, err := http.Get(fmt.Sprintf("http://localhost:8008/testoccurrences?owner=%d&recurrence=%s&start=%s",
+		testOwnerId, url.QueryEscape("FREQ=DAILY;COUNT=3"), url.QueryEscape(start.Format(time.RFC3339))))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	var preview controllers.Occurrences
+	err = json.NewDecoder(resp.Body).Decode(&preview)
+	resp.Body.Close()
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(preview.Occurrences) != 3 || !preview.Occurrences[0].Equal(start) ||
+		!preview.Occurrences[2].Equal(start.AddDate(0, 0, 2)) || preview.Recurrence != "FREQ=DAILY;COUNT=3" {
+		t.Errorf("Expected 3 daily occurrences from %v, found %+v", start, preview)
+		return
+	}
+
+	// a recurring task is previewed from its expiry
+	task := model.Task{Owner: testOwnerId, Title: "Weekly", Expires: start, Recurrence: "FREQ=WEEKLY"}
+	id, err := testStore.AddTask(context.Background(), testOwnerId, task)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp, err = http.Get(fmt.Sprintf("http://localhost:8008/testoccurrences?owner=%d&taskId=%s&limit=2", testOwnerId, id))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	err = json.NewDecoder(resp.Body).Decode(&preview)
+	resp.Body.Close()
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(preview.Occurrences) != 2 || !preview.Occurrences[1].Equal(start.AddDate(0, 0, 7)) {
+		t.Errorf("Expected the task and the week after, found %+v", preview)
+		return
+	}
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"recurrence=" + url.QueryEscape("FREQ=SOMETIMES"), http.StatusBadRequest},
+		{"recurrence=FREQ=DAILY&start=tomorrow", http.StatusBadRequest},
+		{"taskId=" + testTaskId, http.StatusNotFound},
+		{"", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		resp, err = http.Get(fmt.Sprintf("http://localhost:8008/testoccurrences?owner=%d&%s", testOwnerId, tt.query))
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("Expected preview with %s to give %d, found %d", tt.query, tt.status, resp.StatusCode)
+		}
+	}
+
+	// tasks are only saved with a rule which can be read
+	resp, err = http.Post(fmt.Sprintf("http://localhost:8008/test?owner=%d", testOwnerId), "application/json",
+		strings.NewReader(`{"owner": 123, "title": "Bad", "expires": "2030-01-01T00:00:00Z", "recurrence": "FREQ=SOMETIMES"}`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a task with a bad recurrence to be rejected, found %s", resp.Status)
+	}
+}
+
+func TestTaskControllerComplete(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	openId, err := testStore.AddTask(context.Background(), testOwnerId, model.Task{Owner: testOwnerId, Title: "Open Task"})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+
+	// only completed at the version given
+	for _, tt := range []struct {
+		ifMatch string
+		status  int
+	}{
+		{`"2"`, http.StatusPreconditionFailed},
+		{`"1"`, http.StatusOK},
+	} {
+		req, err := http.NewRequest(http.MethodPost,
+			fmt.Sprintf("http://localhost:8008/testcomplete?owner=%d&taskId=%s", testOwnerId, testTaskId), nil)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		req.Header.Set("If-Match", tt.ifMatch)
+		resp, err := http.DefaultClient.Do(req)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		var completed controllers.Completed
+		if tt.status == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(&completed)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || nil != err {
+			t.Errorf("Expected complete with If-Match %s to give %d, found %d, %v", tt.ifMatch, tt.status, resp.StatusCode, err)
+			return
+		}
+		if tt.status == http.StatusOK && (nil == completed.Task || completed.Task.Status != model.StatusDone ||
+			nil == completed.Task.CompletedAt || resp.Header.Get("ETag") != `"2"` || completed.Next != "") {
+			t.Errorf("Expected task %s to be done at version 2, found %+v, ETag %s", testTaskId, completed.Task,
+				resp.Header.Get("ETag"))
+			return
+		}
+	}
+
+	// done tasks are only listed when asked for
+	for _, tt := range []struct {
+		query string
+		ids   []string
+	}{
+		{"", []string{openId}},
+		{"&status=done", []string{testTaskId}},
+		{"&status=open,%20done&sort=title", []string{openId, testTaskId}},
+		{"&status=all&sort=title", []string{openId, testTaskId}},
+	} {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:8008/test?owner=%d%s", testOwnerId, tt.query))
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		by, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		tasks, err := readTasks(by)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		var ids []string
+		for _, task := range tasks {
+			ids = append(ids, task.Id())
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tt.ids) {
+			t.Errorf("Expected listing with %q to give %v, found %v", tt.query, tt.ids, ids)
+		}
+	}
+
+	// a done task is reopened before it changes status
+	resp, err := http.Get(fmt.Sprintf("http://localhost:8008/test?owner=%d&status=unknown", testOwnerId))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected listing an unknown status to give %d, found %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:8008/test?owner=%d", testOwnerId),
+		strings.NewReader(`{"_id": "`+testTaskId+`", "owner": 123, "title": "Test Task", "status": "in-progress"}`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if resp, err = http.DefaultClient.Do(req); nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected putting a done task in progress to give %d, found %d", http.StatusConflict, resp.StatusCode)
+	}
+
+	// completing a recurring task adds its next occurrence straight away
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	id, err := testStore.AddTask(context.Background(), testOwnerId,
+		model.Task{Owner: testOwnerId, Title: "Daily", Expires: expires, Recurrence: "FREQ=DAILY"})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp, err = http.Post(fmt.Sprintf("http://localhost:8008/testcomplete?owner=%d&taskId=%s", testOwnerId, id),
+		"application/json", nil)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	var completed controllers.Completed
+	err = json.NewDecoder(resp.Body).Decode(&completed)
+	resp.Body.Close()
+	if nil != err || completed.Next == "" {
+		t.Errorf("Expected the next occurrence of task %s to be added, found %+v, %v", id, completed, err)
+		return
+	}
+	if next := testStore.GetTask(context.Background(), completed.Next); nil == next ||
+		!next.Expires.Equal(expires.AddDate(0, 0, 1)) || next.Status != model.StatusOpen {
+		t.Errorf("Expected the next occurrence %s to be open, due a day after %v, found %+v", completed.Next, expires, next)
+	}
+
+	for _, taskId := range []string{"5f0e0e0e0e0e0e0e0e0e0e0e", openId} {
+		resp, err = http.Post(fmt.Sprintf("http://localhost:8008/testcomplete?owner=666&taskId=%s", taskId),
+			"application/json", nil)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected completing task %s, not of owner 666, to give %d, found %d", taskId, http.StatusNotFound,
+				resp.StatusCode)
+		}
+	}
+}
+
+func TestTaskControllerSharing(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	// the test task is shared with 456 as an editor, and 789 as a reader
+	task := testStore.GetTask(context.Background(), testTaskId)
+	task.ACL = []model.Grant{{User: 456, Role: model.RoleEditor}, {User: 789, Role: model.RoleReader}}
+	if _, err := testStore.UpdateTask(context.Background(), testOwnerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+
+	for user, role := range map[int]string{456: model.RoleEditor, 789: model.RoleReader} {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:8008/testothers?owner=%d", user))
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		by, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		tasks, err := readTasks(by)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		if len(tasks) != 1 || tasks[0].Role != role {
+			t.Errorf("Expected task %s listed for %d as %s, found %d tasks", testTaskId, user, role, len(tasks))
+			return
+		}
+	}
+
+	// the editor may change the task, but the reader, and the editor changing who it is shared with, are forbidden
+	for _, tt := range []struct {
+		user   int
+		body   string
+		status int
+	}{
+		{789, `{"_id": "` + testTaskId + `", "owner": 123, "title": "read", "acl": [{"user": 456, "role": "editor"}, {"user": 789, "role": "reader"}]}`,
+			http.StatusForbidden},
+		{456, `{"_id": "` + testTaskId + `", "owner": 123, "title": "edited", "acl": [{"user": 456, "role": "admin"}, {"user": 789, "role": "reader"}]}`,
+			http.StatusForbidden},
+		{456, `{"_id": "` + testTaskId + `", "owner": 123, "title": "edited", "acl": [{"user": 456, "role": "editor"}, {"user": 789, "role": "reader"}]}`,
+			http.StatusOK},
+		{123, `{"_id": "` + testTaskId + `", "owner": 123, "title": "edited", "acl": [{"user": 123, "role": "reader"}]}`,
+			http.StatusUnprocessableEntity},
+	} {
+		req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("http://localhost:8008/test?owner=%d", tt.user),
+			strings.NewReader(tt.body))
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("Expected %d putting %s as %d, found %d", tt.status, tt.body, tt.user, resp.StatusCode)
+			return
+		}
+	}
+	if task := testStore.GetTask(context.Background(), testTaskId); nil == task || task.Title != "edited" {
+		t.Errorf("Expected the editors change to task %s, found %+v", testTaskId, task)
+	}
+}
+
+func TestTaskControllerSubtasks(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	// the test task is shared with owner 456, who can read its subtasks through it
+	task := testStore.GetTask(context.Background(), testTaskId)
+	task.ACL = model.ReaderGrants([]int{456})
+	if _, err := testStore.UpdateTask(context.Background(), testOwnerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+	var subtaskIds []string
+	for _, body := range []string{
+		`{"owner": 123, "title": "first", "parent": "` + testTaskId + `"}`,
+		`{"owner": 123, "title": "second", "parent": "` + testTaskId + `", "status": "done"}`,
+	} {
+		resp, err := http.Post("http://localhost:8008/test?owner=123", "application/json", strings.NewReader(body))
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		by, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("Expected subtask %s to be created, found %s", body, resp.Status)
+			return
+		}
+		subtaskIds = append(subtaskIds, string(by))
+	}
+	resp, err := http.Post("http://localhost:8008/test?owner=456", "application/json",
+		strings.NewReader(`{"owner": 456, "title": "not mine", "parent": "`+testTaskId+`"}`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a subtask of someone elses task to be refused, found %s", resp.Status)
+	}
+
+	for _, owner := range []int{testOwnerId, 456} {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:8008/testsubtasks?owner=%d&taskId=%s&status=all&sort=title",
+			owner, testTaskId))
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		by, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		tasks, err := readTasks(by)
+		if nil != err || len(tasks) != 2 || tasks[0].Id() != subtaskIds[0] || tasks[1].Id() != subtaskIds[1] {
+			t.Errorf("Expected owner %d to list subtasks %v, found %d tasks, %v", owner, subtaskIds, len(tasks), err)
+			return
+		}
+
+		resp, err = http.Get(fmt.Sprintf("http://localhost:8008/test?owner=%d&taskId=%s", owner, subtaskIds[0]))
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected owner %d to read subtask %s, found %s", owner, subtaskIds[0], resp.Status)
+		}
+	}
+	resp, err = http.Get(fmt.Sprintf("http://localhost:8008/testsubtasks?owner=789&taskId=%s", testTaskId))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the subtasks to be hidden from owner 789, found %s", resp.Status)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:8008/testtree?owner=456&taskId=%s", testTaskId))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	var tree model.TaskTree
+	err = json.NewDecoder(resp.Body).Decode(&tree)
+	resp.Body.Close()
+	if nil != err || nil == tree.Task || len(tree.Subtasks) != 2 || nil == tree.Task.Progress || *tree.Task.Progress != 50 {
+		t.Errorf("Expected the tree of task %s with 2 subtasks, half done, found %+v, %v", testTaskId, tree, err)
+		return
+	}
+
+	// orphaned subtasks are kept when their parent is deleted
+	req, err := http.NewRequest(http.MethodDelete,
+		fmt.Sprintf("http://localhost:8008/test?owner=123&taskId=%s&subtasks=orphan", testTaskId), nil)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if resp, err = http.DefaultClient.Do(req); nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	for _, id := range subtaskIds {
+		if task := testStore.GetTask(context.Background(), id); nil == task || nil != task.Deleted || task.Parent != "" {
+			t.Errorf("Expected subtask %s to be orphaned, found %+v", id, task)
+		}
+	}
+}
+
+func TestTaskControllerDependencies(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	resp, err := http.Post("http://localhost:8008/test?owner=123", "application/json",
+		strings.NewReader(`{"owner": 123, "title": "blocked", "blockedBy": ["`+testTaskId+`"]}`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected a task blocked by %s to be created, found %s", testTaskId, resp.Status)
+		return
+	}
+	blockedId := string(by)
+
+	resp, err = http.Post("http://localhost:8008/test?owner=123", "application/json",
+		strings.NewReader(`{"owner": 123, "title": "unknown", "blockedBy": ["`+primitive.NewObjectID().Hex()+`"]}`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a task blocked by an unknown task to be refused, found %s", resp.Status)
+	}
+
+	// the test task can't then be blocked by the task it blocks
+	task := testStore.GetTask(context.Background(), testTaskId)
+	task.BlockedBy = []string{blockedId}
+	by, err = json.Marshal(task)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8008/test?owner=123", bytes.NewBuffer(by))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if resp, err = http.DefaultClient.Do(req); nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a dependency cycle to be refused, found %s", resp.Status)
+	}
+
+	resp, err = http.Get("http://localhost:8008/testdependencies?owner=123")
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	var graph model.DependencyGraph
+	err = json.NewDecoder(resp.Body).Decode(&graph)
+	resp.Body.Close()
+	if nil != err || len(graph.Tasks) != 2 || graph.Tasks[0].Id() != testTaskId || graph.Tasks[1].Id() != blockedId {
+		t.Errorf("Expected tasks %s then %s, found %+v, %v", testTaskId, blockedId, graph.Tasks, err)
+		return
+	}
+	if len(graph.Actionable) != 1 || graph.Actionable[0].Id() != testTaskId || len(graph.Dependencies) != 1 {
+		t.Errorf("Expected only task %s to be actionable, found %+v", testTaskId, graph)
+		return
+	}
+
+	// deleting the blocker leaves the blocked task actionable
+	req, err = http.NewRequest(http.MethodDelete, "http://localhost:8008/test?owner=123&taskId="+testTaskId, nil)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if resp, err = http.DefaultClient.Do(req); nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if blocked := testStore.GetTask(context.Background(), blockedId); nil == blocked || len(blocked.BlockedBy) != 0 {
+		t.Errorf("Expected task %s to no longer be blocked, found %+v", blockedId, blocked)
+	}
+}
+
+func TestListsControllerLists(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	resp, err := http.Post("http://localhost:8008/testlists?owner=123", "application/json",
+		strings.NewReader(`{"name": "project", "colour": "orange"}`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a list with an invalid colour to be refused, found %s", resp.Status)
+		return
+	}
+
+	resp, err = http.Post("http://localhost:8008/testlists?owner=123", "application/json",
+		strings.NewReader(`{"name": "project", "colour": "#ff8800"}`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected response %s, found %s: %s", http.StatusText(http.StatusCreated), resp.Status, by)
+		return
+	}
+	var list model.List
+	if err := json.Unmarshal(by, &list); nil != err || list.ID == "" || list.Owner != testOwnerId {
+		t.Errorf("Expected the new list of owner %d, found %+v, %v", testOwnerId, list, err)
+		return
+	}
+
+	resp, err = http.Post("http://localhost:8008/test?owner=123", "application/json",
+		strings.NewReader(`{"owner": 123, "title": "unknown list", "list": "madeup"}`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a task in an unknown list to be refused, found %s", resp.Status)
+		return
+	}
+	resp, err = http.Post("http://localhost:8008/test?owner=123", "application/json",
+		strings.NewReader(`{"owner": 123, "title": "in the list", "list": "`+list.ID+`"}`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected a task in list %s to be created, found %s: %s", list.ID, resp.Status, by)
+		return
+	}
+	taskId := string(by)
+
+	for listId, expected := range map[string]string{list.ID: taskId, model.DefaultList: testTaskId} {
+		resp, err = http.Get("http://localhost:8008/test?owner=123&list=" + listId)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		var tasks []*model.Task
+		err = json.NewDecoder(resp.Body).Decode(&tasks)
+		resp.Body.Close()
+		if nil != err || len(tasks) != 1 || tasks[0].Id() != expected {
+			t.Errorf("Expected only task %s in list %s, found %d tasks, %v", expected, listId, len(tasks), err)
+			return
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, "http://localhost:8008/testlists?owner=123&listId="+list.ID, nil)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if resp, err = http.DefaultClient.Do(req); nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected list %s holding a task not to be deleted, found %s", list.ID, resp.Status)
+		return
+	}
+
+	list.Name = "finished"
+	list.Archived = true
+	if by, err = json.Marshal(list); nil != err {
+		t.Error(err)
+		return
+	}
+	if req, err = http.NewRequest(http.MethodPut, "http://localhost:8008/testlists?owner=123", bytes.NewBuffer(by)); nil != err {
+		t.Error(err)
+		return
+	}
+	if resp, err = http.DefaultClient.Do(req); nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected list %s to be archived, found %s", list.ID, resp.Status)
+		return
+	}
+
+	for query, count := range map[string]int{"": 1, "&archived=true": 2} {
+		resp, err = http.Get("http://localhost:8008/testlists?owner=123" + query)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		var lists []model.List
+		err = json.NewDecoder(resp.Body).Decode(&lists)
+		resp.Body.Close()
+		if nil != err || len(lists) != count || lists[0].ID != model.DefaultList {
+			t.Errorf("Expected %d lists, the default list first, listing %q, found %+v, %v", count, query, lists, err)
+			return
+		}
+	}
+}
+
+func TestGroupsControllerGroups(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	resp, err := http.Post("http://localhost:8008/testgroups?owner=123", "application/json",
+		strings.NewReader(`{"name": "team"}`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected response %s, found %s: %s", http.StatusText(http.StatusCreated), resp.Status, by)
+		return
+	}
+	var group model.Group
+	if err := json.Unmarshal(by, &group); nil != err || group.ID == "" || group.Owner != testOwnerId {
+		t.Errorf("Expected the new group of owner %d, found %+v, %v", testOwnerId, group, err)
+		return
+	}
+
+	// only the owner may change its members
+	for _, tt := range []struct {
+		owner  int
+		status int
+	}{{456, http.StatusNotFound}, {123, http.StatusOK}} {
+		resp, err = http.Post(fmt.Sprintf("http://localhost:8008/testgroups/members?owner=%d&groupId=%s&member=456",
+			tt.owner, group.ID), "application/json", nil)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("Expected %d adding a member as %d, found %s", tt.status, tt.owner, resp.Status)
+			return
+		}
+	}
+
+	body := `{"_id": "` + testTaskId + `", "owner": 123, "title": "Test Task", "acl": [{"group": "madeup", "role": "reader"}]}`
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8008/test?owner=123", strings.NewReader(body))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if resp, err = http.DefaultClient.Do(req); nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a task shared with an unknown group to be refused, found %s", resp.Status)
+		return
+	}
+
+	// nor a group of someone else the owner isn't a member of
+	others := `{"owner": 789, "title": "Other Task", "acl": [{"group": "` + group.ID + `", "role": "reader"}]}`
+	if req, err = http.NewRequest(http.MethodPost, "http://localhost:8008/test?owner=789", strings.NewReader(others)); nil != err {
+		t.Error(err)
+		return
+	}
+	if resp, err = http.DefaultClient.Do(req); nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a task shared with a group of another owner to be refused, found %s", resp.Status)
+		return
+	}
+
+	body = strings.Replace(body, "madeup", group.ID, 1)
+	if req, err = http.NewRequest(http.MethodPut, "http://localhost:8008/test?owner=123", strings.NewReader(body)); nil != err {
+		t.Error(err)
+		return
+	}
+	if resp, err = http.DefaultClient.Do(req); nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the task to be shared with group %s, found %s", group.ID, resp.Status)
+		return
+	}
+
+	othersTasks := func() []*model.Task {
+		resp, err := http.Get("http://localhost:8008/testothers?owner=456")
+		if nil != err {
+			t.Error(err)
+			return nil
+		}
+		by, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		tasks, err := readTasks(by)
+		if nil != err {
+			t.Error(err)
+		}
+		return tasks
+	}
+	if tasks := othersTasks(); len(tasks) != 1 || tasks[0].ID.Hex() != testTaskId || tasks[0].Role != model.RoleReader {
+		t.Errorf("Expected task %s shared with the member as a reader, found %d tasks", testTaskId, len(tasks))
+		return
+	}
+
+	// removing the member stops them seeing the task, without changing it
+	if req, err = http.NewRequest(http.MethodDelete,
+		"http://localhost:8008/testgroups/members?owner=123&groupId="+group.ID+"&member=456", nil); nil != err {
+		t.Error(err)
+		return
+	}
+	if resp, err = http.DefaultClient.Do(req); nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the member to be removed, found %s", resp.Status)
+		return
+	}
+	if tasks := othersTasks(); len(tasks) != 0 {
+		t.Errorf("Expected no tasks shared with a removed member, found %d", len(tasks))
+	}
+}
+
+func TestAPIKeysControllerKeys(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	// do makes a request with the given api key, or as the test owner without one
+	do := func(method string, url string, key string, body string) (*http.Response, []byte) {
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		if nil != err {
+			t.Fatal(err)
+		}
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		} else {
+			req.URL.RawQuery += "&owner=123"
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if nil != err {
+			t.Fatal(err)
+		}
+		by, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if nil != err {
+			t.Fatal(err)
+		}
+		return resp, by
+	}
+
+	resp, _ := do(http.MethodPost, "http://localhost:8008/testapikeys?", "", `{"name": "ci", "scope": "superuser"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a key with an unknown scope to be refused, found %s", resp.Status)
+		return
+	}
+	keys := map[string]model.APIKey{}
+	for _, scope := range []string{model.ScopeRead, model.ScopeReadWrite} {
+		resp, by := do(http.MethodPost, "http://localhost:8008/testapikeys?", "",
+			`{"name": "ci", "scope": "`+scope+`"}`)
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("Expected response %s, found %s: %s", http.StatusText(http.StatusCreated), resp.Status, by)
+			return
+		}
+		var key model.APIKey
+		if err := json.Unmarshal(by, &key); nil != err || key.ID == "" || key.Owner != testOwnerId ||
+			!strings.HasPrefix(key.Key, key.Prefix) {
+			t.Errorf("Expected the new %s key of owner %d, found %+v, %v", scope, testOwnerId, key, err)
+			return
+		}
+		keys[scope] = key
+	}
+	readKey, writeKey := keys[model.ScopeRead], keys[model.ScopeReadWrite]
+
+	// the read key may only read, and neither may manage keys
+	for _, tt := range []struct {
+		method string
+		url    string
+		key    string
+		status int
+	}{
+		{http.MethodGet, "http://localhost:8008/test?taskId=" + testTaskId, readKey.Key, http.StatusOK},
+		{http.MethodDelete, "http://localhost:8008/test?taskId=" + testTaskId, readKey.Key, http.StatusForbidden},
+		{http.MethodGet, "http://localhost:8008/testapikeys?", writeKey.Key, http.StatusForbidden},
+		{http.MethodGet, "http://localhost:8008/test?taskId=" + testTaskId, writeKey.Key + "x", http.StatusUnauthorized},
+		{http.MethodPost, "http://localhost:8008/testcomplete?taskId=" + testTaskId, writeKey.Key, http.StatusOK},
+	} {
+		if resp, by := do(tt.method, tt.url, tt.key, ""); resp.StatusCode != tt.status {
+			t.Errorf("Expected %s %s to respond %d, found %s: %s", tt.method, tt.url, tt.status, resp.Status, by)
+			return
+		}
+	}
+
+	resp, by := do(http.MethodGet, "http://localhost:8008/testapikeys?", "", "")
+	var listed []model.APIKey
+	if err := json.Unmarshal(by, &listed); nil != err || len(listed) != 2 {
+		t.Errorf("Expected the 2 keys of owner %d, found %s, %v", testOwnerId, by, err)
+		return
+	}
+	for _, key := range listed {
+		if key.Key != "" || nil == key.LastUsed {
+			t.Errorf("Expected key %s listed with when it was last used, without the key itself, found %+v", key.ID, key)
+			return
+		}
+	}
+
+	if resp, _ = do(http.MethodDelete, "http://localhost:8008/testapikeys?keyId="+readKey.ID, "", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected key %s to be revoked, found %s", readKey.ID, resp.Status)
+		return
+	}
+	if resp, _ = do(http.MethodGet, "http://localhost:8008/test?taskId="+testTaskId, readKey.Key, ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected the revoked key to be refused, found %s", resp.Status)
+	}
+}
+
+func TestHistoryControllerHistory(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	task, err := createTestTask([]byte(`{"owner": 123, "_id": "` + testTaskId + `", "title": "changed", "readers": [456]}`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err := json.Marshal(&task)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPut,
+		fmt.Sprintf("http://localhost:8008/test?owner=%d", testOwnerId), bytes.NewReader(by))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+
+	// readers may see the history too
+	resp, err = http.Get(fmt.Sprintf("http://localhost:8008/testhistory?owner=456&taskId=%s", testTaskId))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	var revs []*model.Revision
+	if err := json.Unmarshal(by, &revs); nil != err {
+		t.Error(err)
+		return
+	}
+	if len(revs) != 2 || revs[0].Action != model.RevisionCreate || revs[1].Action != model.RevisionUpdate {
+		t.Errorf("Expected the create and update revisions of task %s, found %d revisions", testTaskId, len(revs))
+		return
+	}
+
+	// as may readers of the task a subtask is of
+	subtaskId, err := testStore.AddTask(context.Background(), testOwnerId,
+		model.Task{Owner: testOwnerId, Title: "Subtask", Parent: testTaskId})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp, err = http.Get(fmt.Sprintf("http://localhost:8008/testhistory?owner=456&taskId=%s", subtaskId))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the reader of its parent to see the history of subtask %s, found %s", subtaskId, resp.Status)
+		return
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:8008/testhistory?owner=%d&taskId=%s&from=1&to=2",
+		testOwnerId, testTaskId))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	var diff struct {
+		Changes []model.FieldChange `json:"changes"`
+	}
+	if err := json.Unmarshal(by, &diff); nil != err {
+		t.Error(err)
+		return
+	}
+	changed := map[string]bool{}
+	for _, change := range diff.Changes {
+		changed[change.Field] = true
+	}
+	if !changed["acl"] || !changed["title"] || !changed["version"] || changed["owner"] {
+		t.Errorf("Expected acl, title and version to change, found %v", diff.Changes)
+		return
+	}
+
+	// anyone else may not
+	for _, tt := range []struct {
+		params string
+		status int
+	}{
+		{"owner=666&taskId=" + testTaskId, http.StatusForbidden},
+		{"owner=123&taskId=" + testTaskId + "&from=1&to=9", http.StatusBadRequest},
+		{"owner=123", http.StatusBadRequest},
+		{"owner=123&taskId=madeup", http.StatusNotFound},
+	} {
+		resp, err = http.Get("http://localhost:8008/testhistory?" + tt.params)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("Expected response %s for %s, found %s", http.StatusText(tt.status), tt.params, resp.Status)
+		}
+	}
+}
+
+func TestEventsControllerEvents(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:8008/testevents?owner=%d", testOwnerId))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected an event stream, found %s", resp.Header.Get("Content-Type"))
+		return
+	}
+
+	task, err := createTestTask([]byte(`{"owner": 123, "_id": "` + testTaskId + `", "title": "changed"}`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err := json.Marshal(&task)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPut,
+		fmt.Sprintf("http://localhost:8008/test?owner=%d", testOwnerId), bytes.NewReader(by))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	put, err := http.DefaultClient.Do(req)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	put.Body.Close()
+
+	// the task was created as event 1 before connecting, so its update is event 2
+	event := readEvent(bufio.NewReader(resp.Body))
+	if event["id"] != "2" || event["event"] != model.EventUpdated || !strings.Contains(event["data"], `"changed"`) {
+		t.Errorf("Expected update event 2 for task %s, found %v", testTaskId, event)
+		return
+	}
+
+	// reconnecting replays the events missed
+	req, err = http.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:8008/testevents?owner=%d", testOwnerId), nil)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	req.Header.Set("Last-Event-ID", "1")
+	replay, err := http.DefaultClient.Do(req)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	defer replay.Body.Close()
+	event = readEvent(bufio.NewReader(replay.Body))
+	if event["id"] != "2" || event["event"] != model.EventUpdated {
+		t.Errorf("Expected update event 2 to be replayed, found %v", event)
+		return
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:8008/testevents?owner=%d&lastEventId=nope", testOwnerId))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected response %s for a bad last event id, found %s",
+			http.StatusText(http.StatusBadRequest), resp.Status)
+	}
+}
+
+func TestEventsControllerSubtasks(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	parentId, err := testStore.AddTask(context.Background(), testOwnerId,
+		model.Task{Owner: testOwnerId, Title: "Parent", ACL: model.ReaderGrants([]int{456})})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp, err := http.Get("http://localhost:8008/testevents?owner=456")
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	// readers of the parent are sent the changes to its subtasks
+	subtaskId, err := testStore.AddTask(context.Background(), testOwnerId,
+		model.Task{Owner: testOwnerId, Title: "Subtask", Parent: parentId})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	event := readEvent(bufio.NewReader(resp.Body))
+	if event["event"] != model.EventCreated || !strings.Contains(event["data"], subtaskId) {
+		t.Errorf("Expected the created event of subtask %s, found %v", subtaskId, event)
+	}
+}
+
+func TestWebhooksControllerWebhooks(t *testing.T) {
+	initControllerTest()
+	defer endTest()
+
+	resp, err := http.Post(fmt.Sprintf("http://localhost:8008/testwebhooks?owner=%d", testOwnerId), "application/json",
+		bytes.NewReader([]byte(`{"url": "ftp://localhost/hook"}`)))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected response %s for a webhook to an ftp url, found %s",
+			http.StatusText(http.StatusBadRequest), resp.Status)
+		return
+	}
+
+	// only localhost is allowed although internal
+	resp, err = http.Post(fmt.Sprintf("http://localhost:8008/testwebhooks?owner=%d", testOwnerId), "application/json",
+		bytes.NewReader([]byte(`{"url": "http://169.254.169.254/latest/meta-data"}`)))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected response %s for a webhook to an internal address, found %s",
+			http.StatusText(http.StatusBadRequest), resp.Status)
+		return
+	}
+
+	resp, err = http.Post(fmt.Sprintf("http://localhost:8008/testwebhooks?owner=%d", testOwnerId), "application/json",
+		bytes.NewReader([]byte(`{"url": "http://localhost:9999/hook", "events": ["updated"]}`)))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected response %s, found %s: %s", http.StatusText(http.StatusCreated), resp.Status, by)
+		return
+	}
+	var hook model.Webhook
+	if err := json.Unmarshal(by, &hook); nil != err {
+		t.Error(err)
+		return
+	}
+	if hook.ID == "" || hook.Owner != testOwnerId || hook.Secret == "" {
+		t.Errorf("Expected the new webhook with a generated secret, found %+v", hook)
+		return
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:8008/testwebhooks?owner=%d", testOwnerId))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	var hooks []model.Webhook
+	if err := json.Unmarshal(by, &hooks); nil != err {
+		t.Error(err)
+		return
+	}
+	if len(hooks) != 1 || hooks[0].ID != hook.ID || hooks[0].Secret != "" {
+		t.Errorf("Expected the webhook to be listed without its secret, found %s", by)
+		return
+	}
+
+	// changing the task queues a delivery
+	task, err := createTestTask([]byte(`{"owner": 123, "_id": "` + testTaskId + `", "title": "changed"}`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err = json.Marshal(&task)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPut,
+		fmt.Sprintf("http://localhost:8008/test?owner=%d", testOwnerId), bytes.NewReader(by))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	put, err := http.DefaultClient.Do(req)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	put.Body.Close()
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:8008/testwebhooks/deliveries?owner=%d&webhookId=%s",
+		testOwnerId, hook.ID))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	var deliveries []model.Delivery
+	if err := json.Unmarshal(by, &deliveries); nil != err {
+		t.Error(err)
+		return
+	}
+	if len(deliveries) != 1 || deliveries[0].Event != model.EventUpdated || deliveries[0].Status != model.DeliveryPending {
+		t.Errorf("Expected a pending delivery of the update, found %s", by)
+		return
+	}
+
+	for _, tt := range []struct {
+		owner  int
+		status int
+	}{
+		{666, http.StatusNotFound},
+		{testOwnerId, http.StatusOK},
+		{testOwnerId, http.StatusNotFound},
+	} {
+		req, err := http.NewRequest(http.MethodDelete,
+			fmt.Sprintf("http://localhost:8008/testwebhooks?owner=%d&webhookId=%s", tt.owner, hook.ID), nil)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("Expected response %s deleting the webhook as owner %d, found %s",
+				http.StatusText(tt.status), tt.owner, resp.Status)
+			return
+		}
+	}
+}
+
+// readEvent reads the fields of the next event in a Server-Sent Events stream.
+func readEvent(r *bufio.Reader) map[string]string {
+	event := map[string]string{}
+	for {
+		line, err := r.ReadString('\n')
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			if len(event) > 0 || nil != err {
+				return event
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		if i := strings.Index(line, ": "); i > 0 {
+			event[line[:i]] = line[i+2:]
+		}
+	}
+}
+
+// readTasks reads the tasks from a page of tasks in the response body
+func readTasks(by []byte) ([]*model.Task, error) {
+	var tasks []*model.Task
+	if err := json.Unmarshal(by, &tasks); nil != err {
+		return nil, err
+	}
+	return tasks, nil
+}
+
 func createTestTask(by []byte) (*model.Task, error) {
 	var task model.Task
 	if err := json.Unmarshal(by, &task); nil != err {
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"gatso/data"
+	"net/http"
+	"time"
+)
+
+// Dependencies retrieves the dependency graph of the owners tasks which are neither done nor cancelled,
+// with the tasks sorted so each follows the tasks blocking it, and those which can be worked on now.
+func (c TaskController) Dependencies(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	graph, err := data.GetDependencyGraph(r.Context(), c.data, ownerId)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	for _, task := range graph.Tasks {
+		task.Urgency = c.urgency.Urgency(task, now)
+	}
+	writeJSON(w, http.StatusOK, graph)
+}
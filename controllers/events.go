@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gatso/data"
+	"gatso/model"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const headerLastEventID = "Last-Event-ID"
+const paramLastEventID = "lastEventId"
+
+// eventReset tells a client it has missed changes which can't be replayed, and should fetch its tasks again.
+const eventReset = "reset"
+
+// keepAliveInterval is how often an idle stream is written to, so proxies don't close it.
+const keepAliveInterval = 30 * time.Second
+
+type EventsController struct {
+	bus    *data.EventBus
+	data   data.Datastore
+	groups data.GroupStore
+}
+
+// NewEventsController creates the controller streaming the events of the bus, of tasks of the datastore shared with
+// the users and the groups of the group store.
+func NewEventsController(bus *data.EventBus, ds data.Datastore, groups data.GroupStore) *EventsController {
+	return &EventsController{bus: bus, data: ds, groups: groups}
+}
+
+// Events streams the changes to tasks the owner owns or reads, directly or through a group, or through one of the
+// tasks it is a subtask of, as Server-Sent Events.
+// A client reconnecting with the Last-Event-ID header, or the lastEventId parameter, first receives the changes it
+// missed. If they are no longer available it receives a reset event instead.
+func (c EventsController) Events(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	lastId, err := getLastEventId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sub, missed, complete := c.bus.Subscribe(lastId)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if !complete {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", eventReset)
+	}
+	for _, event := range missed {
+		if err := c.writeEvent(r.Context(), w, ownerId, event); nil != err {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); nil != err {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok { // fell behind, the client reconnects from its last event
+				return
+			}
+			if err := c.writeEvent(r.Context(), w, ownerId, event); nil != err {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// getLastEventId gets the id of the last event the client received, 0 if it hasn't received any.
+func getLastEventId(r *http.Request) (uint64, error) {
+	s := r.Header.Get(headerLastEventID)
+	if s == "" {
+		s = r.URL.Query().Get(paramLastEventID)
+	}
+	if s == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(s, 10, 64)
+	if nil != err {
+		return 0, fmt.Errorf("Invalid last event id %q", s)
+	}
+	return id, nil
+}
+
+// writeEvent writes the event to the stream, if the owner may see it.
+// The groups they are a member of, and the tasks it is a subtask of, are looked up for each event only shared with
+// them through those, so changes to them take effect straight away.
+func (c EventsController) writeEvent(ctx context.Context, w http.ResponseWriter, ownerId int, event model.Event) error {
+	role, err := data.TreeRoleOf(ctx, c.data, c.groups, &event.Task, ownerId)
+	if nil != err {
+		return err
+	}
+	if role == "" {
+		return nil
+	}
+	by, err := json.Marshal(&event.Task)
+	if nil != err {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, by)
+	return err
+}
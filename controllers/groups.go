@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"gatso/data"
+	"gatso/model"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const paramGroupId = "groupId"
+const paramMember = "member"
+
+const maxGroupName = 200 // characters
+
+type GroupsController struct {
+	groups data.GroupStore
+}
+
+// NewGroupsController creates the controller of the groups in the group store.
+func NewGroupsController(groups data.GroupStore) *GroupsController {
+	return &GroupsController{groups: groups}
+}
+
+// Groups retrieves, creates, renames or removes the groups of the owner, depending on the method
+func (c GroupsController) Groups(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Get(paramGroupId) != "" {
+			c.getGroup(ownerId, w, r)
+		} else {
+			c.listGroups(ownerId, w, r)
+		}
+	case http.MethodPost:
+		c.createGroup(ownerId, w, r)
+	case http.MethodPut:
+		c.updateGroup(ownerId, w, r)
+	case http.MethodDelete:
+		c.deleteGroup(ownerId, w, r)
+	default:
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+	}
+}
+
+// Members adds the user given by the member parameter to the owners group given by the groupid parameter,
+// or removes them from it, depending on the method. The change applies to the tasks shared with the group straight away.
+func (c GroupsController) Members(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	groupId := r.URL.Query().Get(paramGroupId)
+	if groupId == "" {
+		http.Error(w, fmt.Sprintf("Missing %s parameter", paramGroupId), http.StatusBadRequest)
+		return
+	}
+	member, err := strconv.Atoi(r.URL.Query().Get(paramMember))
+	if nil != err {
+		http.Error(w, fmt.Sprintf("Failed to read parameter %s as a user ID", paramMember), http.StatusBadRequest)
+		return
+	}
+
+	var changed bool
+	switch r.Method {
+	case http.MethodPost:
+		changed, err = c.groups.AddMember(r.Context(), ownerId, groupId, member)
+	case http.MethodDelete:
+		changed, err = c.groups.RemoveMember(r.Context(), ownerId, groupId, member)
+	default:
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !changed {
+		http.Error(w, fmt.Sprintf("group %s not known", groupId), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// listGroups retrieves the groups of the owner, oldest first,
+// or the groups they are a member of, when the member parameter is true.
+func (c GroupsController) listGroups(ownerId int, w http.ResponseWriter, r *http.Request) {
+	list := c.groups.Groups
+	if r.URL.Query().Get(paramMember) == "true" {
+		list = c.groups.MemberOf
+	}
+	groups, err := list(r.Context(), ownerId)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if nil == groups {
+		groups = []*model.Group{}
+	}
+	writeJSON(w, http.StatusOK, groups)
+}
+
+// getGroup retrieves the group given by the groupid parameter, if the owner owns it or is one of its members.
+func (c GroupsController) getGroup(ownerId int, w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get(paramGroupId)
+	group, err := c.groups.GetGroup(r.Context(), id)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if nil == group || (group.Owner != ownerId && !group.HasMember(ownerId)) {
+		http.Error(w, fmt.Sprintf("group %s not known", id), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, group)
+}
+
+// createGroup adds the group given in the request body, with any members it has, under the owners id,
+// returning it with its new id.
+func (c GroupsController) createGroup(ownerId int, w http.ResponseWriter, r *http.Request) {
+	group, err := readGroup(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err := validateGroup(group); nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	group.Owner = ownerId
+	group.Created = time.Now()
+
+	group.ID, err = c.groups.AddGroup(r.Context(), *group)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, group)
+}
+
+// updateGroup renames the owners group with the id of the group given in the request body.
+// Its members are changed through Members.
+func (c GroupsController) updateGroup(ownerId int, w http.ResponseWriter, r *http.Request) {
+	group, err := readGroup(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if group.ID == "" {
+		http.Error(w, "Missing id of the group to update", http.StatusBadRequest)
+		return
+	}
+	if err := validateGroup(group); nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updated, err := c.groups.UpdateGroup(r.Context(), ownerId, *group)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !updated {
+		http.Error(w, fmt.Sprintf("group %s not known", group.ID), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// deleteGroup removes the group given by the groupid parameter, if the owner owns it.
+// Tasks shared with it are no longer shared with its members.
+func (c GroupsController) deleteGroup(ownerId int, w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get(paramGroupId)
+	deleted, err := c.groups.DeleteGroup(r.Context(), ownerId, id)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, fmt.Sprintf("group %s not known", id), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func readGroup(r *http.Request) (*model.Group, error) {
+	by, err := ioutil.ReadAll(r.Body)
+	if nil != err {
+		return nil, err
+	}
+	var group model.Group
+	if err := json.Unmarshal(by, &group); nil != err {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func validateGroup(group *model.Group) error {
+	if group.Name == "" {
+		return fmt.Errorf("A group must have a name")
+	}
+	if len([]rune(group.Name)) > maxGroupName {
+		return fmt.Errorf("Group name is longer than %d characters", maxGroupName)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"gatso/data"
+	"gatso/model"
+	"net/http"
+	"strconv"
+)
+
+const paramFrom = "from"
+const paramTo = "to"
+
+type HistoryController struct {
+	history data.HistoryStore
+	data    data.Datastore
+	groups  data.GroupStore
+}
+
+// historyDiff is the response to a request for the changes between two revisions of a task.
+type historyDiff struct {
+	TaskID  string              `json:"taskId"`
+	From    int                 `json:"from"`
+	To      int                 `json:"to"`
+	Changes []model.FieldChange `json:"changes"`
+}
+
+// NewHistoryController creates the controller of the history of the tasks of the datastore, shared with the groups
+// of the group store.
+func NewHistoryController(history data.HistoryStore, ds data.Datastore, groups data.GroupStore) *HistoryController {
+	return &HistoryController{history: history, data: ds, groups: groups}
+}
+
+// History retrieves the revisions of the task given by the taskid parameter.
+// With the from and to parameters, it retrieves the fields changed between those two revisions instead.
+// Only the owner of the task, or a user it is shared with, directly or through a group, or through one of the tasks
+// it is a subtask of, may see its history.
+func (c HistoryController) History(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	taskId := r.URL.Query().Get(paramTaskId)
+	if taskId == "" {
+		http.Error(w, fmt.Sprintf("Missing %s parameter", paramTaskId), http.StatusBadRequest)
+		return
+	}
+
+	revs, err := c.history.Revisions(r.Context(), taskId)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(revs) == 0 {
+		http.Error(w, fmt.Sprintf("task %s has no history", taskId), http.StatusNotFound)
+		return
+	}
+	// access is granted by the task as it is now
+	latest := revs[len(revs)-1].Task
+	role, err := data.TreeRoleOf(r.Context(), c.data, c.groups, &latest, ownerId)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if role == "" {
+		http.Error(w, fmt.Sprintf("Owner %d can not see the history of task %s", ownerId, taskId), http.StatusForbidden)
+		return
+	}
+
+	q := r.URL.Query()
+	if q.Get(paramFrom) == "" && q.Get(paramTo) == "" {
+		c.write(w, revs)
+		return
+	}
+
+	from, err := findRevision(revs, q.Get(paramFrom))
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := findRevision(revs, q.Get(paramTo))
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	changes, err := model.Diff(from, to)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.write(w, historyDiff{TaskID: taskId, From: from.Number, To: to.Number, Changes: changes})
+}
+
+func (c HistoryController) write(w http.ResponseWriter, v interface{}) {
+	by, err := json.Marshal(v)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(by)
+}
+
+// findRevision gets the revision with the given number
+func findRevision(revs []*model.Revision, number string) (*model.Revision, error) {
+	n, err := strconv.Atoi(number)
+	if nil != err {
+		return nil, fmt.Errorf("Failed to read revision number %q", number)
+	}
+	for _, rev := range revs {
+		if rev.Number == n {
+			return rev, nil
+		}
+	}
+	return nil, fmt.Errorf("revision %d not known", n)
+}
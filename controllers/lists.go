@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gatso/data"
+	"gatso/model"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const paramListId = "listId"
+const paramArchived = "archived"
+
+const maxListName = 200 // characters
+
+type ListsController struct {
+	lists data.ListStore
+	data  data.Datastore
+}
+
+// NewListsController creates the controller of the lists in the list store, holding the tasks in the datastore.
+func NewListsController(lists data.ListStore, data data.Datastore) *ListsController {
+	return &ListsController{lists: lists, data: data}
+}
+
+// Lists retrieves, creates, updates or removes the lists of the owner, depending on the method
+func (c ListsController) Lists(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Get(paramListId) != "" {
+			c.getList(ownerId, w, r)
+		} else {
+			c.listLists(ownerId, w, r)
+		}
+	case http.MethodPost:
+		c.createList(ownerId, w, r)
+	case http.MethodPut:
+		c.updateList(ownerId, w, r)
+	case http.MethodDelete:
+		c.deleteList(ownerId, w, r)
+	default:
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+	}
+}
+
+// listLists retrieves the lists of the owner, the default list first, then the rest oldest first.
+// Archived lists are left out, unless the archived parameter is true.
+func (c ListsController) listLists(ownerId int, w http.ResponseWriter, r *http.Request) {
+	lists, err := c.lists.Lists(r.Context(), ownerId)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	withArchived := r.URL.Query().Get(paramArchived) == "true"
+	defaultList := model.OwnersDefaultList(ownerId)
+	listed := []*model.List{&defaultList}
+	for _, list := range lists {
+		if withArchived || !list.Archived {
+			listed = append(listed, list)
+		}
+	}
+	writeJSON(w, http.StatusOK, listed)
+}
+
+// getList retrieves the list given by the listid parameter, if the owner owns it.
+func (c ListsController) getList(ownerId int, w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get(paramListId)
+	if id == model.DefaultList {
+		writeJSON(w, http.StatusOK, model.OwnersDefaultList(ownerId))
+		return
+	}
+	list, err := c.lists.GetList(r.Context(), id)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if nil == list || list.Owner != ownerId {
+		http.Error(w, fmt.Sprintf("list %s not known", id), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// createList adds the list given in the request body, under the owners id, returning it with its new id.
+func (c ListsController) createList(ownerId int, w http.ResponseWriter, r *http.Request) {
+	list, err := readList(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err := validateList(list); nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	list.Owner = ownerId
+	list.Created = time.Now()
+
+	list.ID, err = c.lists.AddList(r.Context(), *list)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, list)
+}
+
+// updateList replaces the name, description, colour and archived flag of the owners list with the id of the list
+// given in the request body. The default list can't be changed.
+func (c ListsController) updateList(ownerId int, w http.ResponseWriter, r *http.Request) {
+	list, err := readList(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if list.ID == "" {
+		http.Error(w, "Missing id of the list to update", http.StatusBadRequest)
+		return
+	}
+	if list.ID == model.DefaultList {
+		http.Error(w, "The default list can't be changed", http.StatusBadRequest)
+		return
+	}
+	if err := validateList(list); nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updated, err := c.lists.UpdateList(r.Context(), ownerId, *list)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !updated {
+		http.Error(w, fmt.Sprintf("list %s not known", list.ID), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// deleteList removes the list given by the listid parameter, if the owner owns it.
+// Returns 409 Conflict while the list holds any tasks outside the trash. The default list can't be deleted.
+func (c ListsController) deleteList(ownerId int, w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get(paramListId)
+	if id == "" {
+		http.Error(w, fmt.Sprintf("Missing %s parameter", paramListId), http.StatusBadRequest)
+		return
+	}
+	if id == model.DefaultList {
+		http.Error(w, "The default list can't be deleted", http.StatusBadRequest)
+		return
+	}
+	deleted, err := data.DeleteList(r.Context(), c.data, c.lists, ownerId, id)
+	if errors.Is(err, data.ErrListNotEmpty) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, fmt.Sprintf("list %s not known", id), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func readList(r *http.Request) (*model.List, error) {
+	by, err := ioutil.ReadAll(r.Body)
+	if nil != err {
+		return nil, err
+	}
+	var list model.List
+	if err := json.Unmarshal(by, &list); nil != err {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func validateList(list *model.List) error {
+	if list.Name == "" {
+		return fmt.Errorf("A list must have a name")
+	}
+	if len([]rune(list.Name)) > maxListName {
+		return fmt.Errorf("List name is longer than %d characters", maxListName)
+	}
+	if !model.ValidColour(list.Colour) {
+		return fmt.Errorf("List colour %q must be given as #rrggbb", list.Colour)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"fmt"
+	"gatso/model"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const paramRecurrence = "recurrence"
+const paramStart = "start"
+const defaultOccurrenceCount = 10
+const maxOccurrenceCount = 100
+
+// Occurrences is the preview of when a recurring task is next due.
+type Occurrences struct {
+	Recurrence  string      `json:"recurrence"`
+	Occurrences []time.Time `json:"occurrences"`
+}
+
+// Occurrences previews the upcoming times the task given by the taskid parameter is due, as it recurs.
+// Instead of a task, a recurrence rule may be given, along with the start time of its series, which defaults to now.
+// Only the times after now are given.
+func (c TaskController) Occurrences(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	query := r.URL.Query()
+	task := model.Task{Recurrence: query.Get(paramRecurrence), Expires: now}
+	if taskId := query.Get(paramTaskId); taskId != "" {
+		found := c.data.GetTask(r.Context(), taskId)
+		readable, err := c.readable(r.Context(), found, ownerId)
+		if nil != err {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !readable {
+			http.Error(w, fmt.Sprintf("task %s not known", taskId), http.StatusNotFound)
+			return
+		}
+		task = *found
+	} else if task.Recurrence == "" {
+		http.Error(w, fmt.Sprintf("Missing %s or %s parameter", paramTaskId, paramRecurrence), http.StatusBadRequest)
+		return
+	} else if s := query.Get(paramStart); s != "" {
+		if task.Expires, err = time.Parse(time.RFC3339, s); nil != err {
+			http.Error(w, fmt.Sprintf("Failed to read parameter %s as an RFC 3339 time", paramStart), http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := defaultOccurrenceCount
+	if s := query.Get(paramLimit); s != "" {
+		limit, err = strconv.Atoi(s)
+		if nil != err || limit < 1 {
+			http.Error(w, fmt.Sprintf("Failed to read parameter %s as a positive number", paramLimit), http.StatusBadRequest)
+			return
+		}
+		if limit > maxOccurrenceCount {
+			limit = maxOccurrenceCount
+		}
+	}
+
+	rule, err := model.TaskRecurrence(&task)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if nil == rule {
+		http.Error(w, fmt.Sprintf("task %s does not recur", task.Id()), http.StatusNotFound)
+		return
+	}
+	occurrences := rule.Occurrences(task.Expires, now, limit)
+	if nil == occurrences {
+		occurrences = []time.Time{}
+	}
+	writeJSON(w, http.StatusOK, Occurrences{Recurrence: rule.String(), Occurrences: occurrences})
+}
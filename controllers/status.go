@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"gatso/data"
+	"gatso/model"
+	"net/http"
+	"time"
+)
+
+// Completed is the task marked done, along with the id of its next occurrence, if it recurs.
+type Completed struct {
+	Task *model.Task `json:"task"`
+	Next string      `json:"next,omitempty"`
+}
+
+// Complete marks the task given by the taskid parameter done, adding its next occurrence straight away if it recurs.
+// An If-Match header, with the ETag of the task, only completes the task if it hasn't changed since.
+func (c TaskController) Complete(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	version, ok := getIfMatch(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+		return
+	}
+
+	taskId := r.URL.Query().Get(paramTaskId)
+	if taskId == "" {
+		http.Error(w, fmt.Sprintf("Missing %s parameter", paramTaskId), http.StatusBadRequest)
+		return
+	}
+
+	version, err = c.data.SetTaskStatus(r.Context(), ownerId, taskId, model.StatusDone, version)
+	if err == data.ErrVersionConflict {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	if errors.Is(err, data.ErrStatusChange) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, data.ErrAccessDenied) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if version == 0 {
+		http.Error(w, fmt.Sprintf("task %s not known", taskId), http.StatusNotFound)
+		return
+	}
+
+	completed := Completed{Task: c.data.GetTask(r.Context(), taskId)}
+	if nil == completed.Task {
+		http.Error(w, fmt.Sprintf("task %s not known", taskId), http.StatusNotFound)
+		return
+	}
+	if completed.Task.Recurrence != "" {
+		completed.Next, err = data.AddNextOccurrence(r.Context(), c.data, *completed.Task, time.Now())
+		if nil != err {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set(headerETag, formatETag(completed.Task.Version))
+	writeJSON(w, http.StatusOK, &completed)
+}
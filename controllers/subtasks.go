@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"gatso/data"
+	"gatso/model"
+	"net/http"
+)
+
+const paramSubtasks = "subtasks"
+const subtasksOrphan = "orphan" // subtasks of a deleted task are left as top level tasks, rather than deleted with it
+
+// Subtasks retrieves a page of the subtasks of the task given by the taskid parameter,
+// if the owner owns it or has it shared with them, or one of the tasks it is a subtask of.
+func (c TaskController) Subtasks(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	taskId := r.URL.Query().Get(paramTaskId)
+	if taskId == "" {
+		http.Error(w, fmt.Sprintf("Missing %s parameter", paramTaskId), http.StatusBadRequest)
+		return
+	}
+	opts, err := c.getListOptions(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	readable, err := c.readable(r.Context(), c.data.GetTask(r.Context(), taskId), ownerId)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !readable {
+		http.Error(w, fmt.Sprintf("task %s not known", taskId), http.StatusNotFound)
+		return
+	}
+
+	page, err := c.data.GetSubtasks(r.Context(), taskId, opts)
+	if nil != err {
+		c.listError(w, err)
+		return
+	}
+	for _, task := range page.Tasks {
+		if err := c.setProgress(r.Context(), task); nil != err {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	c.writePage(w, r, page, opts)
+}
+
+// Tree retrieves the task given by the taskid parameter along with all its subtasks, each with the progress of its own,
+// if the owner owns it or has it shared with them, or one of the tasks it is a subtask of.
+func (c TaskController) Tree(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	taskId := r.URL.Query().Get(paramTaskId)
+	if taskId == "" {
+		http.Error(w, fmt.Sprintf("Missing %s parameter", paramTaskId), http.StatusBadRequest)
+		return
+	}
+	tree, err := data.GetTaskTree(r.Context(), c.data, taskId)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	readable := false
+	if nil != tree {
+		if readable, err = c.readable(r.Context(), tree.Task, ownerId); nil != err {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if !readable {
+		http.Error(w, fmt.Sprintf("task %s not known", taskId), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, tree)
+}
+
+// readable checks the task is outside the trash, and the owner owns it or has it shared with them, or with one of
+// their groups, or one of the tasks it is a subtask of. Subtasks are always owned by the owner of their parent,
+// and readable by everyone it is shared with.
+func (c TaskController) readable(ctx context.Context, task *model.Task, ownerId int) (bool, error) {
+	if nil == task || nil != task.Deleted {
+		return false, nil
+	}
+	role, err := data.TreeRoleOf(ctx, c.data, c.groups, task, ownerId)
+	return role != "", err
+}
+
+// setProgress sets the progress of the task through its subtasks, leaving it unset if it has none.
+func (c TaskController) setProgress(ctx context.Context, task *model.Task) error {
+	tree, err := data.GetTaskTree(ctx, c.data, task.Id())
+	if nil != err || nil == tree {
+		return err
+	}
+	task.Progress = tree.Task.Progress
+	return nil
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gatso/data"
+	"gatso/model"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const paramWebhookId = "webhookId"
+
+const defaultDeliveryCount = 50
+const maxDeliveryCount = 500
+
+// webhookEvents are the event types a webhook may subscribe to.
+var webhookEvents = []string{model.EventCreated, model.EventUpdated, model.EventDeleted, model.EventRestored}
+
+type WebhooksController struct {
+	webhooks data.WebhookStore
+	hosts    *data.WebhookHosts
+}
+
+// NewWebhooksController creates the controller of the webhooks in the store, to the hosts allowed by hosts.
+func NewWebhooksController(webhooks data.WebhookStore, hosts *data.WebhookHosts) *WebhooksController {
+	return &WebhooksController{webhooks: webhooks, hosts: hosts}
+}
+
+// Webhooks lists, creates or removes the webhooks of the owner, depending on the method
+func (c WebhooksController) Webhooks(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		c.listWebhooks(ownerId, w, r)
+	case http.MethodPost:
+		c.createWebhook(ownerId, w, r)
+	case http.MethodDelete:
+		c.deleteWebhook(ownerId, w, r)
+	default:
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+	}
+}
+
+// Deliveries lists the latest deliveries to the webhooks of the owner, newest first.
+// With the webhookid parameter, only those to that webhook are listed.
+func (c WebhooksController) Deliveries(w http.ResponseWriter, r *http.Request) {
+	ownerId, err := getOwnerId(r)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultDeliveryCount
+	if s := r.URL.Query().Get(paramLimit); s != "" {
+		limit, err = strconv.Atoi(s)
+		if nil != err || limit < 1 {
+			http.Error(w, fmt.Sprintf("Failed to read parameter %s as a positive number", paramLimit), http.StatusBadRequest)
+			return
+		}
+		if limit > maxDeliveryCount {
+			limit = maxDeliveryCount
+		}
+	}
+
+	deliveries, err := c.webhooks.Deliveries(r.Context(), ownerId, r.URL.Query().Get(paramWebhookId), limit)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if nil == deliveries {
+		deliveries = []*model.Delivery{}
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// listWebhooks retrieves the webhooks of the owner, without their secrets.
+func (c WebhooksController) listWebhooks(ownerId int, w http.ResponseWriter, r *http.Request) {
+	hooks, err := c.webhooks.Webhooks(r.Context(), ownerId)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if nil == hooks {
+		hooks = []*model.Webhook{}
+	}
+	for _, hook := range hooks {
+		hook.Secret = ""
+	}
+	writeJSON(w, http.StatusOK, hooks)
+}
+
+// createWebhook adds the webhook given in the request body, under the owners id.
+// The url must be an absolute http or https url, of a host outside the network the service runs in, unless allowed.
+// If no secret is given one is generated.
+// The webhook is returned, the only time its secret is given back.
+func (c WebhooksController) createWebhook(ownerId int, w http.ResponseWriter, r *http.Request) {
+	by, err := ioutil.ReadAll(r.Body)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	var hook model.Webhook
+	if err := json.Unmarshal(by, &hook); nil != err {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err := validateWebhook(&hook); nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := c.checkHost(r.Context(), &hook); nil != err {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if hook.Secret == "" {
+		if hook.Secret, err = newSecret(); nil != err {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	hook.Owner = ownerId
+	hook.Created = time.Now()
+
+	hook.ID, err = c.webhooks.AddWebhook(r.Context(), hook)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, &hook)
+}
+
+// deleteWebhook removes the webhook given by the webhookid parameter, if the owner owns it.
+func (c WebhooksController) deleteWebhook(ownerId int, w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get(paramWebhookId)
+	if id == "" {
+		http.Error(w, fmt.Sprintf("Missing %s parameter", paramWebhookId), http.StatusBadRequest)
+		return
+	}
+	deleted, err := c.webhooks.DeleteWebhook(r.Context(), ownerId, id)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, fmt.Sprintf("webhook %s not known", id), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func validateWebhook(hook *model.Webhook) error {
+	u, err := url.Parse(hook.URL)
+	if nil != err || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Webhook url %q must be an absolute http or https url", hook.URL)
+	}
+	for _, e := range hook.Events {
+		known := false
+		for _, k := range webhookEvents {
+			known = known || e == k
+		}
+		if !known {
+			return fmt.Errorf("Unknown webhook event %q, expected one of %v", e, webhookEvents)
+		}
+	}
+	return nil
+}
+
+// checkHost checks the host of the webhook url may be delivered to.
+func (c WebhooksController) checkHost(ctx context.Context, hook *model.Webhook) error {
+	u, err := url.Parse(hook.URL)
+	if nil != err {
+		return err
+	}
+	if err := c.hosts.CheckURL(ctx, u); nil != err {
+		return fmt.Errorf("Webhook url %q can't be delivered to: %v", hook.URL, err)
+	}
+	return nil
+}
+
+// newSecret generates a random secret to sign deliveries with.
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); nil != err {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// writeJSON writes the value as the json response, with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	by, err := json.Marshal(v)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(by)
+}
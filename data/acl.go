@@ -0,0 +1,126 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gatso/model"
+	"strings"
+	"time"
+)
+
+// ErrAccessDenied is returned when a user changing a task hasn't the role on it the change needs.
+var ErrAccessDenied = errors.New("access denied")
+
+// ErrInvalidACL is returned when a task is shared in an unknown role, with its owner, or with a user or group more than once.
+var ErrInvalidACL = errors.New("invalid access control list")
+
+// checkACL checks the grants of a task being saved each give a known role to a user other than its owner,
+// or the reader role to a group, and that it is shared with each user and group at most once.
+func checkACL(task *model.Task) error {
+	shared := map[int]bool{}
+	sharedGroups := map[string]bool{}
+	for _, g := range task.ACL {
+		if !model.ValidRole(g.Role) {
+			return fmt.Errorf("%w, unknown role %q, expected one of %s", ErrInvalidACL, g.Role,
+				strings.Join(model.Roles, ", "))
+		}
+		if g.Group != "" {
+			if g.User != 0 {
+				return fmt.Errorf("%w, a task can be shared with a user or a group, not both at once", ErrInvalidACL)
+			}
+			if g.Role != model.RoleReader {
+				return fmt.Errorf("%w, group %s can only be shared the task as a %s", ErrInvalidACL, g.Group, model.RoleReader)
+			}
+			if sharedGroups[g.Group] {
+				return fmt.Errorf("%w, the task is shared with group %s more than once", ErrInvalidACL, g.Group)
+			}
+			sharedGroups[g.Group] = true
+			continue
+		}
+		if g.User == task.Owner {
+			return fmt.Errorf("%w, a task can't be shared with its owner %d", ErrInvalidACL, g.User)
+		}
+		if shared[g.User] {
+			return fmt.Errorf("%w, the task is shared with %d more than once", ErrInvalidACL, g.User)
+		}
+		shared[g.User] = true
+	}
+	return nil
+}
+
+// neededRole gets the role a user needs on the stored task to replace it with the given task.
+// Editors may change the task, but only its owner and admins may change who it is shared with,
+// and only its owner may give it to someone else.
+func neededRole(existing *model.Task, task *model.Task) string {
+	if task.Owner != existing.Owner {
+		return model.RoleOwner
+	}
+	if !model.SameACL(task.ACL, existing.ACL) {
+		return model.RoleAdmin
+	}
+	return model.RoleEditor
+}
+
+// sharedAs checks the task is shared with the user or group of the query grant, in its role if it has one.
+func sharedAs(t *model.Task, query model.Grant) bool {
+	for _, g := range t.ACL {
+		if g.User == query.User && g.Group == query.Group && (query.Role == "" || g.Role == query.Role) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAccess checks the user now has the needed role on the stored task.
+func checkAccess(existing *model.Task, userId int, needed string) error {
+	if model.Allows(model.RoleOf(existing, userId, time.Now()), needed) {
+		return nil
+	}
+	switch needed {
+	case model.RoleOwner:
+		return fmt.Errorf("%w, only the owner of task %s may give it to someone else", ErrAccessDenied, existing.Id())
+	case model.RoleAdmin:
+		return fmt.Errorf("%w, user %d may not change who task %s is shared with", ErrAccessDenied, userId, existing.Id())
+	default:
+		return fmt.Errorf("%w, user %d may not change task %s", ErrAccessDenied, userId, existing.Id())
+	}
+}
+
+// TreeRoleOf gets the role the user has on the task, directly or as a member of one of the groups they belong to now,
+// or else their role on the nearest of the tasks it is a subtask of they have one on, as subtasks are readable by
+// everyone their parents are shared with. Empty if they have no role on the task nor any task above it.
+func TreeRoleOf(ctx context.Context, ds Datastore, groups GroupStore, task *model.Task, userId int) (string, error) {
+	var role string
+	var member []string
+	var err error
+	looked := false
+	upTree(ctx, ds, task, func(t *model.Task) bool {
+		now := time.Now()
+		if role = model.RoleOf(t, userId, now); role != "" || !model.SharedWithGroups(t) {
+			return role == ""
+		}
+		if !looked {
+			looked = true
+			if member, err = MemberGroups(ctx, groups, userId); nil != err {
+				return false
+			}
+		}
+		role = model.MemberRoleOf(t, userId, member, now)
+		return role == ""
+	})
+	return role, err
+}
+
+// upTree calls fn with the task, then with each of the tasks it is a subtask of in turn, nearest first,
+// until fn returns false or the top of the tree is reached.
+func upTree(ctx context.Context, ds Datastore, task *model.Task, fn func(t *model.Task) bool) {
+	seen := map[string]bool{}
+	for nil != task && !seen[task.Id()] && fn(task) {
+		if task.Parent == "" {
+			return
+		}
+		seen[task.Id()] = true
+		task = ds.GetTask(ctx, task.Parent)
+	}
+}
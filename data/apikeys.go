@@ -0,0 +1,29 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"time"
+)
+
+// APIKeyStore holds the api keys of the owners, by the hash of each key.
+type APIKeyStore interface {
+	// Add a new key, returning its id
+	AddKey(ctx context.Context, key model.APIKey) (string, error)
+
+	// Retrieve every key of the given owner, oldest first
+	Keys(ctx context.Context, ownerId int) ([]*model.APIKey, error)
+
+	// Retrieve the key with the given hash, nil if no key has it
+	KeyByHash(ctx context.Context, hash string) (*model.APIKey, error)
+
+	// Record the key as last used at the given time
+	KeyUsed(ctx context.Context, id string, at time.Time) error
+
+	// Remove the key, if owned by the given owner, so it can no longer be used.
+	// Returns false if the owner has no such key.
+	RevokeKey(ctx context.Context, ownerId int, id string) (bool, error)
+
+	// Close the store and release any resources.
+	Close()
+}
@@ -0,0 +1,78 @@
+package data_test
+
+import (
+	"fmt"
+	"gatso/data"
+	"gatso/data/datastoretest"
+	"gatso/model"
+	"testing"
+	"time"
+)
+
+func TestMemoryAPIKeyStore_Conformance(t *testing.T) {
+	datastoretest.RunAPIKeyConformance(t, func() data.APIKeyStore {
+		return data.NewMemoryAPIKeyStore()
+	})
+}
+
+func TestFileAPIKeyStore_Conformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunAPIKeyConformance(t, func() data.APIKeyStore {
+		count++
+		fk, err := data.NewFileAPIKeyStore(fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return fk
+	})
+}
+
+func TestFileAPIKeyStore_Reopen(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	fk, err := data.NewFileAPIKeyStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	kept, err := fk.AddKey(ctx, model.APIKey{Owner: testOwnerId, Name: "kept", Scope: model.ScopeRead, Hash: "kept",
+		Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	revoked, err := fk.AddKey(ctx, model.APIKey{Owner: testOwnerId, Name: "revoked", Scope: model.ScopeAdmin,
+		Hash: "revoked", Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	used := time.Now().Truncate(time.Millisecond)
+	if err := fk.KeyUsed(ctx, kept, used); nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := fk.RevokeKey(ctx, testOwnerId, revoked); nil != err {
+		t.Error(err)
+		return
+	}
+	fk.Close()
+
+	if fk, err = data.NewFileAPIKeyStore(path); nil != err {
+		t.Error(err)
+		return
+	}
+	defer fk.Close()
+	key, err := fk.KeyByHash(ctx, "kept")
+	if nil != err || nil == key || key.ID != kept || nil == key.LastUsed || !key.LastUsed.Equal(used) {
+		t.Errorf("Expected key %s, last used at %s, to be reloaded by its hash, found %+v, %v", kept, used, key, err)
+		return
+	}
+	if key, err := fk.KeyByHash(ctx, "revoked"); nil != err || nil != key {
+		t.Errorf("Expected the revoked key to stay revoked, found %+v, %v", key, err)
+	}
+}
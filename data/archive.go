@@ -0,0 +1,57 @@
+package data
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// TaskArchiver is a background job, moving tasks which expired longer ago than the given age to the archive.
+type TaskArchiver struct {
+	ds    Datastore
+	after time.Duration
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// StartTaskArchiver archives the long expired tasks of the given datastore every interval, until stopped.
+func StartTaskArchiver(ds Datastore, after time.Duration, interval time.Duration) *TaskArchiver {
+	a := &TaskArchiver{
+		ds:    ds,
+		after: after,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go a.run(interval)
+	return a
+}
+
+// Stop the archiver, waiting for any archiving in progress to finish.
+func (a *TaskArchiver) Stop() {
+	close(a.stop)
+	<-a.done
+}
+
+// Archive the long expired tasks now, returning the number of tasks archived.
+func (a *TaskArchiver) Archive(ctx context.Context) (int, error) {
+	return a.ds.ArchiveTasks(ctx, time.Now().Add(-a.after))
+}
+
+func (a *TaskArchiver) run(interval time.Duration) {
+	defer close(a.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			n, err := a.Archive(context.Background())
+			if nil != err {
+				log.Printf("Failed to archive expired tasks: %v", err)
+			} else if n > 0 {
+				log.Printf("Archived %d expired tasks", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package data_test
+
+import (
+	"gatso/data"
+	"gatso/model"
+	"testing"
+	"time"
+)
+
+func TestTaskArchiver(t *testing.T) {
+	ms := data.NewMemoryDataStore()
+	defer ms.Close()
+
+	expires := time.Now().Add(-time.Hour)
+	id, err := ms.AddTask(ctx, testOwnerId, model.Task{Owner: testOwnerId, Title: "Test Task", Expires: expires})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+
+	// a long age keeps the task listed
+	archiver := data.StartTaskArchiver(ms, time.Hour*24, time.Hour)
+	n, err := archiver.Archive(ctx)
+	archiver.Stop()
+	if nil != err || n != 0 {
+		t.Errorf("Expected nothing to be archived, found %d, %v", n, err)
+		return
+	}
+
+	archiver = data.StartTaskArchiver(ms, 0, time.Millisecond*10)
+	defer archiver.Stop()
+	for end := time.Now().Add(time.Second * 5); nil == ms.GetTask(ctx, id).Archived; {
+		if time.Now().After(end) {
+			t.Errorf("Expected task %s to be archived", id)
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
@@ -0,0 +1,119 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"log"
+)
+
+// changeNotifier is embedded by the datastores acting on each change made through the datastore they wrap.
+// Once a task is added, updated, deleted, restored, unarchived or changes status, notify is given the task as the
+// change stored it, along with the kind of change as one of the model.Event types.
+type changeNotifier struct {
+	Datastore
+	notify func(ctx context.Context, ownerId int, change string, task model.Task)
+}
+
+func (c changeNotifier) AddTask(ctx context.Context, ownerId int, task model.Task) (string, error) {
+	wctx, w := recordWrites(ctx)
+	id, err := c.Datastore.AddTask(wctx, ownerId, task)
+	if nil != err {
+		return "", err
+	}
+	c.changed(ctx, ownerId, w, id, model.EventCreated)
+	return id, nil
+}
+
+func (c changeNotifier) UpdateTask(ctx context.Context, ownerId int, task model.Task) (int, error) {
+	wctx, w := recordWrites(ctx)
+	version, err := c.Datastore.UpdateTask(wctx, ownerId, task)
+	if nil != err {
+		return 0, err
+	}
+	change := model.EventUpdated
+	if version == 1 { // missing task was added, under a new id
+		change = model.EventCreated
+	}
+	var taskId string
+	if nil != task.ID {
+		taskId = task.Id()
+	}
+	c.changed(ctx, ownerId, w, taskId, change)
+	return version, nil
+}
+
+func (c changeNotifier) DeleteTask(ctx context.Context, ownerId int, taskId string, version int) (bool, error) {
+	wctx, w := recordWrites(ctx)
+	deleted, err := c.Datastore.DeleteTask(wctx, ownerId, taskId, version)
+	if deleted {
+		c.changed(ctx, ownerId, w, taskId, model.EventDeleted)
+	}
+	return deleted, err
+}
+
+func (c changeNotifier) RestoreTask(ctx context.Context, ownerId int, taskId string) (bool, error) {
+	wctx, w := recordWrites(ctx)
+	restored, err := c.Datastore.RestoreTask(wctx, ownerId, taskId)
+	if restored {
+		c.changed(ctx, ownerId, w, taskId, model.EventRestored)
+	}
+	return restored, err
+}
+
+func (c changeNotifier) SetTaskStatus(ctx context.Context, ownerId int, taskId string, status string, version int) (int, error) {
+	wctx, w := recordWrites(ctx)
+	updated, err := c.Datastore.SetTaskStatus(wctx, ownerId, taskId, status, version)
+	if updated > 0 {
+		c.changed(ctx, ownerId, w, taskId, model.EventUpdated)
+	}
+	return updated, err
+}
+
+func (c changeNotifier) UnarchiveTask(ctx context.Context, ownerId int, taskId string) (bool, error) {
+	wctx, w := recordWrites(ctx)
+	unarchived, err := c.Datastore.UnarchiveTask(wctx, ownerId, taskId)
+	if unarchived {
+		c.changed(ctx, ownerId, w, taskId, model.EventUpdated)
+	}
+	return unarchived, err
+}
+
+// changed notifies of the change with the task the write recorded, passing it on to any notifier this one is wrapped by.
+// The change has already been made, so a write the store didn't record is logged rather than returned.
+func (c changeNotifier) changed(ctx context.Context, ownerId int, w *written, taskId string, change string) {
+	if nil == w.task {
+		log.Printf("Failed to act on %s task %s, the store did not record the task it wrote", change, taskId)
+		return
+	}
+	wrote(ctx, w.task)
+	c.notify(ctx, ownerId, change, *w.task)
+}
+
+type writesKey struct{}
+
+// written holds the task a write stored, as the write stored it.
+type written struct {
+	task *model.Task
+}
+
+// recordWrites gets a context under which the task stored by a write is recorded, along with where it is recorded.
+func recordWrites(ctx context.Context) (context.Context, *written) {
+	w := &written{}
+	return context.WithValue(ctx, writesKey{}, w), w
+}
+
+// wrote records a copy of the task a write stored, if the context is recording writes.
+// Stores call it as part of the write, under the same lock or transaction, so the task is as that write left it,
+// not as a later write did. The id is that the task was stored under, even when upserted under a new one.
+func wrote(ctx context.Context, task *model.Task) {
+	if w, ok := ctx.Value(writesKey{}).(*written); ok {
+		w.task = copyTask(task)
+	}
+}
+
+// recordingWrites reports if the context is recording writes, so stores can skip reading back the task they wrote when
+// it isn't.
+func recordingWrites(ctx context.Context) bool {
+	_, ok := ctx.Value(writesKey{}).(*written)
+	return ok
+}
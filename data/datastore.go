@@ -2,6 +2,7 @@ package data
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"gatso/model"
 	"go.mongodb.org/mongo-driver/bson"
@@ -9,40 +10,100 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"net/url"
+	"sync"
 	"time"
 )
 
 const connectionTimeout = time.Minute * 2 // Time connection to DB waits before giving up
 const databaseName = "todo"
 const tasksCollectionName = "todo_tasks"
-const maxTaskCount = 500 // maximum number of tasks returned in one request.
+const maxTaskCount = 500 // maximum number of tasks returned in one page.
 
+// ErrVersionConflict is returned when a change is made to a task, expecting a version it no longer has.
+var ErrVersionConflict = errors.New("task has been changed since the given version")
+
+// Datastore holds the tasks of every owner.
+// Every operation is carried out under the given context, so is abandoned if the context is cancelled.
+// Deleted tasks are moved to the trash, where they are hidden from every listing but GetTrash,
+// until they are restored or purged.
+// Long expired tasks are likewise moved to the archive, where they are hidden from every listing but GetArchive,
+// until they are unarchived.
 type Datastore interface {
-	// Retrieve all the tasks owned by the given id.  if id unknown, returns nil
-	GetTasks(ownerId int) ([]*model.Task, error)
+	// Retrieve a page of the tasks owned by the given id.  if id unknown, returns no tasks
+	GetTasks(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error)
+
+	// Retrieve a page of the tasks NOT owned by the given id, but visisble to them.
+	GetOthersTasks(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error)
+
+	// Retrieve a page of the owners tasks matching the values given in the query task.
+	FindTasks(ctx context.Context, ownerId int, query model.Task, opts ListOptions) (model.TaskPage, error)
+
+	// Retrieve a page of the subtasks of the task with the given id, outside the trash and archive.
+	GetSubtasks(ctx context.Context, taskId string, opts ListOptions) (model.TaskPage, error)
+
+	// Retrieve a page of the tasks blocked by the task with the given id, outside the trash and archive.
+	GetDependents(ctx context.Context, taskId string, opts ListOptions) (model.TaskPage, error)
+
+	// Get the number of tasks owned by the given ownerId, not counting those in the trash or archive.
+	CountTasks(ctx context.Context, ownerId int) int
+
+	// Add a new Task to the owners list.
+	// A task with a Parent must be owned by the owner of its parent, else ErrInvalidParent is returned.
+	// The tasks it is BlockedBy must likewise be the owners tasks outside the trash, else ErrInvalidDependency is returned.
+	AddTask(ctx context.Context, ownerId int, task model.Task) (string, error)
+
+	// Add or replace the given task with the same ID, returning its new version.
+	// Its Parent is checked as by AddTask, and mustn't be one of its own subtasks.
+	// Its blockers are checked as by AddTask, and mustn't be blocked by the task in turn, directly or not.
+	// If the task has a Version, it must match the stored version, else ErrVersionConflict is returned.
+	// Its status must be one the stored task can move to, else ErrStatusChange is returned.
+	UpdateTask(ctx context.Context, ownerId int, task model.Task) (int, error)
+
+	// Move the task with the given Id to the trash, if it belongs to the given owner id.  Returns false if nothing was deleted.
+	// A non zero version must match the stored version, else ErrVersionConflict is returned.
+	DeleteTask(ctx context.Context, ownerId int, taskId string, version int) (bool, error)
+
+	// Move the owners task to the given status, returning its new version, or 0 if the owner has no such task
+	// outside the trash.  The status must be one the task can move to, else ErrStatusChange is returned.
+	// A non zero version must match the stored version, else ErrVersionConflict is returned.
+	SetTaskStatus(ctx context.Context, ownerId int, taskId string, status string, version int) (int, error)
+
+	// Retrieve a single task by its id, nil if not found.  Tasks in the trash have their Deleted time set.
+	GetTask(ctx context.Context, taskId string) *model.Task
+
+	// Retrieve a page of the owners tasks in the trash.
+	GetTrash(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error)
+
+	// Move the owners task out of the trash.  Returns false if the task wasn't in the owners trash.
+	RestoreTask(ctx context.Context, ownerId int, taskId string) (bool, error)
 
-	// Retrieve all the tasks NOT owned by the given id, but visisble to them.
-	GetOthersTasks(ownerId int) ([]*model.Task, error)
+	// Permanently remove every task moved to the trash before the given time, returning how many were removed.
+	PurgeTrash(ctx context.Context, before time.Time) (int, error)
 
-	FindTasks(ownerId int, query model.Task) ([]*model.Task, error)
+	// Retrieve a page of the owners archived tasks matching the values given in the query task, as FindTasks.
+	GetArchive(ctx context.Context, ownerId int, query model.Task, opts ListOptions) (model.TaskPage, error)
 
-	// Get the number of tasks owned by the given ownerId
-	CountTasks(ownerId int) int
+	// Move the owners task out of the archive.  Returns false if the task wasn't in the owners archive.
+	// The task is not archived again until it expires again.
+	UnarchiveTask(ctx context.Context, ownerId int, taskId string) (bool, error)
 
-	// Add a new Task to the owners list
-	AddTask(ownerId int, task model.Task) (string, error)
+	// Archive every task which expired before the given time, returning how many were archived.
+	// Tasks in the trash, and tasks unarchived since they expired, are left alone.
+	ArchiveTasks(ctx context.Context, expiredBefore time.Time) (int, error)
 
-	// Add or replace the given task with the same ID
-	UpdateTask(ownerId int, task model.Task) error
+	// Retrieve up to limit recurring tasks, outside the trash and archive, which expired before the given time
+	// without their next occurrence being added since.
+	DueRecurrences(ctx context.Context, before time.Time, limit int) ([]*model.Task, error)
 
-	// Delete the task with the given Id, if it belongs to the given owner id.
-	DeleteTask(ownerId int, taskId string) bool
+	// Record the next occurrence of the task expiring at the given time has been added, without changing its version.
+	// Returns false if it already was, or the task no longer expires then.  A zero time clears the record instead.
+	MarkRecurred(ctx context.Context, taskId string, expires time.Time) (bool, error)
 
 	// Close the datastore and release connections.
 	Close()
 
 	// List all the user ID's known
-	Users()  ([]int, error)
+	Users(ctx context.Context) ([]int, error)
 }
 
 // MongoDb implementation of the datastore
@@ -50,8 +111,13 @@ type MongoDataStore struct {
 	client         *mongo.Client
 	db             *mongo.Database
 	collectionName string
+	treeLocks      *[treeLocks]sync.Mutex // an owner's tasks are checked and saved under one of these, see checkTree
 }
 
+// treeLocks is the number of locks the owners are spread over while the parents and blockers of their tasks are
+// checked and saved.
+const treeLocks = 64
+
 // Create a new MongoDataStore with the given connection uri to the mongo database.
 func NewMongoDataStore(uri string) (*MongoDataStore, error) {
 
@@ -79,11 +145,44 @@ func NewMongoDataStore(uri string) (*MongoDataStore, error) {
 	}
 
 	db := client.Database(databaseName)
-	return &MongoDataStore{
+	m := &MongoDataStore{
 		db:             db,
 		client:         client,
 		collectionName: colName,
-	}, nil
+		treeLocks:      &[treeLocks]sync.Mutex{},
+	}
+	if err := m.migrateReaders(ctx); nil != err {
+		client.Disconnect(ctx)
+		return nil, err
+	}
+	if err := m.migratePriority(ctx); nil != err {
+		client.Disconnect(ctx)
+		return nil, err
+	}
+	return m, nil
+}
+
+// migrateReaders shares the tasks saved with readers, before tasks had an ACL, with each of them as a reader.
+func (m MongoDataStore) migrateReaders(ctx context.Context) error {
+	readers := bson.D{{"$map", bson.D{
+		{"input", bson.D{{"$ifNull", bson.A{"$readers", bson.A{}}}}},
+		{"as", "reader"},
+		{"in", bson.D{{"user", "$$reader"}, {"role", model.RoleReader}}},
+	}}}
+	_, err := m.collection().UpdateMany(ctx, bson.D{{"readers", bson.D{{"$exists", true}}}}, mongo.Pipeline{
+		{{"$set", bson.D{{"acl", bson.D{{"$concatArrays", bson.A{
+			bson.D{{"$ifNull", bson.A{"$acl", bson.A{}}}}, readers}}}}}}},
+		{{"$unset", "readers"}},
+	})
+	return err
+}
+
+// migratePriority gives the tasks saved before tasks had a priority PriorityNone, so they are sorted and paged by
+// priority along with the tasks saved since, rather than left out of the queries following a cursor.
+func (m MongoDataStore) migratePriority(ctx context.Context) error {
+	_, err := m.collection().UpdateMany(ctx, bson.D{{"priority", bson.D{{"$exists", false}}}},
+		bson.D{{"$set", bson.D{{"priority", model.PriorityNone}}}})
+	return err
 }
 
 // Drop will destroy the entire tasks database. (Used for testing)
@@ -100,52 +199,122 @@ func (m MongoDataStore) Close() {
 	m.client.Disconnect(ctx)
 }
 
-func (m MongoDataStore) GetTasks(ownerId int) ([]*model.Task, error) {
-	return m.query(bson.D{{"owner", ownerId}}, bson.D{{"expires", -1}})
+func (m MongoDataStore) GetTasks(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error) {
+	return m.page(ctx, withStatus(bson.D{{"owner", ownerId}, {"deleted", nil}, {"archived", nil}}, opts.statuses(true)), opts)
 }
 
-func (m MongoDataStore) GetOthersTasks(ownerId int) ([]*model.Task, error) {
-	// single element query on an array returns any item with an array containing that value
-	return m.query(bson.D{{"readers", ownerId}}, bson.D{{"expires", -1}})
+func (m MongoDataStore) GetOthersTasks(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error) {
+	grantees := bson.A{bson.D{{"user", ownerId}, {"group", nil}}}
+	if len(opts.Groups) > 0 {
+		groups := bson.A{}
+		for _, g := range opts.Groups {
+			groups = append(groups, g)
+		}
+		grantees = append(grantees, bson.D{{"group", bson.D{{"$in", groups}}}})
+	}
+	shared := bson.D{{"$elemMatch", bson.D{{"$and", bson.A{bson.D{{"$or", grantees}}, bson.D{{"$or", activeGrant()}}}}}}}
+	return m.page(ctx, withStatus(bson.D{{"acl", shared}, {"deleted", nil}, {"archived", nil}}, opts.statuses(true)), opts)
 }
 
-func (m MongoDataStore) FindTasks(ownerId int, query model.Task) ([]*model.Task, error) {
+func (m MongoDataStore) FindTasks(ctx context.Context, ownerId int, query model.Task, opts ListOptions) (model.TaskPage, error) {
+	filter := append(findFilter(ownerId, query), bson.E{"archived", nil})
+	return m.page(ctx, withStatus(filter, opts.withQueryStatus(query).statuses(true)), opts)
+}
 
+// withStatus limits the filter to the tasks with the given statuses, unless none are given.
+// Tasks saved before they had a status are open.
+func withStatus(filter bson.D, statuses []string) bson.D {
+	if len(statuses) == 0 {
+		return filter
+	}
+	items := bson.A{}
+	for _, s := range statuses {
+		items = append(items, s)
+		if s == model.StatusOpen {
+			items = append(items, nil)
+		}
+	}
+	return append(filter, bson.E{"status", bson.D{{"$in", items}}})
+}
+
+// accessFilter selects the tasks the user has at least the needed role on.
+func accessFilter(userId int, needed string) bson.E {
+	roles := model.RolesAllowing(needed)
+	if len(roles) == 0 {
+		return bson.E{"owner", userId}
+	}
+	items := bson.A{}
+	for _, role := range roles {
+		items = append(items, role)
+	}
+	granted := bson.D{{"$elemMatch", bson.D{{"user", userId}, {"group", nil}, {"role", bson.D{{"$in", items}}}, {"$or", activeGrant()}}}}
+	return bson.E{"$or", bson.A{bson.D{{"owner", userId}}, bson.D{{"acl", granted}}}}
+}
+
+// activeGrant matches the grants which haven't expired.
+func activeGrant() bson.A {
+	return bson.A{bson.D{{"expires", nil}}, bson.D{{"expires", bson.D{{"$gt", time.Now()}}}}}
+}
+
+// withList limits the filter to the tasks in the given list, unless none is given.
+// Tasks saved before they had a list are in the default list.
+func withList(filter bson.D, list string) bson.D {
+	if list == "" {
+		return filter
+	}
+	if list == model.DefaultList {
+		return append(filter, bson.E{"list", bson.D{{"$in", bson.A{list, nil}}}})
+	}
+	return append(filter, bson.E{"list", list})
+}
+
+func (m MongoDataStore) GetSubtasks(ctx context.Context, taskId string, opts ListOptions) (model.TaskPage, error) {
+	filter := bson.D{{"parent", taskId}, {"deleted", nil}, {"archived", nil}}
+	return m.page(ctx, withStatus(filter, opts.statuses(true)), opts)
+}
+
+func (m MongoDataStore) GetDependents(ctx context.Context, taskId string, opts ListOptions) (model.TaskPage, error) {
+	filter := bson.D{{"blockedBy", taskId}, {"deleted", nil}, {"archived", nil}}
+	return m.page(ctx, withStatus(filter, opts.statuses(true)), opts)
+}
+
+// findFilter builds the filter selecting the owners tasks outside the trash matching the query task.
+func findFilter(ownerId int, query model.Task) bson.D {
 	doc := bson.D{}
 	doc = append(doc, bson.E{"owner", ownerId})
+	doc = append(doc, bson.E{"deleted", nil})
 
 	if query.Title != "" {
 		doc = append(doc, bson.E{"title", query.Title})
 	}
 
 	if !query.Expires.IsZero() {
-		by, err := bson.Marshal(&query.Expires)
-		if nil != err {
-			return nil, err
-		}
-
-		rVal := bson.D{{"$lt", bson.Raw(by)}}
+		rVal := bson.D{{"$lt", query.Expires}}
 		doc = append(doc, bson.E{"expires", rVal})
 	}
 
 	if !query.Created.IsZero() {
-		by, err := bson.Marshal(&query.Created)
-		if nil != err {
-			return nil, err
-		}
-
-		rVal := bson.D{{"$gte", bson.Raw(by)}}
+		rVal := bson.D{{"$gte", query.Created}}
 		doc = append(doc, bson.E{"created", rVal})
 	}
 
-	if len(query.Readers) != 0 {
-		by, err := bson.Marshal(&query.Readers)
-		if nil != err {
-			return nil, err
+	doc = withList(doc, query.List)
+
+	if len(query.ACL) != 0 {
+		items := bson.A{}
+		for _, g := range query.ACL {
+			grant := bson.D{{"user", g.User}}
+			if g.Group != "" {
+				grant = bson.D{{"group", g.Group}}
+			}
+			if g.Role != "" {
+				grant = append(grant, bson.E{"role", g.Role})
+			}
+			items = append(items, bson.D{{"$elemMatch", grant}})
 		}
 
-		rVal := bson.D{{"$all", bson.Raw(by)}}
-		doc = append(doc, bson.E{"readers", rVal})
+		rVal := bson.D{{"$all", items}}
+		doc = append(doc, bson.E{"acl", rVal})
 	}
 
 	if len(query.Labels) != 0 {
@@ -159,28 +328,53 @@ func (m MongoDataStore) FindTasks(ownerId int, query model.Task) ([]*model.Task,
 	}
 
 	if len(query.Notes) != 0 {
-		by, err := bson.Marshal(&query.Notes)
-		if nil != err {
-			return nil, err
+		items := bson.A{}
+		for _, s := range query.Notes {
+			items = append(items, s)
 		}
 
-		rVal := bson.D{{"$all", bson.Raw(by)}}
+		rVal := bson.D{{"$all", items}}
 		doc = append(doc, bson.E{"notes", rVal})
 	}
 
-	return m.query(doc, bson.D{{"expires", -1}})
+	if len(query.BlockedBy) != 0 {
+		items := bson.A{}
+		for _, s := range query.BlockedBy {
+			items = append(items, s)
+		}
+
+		rVal := bson.D{{"$all", items}}
+		doc = append(doc, bson.E{"blockedBy", rVal})
+	}
+
+	return doc
 }
 
-func (m MongoDataStore) AddTask(ownerId int, task model.Task) (string, error) {
+func (m MongoDataStore) AddTask(ctx context.Context, ownerId int, task model.Task) (string, error) {
 	if task.Owner != ownerId {
 		return "", fmt.Errorf("Owner %d does not own the given task to add", ownerId)
 	}
+	if err := checkStatus(&task); nil != err {
+		return "", err
+	}
+	if err := checkACL(&task); nil != err {
+		return "", err
+	}
+	task.List = model.ListOf(&task)
+	unlock, err := m.checkTree(ctx, &task)
+	if nil != err {
+		return "", err
+	}
+	defer unlock()
 
 	task.Created = time.Now()
+	task.CompletedAt = completedAt(task.Status, nil)
 	task.ID = nil
-
-	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
-	defer cancel()
+	task.Version = 1
+	task.Deleted = nil
+	task.Archived = nil
+	task.Unarchived = nil
+	task.Recurred = nil
 
 	result, err := m.collection().InsertOne(ctx, &task)
 	if nil != err {
@@ -190,69 +384,336 @@ func (m MongoDataStore) AddTask(ownerId int, task model.Task) (string, error) {
 	if !ok {
 		return "", fmt.Errorf("failed to read new id of inserted item")
 	}
+	task.ID = &oid
+	wrote(ctx, &task)
 	return oid.Hex(), nil
 }
 
-func (m MongoDataStore) UpdateTask(ownerId int, task model.Task) error {
-	existing := m.GetTask(task.Id())
-	if nil == existing { // doesn't exist, treat as an Add
-		_, err := m.AddTask(ownerId, task)
-		return err
+func (m MongoDataStore) UpdateTask(ctx context.Context, ownerId int, task model.Task) (int, error) {
+	version := task.Version
+	if nil == task.ID { // no id, treat as an Add
+		return m.upsertMissing(ctx, ownerId, task)
+	}
+	if err := checkStatus(&task); nil != err {
+		return 0, err
 	}
-	if existing.Owner != ownerId {
-		return fmt.Errorf("Owner %d does not own the given task to update", ownerId)
+	if err := checkACL(&task); nil != err {
+		return 0, err
+	}
+	task.List = model.ListOf(&task)
+	unlock, err := m.checkTree(ctx, &task)
+	if nil != err {
+		return 0, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
-	defer cancel()
+	existing := m.GetTask(ctx, task.Id())
+	if nil == existing { // doesn't exist, treat as an Add
+		unlock()
+		return m.upsertMissing(ctx, ownerId, task)
+	}
+	needed := neededRole(existing, &task)
+	if err := checkAccess(existing, ownerId, needed); nil != err {
+		unlock()
+		return 0, err
+	}
 
+	// version, status and access are checked as part of the update, so a concurrent change can't slip in between.
+	filter := updateFilter(*task.ID, ownerId, needed, task.Status, version)
+	task.Version = 0       // left out of the $set, as it is incremented
+	task.CompletedAt = nil // set apart, so the time a done task was done is kept
+	task.Deleted = nil
+	task.Archived = nil
+	task.Unarchived = nil // left out of the $set, so the stored times are kept
+	task.Recurred = nil
 	by, err := bson.Marshal(&task)
+	if nil != err {
+		unlock()
+		return 0, err
+	}
+	var set bson.D
+	if err := bson.Unmarshal(by, &set); nil != err {
+		unlock()
+		return 0, err
+	}
+	if needed == model.RoleEditor {
+		set = withoutField(set, "acl") // unchanged, so left as stored in case it has just been changed
+	}
+	update := append(bson.D{{"$set", set}, {"$inc", bson.D{{"version", 1}}}}, completedAtUpdate(task.Status))
+
+	var updated model.Task
+	err = m.collection().FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	unlock()
+	if nil == err {
+		wrote(ctx, &updated)
+		return updated.Version, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+
+	// nothing updated, find out why
+	existing = m.GetTask(ctx, task.Id())
+	if nil == existing { // doesn't exist, treat as an Add
+		task.Version = version
+		return m.upsertMissing(ctx, ownerId, task)
+	}
+	if err := checkAccess(existing, ownerId, needed); nil != err {
+		return 0, err
+	}
+	return 0, updateRefused(existing, ownerId, task.Status)
+}
+
+// checkTree checks the parent and blockers of the task being saved, returning with its owner locked until the
+// returned func is called, once it is saved. The tasks of an owner are only checked and saved one at a time, so two
+// changes can't each pass the check and together make a cycle. Owners are only locked within this process, several
+// instances sharing the database may still make one between them.
+func (m MongoDataStore) checkTree(ctx context.Context, task *model.Task) (func(), error) {
+	lock := &m.treeLocks[uint(task.Owner)%treeLocks]
+	lock.Lock()
+	get := func(id string) *model.Task { return m.GetTask(ctx, id) }
+	if err := checkParent(task, get); nil != err {
+		lock.Unlock()
+		return nil, err
+	}
+	if err := checkDependencies(task, get); nil != err {
+		lock.Unlock()
+		return nil, err
+	}
+	return lock.Unlock, nil
+}
+
+// updateFilter selects the task to update if the user has the needed role on it, it can move to the given status,
+// and is at the given version, unless that is 0.
+func updateFilter(docId primitive.ObjectID, userId int, needed string, status string, version int) bson.D {
+	filter := bson.D{{"_id", docId}, accessFilter(userId, needed), {"deleted", nil}, {"archived", nil}}
+	if version != 0 {
+		filter = append(filter, bson.E{"version", version})
+	}
+	return withStatus(filter, model.StatusesBefore(status))
+}
+
+// withoutField removes the named field from the document.
+func withoutField(doc bson.D, name string) bson.D {
+	var kept bson.D
+	for _, e := range doc {
+		if e.Key != name {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// completedAtUpdate records when a task moving to the given status was done, keeping the time of a task already done.
+func completedAtUpdate(status string) bson.E {
+	if status == model.StatusDone {
+		return bson.E{"$min", bson.D{{"completedAt", time.Now()}}}
+	}
+	return bson.E{"$unset", bson.D{{"completedAt", ""}}}
+}
 
-	filter := bson.D{{"_id", existing.ID}}
-	update := bson.D{{"$set", bson.Raw(by)}}
-	_, err = m.collection().UpdateOne(ctx, filter, update)
+func (m MongoDataStore) SetTaskStatus(ctx context.Context, ownerId int, taskId string, status string, version int) (int, error) {
+	docId, err := primitive.ObjectIDFromHex(taskId)
 	if nil != err {
-		return err
+		return 0, nil
+	}
+	task := model.Task{Status: status}
+	if err := checkStatus(&task); nil != err {
+		return 0, err
 	}
-	return nil
 
+	filter := updateFilter(docId, ownerId, model.RoleEditor, task.Status, version)
+	update := bson.D{
+		{"$set", bson.D{{"status", task.Status}}},
+		{"$inc", bson.D{{"version", 1}}},
+		completedAtUpdate(task.Status),
+	}
+	var updated model.Task
+	err = m.collection().FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if nil == err {
+		wrote(ctx, &updated)
+		return updated.Version, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+
+	// nothing updated, find out why
+	existing := m.GetTask(ctx, taskId)
+	if nil == existing || model.RoleOf(existing, ownerId, time.Now()) == "" || nil != existing.Deleted {
+		return 0, nil
+	}
+	return 0, updateRefused(existing, ownerId, task.Status)
 }
 
-func (m MongoDataStore) DeleteTask(ownerId int, taskId string) bool {
-	existing := m.GetTask(taskId)
-	if nil == existing {
-		return false
+// upsertMissing adds a task given to update, which doesn't exist. It has no version to match.
+func (m MongoDataStore) upsertMissing(ctx context.Context, ownerId int, task model.Task) (int, error) {
+	if task.Version != 0 {
+		return 0, ErrVersionConflict
 	}
-	if ownerId != existing.Owner {
-		return false
+	if _, err := m.AddTask(ctx, ownerId, task); nil != err {
+		return 0, err
 	}
+	return 1, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
-	defer cancel()
+func (m MongoDataStore) DeleteTask(ctx context.Context, ownerId int, taskId string, version int) (bool, error) {
+	docId, err := primitive.ObjectIDFromHex(taskId)
+	if nil != err {
+		return false, nil
+	}
 
-	filter := bson.D{{"_id", existing.ID}}
+	filter := bson.D{{"_id", docId}, accessFilter(ownerId, model.RoleAdmin), {"deleted", nil}}
+	if version != 0 {
+		filter = append(filter, bson.E{"version", version})
+	}
+	update := bson.D{{"$set", bson.D{{"deleted", time.Now()}}}, {"$inc", bson.D{{"version", 1}}}}
 
-	count, err := m.collection().DeleteOne(ctx, filter)
-	return nil == err && count.DeletedCount > 0
+	deleted, err := m.updateOne(ctx, filter, update)
+	if nil != err || deleted {
+		return deleted, err
+	}
+	if version != 0 {
+		existing := m.GetTask(ctx, taskId)
+		if nil != existing && model.Allows(model.RoleOf(existing, ownerId, time.Now()), model.RoleAdmin) &&
+			nil == existing.Deleted {
+			return false, ErrVersionConflict
+		}
+	}
+	return false, nil
 }
 
-func (m MongoDataStore) GetTask(taskId string) *model.Task {
+func (m MongoDataStore) GetTrash(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error) {
+	return m.page(ctx, withStatus(bson.D{{"owner", ownerId}, {"deleted", bson.D{{"$ne", nil}}}}, opts.statuses(false)), opts)
+}
+
+func (m MongoDataStore) RestoreTask(ctx context.Context, ownerId int, taskId string) (bool, error) {
+	docId, err := primitive.ObjectIDFromHex(taskId)
+	if nil != err {
+		return false, nil
+	}
+
+	filter := bson.D{{"_id", docId}, accessFilter(ownerId, model.RoleAdmin), {"deleted", bson.D{{"$ne", nil}}}}
+	update := bson.D{{"$unset", bson.D{{"deleted", ""}}}, {"$inc", bson.D{{"version", 1}}}}
+	return m.updateOne(ctx, filter, update)
+}
+
+func (m MongoDataStore) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	result, err := m.collection().DeleteMany(ctx, bson.D{{"deleted", bson.D{{"$lt", before}}}})
+	if nil != err {
+		return 0, err
+	}
+	return int(result.DeletedCount), nil
+}
+
+func (m MongoDataStore) GetArchive(ctx context.Context, ownerId int, query model.Task, opts ListOptions) (model.TaskPage, error) {
+	filter := append(findFilter(ownerId, query), bson.E{"archived", bson.D{{"$ne", nil}}})
+	return m.page(ctx, withStatus(filter, opts.withQueryStatus(query).statuses(false)), opts)
+}
+
+func (m MongoDataStore) UnarchiveTask(ctx context.Context, ownerId int, taskId string) (bool, error) {
+	docId, err := primitive.ObjectIDFromHex(taskId)
+	if nil != err {
+		return false, nil
+	}
+
+	filter := bson.D{{"_id", docId}, {"owner", ownerId}, {"deleted", nil}, {"archived", bson.D{{"$ne", nil}}}}
+	update := bson.D{
+		{"$unset", bson.D{{"archived", ""}}},
+		{"$set", bson.D{{"unarchived", time.Now()}}},
+		{"$inc", bson.D{{"version", 1}}},
+	}
+	return m.updateOne(ctx, filter, update)
+}
+
+// updateOne applies the update to the task matching the filter, reporting if there was one.
+// The task is read back as part of the update, to record it as written.
+func (m MongoDataStore) updateOne(ctx context.Context, filter bson.D, update bson.D) (bool, error) {
+	var updated model.Task
+	err := m.collection().FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if nil != err {
+		return false, err
+	}
+	wrote(ctx, &updated)
+	return true, nil
+}
+
+func (m MongoDataStore) ArchiveTasks(ctx context.Context, expiredBefore time.Time) (int, error) {
+	filter := bson.D{
+		{"deleted", nil},
+		{"archived", nil},
+		{"expires", bson.D{{"$gt", time.Time{}}, {"$lt", expiredBefore}}},
+		{"$or", bson.A{
+			bson.D{{"unarchived", nil}},
+			bson.D{{"$expr", bson.D{{"$lt", bson.A{"$unarchived", "$expires"}}}}},
+		}},
+	}
+	update := bson.D{{"$set", bson.D{{"archived", time.Now()}}}, {"$inc", bson.D{{"version", 1}}}}
+	result, err := m.collection().UpdateMany(ctx, filter, update)
+	if nil != err {
+		return 0, err
+	}
+	return int(result.ModifiedCount), nil
+}
+
+func (m MongoDataStore) DueRecurrences(ctx context.Context, before time.Time, limit int) ([]*model.Task, error) {
+	filter := bson.D{
+		{"recurrence", bson.D{{"$gt", ""}}},
+		{"deleted", nil},
+		{"archived", nil},
+		{"expires", bson.D{{"$gt", time.Time{}}, {"$lt", before}}},
+		{"$or", bson.A{
+			bson.D{{"recurred", nil}},
+			bson.D{{"$expr", bson.D{{"$lt", bson.A{"$recurred", "$expires"}}}}},
+		}},
+	}
+	return m.query(ctx, filter, bson.D{{"expires", 1}}, int64(limit))
+}
+
+func (m MongoDataStore) MarkRecurred(ctx context.Context, taskId string, expires time.Time) (bool, error) {
+	docId, err := primitive.ObjectIDFromHex(taskId)
+	if nil != err {
+		return false, nil
+	}
+
+	filter := bson.D{{"_id", docId}}
+	update := bson.D{{"$unset", bson.D{{"recurred", ""}}}}
+	if !expires.IsZero() {
+		filter = append(filter,
+			bson.E{"recurrence", bson.D{{"$gt", ""}}},
+			bson.E{"expires", expires},
+			bson.E{"$or", bson.A{
+				bson.D{{"recurred", nil}},
+				bson.D{{"recurred", bson.D{{"$lt", expires}}}},
+			}})
+		update = bson.D{{"$set", bson.D{{"recurred", expires}}}}
+	}
+	result, err := m.collection().UpdateOne(ctx, filter, update)
+	if nil != err {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (m MongoDataStore) GetTask(ctx context.Context, taskId string) *model.Task {
 	docId, err := primitive.ObjectIDFromHex(taskId)
 	if nil != err {
 		return nil
 	}
-	tasks, err := m.query(bson.D{{"_id", docId}}, nil)
+	tasks, err := m.query(ctx, bson.D{{"_id", docId}}, nil, 1)
 	if nil != err || len(tasks) == 0 {
 		return nil
 	}
 	return tasks[0]
 }
 
-func (m MongoDataStore) CountTasks(ownerId int) int {
-	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
-	defer cancel()
-
-	query := bson.D{{"owner", ownerId}}
+func (m MongoDataStore) CountTasks(ctx context.Context, ownerId int) int {
+	query := bson.D{{"owner", ownerId}, {"deleted", nil}, {"archived", nil}}
 
 	c, err := m.collection().CountDocuments(ctx, query, nil)
 	if nil != err {
@@ -261,15 +722,11 @@ func (m MongoDataStore) CountTasks(ownerId int) int {
 	return int(c)
 }
 
-func (m MongoDataStore) Exists(taskId string) bool {
-	return m.GetTask(taskId) != nil
+func (m MongoDataStore) Exists(ctx context.Context, taskId string) bool {
+	return m.GetTask(ctx, taskId) != nil
 }
 
-func (m MongoDataStore) Users() ([]int, error) {
-
-	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
-	defer cancel()
-
+func (m MongoDataStore) Users(ctx context.Context) ([]int, error) {
 	vals, err := m.collection().Distinct(ctx, "owner", bson.D{}, options.Distinct())
 	if nil != err {
 		return nil, err
@@ -289,16 +746,64 @@ func (m MongoDataStore) collection() *mongo.Collection {
 	return m.db.Collection(m.collectionName)
 }
 
-func (m MongoDataStore) query(query bson.D, sort bson.D) ([]*model.Task, error) {
+// page reads the page of tasks matching the given query, in the listing order, starting after the cursor.
+func (m MongoDataStore) page(ctx context.Context, query bson.D, opts ListOptions) (model.TaskPage, error) {
+	query = withList(query, opts.List)
+	if opts.Sort.ByUrgency() {
+		tasks, err := m.query(ctx, query, nil, MaxUrgencyTasks+1) // every task, for urgencyPage to score them
+		if nil != err {
+			return model.TaskPage{}, err
+		}
+		return urgencyPage(tasks, opts)
+	}
+	after, err := opts.after()
+	if nil != err {
+		return model.TaskPage{}, err
+	}
+	keys := opts.Sort.keys()
+	if nil != after {
+		// tasks following the cursor, differ from it at one of the sort keys, being equal on all before it.
+		var following bson.A
+		for i, key := range keys {
+			prefix := bson.D{}
+			for _, k := range keys[:i] {
+				f := sortFields[k.Field]
+				prefix = append(prefix, bson.E{f.bson, f.value(after)})
+			}
+			f := sortFields[key.Field]
+			op := "$gt"
+			if key.Desc {
+				op = "$lt"
+			}
+			following = append(following, append(prefix, bson.E{f.bson, bson.D{{op, f.value(after)}}}))
+		}
+		query = append(query, bson.E{"$or", following})
+	}
+
+	sort := bson.D{}
+	for _, key := range keys {
+		dir := 1
+		if key.Desc {
+			dir = -1
+		}
+		sort = append(sort, bson.E{sortFields[key.Field].bson, dir})
+	}
+
+	tasks, err := m.query(ctx, query, sort, int64(opts.limit()+1))
+	if nil != err {
+		return model.TaskPage{}, err
+	}
+	return newPage(tasks, opts), nil
+}
+
+func (m MongoDataStore) query(ctx context.Context, query bson.D, sort bson.D, limit int64) ([]*model.Task, error) {
 	findOptions := options.Find()
-	findOptions.SetLimit(maxTaskCount)
+	findOptions.SetLimit(limit)
 	if nil != sort {
 		findOptions.SetSort(sort)
 	}
 
 	var tasks []*model.Task
-	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
-	defer cancel()
 	cur, err := m.collection().Find(ctx, query, findOptions)
 	if nil != err {
 		return nil, err
@@ -315,5 +820,9 @@ func (m MongoDataStore) query(query bson.D, sort bson.D) ([]*model.Task, error)
 
 		tasks = append(tasks, &task)
 	}
+	// A cancelled context stops the cursor early, don't mistake that for the end of the results
+	if err := cur.Err(); nil != err {
+		return nil, err
+	}
 	return tasks, nil
 }
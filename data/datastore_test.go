@@ -1,32 +1,38 @@
 package data_test
 
 import (
+	"context"
 	"encoding/json"
 	"gatso/data"
+	"gatso/data/datastoretest"
 	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"testing"
+	"time"
 )
 
-const testDBUri = "mongodb://localhost:27017"
+// Fail fast when no local mongodb is running, so those tests are skipped rather than hang.
+const testDBUri = "mongodb://localhost:27017/?serverSelectionTimeoutMS=2000"
 const testOwnerId = 123
 
+var ctx = context.Background()
+
 var testTaskId string
 
 //initTest will drop the database and create a single test task, belonging to owner 123.
-func initTest() *data.MongoDataStore {
+func initTest(t *testing.T) *data.MongoDataStore {
 
 	// Use an alternative collection name to prevent other tests running in parrallel spoiling test data.
-	ms, err := data.NewMongoDataStore(testDBUri + "#storetest")
-	if nil != err {
-		panic(err)
-	}
+	ms := openTestStore(t, testDBUri+"#storetest")
 	ms.Drop()
 
 	task, err := createTestTask([]byte(`{ "owner": 123, "title": "Test Task" }`))
 	if nil != err {
 		panic(err)
 	}
-	testTaskId, err = ms.AddTask(testOwnerId, *task)
+	testTaskId, err = ms.AddTask(ctx, testOwnerId, *task)
 	if nil != err {
 		panic(err)
 	}
@@ -34,30 +40,136 @@ func initTest() *data.MongoDataStore {
 	return ms
 }
 
-func TestNewMongoDataStore(t *testing.T) {
-	ms, err := data.NewMongoDataStore(testDBUri)
-	defer ms.Close()
+var mongoUnavailable error
 
+// openTestStore connects to the test mongodb, skipping the calling test if it can't be reached.
+// Once a connection has failed, later tests are skipped without waiting on another attempt.
+func openTestStore(t *testing.T, uri string) *data.MongoDataStore {
+	if nil != mongoUnavailable {
+		t.Skipf("mongodb not available: %v", mongoUnavailable)
+	}
+	ms, err := data.NewMongoDataStore(uri)
 	if nil != err {
-		t.Error(err)
-		return
+		mongoUnavailable = err
+		t.Skipf("mongodb not available at %s: %v", uri, err)
 	}
+	return ms
+}
+
+func TestNewMongoDataStore(t *testing.T) {
+	ms := openTestStore(t, testDBUri)
+	defer ms.Close()
+
 	if nil == ms {
 		t.Errorf("Expected non nil datastore from TestNewMongoDataStore")
 		return
 	}
 
-	ms, err = data.NewMongoDataStore("")
+	_, err := data.NewMongoDataStore("")
 	if nil == err {
 		t.Errorf("Expected exception requesting new datastore with empty uri")
 		return
 	}
 }
 
+func TestMongoDataStore_Conformance(t *testing.T) {
+	ms := openTestStore(t, testDBUri+"#conformance")
+	ms.Close()
+
+	datastoretest.RunConformance(t, func() data.Datastore {
+		ms, err := data.NewMongoDataStore(testDBUri + "#conformance")
+		if nil != err {
+			t.Fatal(err)
+		}
+		ms.Drop()
+		return ms
+	})
+}
+
+func TestMongoDataStore_ChangeConformance(t *testing.T) {
+	ms := openTestStore(t, testDBUri+"#conformance")
+	ms.Close()
+
+	datastoretest.RunChangeConformance(t, func() data.Datastore {
+		ms, err := data.NewMongoDataStore(testDBUri + "#conformance")
+		if nil != err {
+			t.Fatal(err)
+		}
+		ms.Drop()
+		return ms
+	})
+}
+
+func TestMongoHistoryStore_Conformance(t *testing.T) {
+	ms := openTestStore(t, testDBUri+"#conformance")
+	defer ms.Close()
+
+	datastoretest.RunHistoryConformance(t, func() data.HistoryStore {
+		hs := data.NewMongoHistoryStore(ms)
+		hs.Drop()
+		return hs
+	})
+}
+
+func TestMongoWebhookStore_Conformance(t *testing.T) {
+	ms := openTestStore(t, testDBUri+"#conformance")
+	defer ms.Close()
+
+	datastoretest.RunWebhookConformance(t, func() data.WebhookStore {
+		ws := data.NewMongoWebhookStore(ms)
+		ws.Drop()
+		return ws
+	})
+}
+
+func TestMongoListStore_Conformance(t *testing.T) {
+	ms := openTestStore(t, testDBUri+"#conformance")
+	defer ms.Close()
+
+	datastoretest.RunListConformance(t, func() data.ListStore {
+		ls := data.NewMongoListStore(ms)
+		ls.Drop()
+		return ls
+	})
+}
+
+func TestMongoGroupStore_Conformance(t *testing.T) {
+	ms := openTestStore(t, testDBUri+"#conformance")
+	defer ms.Close()
+
+	datastoretest.RunGroupConformance(t, func() data.GroupStore {
+		gs := data.NewMongoGroupStore(ms)
+		gs.Drop()
+		return gs
+	})
+}
+
+func TestMongoAPIKeyStore_Conformance(t *testing.T) {
+	ms := openTestStore(t, testDBUri+"#conformance")
+	defer ms.Close()
+
+	datastoretest.RunAPIKeyConformance(t, func() data.APIKeyStore {
+		ks := data.NewMongoAPIKeyStore(ms)
+		ks.Drop()
+		return ks
+	})
+}
+
+func TestMongoReminderStore_Conformance(t *testing.T) {
+	ms := openTestStore(t, testDBUri+"#conformance")
+	defer ms.Close()
+
+	datastoretest.RunReminderConformance(t, func() data.ReminderStore {
+		rs := data.NewMongoReminderStore(ms)
+		rs.Drop()
+		return rs
+	})
+}
+
 func TestMongoDataStore_Close(t *testing.T) {
-	ms := initTest()
+	ms := initTest(t)
 
-	c := ms.CountTasks(testOwnerId)
+	c := ms.CountTasks(ctx, testOwnerId)
 	if c < 0 {
 		t.Errorf("expected positive result from count, found %d", c)
 		return
@@ -65,7 +177,7 @@ func TestMongoDataStore_Close(t *testing.T) {
 
 	ms.Close()
 	// Should return negative result on error state, store being closed.
-	c = ms.CountTasks(testOwnerId)
+	c = ms.CountTasks(ctx, testOwnerId)
 	if c >= 0 {
 		t.Errorf("expected negative result from count on closed store, found %d", c)
 		return
@@ -73,7 +185,7 @@ func TestMongoDataStore_Close(t *testing.T) {
 }
 
 func TestMongoDataStore_AddTask(t *testing.T) {
-	ms := initTest()
+	ms := initTest(t)
 	defer ms.Close()
 
 	task, err := createTestTask([]byte(`{ "owner": 123, "title": "Another Test Task" }`))
@@ -82,42 +194,42 @@ func TestMongoDataStore_AddTask(t *testing.T) {
 		return
 	}
 
-	id, err := ms.AddTask(testOwnerId, *task)
+	id, err := ms.AddTask(ctx, testOwnerId, *task)
 	if nil != err {
 		t.Error(err)
 		return
 	}
-	if !ms.Exists(id) {
+	if !ms.Exists(ctx, id) {
 		t.Errorf("Expected new test task with id %s to exist, it does not.", id)
 		return
 	}
 }
 
 func TestMongoDataStore_DeleteTask(t *testing.T) {
-	ms := initTest()
+	ms := initTest(t)
 	defer ms.Close()
 
-	if !ms.Exists(testTaskId) {
+	if !ms.Exists(ctx, testTaskId) {
 		t.Errorf("Expected test task with id %s to exist, it does not.", testTaskId)
 		return
 	}
 
-	if !ms.DeleteTask(testOwnerId, testTaskId) {
+	if deleted, err := ms.DeleteTask(ctx, testOwnerId, testTaskId, 0); !deleted || nil != err {
 		t.Errorf("Expected positive result from delete of task %s, found false", testTaskId)
 		return
 	}
 
-	if ms.Exists(testTaskId) {
+	if ms.Exists(ctx, testTaskId) {
 		t.Errorf("Expected false from Exists check on id %s, after delete, found true.", testTaskId)
 		return
 	}
 }
 
 func TestMongoDataStore_GetTask(t *testing.T) {
-	ms := initTest()
+	ms := initTest(t)
 	defer ms.Close()
 
-	task := ms.GetTask(testTaskId)
+	task := ms.GetTask(ctx, testTaskId)
 	if nil == task {
 		t.Errorf("Expected result from getTask with task id %s, nil found", testTaskId)
 		return
@@ -129,13 +241,13 @@ func TestMongoDataStore_GetTask(t *testing.T) {
 	}
 
 	// Try non existing id
-	task = ms.GetTask("madeupid")
+	task = ms.GetTask(ctx, "madeupid")
 	if nil != task {
 		t.Errorf("Expected result from getTask with invalid task id to be nil, found %s", task.Id())
 		return
 	}
 
-	task = ms.GetTask("")
+	task = ms.GetTask(ctx, "")
 	if nil != task {
 		t.Errorf("Expected result from getTask with empty task id to be nil, found %s", task.Id())
 		return
@@ -144,10 +256,10 @@ func TestMongoDataStore_GetTask(t *testing.T) {
 }
 
 func TestMongoDataStore_GetTasks(t *testing.T) {
-	ms := initTest()
+	ms := initTest(t)
 	defer ms.Close()
 
-	tasks, err := ms.GetTasks(testOwnerId)
+	tasks, err := pageTasks(ms.GetTasks(ctx, testOwnerId, data.ListOptions{}))
 	if nil != err {
 		t.Error(err)
 		return
@@ -169,13 +281,13 @@ func TestMongoDataStore_GetTasks(t *testing.T) {
 		t.Error(err)
 		return
 	}
-	_, err = ms.AddTask(testOwnerId, *newTask)
+	_, err = ms.AddTask(ctx, testOwnerId, *newTask)
 	if nil != err {
 		t.Error(err)
 		return
 	}
 
-	tasks, err = ms.GetTasks(testOwnerId)
+	tasks, err = pageTasks(ms.GetTasks(ctx, testOwnerId, data.ListOptions{}))
 	if nil != err {
 		t.Error(err)
 		return
@@ -191,13 +303,13 @@ func TestMongoDataStore_GetTasks(t *testing.T) {
 		t.Error(err)
 		return
 	}
-	_, err = ms.AddTask(666, *newTask)
+	_, err = ms.AddTask(ctx, 666, *newTask)
 	if nil != err {
 		t.Error(err)
 		return
 	}
 
-	tasks, err = ms.GetTasks(testOwnerId)
+	tasks, err = pageTasks(ms.GetTasks(ctx, testOwnerId, data.ListOptions{}))
 	if nil != err {
 		t.Error(err)
 		return
@@ -209,10 +321,10 @@ func TestMongoDataStore_GetTasks(t *testing.T) {
 }
 
 func TestMongoDataStore_UpdateTask(t *testing.T) {
-	ms := initTest()
+	ms := initTest(t)
 	defer ms.Close()
 
-	task := ms.GetTask(testTaskId)
+	task := ms.GetTask(ctx, testTaskId)
 	if nil == task {
 		t.Errorf("Test task %s was not found", testTaskId)
 		return
@@ -221,13 +333,13 @@ func TestMongoDataStore_UpdateTask(t *testing.T) {
 	testNote := "A test note to note is its noted"
 	task.Notes = append(task.Notes, testNote)
 
-	err := ms.UpdateTask(testOwnerId, *task)
+	_, err := ms.UpdateTask(ctx, testOwnerId, *task)
 	if nil != err {
 		t.Error(err)
 		return
 	}
 
-	task = ms.GetTask(testTaskId)
+	task = ms.GetTask(ctx, testTaskId)
 	if nil == task {
 		t.Errorf("Test task %s was not found", testTaskId)
 		return
@@ -244,13 +356,13 @@ func TestMongoDataStore_UpdateTask(t *testing.T) {
 }
 
 func TestMongoDataStore_FindTasks(t *testing.T) {
-	ms := initTest()
+	ms := initTest(t)
 	defer ms.Close()
 
 	query := model.Task{
 		Title: "Test Task",
 	}
-	tasks, err := ms.FindTasks(testOwnerId, query)
+	tasks, err := pageTasks(ms.FindTasks(ctx, testOwnerId, query, data.ListOptions{}))
 	if nil != err {
 		t.Error(err)
 		return
@@ -266,7 +378,7 @@ func TestMongoDataStore_FindTasks(t *testing.T) {
 		t.Error(err)
 		return
 	}
-	_, err = ms.AddTask(testOwnerId, *newTask)
+	_, err = ms.AddTask(ctx, testOwnerId, *newTask)
 	if nil != err {
 		t.Error(err)
 		return
@@ -274,7 +386,7 @@ func TestMongoDataStore_FindTasks(t *testing.T) {
 
 	// Search for second item
 	query = model.Task{Title: "Second task"}
-	tasks, err = ms.FindTasks(testOwnerId, query)
+	tasks, err = pageTasks(ms.FindTasks(ctx, testOwnerId, query, data.ListOptions{}))
 	if nil != err {
 		t.Error(err)
 		return
@@ -286,7 +398,7 @@ func TestMongoDataStore_FindTasks(t *testing.T) {
 
 	// Search for all items
 	query = model.Task{Owner: testOwnerId}
-	tasks, err = ms.FindTasks(testOwnerId, query)
+	tasks, err = pageTasks(ms.FindTasks(ctx, testOwnerId, query, data.ListOptions{}))
 	if nil != err {
 		t.Error(err)
 		return
@@ -298,7 +410,7 @@ func TestMongoDataStore_FindTasks(t *testing.T) {
 
 	// Search for non existing
 	query = model.Task{Title: "doesn't exist"}
-	tasks, err = ms.FindTasks(testOwnerId, query)
+	tasks, err = pageTasks(ms.FindTasks(ctx, testOwnerId, query, data.ListOptions{}))
 	if nil != err {
 		t.Error(err)
 		return
@@ -310,7 +422,7 @@ func TestMongoDataStore_FindTasks(t *testing.T) {
 }
 
 func TestMongoDataStore_FindTasksArrays(t *testing.T) {
-	ms := initTest()
+	ms := initTest(t)
 	defer ms.Close()
 
 	// Add another task
@@ -320,7 +432,7 @@ func TestMongoDataStore_FindTasksArrays(t *testing.T) {
 		return
 	}
 	newTask.Labels = append(newTask.Labels, "myLabel")
-	_, err = ms.AddTask(testOwnerId, *newTask)
+	_, err = ms.AddTask(ctx, testOwnerId, *newTask)
 	if nil != err {
 		t.Error(err)
 		return
@@ -330,7 +442,7 @@ func TestMongoDataStore_FindTasksArrays(t *testing.T) {
 		Labels: []string{"myLabel"},
 	}
 
-	tasks, err := ms.FindTasks(testOwnerId, query)
+	tasks, err := pageTasks(ms.FindTasks(ctx, testOwnerId, query, data.ListOptions{}))
 	if nil != err {
 		t.Error(err)
 		return
@@ -346,7 +458,7 @@ func TestMongoDataStore_FindTasksArrays(t *testing.T) {
 }
 
 func TestMongoDataStore_GetOthersTasks(t *testing.T) {
-	ms := initTest()
+	ms := initTest(t)
 	defer ms.Close()
 
 	// Add a task for another owner
@@ -355,13 +467,13 @@ func TestMongoDataStore_GetOthersTasks(t *testing.T) {
 		t.Error(err)
 		return
 	}
-	otherId, err := ms.AddTask(666, *newTask)
+	otherId, err := ms.AddTask(ctx, 666, *newTask)
 	if nil != err {
 		t.Error(err)
 		return
 	}
 
-	tasks, err := ms.GetOthersTasks(testOwnerId)
+	tasks, err := pageTasks(ms.GetOthersTasks(ctx, testOwnerId, data.ListOptions{}))
 	if nil != err {
 		t.Error(err)
 		return
@@ -372,19 +484,19 @@ func TestMongoDataStore_GetOthersTasks(t *testing.T) {
 	}
 
 	// Not add testowner to others task and see if it appear
-	newTask = ms.GetTask(otherId)
+	newTask = ms.GetTask(ctx, otherId)
 	if nil == newTask {
 		t.Errorf("Expected other persons task %s, found nothing", otherId)
 		return
 	}
 
-	newTask.Readers = append(newTask.Readers, testOwnerId)
-	if err := ms.UpdateTask(666, *newTask); nil != err {
+	newTask.ACL = append(newTask.ACL, model.Grant{User: testOwnerId, Role: model.RoleReader})
+	if _, err := ms.UpdateTask(ctx, 666, *newTask); nil != err {
 		t.Error(err)
 		return
 	}
 
-	tasks, err = ms.GetOthersTasks(testOwnerId)
+	tasks, err = pageTasks(ms.GetOthersTasks(ctx, testOwnerId, data.ListOptions{}))
 	if nil != err {
 		t.Error(err)
 		return
@@ -400,6 +512,56 @@ func TestMongoDataStore_GetOthersTasks(t *testing.T) {
 
 }
 
+func TestMongoDataStore_MigratePriority(t *testing.T) {
+	uri := testDBUri + "#prioritytest"
+	ms := openTestStore(t, uri)
+	ms.Drop()
+	ms.Close()
+
+	// a task saved before tasks had a priority
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(testDBUri))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(ctx)
+	if _, err := client.Database("todo").Collection("prioritytest").InsertOne(ctx, bson.D{
+		{Key: "owner", Value: testOwnerId}, {Key: "title", Value: "Legacy Task"}, {Key: "created", Value: time.Now()},
+		{Key: "version", Value: 1}}); nil != err {
+		t.Fatal(err)
+	}
+
+	ms = openTestStore(t, uri)
+	defer ms.Close()
+	if _, err := ms.AddTask(ctx, testOwnerId, model.Task{Owner: testOwnerId, Title: "Test Task"}); nil != err {
+		t.Fatal(err)
+	}
+	sort, err := data.ParseSort("priority")
+	if nil != err {
+		t.Fatal(err)
+	}
+	opts := data.ListOptions{Limit: 1, Sort: sort}
+	var found []*model.Task
+	for {
+		page, err := ms.GetTasks(ctx, testOwnerId, opts)
+		if nil != err {
+			t.Fatal(err)
+		}
+		found = append(found, page.Tasks...)
+		if page.Next == "" {
+			break
+		}
+		opts.Cursor = page.Next
+	}
+	if len(found) != 2 {
+		t.Errorf("Expected the legacy task to be paged by priority along with the new one, found %d tasks", len(found))
+	}
+}
+
+// pageTasks unwraps the tasks of a listing page.
+func pageTasks(page model.TaskPage, err error) ([]*model.Task, error) {
+	return page.Tasks, err
+}
+
 func createTestTask(by []byte) (*model.Task, error) {
 	var task model.Task
 	if err := json.Unmarshal(by, &task); nil != err {
@@ -0,0 +1,134 @@
+package datastoretest
+
+import (
+	"gatso/data"
+	"gatso/model"
+	"testing"
+	"time"
+)
+
+// APIKeyFactory creates a new, empty api key store for each test in the suite.
+// The suite closes the store once each test completes.
+type APIKeyFactory func() data.APIKeyStore
+
+// RunAPIKeyConformance runs the api key conformance suite against the key stores created by the given factory.
+func RunAPIKeyConformance(t *testing.T, factory APIKeyFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, ks data.APIKeyStore)
+	}{
+		{"APIKeys", testAPIKeys},
+		{"KeyUsed", testKeyUsed},
+		{"RevokeKey", testRevokeKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := factory()
+			defer ks.Close()
+			tt.test(t, ks)
+		})
+	}
+}
+
+func testAPIKeys(t *testing.T, ks data.APIKeyStore) {
+	created := time.Now().Truncate(time.Millisecond)
+	var ids []string
+	for i, key := range []model.APIKey{
+		{Owner: ownerId, Name: "first", Scope: model.ScopeRead, Prefix: "todo_first", Hash: "hash1", Created: created,
+			Key: "todo_firstkey"},
+		{Owner: ownerId, Name: "second", Scope: model.ScopeAdmin, Prefix: "todo_second", Hash: "hash2",
+			Created: created.Add(time.Second)},
+		{Owner: otherOwnerId, Name: "other", Scope: model.ScopeReadWrite, Prefix: "todo_other", Hash: "hash3",
+			Created: created},
+	} {
+		id, err := ks.AddKey(ctx, key)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		if id == "" {
+			t.Errorf("Expected a new id for key %d", i)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	keys, err := ks.Keys(ctx, ownerId)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(keys) != 2 || keys[0].ID != ids[0] || keys[1].ID != ids[1] {
+		t.Errorf("Expected the 2 keys of owner %d, oldest first, found %d", ownerId, len(keys))
+		return
+	}
+	key := keys[0]
+	if key.Name != "first" || key.Scope != model.ScopeRead || key.Prefix != "todo_first" || key.Hash != "hash1" ||
+		!key.Created.Equal(created) || nil != key.LastUsed {
+		t.Errorf("Expected the first key as added, found %+v", key)
+		return
+	}
+	if key.Key != "" {
+		t.Errorf("Expected the key itself not to be kept, found %q", key.Key)
+		return
+	}
+
+	key, err = ks.KeyByHash(ctx, "hash3")
+	if nil != err || nil == key || key.ID != ids[2] || key.Owner != otherOwnerId || key.Scope != model.ScopeReadWrite {
+		t.Errorf("Expected key %s of owner %d by its hash, found %+v, %v", ids[2], otherOwnerId, key, err)
+		return
+	}
+	if key, err = ks.KeyByHash(ctx, "madeup"); nil != err || nil != key {
+		t.Errorf("Expected no key for an unknown hash, found %+v, %v", key, err)
+	}
+}
+
+func testKeyUsed(t *testing.T, ks data.APIKeyStore) {
+	id, err := ks.AddKey(ctx, model.APIKey{Owner: ownerId, Name: "key", Scope: model.ScopeRead, Hash: "hash",
+		Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	used := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	if err := ks.KeyUsed(ctx, id, used); nil != err {
+		t.Error(err)
+		return
+	}
+	if err := ks.KeyUsed(ctx, "madeup", used); nil != err {
+		t.Errorf("Expected using an unknown key to change nothing, found %v", err)
+		return
+	}
+	key, err := ks.KeyByHash(ctx, "hash")
+	if nil != err || nil == key || nil == key.LastUsed || !key.LastUsed.Equal(used) {
+		t.Errorf("Expected key %s last used at %s, found %+v, %v", id, used, key, err)
+	}
+}
+
+func testRevokeKey(t *testing.T, ks data.APIKeyStore) {
+	id, err := ks.AddKey(ctx, model.APIKey{Owner: ownerId, Name: "key", Scope: model.ScopeRead, Hash: "hash",
+		Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if revoked, err := ks.RevokeKey(ctx, otherOwnerId, id); nil != err || revoked {
+		t.Errorf("Expected owner %d not to revoke the key of owner %d, found %v, %v", otherOwnerId, ownerId, revoked, err)
+		return
+	}
+	if revoked, err := ks.RevokeKey(ctx, ownerId, id); nil != err || !revoked {
+		t.Errorf("Expected owner %d to revoke their key, found %v, %v", ownerId, revoked, err)
+		return
+	}
+	if revoked, err := ks.RevokeKey(ctx, ownerId, id); nil != err || revoked {
+		t.Errorf("Expected the key to be revoked only once, found %v, %v", revoked, err)
+		return
+	}
+	if key, err := ks.KeyByHash(ctx, "hash"); nil != err || nil != key {
+		t.Errorf("Expected the revoked key to be gone, found %+v, %v", key, err)
+		return
+	}
+	if keys, err := ks.Keys(ctx, ownerId); nil != err || len(keys) != 0 {
+		t.Errorf("Expected owner %d to have no keys left, found %d, %v", ownerId, len(keys), err)
+	}
+}
@@ -0,0 +1,143 @@
+package datastoretest
+
+import (
+	"gatso/data"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+	"testing"
+	"time"
+)
+
+// RunChangeConformance runs the change conformance suite against the datastores created by the given factory.
+// Each store is wrapped in a HistoryDataStore, to check the changes made through it are acted on with the task
+// as the change stored it.
+func RunChangeConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, ds *data.HistoryDataStore)
+	}{
+		{"UpdateUnknownId", testChangeUpdateUnknownId},
+		{"ChangeRevisions", testChangeRevisions},
+		{"ConcurrentUpdates", testChangeConcurrentUpdates},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := data.NewHistoryDataStore(factory(), data.NewMemoryHistoryStore())
+			defer ds.Close()
+			tt.test(t, ds)
+		})
+	}
+}
+
+func testChangeUpdateUnknownId(t *testing.T, ds *data.HistoryDataStore) {
+	unknownId := primitive.NewObjectID()
+	version, err := ds.UpdateTask(ctx, ownerId, model.Task{ID: &unknownId, Owner: ownerId, Title: "upserted"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	tasks := getTasks(t, ds, ownerId)
+	if version != 1 || len(tasks) != 1 {
+		t.Fatalf("Expected update of an unknown id to add a task at version 1, found %d tasks at %d", len(tasks), version)
+	}
+
+	// the change is recorded against the id the task was stored under
+	revs := revisions(t, ds, tasks[0].Id())
+	if len(revs) != 1 || revs[0].Action != model.RevisionCreate || revs[0].Task.Title != "upserted" {
+		t.Errorf("Expected the task %s to have been recorded as created, found %d revisions", tasks[0].Id(), len(revs))
+	}
+	if tasks[0].Id() != unknownId.Hex() && len(revisions(t, ds, unknownId.Hex())) != 0 {
+		t.Errorf("Expected no revisions of the unknown id %s", unknownId.Hex())
+	}
+}
+
+func testChangeRevisions(t *testing.T, ds *data.HistoryDataStore) {
+	id := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Expires: time.Now().Add(-time.Hour)})
+	task := findTask(t, ds, ownerId, id)
+	task.Title = "changed"
+	if _, err := ds.UpdateTask(ctx, ownerId, *task); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := ds.SetTaskStatus(ctx, ownerId, id, model.StatusDone, 0); nil != err {
+		t.Fatal(err)
+	}
+	// archiving isn't recorded, the unarchive after it is
+	if _, err := ds.ArchiveTasks(ctx, time.Now()); nil != err {
+		t.Fatal(err)
+	}
+	if unarchived, err := ds.UnarchiveTask(ctx, ownerId, id); !unarchived || nil != err {
+		t.Fatalf("Expected task %s to be unarchived, found %v", id, err)
+	}
+	deleteTask(t, ds, ownerId, id)
+	if restored, err := ds.RestoreTask(ctx, ownerId, id); !restored || nil != err {
+		t.Fatalf("Expected task %s to be restored, found %v", id, err)
+	}
+
+	revs := revisions(t, ds, id)
+	expected := []struct {
+		number int
+		action string
+	}{
+		{1, model.RevisionCreate},
+		{2, model.RevisionUpdate},
+		{3, model.RevisionUpdate},
+		{5, model.RevisionUpdate},
+		{6, model.RevisionDelete},
+		{7, model.RevisionRestore},
+	}
+	if len(revs) != len(expected) {
+		t.Fatalf("Expected %d revisions of task %s, found %d", len(expected), id, len(revs))
+	}
+	for i, rev := range revs {
+		if rev.Number != expected[i].number || rev.Action != expected[i].action || rev.Task.Version != rev.Number ||
+			rev.Task.Id() != id {
+			t.Errorf("Expected revision %d to %s task %s, found revision %d to %s version %d",
+				expected[i].number, expected[i].action, id, rev.Number, rev.Action, rev.Task.Version)
+		}
+	}
+	if revs[1].Task.Title != "changed" || revs[2].Task.Status != model.StatusDone || nil == revs[4].Task.Deleted ||
+		nil == revs[3].Task.Unarchived {
+		t.Errorf("Expected each revision to hold the task as its change stored it")
+	}
+}
+
+func testChangeConcurrentUpdates(t *testing.T, ds *data.HistoryDataStore) {
+	const updates = 10
+	id := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+	task := findTask(t, ds, ownerId, id)
+	task.Version = 0 // not checked, so every update is made
+
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ds.UpdateTask(ctx, ownerId, *task); nil != err {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// each update is recorded once, as the version it stored
+	revs := revisions(t, ds, id)
+	if len(revs) != updates+1 {
+		t.Fatalf("Expected %d revisions of task %s, found %d", updates+1, id, len(revs))
+	}
+	for i, rev := range revs {
+		if rev.Number != i+1 || rev.Task.Version != i+1 {
+			t.Errorf("Expected revision %d of version %d, found revision %d of version %d",
+				i+1, i+1, rev.Number, rev.Task.Version)
+		}
+	}
+}
+
+// revisions reads every revision recorded of the task, failing the test on an error.
+func revisions(t *testing.T, ds *data.HistoryDataStore, taskId string) []*model.Revision {
+	t.Helper()
+	revs, err := ds.History().Revisions(ctx, taskId)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return revs
+}
@@ -0,0 +1,1841 @@
+// Package datastoretest provides a conformance suite any data.Datastore implementation can run
+// to prove it behaves the same as the MongoDataStore.
+package datastoretest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gatso/data"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
+	"testing"
+	"time"
+)
+
+const ownerId = 123
+const otherOwnerId = 666
+
+var ctx = context.Background()
+
+// Factory creates a new, empty datastore for each test in the suite.
+// The suite closes the store once each test completes.
+type Factory func() data.Datastore
+
+// RunConformance runs the full conformance suite against the datastores created by the given factory.
+func RunConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, ds data.Datastore)
+	}{
+		{"AddTask", testAddTask},
+		{"UpdateTask", testUpdateTask},
+		{"UpdateTaskMissing", testUpdateTaskMissing},
+		{"DeleteTask", testDeleteTask},
+		{"Versions", testVersions},
+		{"DeleteVersion", testDeleteVersion},
+		{"Trash", testTrash},
+		{"PurgeTrash", testPurgeTrash},
+		{"Archive", testArchive},
+		{"Unarchive", testUnarchive},
+		{"Recurrences", testRecurrences},
+		{"Status", testStatus},
+		{"StatusListings", testStatusListings},
+		{"Subtasks", testSubtasks},
+		{"Dependencies", testDependencies},
+		{"ConcurrentCycles", testConcurrentCycles},
+		{"TaskLists", testTaskLists},
+		{"GetTasks", testGetTasks},
+		{"GetOthersTasks", testGetOthersTasks},
+		{"Sharing", testSharing},
+		{"GroupSharing", testGroupSharing},
+		{"FindTasks", testFindTasks},
+		{"FindTasksArrays", testFindTasksArrays},
+		{"Pagination", testPagination},
+		{"PaginationFind", testPaginationFind},
+		{"InvalidCursor", testInvalidCursor},
+		{"Sort", testSort},
+		{"SortPagination", testSortPagination},
+		{"SortPriority", testSortPriority},
+		{"SortUrgency", testSortUrgency},
+		{"SortUrgencyTooMany", testSortUrgencyTooMany},
+		{"CountTasks", testCountTasks},
+		{"Users", testUsers},
+		{"CancelledContext", testCancelledContext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := factory()
+			defer ds.Close()
+			tt.test(t, ds)
+		})
+	}
+}
+
+func testAddTask(t *testing.T, ds data.Datastore) {
+	before := time.Now().Add(-time.Second)
+	fakeId := primitive.NewObjectID()
+	id, err := ds.AddTask(ctx, ownerId, model.Task{ID: &fakeId, Owner: ownerId, Title: "Test Task"})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if id == "" || id == fakeId.Hex() {
+		t.Errorf("Expected AddTask to assign a new id, found %q", id)
+		return
+	}
+
+	task := findTask(t, ds, ownerId, id)
+	if nil == task {
+		t.Errorf("Expected new task %s to exist, it does not", id)
+		return
+	}
+	if task.Title != "Test Task" {
+		t.Errorf("Expected title %q, found %q", "Test Task", task.Title)
+		return
+	}
+	if task.Created.Before(before) {
+		t.Errorf("Expected created time to be set when added, found %v", task.Created)
+		return
+	}
+
+	// Adding a task owned by someone else must fail
+	if _, err := ds.AddTask(ctx, otherOwnerId, model.Task{Owner: ownerId, Title: "Not mine"}); nil == err {
+		t.Errorf("Expected error adding a task owned by %d as owner %d", ownerId, otherOwnerId)
+		return
+	}
+	if c := ds.CountTasks(ctx, ownerId); c != 1 {
+		t.Errorf("Expected one task after rejected add, found %d", c)
+		return
+	}
+}
+
+func testUpdateTask(t *testing.T, ds data.Datastore) {
+	id := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+	task := findTask(t, ds, ownerId, id)
+	if nil == task {
+		t.Errorf("Test task %s was not found", id)
+		return
+	}
+
+	testNote := "A test note to note is its noted"
+	task.Notes = append(task.Notes, testNote)
+	task.Reminders = []string{"1d", "0"}
+	task.Recurrence = "FREQ=WEEKLY;BYDAY=MO"
+	if _, err := ds.UpdateTask(ctx, ownerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+	task = findTask(t, ds, ownerId, id)
+	if nil == task || len(task.Notes) != 1 || task.Notes[0] != testNote {
+		t.Errorf("Expected task %s to have the note %q after update", id, testNote)
+		return
+	}
+	if len(task.Reminders) != 2 || task.Reminders[0] != "1d" || task.Reminders[1] != "0" {
+		t.Errorf("Expected task %s to have its reminders after update, found %v", id, task.Reminders)
+		return
+	}
+	if task.Recurrence != "FREQ=WEEKLY;BYDAY=MO" {
+		t.Errorf("Expected task %s to have its recurrence after update, found %q", id, task.Recurrence)
+		return
+	}
+
+	// Updating someone elses task must fail and leave it unchanged
+	task.Title = "changed"
+	if _, err := ds.UpdateTask(ctx, otherOwnerId, *task); nil == err {
+		t.Errorf("Expected error updating task %s as owner %d", id, otherOwnerId)
+		return
+	}
+	task = findTask(t, ds, ownerId, id)
+	if nil == task || task.Title != "Test Task" {
+		t.Errorf("Expected task %s to be unchanged after rejected update", id)
+		return
+	}
+}
+
+func testUpdateTaskMissing(t *testing.T, ds data.Datastore) {
+	// Unknown id is treated as an add
+	missingId := primitive.NewObjectID()
+	if _, err := ds.UpdateTask(ctx, ownerId, model.Task{ID: &missingId, Owner: ownerId, Title: "upserted"}); nil != err {
+		t.Error(err)
+		return
+	}
+	tasks := getTasks(t, ds, ownerId)
+	if len(tasks) != 1 || tasks[0].Title != "upserted" {
+		t.Errorf("Expected update of a missing task to add it, found %d tasks", len(tasks))
+		return
+	}
+
+	// As is a task with no id
+	if _, err := ds.UpdateTask(ctx, ownerId, model.Task{Owner: ownerId, Title: "no id"}); nil != err {
+		t.Error(err)
+		return
+	}
+	if c := ds.CountTasks(ctx, ownerId); c != 2 {
+		t.Errorf("Expected update of a task without an id to add it, found %d tasks", c)
+		return
+	}
+
+	// and the add still checks ownership
+	otherId := primitive.NewObjectID()
+	if _, err := ds.UpdateTask(ctx, otherOwnerId, model.Task{ID: &otherId, Owner: ownerId}); nil == err {
+		t.Errorf("Expected error upserting a task owned by %d as owner %d", ownerId, otherOwnerId)
+		return
+	}
+}
+
+func testDeleteTask(t *testing.T, ds data.Datastore) {
+	id := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+
+	if deleteTask(t, ds, otherOwnerId, id) {
+		t.Errorf("Expected delete of task %s by owner %d to fail", id, otherOwnerId)
+		return
+	}
+	if deleteTask(t, ds, ownerId, primitive.NewObjectID().Hex()) {
+		t.Errorf("Expected delete of unknown task to fail")
+		return
+	}
+	if deleteTask(t, ds, ownerId, "madeupid") {
+		t.Errorf("Expected delete of invalid task id to fail")
+		return
+	}
+	if !deleteTask(t, ds, ownerId, id) {
+		t.Errorf("Expected positive result from delete of task %s, found false", id)
+		return
+	}
+	if nil != findTask(t, ds, ownerId, id) {
+		t.Errorf("Expected task %s to be gone after delete", id)
+		return
+	}
+	if deleteTask(t, ds, ownerId, id) {
+		t.Errorf("Expected second delete of task %s to fail", id)
+		return
+	}
+}
+
+func testVersions(t *testing.T, ds data.Datastore) {
+	id := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+	task := ds.GetTask(ctx, id)
+	if nil == task || task.Version != 1 {
+		t.Errorf("Expected new task %s to be version 1", id)
+		return
+	}
+
+	// each update bumps the version
+	task.Title = "first change"
+	version, err := ds.UpdateTask(ctx, ownerId, *task)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if version != 2 {
+		t.Errorf("Expected update to give version 2, found %d", version)
+		return
+	}
+	task.Version = 0 // unconditional
+	task.Title = "second change"
+	if version, err = ds.UpdateTask(ctx, ownerId, *task); nil != err || version != 3 {
+		t.Errorf("Expected unconditional update to give version 3, found %d, %v", version, err)
+		return
+	}
+
+	// an update from an old version is rejected, leaving the task unchanged
+	task.Version = 2
+	task.Title = "lost change"
+	if _, err := ds.UpdateTask(ctx, ownerId, *task); err != data.ErrVersionConflict {
+		t.Errorf("Expected ErrVersionConflict updating an old version, found %v", err)
+		return
+	}
+	task = ds.GetTask(ctx, id)
+	if nil == task || task.Title != "second change" || task.Version != 3 {
+		t.Errorf("Expected task %s to be unchanged after a conflicting update", id)
+		return
+	}
+
+	// a missing task can't have the expected version
+	missingId := primitive.NewObjectID()
+	if _, err := ds.UpdateTask(ctx, ownerId, model.Task{ID: &missingId, Owner: ownerId, Version: 1}); err != data.ErrVersionConflict {
+		t.Errorf("Expected ErrVersionConflict updating a missing task with a version, found %v", err)
+		return
+	}
+}
+
+func testDeleteVersion(t *testing.T, ds data.Datastore) {
+	id := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+	task := ds.GetTask(ctx, id)
+	if _, err := ds.UpdateTask(ctx, ownerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+
+	if deleted, err := ds.DeleteTask(ctx, ownerId, id, 1); deleted || err != data.ErrVersionConflict {
+		t.Errorf("Expected ErrVersionConflict deleting an old version, found %v", err)
+		return
+	}
+	if nil == ds.GetTask(ctx, id) {
+		t.Errorf("Expected task %s to remain after a conflicting delete", id)
+		return
+	}
+	if deleted, err := ds.DeleteTask(ctx, ownerId, id, 2); !deleted || nil != err {
+		t.Errorf("Expected delete of the current version to succeed, found %v", err)
+		return
+	}
+	if deleted, err := ds.DeleteTask(ctx, ownerId, id, 2); deleted || nil != err {
+		t.Errorf("Expected delete of a missing task to do nothing, found %v", err)
+		return
+	}
+}
+
+func testTrash(t *testing.T, ds data.Datastore) {
+	keepId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+	id := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", ACL: model.ReaderGrants([]int{otherOwnerId})})
+
+	if !deleteTask(t, ds, ownerId, id) {
+		t.Errorf("Expected delete of task %s to succeed", id)
+		return
+	}
+
+	// hidden from every listing
+	if tasks := getTasks(t, ds, ownerId); len(tasks) != 1 || tasks[0].Id() != keepId {
+		t.Errorf("Expected deleted task %s to be hidden from GetTasks", id)
+		return
+	}
+	page, err := ds.FindTasks(ctx, ownerId, model.Task{Title: "Test Task"}, data.ListOptions{})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if !sameIds(page.Tasks, []string{keepId}) {
+		t.Errorf("Expected deleted task %s to be hidden from FindTasks", id)
+		return
+	}
+	page, err = ds.GetOthersTasks(ctx, otherOwnerId, data.ListOptions{})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(page.Tasks) != 0 {
+		t.Errorf("Expected deleted task %s to be hidden from GetOthersTasks", id)
+		return
+	}
+	if c := ds.CountTasks(ctx, ownerId); c != 1 {
+		t.Errorf("Expected deleted task %s not to be counted, found %d tasks", id, c)
+		return
+	}
+
+	// but listed in the owners trash, and no one elses
+	page, err = ds.GetTrash(ctx, ownerId, data.ListOptions{})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if !sameIds(page.Tasks, []string{id}) || nil == page.Tasks[0].Deleted {
+		t.Errorf("Expected the trash to hold task %s, found %v", id, taskIds(page.Tasks))
+		return
+	}
+	page, err = ds.GetTrash(ctx, otherOwnerId, data.ListOptions{})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(page.Tasks) != 0 {
+		t.Errorf("Expected an empty trash for owner %d, found %d tasks", otherOwnerId, len(page.Tasks))
+		return
+	}
+
+	// a task in the trash can't be updated, only restored by its owner
+	task := ds.GetTask(ctx, id)
+	if nil == task || nil == task.Deleted {
+		t.Errorf("Expected task %s to be marked as deleted", id)
+		return
+	}
+	task.Deleted = nil
+	if _, err := ds.UpdateTask(ctx, ownerId, *task); nil == err {
+		t.Errorf("Expected error updating task %s in the trash", id)
+		return
+	}
+	if restored, err := ds.RestoreTask(ctx, otherOwnerId, id); restored || nil != err {
+		t.Errorf("Expected restore of task %s by owner %d to fail, found %v", id, otherOwnerId, err)
+		return
+	}
+	if restored, err := ds.RestoreTask(ctx, ownerId, id); !restored || nil != err {
+		t.Errorf("Expected restore of task %s to succeed, found %v", id, err)
+		return
+	}
+	if restored, err := ds.RestoreTask(ctx, ownerId, keepId); restored || nil != err {
+		t.Errorf("Expected restore of task %s, not in the trash, to fail, found %v", keepId, err)
+		return
+	}
+	if tasks := getTasks(t, ds, ownerId); len(tasks) != 2 {
+		t.Errorf("Expected restored task %s to be listed, found %d tasks", id, len(tasks))
+		return
+	}
+	task = ds.GetTask(ctx, id)
+	if nil == task || nil != task.Deleted || task.Title != "Test Task" {
+		t.Errorf("Expected task %s to be unchanged by restoring it", id)
+		return
+	}
+}
+
+func testPurgeTrash(t *testing.T, ds data.Datastore) {
+	keepId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Labels: []string{"kept"}})
+	id := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Labels: []string{"purged"}})
+	deleteTask(t, ds, ownerId, id)
+
+	// nothing was deleted before an hour ago
+	if n, err := ds.PurgeTrash(ctx, time.Now().Add(-time.Hour)); nil != err || n != 0 {
+		t.Errorf("Expected nothing to be purged, found %d, %v", n, err)
+		return
+	}
+	if nil == ds.GetTask(ctx, id) {
+		t.Errorf("Expected task %s to remain in the trash", id)
+		return
+	}
+
+	if n, err := ds.PurgeTrash(ctx, time.Now().Add(time.Second)); nil != err || n != 1 {
+		t.Errorf("Expected one task to be purged, found %d, %v", n, err)
+		return
+	}
+	if nil != ds.GetTask(ctx, id) {
+		t.Errorf("Expected task %s to be purged", id)
+		return
+	}
+	if restored, err := ds.RestoreTask(ctx, ownerId, id); restored || nil != err {
+		t.Errorf("Expected purged task %s not to be restored, found %v", id, err)
+		return
+	}
+	if task := ds.GetTask(ctx, keepId); nil == task || len(task.Labels) != 1 {
+		t.Errorf("Expected task %s not in the trash to be kept", keepId)
+		return
+	}
+}
+
+func testArchive(t *testing.T, ds data.Datastore) {
+	now := time.Now()
+	oldId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Labels: []string{"old"},
+		ACL: model.ReaderGrants([]int{otherOwnerId}), Expires: now.Add(-time.Hour * 240)})
+	recentId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Expires: now.Add(-time.Hour * 24)})
+	futureId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Expires: now.Add(time.Hour * 24)})
+	noExpiryId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+	trashedId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Expires: now.Add(-time.Hour * 240)})
+	deleteTask(t, ds, ownerId, trashedId)
+
+	// only the task expired before the cutoff, outside the trash, is archived
+	if n, err := ds.ArchiveTasks(ctx, now.Add(-time.Hour*120)); nil != err || n != 1 {
+		t.Errorf("Expected one task to be archived, found %d, %v", n, err)
+		return
+	}
+	task := ds.GetTask(ctx, oldId)
+	if nil == task || nil == task.Archived || task.Version != 2 {
+		t.Errorf("Expected task %s to be marked as archived at version 2", oldId)
+		return
+	}
+	if task = ds.GetTask(ctx, trashedId); nil == task || nil != task.Archived {
+		t.Errorf("Expected task %s in the trash not to be archived", trashedId)
+		return
+	}
+
+	// hidden from every listing
+	if tasks := getTasks(t, ds, ownerId); !sameIds(tasks, []string{futureId, recentId, noExpiryId}) {
+		t.Errorf("Expected archived task %s to be hidden from GetTasks, found %v", oldId, taskIds(tasks))
+		return
+	}
+	page, err := ds.FindTasks(ctx, ownerId, model.Task{Labels: []string{"old"}}, data.ListOptions{})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(page.Tasks) != 0 {
+		t.Errorf("Expected archived task %s to be hidden from FindTasks", oldId)
+		return
+	}
+	page, err = ds.GetOthersTasks(ctx, otherOwnerId, data.ListOptions{})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(page.Tasks) != 0 {
+		t.Errorf("Expected archived task %s to be hidden from GetOthersTasks", oldId)
+		return
+	}
+	if c := ds.CountTasks(ctx, ownerId); c != 3 {
+		t.Errorf("Expected archived task %s not to be counted, found %d tasks", oldId, c)
+		return
+	}
+
+	// but found in the owners archive, and no one elses
+	tests := []struct {
+		query model.Task
+		ids   []string
+	}{
+		{model.Task{}, []string{oldId}},
+		{model.Task{Title: "Test Task", Labels: []string{"old"}}, []string{oldId}},
+		{model.Task{Title: "Other Task"}, nil},
+		{model.Task{Labels: []string{"missing"}}, nil},
+	}
+	for _, tt := range tests {
+		page, err = ds.GetArchive(ctx, ownerId, tt.query, data.ListOptions{})
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		if !sameIds(page.Tasks, tt.ids) {
+			t.Errorf("Expected archive query %+v to find %v, found %v", tt.query, tt.ids, taskIds(page.Tasks))
+			return
+		}
+	}
+	page, err = ds.GetArchive(ctx, otherOwnerId, model.Task{}, data.ListOptions{})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(page.Tasks) != 0 {
+		t.Errorf("Expected an empty archive for owner %d, found %d tasks", otherOwnerId, len(page.Tasks))
+		return
+	}
+
+	// an archived task can't be updated
+	task = ds.GetTask(ctx, oldId)
+	task.Archived = nil
+	task.Title = "changed"
+	if _, err := ds.UpdateTask(ctx, ownerId, *task); nil == err {
+		t.Errorf("Expected error updating archived task %s", oldId)
+		return
+	}
+
+	// a deleted archived task is only in the trash
+	deleteTask(t, ds, ownerId, oldId)
+	page, err = ds.GetArchive(ctx, ownerId, model.Task{}, data.ListOptions{})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(page.Tasks) != 0 {
+		t.Errorf("Expected deleted task %s to be hidden from the archive", oldId)
+		return
+	}
+	page, err = ds.GetTrash(ctx, ownerId, data.ListOptions{})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(page.Tasks) != 2 {
+		t.Errorf("Expected deleted archived task %s in the trash, found %v", oldId, taskIds(page.Tasks))
+		return
+	}
+}
+
+func testUnarchive(t *testing.T, ds data.Datastore) {
+	now := time.Now()
+	id := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Expires: now.Add(-time.Hour * 240)})
+	keepId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+	if n, err := ds.ArchiveTasks(ctx, now); nil != err || n != 1 {
+		t.Errorf("Expected one task to be archived, found %d, %v", n, err)
+		return
+	}
+
+	if unarchived, err := ds.UnarchiveTask(ctx, otherOwnerId, id); unarchived || nil != err {
+		t.Errorf("Expected unarchive of task %s by owner %d to fail, found %v", id, otherOwnerId, err)
+		return
+	}
+	if unarchived, err := ds.UnarchiveTask(ctx, ownerId, keepId); unarchived || nil != err {
+		t.Errorf("Expected unarchive of task %s, not archived, to fail, found %v", keepId, err)
+		return
+	}
+	if unarchived, err := ds.UnarchiveTask(ctx, ownerId, id); !unarchived || nil != err {
+		t.Errorf("Expected unarchive of task %s to succeed, found %v", id, err)
+		return
+	}
+	task := findTask(t, ds, ownerId, id)
+	if nil == task || nil != task.Archived || nil == task.Unarchived || task.Version != 3 {
+		t.Errorf("Expected task %s to be listed as unarchived at version 3", id)
+		return
+	}
+
+	// it isn't archived again, until it expires again
+	if n, err := ds.ArchiveTasks(ctx, now); nil != err || n != 0 {
+		t.Errorf("Expected unarchived task %s not to be archived again, found %d, %v", id, n, err)
+		return
+	}
+	task.Title = "changed"
+	task.Unarchived = nil
+	if _, err := ds.UpdateTask(ctx, ownerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+	if task = ds.GetTask(ctx, id); nil == task || task.Title != "changed" || nil == task.Unarchived {
+		t.Errorf("Expected task %s to keep its unarchived time when updated", id)
+		return
+	}
+	task.Expires = time.Now().Add(time.Second)
+	if _, err := ds.UpdateTask(ctx, ownerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+	if n, err := ds.ArchiveTasks(ctx, time.Now().Add(time.Minute)); nil != err || n != 1 {
+		t.Errorf("Expected task %s to be archived once it expired again, found %d, %v", id, n, err)
+		return
+	}
+}
+
+func testRecurrences(t *testing.T, ds data.Datastore) {
+	now := time.Now()
+	id := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Recurrence: "FREQ=DAILY",
+		Expires: now.Add(-time.Hour)})
+	earlierId := addTask(t, ds, model.Task{Owner: otherOwnerId, Title: "Test Task", Recurrence: "FREQ=WEEKLY",
+		Expires: now.Add(-time.Hour * 2)})
+	addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Recurrence: "FREQ=DAILY", Expires: now.Add(time.Hour)})
+	addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Expires: now.Add(-time.Hour)})
+	addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Recurrence: "FREQ=DAILY"})
+	trashedId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Recurrence: "FREQ=DAILY",
+		Expires: now.Add(-time.Hour)})
+	deleteTask(t, ds, ownerId, trashedId)
+
+	// only the expired recurring tasks outside the trash are due, earliest first
+	due, err := ds.DueRecurrences(ctx, now, 10)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if !sameIds(due, []string{earlierId, id}) || due[1].Recurrence != "FREQ=DAILY" {
+		t.Errorf("Expected tasks %s and %s to be due to recur, found %v", earlierId, id, taskIds(due))
+		return
+	}
+	if due, err = ds.DueRecurrences(ctx, now, 1); nil != err || !sameIds(due, []string{earlierId}) {
+		t.Errorf("Expected the earliest task %s to be due first, found %v, %v", earlierId, taskIds(due), err)
+		return
+	}
+
+	// marked once, only at its expiry, without changing its version
+	task := ds.GetTask(ctx, id)
+	if marked, err := ds.MarkRecurred(ctx, id, task.Expires.Add(time.Minute)); marked || nil != err {
+		t.Errorf("Expected marking task %s at another expiry to fail, found %v", id, err)
+		return
+	}
+	if marked, err := ds.MarkRecurred(ctx, id, task.Expires); !marked || nil != err {
+		t.Errorf("Expected marking task %s to succeed, found %v", id, err)
+		return
+	}
+	if marked, err := ds.MarkRecurred(ctx, id, task.Expires); marked || nil != err {
+		t.Errorf("Expected marking task %s again to fail, found %v", id, err)
+		return
+	}
+	marked := ds.GetTask(ctx, id)
+	if nil == marked || nil == marked.Recurred || marked.Version != task.Version {
+		t.Errorf("Expected task %s to be marked as recurred at version %d", id, task.Version)
+		return
+	}
+	if due, err = ds.DueRecurrences(ctx, now, 10); nil != err || !sameIds(due, []string{earlierId}) {
+		t.Errorf("Expected task %s to no longer be due, found %v, %v", id, taskIds(due), err)
+		return
+	}
+
+	// an update keeps the mark, until the task expires later
+	task.Title = "changed"
+	if _, err := ds.UpdateTask(ctx, ownerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+	if due, err = ds.DueRecurrences(ctx, now, 10); nil != err || !sameIds(due, []string{earlierId}) {
+		t.Errorf("Expected updated task %s to keep its mark, found %v, %v", id, taskIds(due), err)
+		return
+	}
+	task = ds.GetTask(ctx, id)
+	task.Expires = now.Add(-time.Minute)
+	if _, err := ds.UpdateTask(ctx, ownerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+	if due, err = ds.DueRecurrences(ctx, now, 10); nil != err || !sameIds(due, []string{earlierId, id}) {
+		t.Errorf("Expected task %s to be due again once expiring later, found %v, %v", id, taskIds(due), err)
+		return
+	}
+
+	// clearing the mark makes it due again
+	if marked, err := ds.MarkRecurred(ctx, earlierId, due[0].Expires); !marked || nil != err {
+		t.Errorf("Expected marking task %s to succeed, found %v", earlierId, err)
+		return
+	}
+	if cleared, err := ds.MarkRecurred(ctx, earlierId, time.Time{}); !cleared || nil != err {
+		t.Errorf("Expected clearing the mark of task %s to succeed, found %v", earlierId, err)
+		return
+	}
+	if task = ds.GetTask(ctx, earlierId); nil == task || nil != task.Recurred {
+		t.Errorf("Expected task %s to have its mark cleared", earlierId)
+		return
+	}
+}
+
+func testGetTasks(t *testing.T, ds data.Datastore) {
+	now := time.Now()
+	firstId := addTask(t, ds, model.Task{Owner: ownerId, Title: "first", Expires: now.Add(time.Hour)})
+	laterId := addTask(t, ds, model.Task{Owner: ownerId, Title: "later", Expires: now.Add(time.Hour * 2)})
+	addTask(t, ds, model.Task{Owner: otherOwnerId, Title: "Someone elses business"})
+
+	tasks := getTasks(t, ds, ownerId)
+	if len(tasks) != 2 {
+		t.Errorf("Expecting result on GetTasks for owner %d to be 2, found %d", ownerId, len(tasks))
+		return
+	}
+	if tasks[0].Id() != laterId || tasks[1].Id() != firstId {
+		t.Errorf("Expecting tasks sorted by expires, latest first")
+		return
+	}
+
+	page, err := ds.GetTasks(ctx, 456, data.ListOptions{})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if nil != page.Tasks || page.Next != "" {
+		t.Errorf("Expecting nil result on GetTasks for unknown owner, found %d tasks", len(page.Tasks))
+		return
+	}
+}
+
+func testGetOthersTasks(t *testing.T, ds data.Datastore) {
+	addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+	otherId := addTask(t, ds, model.Task{Owner: otherOwnerId, Title: "Someone elses business"})
+
+	page, err := ds.GetOthersTasks(ctx, ownerId, data.ListOptions{})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(page.Tasks) != 0 {
+		t.Errorf("Expecting empty list of others tasks, found %d items", len(page.Tasks))
+		return
+	}
+
+	task := findTask(t, ds, otherOwnerId, otherId)
+	task.ACL = append(task.ACL, model.Grant{User: ownerId, Role: model.RoleReader})
+	if _, err := ds.UpdateTask(ctx, otherOwnerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+
+	page, err = ds.GetOthersTasks(ctx, ownerId, data.ListOptions{})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(page.Tasks) != 1 || page.Tasks[0].Id() != otherId {
+		t.Errorf("Expecting others tasks to contain task %s, found %d items", otherId, len(page.Tasks))
+		return
+	}
+}
+
+func testSharing(t *testing.T, ds data.Datastore) {
+	const readerId, editorId, adminId, expiredId, strangerId = 1, 2, 3, 4, 5
+	yesterday := time.Now().Add(-24 * time.Hour)
+	for _, acl := range [][]model.Grant{
+		{{User: readerId, Role: "owner"}},
+		{{User: ownerId, Role: model.RoleEditor}},
+		{{User: readerId, Role: model.RoleReader}, {User: readerId, Role: model.RoleEditor}},
+	} {
+		_, err := ds.AddTask(ctx, ownerId, model.Task{Owner: ownerId, Title: "Test Task", ACL: acl})
+		if !errors.Is(err, data.ErrInvalidACL) {
+			t.Errorf("Expected a task shared as %+v to be refused, found %v", acl, err)
+			return
+		}
+	}
+	id := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", ACL: []model.Grant{
+		{User: readerId, Role: model.RoleReader},
+		{User: editorId, Role: model.RoleEditor},
+		{User: adminId, Role: model.RoleAdmin},
+		{User: expiredId, Role: model.RoleEditor, Expires: &yesterday},
+	}})
+
+	for user, shared := range map[int]bool{readerId: true, editorId: true, adminId: true, expiredId: false, strangerId: false} {
+		page, err := ds.GetOthersTasks(ctx, user, data.ListOptions{})
+		if nil != err || (len(page.Tasks) == 1) != shared {
+			t.Errorf("Expected task %s shared with user %d %v, found %d tasks, %v", id, user, shared, len(page.Tasks), err)
+			return
+		}
+	}
+
+	// editors may change the task, but not who it is shared with, nor who owns it
+	task := ds.GetTask(ctx, id)
+	task.Title = "changed"
+	for _, user := range []int{readerId, expiredId, strangerId} {
+		if _, err := ds.UpdateTask(ctx, user, *task); !errors.Is(err, data.ErrAccessDenied) {
+			t.Errorf("Expected user %d not to update task %s, found %v", user, id, err)
+			return
+		}
+	}
+	if _, err := ds.SetTaskStatus(ctx, readerId, id, model.StatusInProgress, 0); !errors.Is(err, data.ErrAccessDenied) {
+		t.Errorf("Expected a reader not to change the status of task %s, found %v", id, err)
+		return
+	}
+	version, err := ds.UpdateTask(ctx, editorId, *task)
+	if nil != err || version != 2 {
+		t.Errorf("Expected an editor to update task %s, found version %d, %v", id, version, err)
+		return
+	}
+	task = ds.GetTask(ctx, id)
+	if task.Title != "changed" || task.Owner != ownerId || len(task.ACL) != 4 {
+		t.Errorf("Expected the editors change, keeping the owner and ACL, found %+v", task)
+		return
+	}
+	if version, err = ds.SetTaskStatus(ctx, editorId, id, model.StatusInProgress, 0); nil != err || version != 3 {
+		t.Errorf("Expected an editor to change the status of task %s, found version %d, %v", id, version, err)
+		return
+	}
+	task = ds.GetTask(ctx, id)
+	shared := *task
+	shared.ACL = task.ACL[1:]
+	if _, err := ds.UpdateTask(ctx, editorId, shared); !errors.Is(err, data.ErrAccessDenied) {
+		t.Errorf("Expected an editor not to change who task %s is shared with, found %v", id, err)
+		return
+	}
+	given := *task
+	given.Owner = adminId
+	given.ACL = nil
+	if _, err := ds.UpdateTask(ctx, adminId, given); !errors.Is(err, data.ErrAccessDenied) {
+		t.Errorf("Expected an admin not to take task %s from its owner, found %v", id, err)
+		return
+	}
+	if deleteTask(t, ds, editorId, id) {
+		t.Errorf("Expected an editor not to delete task %s", id)
+		return
+	}
+
+	// admins may also change who it is shared with, and delete it
+	if _, err := ds.UpdateTask(ctx, adminId, shared); nil != err {
+		t.Errorf("Expected an admin to change who task %s is shared with, found %v", id, err)
+		return
+	}
+	if page, err := ds.GetOthersTasks(ctx, readerId, data.ListOptions{}); nil != err || len(page.Tasks) != 0 {
+		t.Errorf("Expected task %s no longer shared with user %d, found %d tasks, %v", id, readerId, len(page.Tasks), err)
+		return
+	}
+	if !deleteTask(t, ds, adminId, id) {
+		t.Errorf("Expected an admin to delete task %s", id)
+		return
+	}
+	if restored, err := ds.RestoreTask(ctx, editorId, id); nil != err || restored {
+		t.Errorf("Expected an editor not to restore task %s, found %v, %v", id, restored, err)
+		return
+	}
+	if restored, err := ds.RestoreTask(ctx, adminId, id); nil != err || !restored {
+		t.Errorf("Expected an admin to restore task %s, found %v, %v", id, restored, err)
+	}
+}
+
+func testGroupSharing(t *testing.T, ds data.Datastore) {
+	const memberId = 1
+	for _, acl := range [][]model.Grant{
+		{{Group: "team", Role: model.RoleEditor}},
+		{{Group: "team", User: memberId, Role: model.RoleReader}},
+		{{Group: "team", Role: model.RoleReader}, {Group: "team", Role: model.RoleReader}},
+	} {
+		_, err := ds.AddTask(ctx, ownerId, model.Task{Owner: ownerId, Title: "Test Task", ACL: acl})
+		if !errors.Is(err, data.ErrInvalidACL) {
+			t.Errorf("Expected a task shared as %+v to be refused, found %v", acl, err)
+			return
+		}
+	}
+	yesterday := time.Now().Add(-24 * time.Hour)
+	teamId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task",
+		ACL: []model.Grant{{Group: "team", Role: model.RoleReader}}})
+	bothId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", ACL: []model.Grant{
+		{Group: "team", Role: model.RoleReader}, {Group: "other", Role: model.RoleReader}, {User: memberId, Role: model.RoleEditor}}})
+	addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task",
+		ACL: []model.Grant{{Group: "team", Role: model.RoleReader, Expires: &yesterday}}})
+
+	tests := []struct {
+		groups []string
+		want   []string
+	}{
+		{nil, []string{bothId}},
+		{[]string{"other"}, []string{bothId}},
+		{[]string{"team", "other"}, []string{bothId, teamId}},
+	}
+	for _, tt := range tests {
+		opts := data.ListOptions{Groups: tt.groups, Sort: data.Sort{{Field: "_id", Desc: true}}}
+		page, err := ds.GetOthersTasks(ctx, memberId, opts)
+		if nil != err || !sameIds(page.Tasks, tt.want) {
+			t.Errorf("Expected tasks %v shared with user %d in groups %v, found %v, %v", tt.want, memberId, tt.groups,
+				taskIds(page.Tasks), err)
+			return
+		}
+	}
+
+	// a member of a group is only ever a reader through it
+	task := ds.GetTask(ctx, teamId)
+	task.Title = "changed"
+	if _, err := ds.UpdateTask(ctx, memberId, *task); !errors.Is(err, data.ErrAccessDenied) {
+		t.Errorf("Expected a group member not to update task %s, found %v", teamId, err)
+		return
+	}
+
+	page, err := ds.FindTasks(ctx, ownerId, model.Task{ACL: []model.Grant{{Group: "other"}}}, data.ListOptions{})
+	if nil != err || len(page.Tasks) != 1 || page.Tasks[0].Id() != bothId {
+		t.Errorf("Expected to find task %s shared with group other, found %d tasks, %v", bothId, len(page.Tasks), err)
+	}
+}
+
+func testFindTasks(t *testing.T, ds data.Datastore) {
+	expires := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	firstId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Expires: expires})
+	secondId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Second task", Expires: expires.AddDate(0, 0, 1)})
+	addTask(t, ds, model.Task{Owner: otherOwnerId, Title: "Test Task"})
+
+	tests := []struct {
+		query model.Task
+		ids   []string
+	}{
+		{model.Task{Title: "Test Task"}, []string{firstId}},
+		{model.Task{Title: "Second task"}, []string{secondId}},
+		{model.Task{Title: "doesn't exist"}, nil},
+		{model.Task{Owner: ownerId}, []string{secondId, firstId}},
+		// expires is strictly before
+		{model.Task{Expires: expires.AddDate(0, 0, 1)}, []string{firstId}},
+		{model.Task{Expires: expires}, nil},
+		// created is on or after
+		{model.Task{Created: time.Now().Add(-time.Hour)}, []string{secondId, firstId}},
+		{model.Task{Created: time.Now().Add(time.Hour)}, nil},
+	}
+	for _, tt := range tests {
+		page, err := ds.FindTasks(ctx, ownerId, tt.query, data.ListOptions{})
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		if !sameIds(page.Tasks, tt.ids) {
+			t.Errorf("Expected query %+v to find tasks %v, found %v", tt.query, tt.ids, taskIds(page.Tasks))
+		}
+	}
+}
+
+func testFindTasksArrays(t *testing.T, ds data.Datastore) {
+	firstId := addTask(t, ds, model.Task{Owner: ownerId, Title: "first",
+		Labels: []string{"myLabel"}, Notes: []string{"a note"}, ACL: model.ReaderGrants([]int{1})})
+	secondId := addTask(t, ds, model.Task{Owner: ownerId, Title: "second", Expires: time.Now(),
+		Labels: []string{"myLabel", "other"}, Notes: []string{"a note", "another"},
+		ACL: []model.Grant{{User: 1, Role: model.RoleEditor}, {User: 2, Role: model.RoleReader}}})
+
+	tests := []struct {
+		query model.Task
+		ids   []string
+	}{
+		{model.Task{Labels: []string{"myLabel"}}, []string{secondId, firstId}},
+		{model.Task{Labels: []string{"other", "myLabel"}}, []string{secondId}},
+		{model.Task{Labels: []string{"myLabel", "missing"}}, nil},
+		{model.Task{Notes: []string{"a note"}}, []string{secondId, firstId}},
+		{model.Task{Notes: []string{"another"}}, []string{secondId}},
+		{model.Task{ACL: []model.Grant{{User: 1}}}, []string{secondId, firstId}},
+		{model.Task{ACL: []model.Grant{{User: 1}, {User: 2}}}, []string{secondId}},
+		{model.Task{ACL: model.ReaderGrants([]int{1})}, []string{firstId}},
+		{model.Task{ACL: []model.Grant{{User: 3}}}, nil},
+		{model.Task{Title: "first", Labels: []string{"myLabel"}}, []string{firstId}},
+	}
+	for _, tt := range tests {
+		page, err := ds.FindTasks(ctx, ownerId, tt.query, data.ListOptions{})
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		if !sameIds(page.Tasks, tt.ids) {
+			t.Errorf("Expected query %+v to find tasks %v, found %v", tt.query, tt.ids, taskIds(page.Tasks))
+		}
+	}
+}
+
+func testCountTasks(t *testing.T, ds data.Datastore) {
+	if c := ds.CountTasks(ctx, ownerId); c != 0 {
+		t.Errorf("Expected no tasks in empty store, found %d", c)
+		return
+	}
+	addTask(t, ds, model.Task{Owner: ownerId, Title: "one"})
+	addTask(t, ds, model.Task{Owner: ownerId, Title: "two"})
+	addTask(t, ds, model.Task{Owner: otherOwnerId, Title: "other"})
+
+	if c := ds.CountTasks(ctx, ownerId); c != 2 {
+		t.Errorf("Expected 2 tasks for owner %d, found %d", ownerId, c)
+		return
+	}
+	if c := ds.CountTasks(ctx, otherOwnerId); c != 1 {
+		t.Errorf("Expected 1 task for owner %d, found %d", otherOwnerId, c)
+		return
+	}
+}
+
+func testUsers(t *testing.T, ds data.Datastore) {
+	users, err := ds.Users(ctx)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(users) != 0 {
+		t.Errorf("Expected no users in empty store, found %v", users)
+		return
+	}
+
+	addTask(t, ds, model.Task{Owner: ownerId, Title: "one"})
+	addTask(t, ds, model.Task{Owner: ownerId, Title: "two"})
+	addTask(t, ds, model.Task{Owner: otherOwnerId, Title: "other"})
+
+	users, err = ds.Users(ctx)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(users) != 2 || !containsInt(users, ownerId) || !containsInt(users, otherOwnerId) {
+		t.Errorf("Expected users %d and %d, found %v", ownerId, otherOwnerId, users)
+		return
+	}
+}
+
+func testPagination(t *testing.T, ds data.Datastore) {
+	// many tasks sharing the same expires must still page in a stable order
+	expires := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	var ids []string
+	for i := 0; i < 7; i++ {
+		e := expires
+		if i%3 == 0 {
+			e = expires.Add(time.Minute)
+		}
+		ids = append(ids, addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Expires: e}))
+	}
+	addTask(t, ds, model.Task{Owner: otherOwnerId, Title: "Someone elses business", Expires: expires})
+
+	all := getTasks(t, ds, ownerId)
+	if len(all) != len(ids) {
+		t.Errorf("Expected %d tasks, found %d", len(ids), len(all))
+		return
+	}
+
+	var paged []*model.Task
+	opts := data.ListOptions{Limit: 2}
+	for pages := 1; ; pages++ {
+		page, err := ds.GetTasks(ctx, ownerId, opts)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		if len(page.Tasks) > opts.Limit {
+			t.Errorf("Expected no more than %d tasks on page %d, found %d", opts.Limit, pages, len(page.Tasks))
+			return
+		}
+		paged = append(paged, page.Tasks...)
+		if page.Next == "" {
+			break
+		}
+		if pages > len(ids) {
+			t.Errorf("Expected paging to end after %d pages", len(ids))
+			return
+		}
+		opts.Cursor = page.Next
+	}
+	if !sameIds(paged, taskIds(all)) {
+		t.Errorf("Expected pages to hold %v, found %v", taskIds(all), taskIds(paged))
+		return
+	}
+
+	// a full last page has no next page
+	page, err := ds.GetTasks(ctx, ownerId, data.ListOptions{Limit: len(ids)})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(page.Tasks) != len(ids) || page.Next != "" {
+		t.Errorf("Expected a single page of %d tasks, found %d with next %q", len(ids), len(page.Tasks), page.Next)
+		return
+	}
+}
+
+func testPaginationFind(t *testing.T, ds data.Datastore) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		ids = append(ids, addTask(t, ds, model.Task{Owner: ownerId, Title: "found", Expires: expires}))
+		addTask(t, ds, model.Task{Owner: ownerId, Title: "missed", Expires: expires})
+	}
+
+	var paged []*model.Task
+	opts := data.ListOptions{Limit: 2}
+	for {
+		page, err := ds.FindTasks(ctx, ownerId, model.Task{Title: "found"}, opts)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		paged = append(paged, page.Tasks...)
+		if page.Next == "" || len(paged) > len(ids) {
+			break
+		}
+		opts.Cursor = page.Next
+	}
+	if !sameIds(paged, ids) {
+		t.Errorf("Expected pages to hold %v, found %v", ids, taskIds(paged))
+		return
+	}
+}
+
+func testInvalidCursor(t *testing.T, ds data.Datastore) {
+	addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+
+	opts := data.ListOptions{Cursor: "not a cursor"}
+	if _, err := ds.GetTasks(ctx, ownerId, opts); err != data.ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor from GetTasks, found %v", err)
+	}
+	if _, err := ds.GetOthersTasks(ctx, ownerId, opts); err != data.ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor from GetOthersTasks, found %v", err)
+	}
+	if _, err := ds.FindTasks(ctx, ownerId, model.Task{Title: "Test Task"}, opts); err != data.ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor from FindTasks, found %v", err)
+	}
+}
+
+func testSort(t *testing.T, ds data.Datastore) {
+	now := time.Now().Truncate(time.Millisecond)
+	bId := addTask(t, ds, model.Task{Owner: ownerId, Title: "b", Expires: now.Add(time.Hour)})
+	aId := addTask(t, ds, model.Task{Owner: ownerId, Title: "a", Expires: now.Add(time.Hour * 2)})
+	cId := addTask(t, ds, model.Task{Owner: ownerId, Title: "c", Expires: now.Add(time.Hour)})
+
+	tests := []struct {
+		sort string
+		ids  []string
+	}{
+		{"", []string{aId, bId, cId}},
+		{"title", []string{aId, bId, cId}},
+		{"-title", []string{cId, bId, aId}},
+		{"expires,-title", []string{cId, bId, aId}},
+		{"-created", []string{cId, aId, bId}},
+		{"_id", []string{bId, aId, cId}},
+	}
+	for _, tt := range tests {
+		sort, err := data.ParseSort(tt.sort)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		page, err := ds.GetTasks(ctx, ownerId, data.ListOptions{Sort: sort})
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		if !sameIds(page.Tasks, tt.ids) {
+			t.Errorf("Expected sort %q to list tasks %v, found %v", tt.sort, tt.ids, taskIds(page.Tasks))
+		}
+	}
+}
+
+func testSortPagination(t *testing.T, ds data.Datastore) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	for i := 0; i < 9; i++ {
+		addTask(t, ds, model.Task{Owner: ownerId, Title: fmt.Sprintf("task %d", i%3), Expires: expires.Add(time.Minute * time.Duration(i%2))})
+	}
+
+	sort, err := data.ParseSort("-title,expires")
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	all, err := ds.GetTasks(ctx, ownerId, data.ListOptions{Sort: sort})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	for i := 1; i < len(all.Tasks); i++ {
+		if all.Tasks[i-1].Title < all.Tasks[i].Title {
+			t.Errorf("Expected tasks sorted by title, descending, found %q before %q", all.Tasks[i-1].Title, all.Tasks[i].Title)
+			return
+		}
+	}
+
+	var paged []*model.Task
+	opts := data.ListOptions{Limit: 2, Sort: sort}
+	for {
+		page, err := ds.GetTasks(ctx, ownerId, opts)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		paged = append(paged, page.Tasks...)
+		if page.Next == "" || len(paged) > len(all.Tasks) {
+			break
+		}
+		opts.Cursor = page.Next
+	}
+	if !sameIds(paged, taskIds(all.Tasks)) {
+		t.Errorf("Expected pages to hold %v, found %v", taskIds(all.Tasks), taskIds(paged))
+		return
+	}
+
+	// a cursor only continues the listing with the sort it was created with
+	page, err := ds.GetTasks(ctx, ownerId, data.ListOptions{Limit: 2, Sort: sort})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := ds.GetTasks(ctx, ownerId, data.ListOptions{Cursor: page.Next}); err != data.ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor using a cursor with a different sort, found %v", err)
+		return
+	}
+}
+
+func testCancelledContext(t *testing.T, ds data.Datastore) {
+	id := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+	task := findTask(t, ds, ownerId, id)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if _, err := ds.GetTasks(cancelled, ownerId, data.ListOptions{}); nil == err {
+		t.Errorf("Expected error from GetTasks with a cancelled context")
+	}
+	if _, err := ds.GetOthersTasks(cancelled, ownerId, data.ListOptions{}); nil == err {
+		t.Errorf("Expected error from GetOthersTasks with a cancelled context")
+	}
+	if _, err := ds.FindTasks(cancelled, ownerId, model.Task{Title: "Test Task"}, data.ListOptions{}); nil == err {
+		t.Errorf("Expected error from FindTasks with a cancelled context")
+	}
+	if _, err := ds.GetTrash(cancelled, ownerId, data.ListOptions{}); nil == err {
+		t.Errorf("Expected error from GetTrash with a cancelled context")
+	}
+	if _, err := ds.PurgeTrash(cancelled, time.Now()); nil == err {
+		t.Errorf("Expected error from PurgeTrash with a cancelled context")
+	}
+	if _, err := ds.GetArchive(cancelled, ownerId, model.Task{}, data.ListOptions{}); nil == err {
+		t.Errorf("Expected error from GetArchive with a cancelled context")
+	}
+	if _, err := ds.ArchiveTasks(cancelled, time.Now()); nil == err {
+		t.Errorf("Expected error from ArchiveTasks with a cancelled context")
+	}
+	if _, err := ds.GetSubtasks(cancelled, id, data.ListOptions{}); nil == err {
+		t.Errorf("Expected error from GetSubtasks with a cancelled context")
+	}
+	if _, err := ds.GetDependents(cancelled, id, data.ListOptions{}); nil == err {
+		t.Errorf("Expected error from GetDependents with a cancelled context")
+	}
+	if _, err := ds.DueRecurrences(cancelled, time.Now(), 10); nil == err {
+		t.Errorf("Expected error from DueRecurrences with a cancelled context")
+	}
+	if _, err := ds.Users(cancelled); nil == err {
+		t.Errorf("Expected error from Users with a cancelled context")
+	}
+	if c := ds.CountTasks(cancelled, ownerId); c >= 0 {
+		t.Errorf("Expected negative count with a cancelled context, found %d", c)
+	}
+	if _, err := ds.AddTask(cancelled, ownerId, model.Task{Owner: ownerId, Title: "cancelled"}); nil == err {
+		t.Errorf("Expected error from AddTask with a cancelled context")
+	}
+	task.Title = "changed"
+	if _, err := ds.UpdateTask(cancelled, ownerId, *task); nil == err {
+		t.Errorf("Expected error from UpdateTask with a cancelled context")
+	}
+	if deleted, _ := ds.DeleteTask(cancelled, ownerId, id, 0); deleted {
+		t.Errorf("Expected DeleteTask to fail with a cancelled context")
+	}
+	if version, _ := ds.SetTaskStatus(cancelled, ownerId, id, model.StatusDone, 0); version != 0 {
+		t.Errorf("Expected SetTaskStatus to fail with a cancelled context")
+	}
+
+	// and nothing was changed
+	tasks := getTasks(t, ds, ownerId)
+	if len(tasks) != 1 || tasks[0].Title != "Test Task" {
+		t.Errorf("Expected tasks to be unchanged by cancelled operations")
+	}
+}
+
+func testStatus(t *testing.T, ds data.Datastore) {
+	id := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+	task := ds.GetTask(ctx, id)
+	if nil == task || task.Status != model.StatusOpen || nil != task.CompletedAt {
+		t.Errorf("Expected task %s to be added open, found %v", id, task)
+		return
+	}
+	if _, err := ds.AddTask(ctx, ownerId, model.Task{Owner: ownerId, Title: "Test Task", Status: "unknown"}); nil == err {
+		t.Errorf("Expected a task with an unknown status not to be added")
+		return
+	}
+
+	if version, err := ds.SetTaskStatus(ctx, otherOwnerId, id, model.StatusDone, 0); version != 0 || nil != err {
+		t.Errorf("Expected owner %d to have no task %s to complete, found %d, %v", otherOwnerId, id, version, err)
+		return
+	}
+	if version, err := ds.SetTaskStatus(ctx, ownerId, id, model.StatusBlocked, 2); err != data.ErrVersionConflict {
+		t.Errorf("Expected a version conflict blocking task %s, found %d, %v", id, version, err)
+		return
+	}
+	if version, err := ds.SetTaskStatus(ctx, ownerId, id, model.StatusBlocked, 1); version != 2 || nil != err {
+		t.Errorf("Expected task %s to be blocked at version 2, found %d, %v", id, version, err)
+		return
+	}
+	if _, err := ds.SetTaskStatus(ctx, ownerId, id, model.StatusDone, 0); !errors.Is(err, data.ErrStatusChange) {
+		t.Errorf("Expected blocked task %s not to be done, found %v", id, err)
+		return
+	}
+
+	// updated to in progress, then done
+	task = ds.GetTask(ctx, id)
+	task.Status = model.StatusInProgress
+	if version, err := ds.UpdateTask(ctx, ownerId, *task); version != 3 || nil != err {
+		t.Errorf("Expected task %s to be in progress at version 3, found %d, %v", id, version, err)
+		return
+	}
+	if version, err := ds.SetTaskStatus(ctx, ownerId, id, model.StatusDone, 3); version != 4 || nil != err {
+		t.Errorf("Expected task %s to be done at version 4, found %d, %v", id, version, err)
+		return
+	}
+	task = ds.GetTask(ctx, id)
+	if nil == task || task.Status != model.StatusDone || nil == task.CompletedAt {
+		t.Errorf("Expected task %s to be done with a completed time, found %v", id, task)
+		return
+	}
+	completed := *task.CompletedAt
+
+	// a done task keeps the time it was done, until it is reopened
+	task.Title = "changed"
+	task.CompletedAt = nil
+	if _, err := ds.UpdateTask(ctx, ownerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+	if task = ds.GetTask(ctx, id); nil == task || nil == task.CompletedAt || !task.CompletedAt.Equal(completed) {
+		t.Errorf("Expected task %s to keep its completed time %v when updated, found %v", id, completed, task)
+		return
+	}
+	task.Status = model.StatusInProgress
+	if _, err := ds.UpdateTask(ctx, ownerId, *task); !errors.Is(err, data.ErrStatusChange) {
+		t.Errorf("Expected done task %s not to be put in progress, found %v", id, err)
+		return
+	}
+	if _, err := ds.SetTaskStatus(ctx, ownerId, id, model.StatusOpen, 0); nil != err {
+		t.Error(err)
+		return
+	}
+	if task = ds.GetTask(ctx, id); nil == task || task.Status != model.StatusOpen || nil != task.CompletedAt {
+		t.Errorf("Expected task %s to be reopened without a completed time, found %v", id, task)
+		return
+	}
+
+	// tasks in the trash keep their status
+	if _, err := ds.DeleteTask(ctx, ownerId, id, 0); nil != err {
+		t.Error(err)
+		return
+	}
+	if version, err := ds.SetTaskStatus(ctx, ownerId, id, model.StatusDone, 0); version != 0 || nil != err {
+		t.Errorf("Expected task %s in the trash not to be completed, found %d, %v", id, version, err)
+		return
+	}
+}
+
+func testStatusListings(t *testing.T, ds data.Datastore) {
+	now := time.Now()
+	openId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", ACL: model.ReaderGrants([]int{otherOwnerId}),
+		Expires: now.Add(time.Hour * 3)})
+	doneId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", ACL: model.ReaderGrants([]int{otherOwnerId}),
+		Expires: now.Add(time.Hour * 2), Status: model.StatusDone})
+	cancelledId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", ACL: model.ReaderGrants([]int{otherOwnerId}),
+		Expires: now.Add(time.Hour), Status: model.StatusCancelled})
+	if task := ds.GetTask(ctx, doneId); nil == task || nil == task.CompletedAt {
+		t.Errorf("Expected task %s added done to have a completed time", doneId)
+		return
+	}
+
+	// done tasks are hidden, unless asked for
+	if tasks := getTasks(t, ds, ownerId); !sameIds(tasks, []string{openId, cancelledId}) {
+		t.Errorf("Expected tasks %v without those done, found %v", []string{openId, cancelledId}, taskIds(tasks))
+		return
+	}
+	for _, tc := range []struct {
+		status []string
+		ids    []string
+	}{
+		{[]string{model.StatusDone}, []string{doneId}},
+		{[]string{model.StatusOpen, model.StatusDone}, []string{openId, doneId}},
+		{model.Statuses, []string{openId, doneId, cancelledId}},
+		{[]string{model.StatusBlocked}, nil},
+	} {
+		opts := data.ListOptions{Status: tc.status}
+		page, err := ds.GetTasks(ctx, ownerId, opts)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		if !sameIds(page.Tasks, tc.ids) {
+			t.Errorf("Expected tasks %v with status %v, found %v", tc.ids, tc.status, taskIds(page.Tasks))
+		}
+		if page, err = ds.GetOthersTasks(ctx, otherOwnerId, opts); nil != err || !sameIds(page.Tasks, tc.ids) {
+			t.Errorf("Expected others tasks %v with status %v, found %v, %v", tc.ids, tc.status, taskIds(page.Tasks), err)
+		}
+		if page, err = ds.FindTasks(ctx, ownerId, model.Task{Title: "Test Task"}, opts); nil != err ||
+			!sameIds(page.Tasks, tc.ids) {
+			t.Errorf("Expected to find tasks %v with status %v, found %v, %v", tc.ids, tc.status, taskIds(page.Tasks), err)
+		}
+	}
+
+	// the status of the query task is found, in place of the listed statuses
+	page, err := ds.FindTasks(ctx, ownerId, model.Task{Status: model.StatusDone}, data.ListOptions{})
+	if nil != err || !sameIds(page.Tasks, []string{doneId}) {
+		t.Errorf("Expected to find the done task %s, found %v, %v", doneId, taskIds(page.Tasks), err)
+		return
+	}
+
+	// the trash lists every status
+	if _, err := ds.DeleteTask(ctx, ownerId, doneId, 0); nil != err {
+		t.Error(err)
+		return
+	}
+	if page, err = ds.GetTrash(ctx, ownerId, data.ListOptions{}); nil != err || !sameIds(page.Tasks, []string{doneId}) {
+		t.Errorf("Expected the done task %s in the trash, found %v, %v", doneId, taskIds(page.Tasks), err)
+		return
+	}
+}
+
+func testSortPriority(t *testing.T, ds data.Datastore) {
+	lowId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Priority: model.PriorityLow})
+	highId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Priority: model.PriorityHigh})
+	noneId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+
+	sort, err := data.ParseSort("-priority")
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	var paged []*model.Task
+	opts := data.ListOptions{Limit: 1, Sort: sort}
+	for {
+		page, err := ds.GetTasks(ctx, ownerId, opts)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		paged = append(paged, page.Tasks...)
+		if page.Next == "" || len(paged) > 3 {
+			break
+		}
+		opts.Cursor = page.Next
+	}
+	if want := []string{highId, lowId, noneId}; !sameIds(paged, want) {
+		t.Errorf("Expected tasks by priority %v, found %v", want, taskIds(paged))
+		return
+	}
+
+	task := ds.GetTask(ctx, lowId)
+	task.Priority = model.PriorityMedium
+	if _, err := ds.UpdateTask(ctx, ownerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+	if task = ds.GetTask(ctx, lowId); nil == task || task.Priority != model.PriorityMedium {
+		t.Errorf("Expected task %s to be updated to medium priority, found %v", lowId, task)
+	}
+}
+
+func testSortUrgency(t *testing.T, ds data.Datastore) {
+	now := time.Now()
+	var want []string // most urgent first
+	want = append(want, addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Priority: model.PriorityHigh,
+		Expires: now.Add(-time.Hour * 24 * 10)}))
+	want = append(want, addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Expires: now.Add(-time.Hour)}))
+	want = append(want, addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Priority: model.PriorityHigh}))
+	want = append(want, addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Expires: now.Add(time.Hour * 24 * 30)}))
+	want = append(want, addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"}))
+
+	sort, err := data.ParseSort("urgency")
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	var paged []*model.Task
+	opts := data.ListOptions{Limit: 2, Sort: sort, Urgency: model.DefaultUrgencyWeights}
+	for {
+		page, err := ds.GetTasks(ctx, ownerId, opts)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		paged = append(paged, page.Tasks...)
+		if page.Next == "" || len(paged) > len(want) {
+			break
+		}
+		opts.Cursor = page.Next
+	}
+	if !sameIds(paged, want) {
+		t.Errorf("Expected tasks by urgency %v, found %v", want, taskIds(paged))
+		return
+	}
+	for i := 1; i < len(paged); i++ {
+		if paged[i-1].Urgency < paged[i].Urgency {
+			t.Errorf("Expected tasks listed with their urgency, most urgent first, found %v before %v",
+				paged[i-1].Urgency, paged[i].Urgency)
+			return
+		}
+	}
+
+	// found tasks are also sorted by urgency
+	page, err := ds.FindTasks(ctx, ownerId, model.Task{Title: "Test Task"},
+		data.ListOptions{Sort: sort, Urgency: model.DefaultUrgencyWeights})
+	if nil != err || !sameIds(page.Tasks, want) {
+		t.Errorf("Expected to find tasks by urgency %v, found %v, %v", want, taskIds(page.Tasks), err)
+		return
+	}
+	if _, err := ds.GetTasks(ctx, ownerId, data.ListOptions{Sort: sort, Cursor: "bad"}); err != data.ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor listing by urgency with a bad cursor, found %v", err)
+	}
+}
+
+func testSortUrgencyTooMany(t *testing.T, ds data.Datastore) {
+	for i := 0; i < data.MaxUrgencyTasks; i++ {
+		addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+	}
+	sort, err := data.ParseSort("urgency")
+	if nil != err {
+		t.Fatal(err)
+	}
+	opts := data.ListOptions{Limit: 1, Sort: sort, Urgency: model.DefaultUrgencyWeights}
+	if page, err := ds.GetTasks(ctx, ownerId, opts); nil != err || len(page.Tasks) != 1 {
+		t.Fatalf("Expected to list %d tasks by urgency, found %v", data.MaxUrgencyTasks, err)
+	}
+
+	// one more is refused, though not when the listing is narrowed
+	addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Status: model.StatusDone})
+	opts.Status = []string{model.StatusDone, model.StatusOpen}
+	if _, err := ds.GetTasks(ctx, ownerId, opts); !errors.Is(err, data.ErrTooManyToSort) {
+		t.Errorf("Expected ErrTooManyToSort listing %d tasks by urgency, found %v", data.MaxUrgencyTasks+1, err)
+	}
+	opts.Status = []string{model.StatusDone}
+	if page, err := ds.GetTasks(ctx, ownerId, opts); nil != err || len(page.Tasks) != 1 {
+		t.Errorf("Expected to list the done task by urgency, found %v", err)
+	}
+}
+
+func testSubtasks(t *testing.T, ds data.Datastore) {
+	now := time.Now()
+	parentId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+	firstId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Parent: parentId, Expires: now.Add(time.Hour * 2)})
+	secondId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Parent: parentId, Expires: now.Add(time.Hour)})
+	doneId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Parent: parentId, Status: model.StatusDone})
+	nestedId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", Parent: firstId})
+
+	page, err := ds.GetSubtasks(ctx, parentId, data.ListOptions{})
+	if nil != err || !sameIds(page.Tasks, []string{firstId, secondId}) {
+		t.Errorf("Expected subtasks %v, found %v, %v", []string{firstId, secondId}, taskIds(page.Tasks), err)
+		return
+	}
+	if page.Tasks[0].Parent != parentId {
+		t.Errorf("Expected subtask %s to have parent %s, found %q", firstId, parentId, page.Tasks[0].Parent)
+		return
+	}
+	page, err = ds.GetSubtasks(ctx, parentId, data.ListOptions{Status: model.Statuses, Limit: 2})
+	if nil != err || !sameIds(page.Tasks, []string{firstId, secondId}) || page.Next == "" {
+		t.Errorf("Expected a first page of subtasks %v, found %v, %v", []string{firstId, secondId}, taskIds(page.Tasks), err)
+		return
+	}
+	page, err = ds.GetSubtasks(ctx, parentId, data.ListOptions{Status: model.Statuses, Limit: 2, Cursor: page.Next})
+	if nil != err || !sameIds(page.Tasks, []string{doneId}) {
+		t.Errorf("Expected the done subtask %s on the next page, found %v, %v", doneId, taskIds(page.Tasks), err)
+		return
+	}
+	if page, err = ds.GetSubtasks(ctx, firstId, data.ListOptions{}); nil != err || !sameIds(page.Tasks, []string{nestedId}) {
+		t.Errorf("Expected nested subtask %s, found %v, %v", nestedId, taskIds(page.Tasks), err)
+		return
+	}
+
+	// a parent must be a task of the same owner, which isn't one of its subtasks
+	for _, task := range []model.Task{
+		{Owner: otherOwnerId, Title: "Test Task", Parent: parentId},
+		{Owner: ownerId, Title: "Test Task", Parent: primitive.NewObjectID().Hex()},
+	} {
+		if _, err := ds.AddTask(ctx, task.Owner, task); !errors.Is(err, data.ErrInvalidParent) {
+			t.Errorf("Expected ErrInvalidParent adding a subtask of %s owned by %d, found %v", task.Parent, task.Owner, err)
+			return
+		}
+	}
+	parent := ds.GetTask(ctx, parentId)
+	for _, id := range []string{parentId, nestedId} {
+		parent.Parent = id
+		if _, err := ds.UpdateTask(ctx, ownerId, *parent); !errors.Is(err, data.ErrInvalidParent) {
+			t.Errorf("Expected ErrInvalidParent making task %s a subtask of %s, found %v", parentId, id, err)
+			return
+		}
+	}
+
+	// subtasks in the trash aren't listed, and nor can trashed tasks have subtasks
+	if _, err := ds.DeleteTask(ctx, ownerId, secondId, 0); nil != err {
+		t.Error(err)
+		return
+	}
+	if page, err = ds.GetSubtasks(ctx, parentId, data.ListOptions{}); nil != err || !sameIds(page.Tasks, []string{firstId}) {
+		t.Errorf("Expected subtask %s in the trash not to be listed, found %v, %v", secondId, taskIds(page.Tasks), err)
+		return
+	}
+	task := model.Task{Owner: ownerId, Title: "Test Task", Parent: secondId}
+	if _, err := ds.AddTask(ctx, ownerId, task); !errors.Is(err, data.ErrInvalidParent) {
+		t.Errorf("Expected ErrInvalidParent adding a subtask of task %s in the trash, found %v", secondId, err)
+		return
+	}
+
+	// a subtask is made a top level task by clearing its parent
+	first := ds.GetTask(ctx, firstId)
+	first.Parent = ""
+	if _, err := ds.UpdateTask(ctx, ownerId, *first); nil != err {
+		t.Error(err)
+		return
+	}
+	if page, err = ds.GetSubtasks(ctx, parentId, data.ListOptions{}); nil != err || len(page.Tasks) != 0 {
+		t.Errorf("Expected no subtasks of %s left, found %v, %v", parentId, taskIds(page.Tasks), err)
+		return
+	}
+	if first = ds.GetTask(ctx, firstId); nil == first || first.Parent != "" {
+		t.Errorf("Expected task %s to have no parent, found %v", firstId, first)
+	}
+}
+
+func testConcurrentCycles(t *testing.T, ds data.Datastore) {
+	const rounds = 100
+	for i := 0; i < rounds; i++ {
+		aId := addTask(t, ds, model.Task{Owner: ownerId, Title: "a"})
+		bId := addTask(t, ds, model.Task{Owner: ownerId, Title: "b"})
+
+		// each change alone is fine, made at once, only one may be saved
+		changes := []func(a, b *model.Task){
+			func(a, b *model.Task) { a.BlockedBy, b.BlockedBy = []string{bId}, []string{aId} },
+			func(a, b *model.Task) { a.Parent, b.Parent = bId, aId },
+		}
+		for _, change := range changes {
+			a, b := ds.GetTask(ctx, aId), ds.GetTask(ctx, bId)
+			a.Version, b.Version = 0, 0
+			change(a, b)
+			var wg sync.WaitGroup
+			for _, task := range []*model.Task{a, b} {
+				wg.Add(1)
+				go func(task model.Task) {
+					defer wg.Done()
+					if _, err := ds.UpdateTask(ctx, ownerId, task); nil != err && !errors.Is(err, data.ErrInvalidDependency) &&
+						!errors.Is(err, data.ErrInvalidParent) {
+						t.Error(err)
+					}
+				}(*task)
+			}
+			wg.Wait()
+			a, b = ds.GetTask(ctx, aId), ds.GetTask(ctx, bId)
+			if (len(a.BlockedBy) > 0 && len(b.BlockedBy) > 0) || (a.Parent != "" && b.Parent != "") {
+				t.Fatalf("Expected tasks %s and %s not to be saved in a cycle, found %v and %v, parents %q and %q",
+					aId, bId, a.BlockedBy, b.BlockedBy, a.Parent, b.Parent)
+			}
+		}
+	}
+}
+
+func testDependencies(t *testing.T, ds data.Datastore) {
+	aId := addTask(t, ds, model.Task{Owner: ownerId, Title: "a"})
+	bId := addTask(t, ds, model.Task{Owner: ownerId, Title: "b", BlockedBy: []string{aId}})
+	cId := addTask(t, ds, model.Task{Owner: ownerId, Title: "c", BlockedBy: []string{aId, bId}})
+	doneId := addTask(t, ds, model.Task{Owner: ownerId, Title: "done", Status: model.StatusDone, BlockedBy: []string{aId}})
+
+	if c := ds.GetTask(ctx, cId); nil == c || fmt.Sprint(c.BlockedBy) != fmt.Sprint([]string{aId, bId}) {
+		t.Errorf("Expected task %s to be blocked by %v, found %v", cId, []string{aId, bId}, c)
+		return
+	}
+	page, err := ds.GetDependents(ctx, aId, data.ListOptions{Sort: data.Sort{{Field: "title"}}})
+	if nil != err || !sameIds(page.Tasks, []string{bId, cId}) {
+		t.Errorf("Expected tasks %v blocked by %s, found %v, %v", []string{bId, cId}, aId, taskIds(page.Tasks), err)
+		return
+	}
+	page, err = ds.GetDependents(ctx, aId, data.ListOptions{Status: model.Statuses, Sort: data.Sort{{Field: "title"}}})
+	if nil != err || !sameIds(page.Tasks, []string{bId, cId, doneId}) {
+		t.Errorf("Expected tasks %v of any status blocked by %s, found %v, %v", []string{bId, cId, doneId}, aId,
+			taskIds(page.Tasks), err)
+		return
+	}
+
+	// blockers must be tasks of the same owner, outside the trash
+	otherId := addTask(t, ds, model.Task{Owner: otherOwnerId, Title: "other"})
+	for _, blocker := range []string{otherId, primitive.NewObjectID().Hex()} {
+		task := model.Task{Owner: ownerId, Title: "Test Task", BlockedBy: []string{blocker}}
+		if _, err := ds.AddTask(ctx, ownerId, task); !errors.Is(err, data.ErrInvalidDependency) {
+			t.Errorf("Expected ErrInvalidDependency adding a task blocked by %s, found %v", blocker, err)
+			return
+		}
+	}
+
+	// no task may end up blocked by itself, however indirectly
+	a := ds.GetTask(ctx, aId)
+	for _, blocker := range []string{aId, bId, cId} {
+		a.BlockedBy = []string{blocker}
+		if _, err := ds.UpdateTask(ctx, ownerId, *a); !errors.Is(err, data.ErrInvalidDependency) {
+			t.Errorf("Expected ErrInvalidDependency making %s blocked by %s, found %v", aId, blocker, err)
+			return
+		}
+	}
+	if a = ds.GetTask(ctx, aId); nil == a || len(a.BlockedBy) != 0 {
+		t.Errorf("Expected task %s to be left unblocked, found %v", aId, a)
+		return
+	}
+
+	// deleting a task removes it from the tasks it blocked
+	if deleted, err := data.DeleteTaskTree(ctx, ds, ownerId, aId, 0, false); !deleted || nil != err {
+		t.Errorf("Expected task %s to be deleted, found %v", aId, err)
+		return
+	}
+	if page, err = ds.GetDependents(ctx, aId, data.ListOptions{Status: model.Statuses}); nil != err || len(page.Tasks) != 0 {
+		t.Errorf("Expected no tasks left blocked by %s, found %v, %v", aId, taskIds(page.Tasks), err)
+		return
+	}
+	if c := ds.GetTask(ctx, cId); nil == c || fmt.Sprint(c.BlockedBy) != fmt.Sprint([]string{bId}) {
+		t.Errorf("Expected task %s to be left blocked by %s, found %v", cId, bId, c)
+		return
+	}
+	if b := ds.GetTask(ctx, bId); nil == b || len(b.BlockedBy) != 0 {
+		t.Errorf("Expected task %s to be left unblocked, found %v", bId, b)
+		return
+	}
+	task := model.Task{Owner: ownerId, Title: "Test Task", BlockedBy: []string{aId}}
+	if _, err := ds.AddTask(ctx, ownerId, task); !errors.Is(err, data.ErrInvalidDependency) {
+		t.Errorf("Expected ErrInvalidDependency adding a task blocked by %s in the trash, found %v", aId, err)
+	}
+}
+
+func testTaskLists(t *testing.T, ds data.Datastore) {
+	defaultId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task"})
+	projectId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", List: "project"})
+	doneId := addTask(t, ds, model.Task{Owner: ownerId, Title: "Test Task", List: "project", Status: model.StatusDone})
+	sharedId := addTask(t, ds, model.Task{Owner: otherOwnerId, Title: "Test Task", List: "shared", ACL: model.ReaderGrants([]int{ownerId})})
+
+	if task := ds.GetTask(ctx, defaultId); nil == task || task.List != model.DefaultList {
+		t.Errorf("Expected task %s without a list to be in the default list, found %v", defaultId, task)
+		return
+	}
+	for _, tt := range []struct {
+		list string
+		want []string
+	}{
+		{"", []string{defaultId, projectId}},
+		{model.DefaultList, []string{defaultId}},
+		{"project", []string{projectId}},
+		{"missing", nil},
+	} {
+		page, err := ds.GetTasks(ctx, ownerId, data.ListOptions{List: tt.list, Sort: data.Sort{{Field: "_id"}}})
+		if nil != err || !sameIds(page.Tasks, tt.want) {
+			t.Errorf("Expected tasks %v in list %q, found %v, %v", tt.want, tt.list, taskIds(page.Tasks), err)
+			return
+		}
+	}
+	opts := data.ListOptions{List: "project", Status: model.Statuses, Limit: 1, Sort: data.Sort{{Field: "_id"}}}
+	page, err := ds.GetTasks(ctx, ownerId, opts)
+	if nil != err || !sameIds(page.Tasks, []string{projectId}) || page.Next == "" {
+		t.Errorf("Expected a first page of list project, found %v, %v", taskIds(page.Tasks), err)
+		return
+	}
+	opts.Cursor = page.Next
+	if page, err = ds.GetTasks(ctx, ownerId, opts); nil != err || !sameIds(page.Tasks, []string{doneId}) {
+		t.Errorf("Expected task %s on the next page of list project, found %v, %v", doneId, taskIds(page.Tasks), err)
+		return
+	}
+	if page, err = ds.GetOthersTasks(ctx, ownerId, data.ListOptions{List: "shared"}); nil != err ||
+		!sameIds(page.Tasks, []string{sharedId}) {
+		t.Errorf("Expected shared task %s in list shared, found %v, %v", sharedId, taskIds(page.Tasks), err)
+		return
+	}
+
+	// found tasks are filtered by the list of the query, and of the listing
+	for _, tt := range []struct {
+		query model.Task
+		list  string
+		want  []string
+	}{
+		{model.Task{List: "project"}, "", []string{projectId}},
+		{model.Task{List: model.DefaultList}, "", []string{defaultId}},
+		{model.Task{Title: "Test Task"}, "project", []string{projectId}},
+		{model.Task{List: "project"}, model.DefaultList, nil},
+	} {
+		page, err := ds.FindTasks(ctx, ownerId, tt.query, data.ListOptions{List: tt.list})
+		if nil != err || !sameIds(page.Tasks, tt.want) {
+			t.Errorf("Expected to find tasks %v in list %q listing %q, found %v, %v", tt.want, tt.query.List, tt.list,
+				taskIds(page.Tasks), err)
+			return
+		}
+	}
+
+	// tasks are moved between lists by updating them
+	task := ds.GetTask(ctx, projectId)
+	task.List = ""
+	if _, err := ds.UpdateTask(ctx, ownerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+	page, err = ds.GetTasks(ctx, ownerId, data.ListOptions{List: model.DefaultList, Sort: data.Sort{{Field: "_id"}}})
+	if nil != err || !sameIds(page.Tasks, []string{defaultId, projectId}) {
+		t.Errorf("Expected task %s moved to the default list, found %v, %v", projectId, taskIds(page.Tasks), err)
+	}
+}
+
+// addTask adds the given task to the store, failing the test if it can't be added.
+func addTask(t *testing.T, ds data.Datastore, task model.Task) string {
+	t.Helper()
+	id, err := ds.AddTask(ctx, task.Owner, task)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// getTasks reads every task of the given owner, in a single page.
+func getTasks(t *testing.T, ds data.Datastore, ownerId int) []*model.Task {
+	t.Helper()
+	page, err := ds.GetTasks(ctx, ownerId, data.ListOptions{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if page.Next != "" {
+		t.Fatalf("Expected all the tasks of owner %d in a single page", ownerId)
+	}
+	return page.Tasks
+}
+
+// deleteTask deletes the task without checking its version, failing the test on an error.
+func deleteTask(t *testing.T, ds data.Datastore, ownerId int, taskId string) bool {
+	t.Helper()
+	deleted, err := ds.DeleteTask(ctx, ownerId, taskId, 0)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return deleted
+}
+
+// findTask looks up a single task in the given owners list, returning nil if not found.
+func findTask(t *testing.T, ds data.Datastore, ownerId int, taskId string) *model.Task {
+	t.Helper()
+	for _, task := range getTasks(t, ds, ownerId) {
+		if task.Id() == taskId {
+			return task
+		}
+	}
+	return nil
+}
+
+func taskIds(tasks []*model.Task) []string {
+	var ids []string
+	for _, task := range tasks {
+		ids = append(ids, task.Id())
+	}
+	return ids
+}
+
+// sameIds checks the tasks have the given ids, in the same order.
+func sameIds(tasks []*model.Task, ids []string) bool {
+	if len(tasks) != len(ids) {
+		return false
+	}
+	for i, task := range tasks {
+		if task.Id() != ids[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsInt(items []int, i int) bool {
+	for _, item := range items {
+		if item == i {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package datastoretest
+
+import (
+	"gatso/data"
+	"gatso/model"
+	"testing"
+	"time"
+)
+
+// GroupFactory creates a new, empty group store for each test in the suite.
+// The suite closes the store once each test completes.
+type GroupFactory func() data.GroupStore
+
+// RunGroupConformance runs the group conformance suite against the group stores created by the given factory.
+func RunGroupConformance(t *testing.T, factory GroupFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, gs data.GroupStore)
+	}{
+		{"Groups", testGroups},
+		{"GroupMembers", testGroupMembers},
+		{"DeleteGroup", testDeleteGroup},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs := factory()
+			defer gs.Close()
+			tt.test(t, gs)
+		})
+	}
+}
+
+func testGroups(t *testing.T, gs data.GroupStore) {
+	created := time.Now().Truncate(time.Millisecond)
+	var ids []string
+	for i, group := range []model.Group{
+		{Owner: ownerId, Name: "first", Members: []int{3, 1, 3}, Created: created},
+		{Owner: ownerId, Name: "second", Created: created.Add(time.Second)},
+		{Owner: otherOwnerId, Name: "other", Members: []int{1}, Created: created},
+	} {
+		id, err := gs.AddGroup(ctx, group)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		if id == "" {
+			t.Errorf("Expected a new id for group %d", i)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	groups, err := gs.Groups(ctx, ownerId)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(groups) != 2 || groups[0].ID != ids[0] || groups[1].ID != ids[1] {
+		t.Errorf("Expected the 2 groups of owner %d, oldest first, found %d", ownerId, len(groups))
+		return
+	}
+	group := groups[0]
+	if group.Name != "first" || len(group.Members) != 2 || group.Members[0] != 1 || group.Members[1] != 3 ||
+		!group.Created.Equal(created) {
+		t.Errorf("Expected the first group as added, each member once, found %+v", group)
+		return
+	}
+
+	group, err = gs.GetGroup(ctx, ids[2])
+	if nil != err || nil == group || group.Owner != otherOwnerId || group.Name != "other" {
+		t.Errorf("Expected group %s of owner %d, found %+v, %v", ids[2], otherOwnerId, group, err)
+		return
+	}
+	if group, err = gs.GetGroup(ctx, "madeup"); nil != err || nil != group {
+		t.Errorf("Expected no group for an unknown id, found %+v, %v", group, err)
+		return
+	}
+
+	change := model.Group{ID: ids[0], Owner: otherOwnerId, Name: "renamed", Members: []int{9},
+		Created: created.Add(time.Hour)}
+	if updated, err := gs.UpdateGroup(ctx, otherOwnerId, change); nil != err || updated {
+		t.Errorf("Expected owner %d not to rename the group of owner %d, found %v, %v", otherOwnerId, ownerId, updated, err)
+		return
+	}
+	if updated, err := gs.UpdateGroup(ctx, ownerId, change); nil != err || !updated {
+		t.Errorf("Expected owner %d to rename their group, found %v, %v", ownerId, updated, err)
+		return
+	}
+	group, err = gs.GetGroup(ctx, ids[0])
+	if nil != err || nil == group || group.Name != "renamed" || group.Owner != ownerId || len(group.Members) != 2 ||
+		!group.Created.Equal(created) {
+		t.Errorf("Expected the group renamed, keeping its owner, members and when it was created, found %+v, %v", group, err)
+	}
+}
+
+func testGroupMembers(t *testing.T, gs data.GroupStore) {
+	firstId, err := gs.AddGroup(ctx, model.Group{Owner: ownerId, Name: "first", Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	secondId, err := gs.AddGroup(ctx, model.Group{Owner: otherOwnerId, Name: "second", Members: []int{1},
+		Created: time.Now().Add(time.Second)})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+
+	if added, err := gs.AddMember(ctx, otherOwnerId, firstId, 1); nil != err || added {
+		t.Errorf("Expected owner %d not to add to the group of owner %d, found %v, %v", otherOwnerId, ownerId, added, err)
+		return
+	}
+	for _, member := range []int{1, 2, 1} {
+		if added, err := gs.AddMember(ctx, ownerId, firstId, member); nil != err || !added {
+			t.Errorf("Expected owner %d to add %d to their group, found %v, %v", ownerId, member, added, err)
+			return
+		}
+	}
+	if added, err := gs.AddMember(ctx, ownerId, "madeup", 1); nil != err || added {
+		t.Errorf("Expected no member added to an unknown group, found %v, %v", added, err)
+		return
+	}
+	group, err := gs.GetGroup(ctx, firstId)
+	if nil != err || nil == group || len(group.Members) != 2 || group.Members[0] != 1 || group.Members[1] != 2 {
+		t.Errorf("Expected members 1 and 2 in group %s, found %+v, %v", firstId, group, err)
+		return
+	}
+
+	groups, err := gs.MemberOf(ctx, 1)
+	if nil != err || len(groups) != 2 || groups[0].ID != firstId || groups[1].ID != secondId {
+		t.Errorf("Expected user 1 to be a member of both groups, oldest first, found %d, %v", len(groups), err)
+		return
+	}
+
+	if removed, err := gs.RemoveMember(ctx, otherOwnerId, firstId, 1); nil != err || removed {
+		t.Errorf("Expected owner %d not to remove from the group of owner %d, found %v, %v", otherOwnerId, ownerId, removed, err)
+		return
+	}
+	if removed, err := gs.RemoveMember(ctx, ownerId, firstId, 1); nil != err || !removed {
+		t.Errorf("Expected owner %d to remove 1 from their group, found %v, %v", ownerId, removed, err)
+		return
+	}
+	groups, err = gs.MemberOf(ctx, 1)
+	if nil != err || len(groups) != 1 || groups[0].ID != secondId {
+		t.Errorf("Expected user 1 to be left a member of group %s only, found %d, %v", secondId, len(groups), err)
+		return
+	}
+	if groups, err = gs.MemberOf(ctx, ownerId); nil != err || len(groups) != 0 {
+		t.Errorf("Expected the owner of a group not to be a member of it, found %d, %v", len(groups), err)
+	}
+}
+
+func testDeleteGroup(t *testing.T, gs data.GroupStore) {
+	id, err := gs.AddGroup(ctx, model.Group{Owner: ownerId, Name: "group", Members: []int{1}, Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if deleted, err := gs.DeleteGroup(ctx, otherOwnerId, id); nil != err || deleted {
+		t.Errorf("Expected owner %d not to delete the group of owner %d, found %v, %v", otherOwnerId, ownerId, deleted, err)
+		return
+	}
+	if deleted, err := gs.DeleteGroup(ctx, ownerId, id); nil != err || !deleted {
+		t.Errorf("Expected owner %d to delete their group, found %v, %v", ownerId, deleted, err)
+		return
+	}
+	if deleted, err := gs.DeleteGroup(ctx, ownerId, id); nil != err || deleted {
+		t.Errorf("Expected the group to be deleted only once, found %v, %v", deleted, err)
+		return
+	}
+	if group, err := gs.GetGroup(ctx, id); nil != err || nil != group {
+		t.Errorf("Expected group %s to be gone, found %+v, %v", id, group, err)
+		return
+	}
+	if groups, err := gs.MemberOf(ctx, 1); nil != err || len(groups) != 0 {
+		t.Errorf("Expected user 1 no longer a member of the deleted group, found %d, %v", len(groups), err)
+	}
+}
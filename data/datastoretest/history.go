@@ -0,0 +1,82 @@
+package datastoretest
+
+import (
+	"gatso/data"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+// HistoryFactory creates a new, empty history store for each test in the suite.
+// The suite closes the store once each test completes.
+type HistoryFactory func() data.HistoryStore
+
+// RunHistoryConformance runs the history conformance suite against the history stores created by the given factory.
+func RunHistoryConformance(t *testing.T, factory HistoryFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, hs data.HistoryStore)
+	}{
+		{"Revisions", testRevisions},
+		{"RevisionsUnknown", testRevisionsUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := factory()
+			defer hs.Close()
+			tt.test(t, hs)
+		})
+	}
+}
+
+func testRevisions(t *testing.T, hs data.HistoryStore) {
+	id := primitive.NewObjectID()
+	otherId := primitive.NewObjectID()
+	changed := time.Now().Truncate(time.Millisecond)
+	for _, rev := range []model.Revision{
+		{TaskID: id.Hex(), Number: 2, Action: model.RevisionUpdate, Changed: changed, ChangedBy: ownerId,
+			Task: model.Task{ID: &id, Owner: ownerId, Title: "changed", Labels: []string{"a label"}, Version: 2}},
+		{TaskID: id.Hex(), Number: 1, Action: model.RevisionCreate, Changed: changed, ChangedBy: ownerId,
+			Task: model.Task{ID: &id, Owner: ownerId, Title: "Test Task", Version: 1}},
+		{TaskID: otherId.Hex(), Number: 1, Action: model.RevisionCreate, Changed: changed, ChangedBy: otherOwnerId,
+			Task: model.Task{ID: &otherId, Owner: otherOwnerId, Title: "Someone elses business", Version: 1}},
+	} {
+		if err := hs.AddRevision(ctx, rev); nil != err {
+			t.Error(err)
+			return
+		}
+	}
+
+	revs, err := hs.Revisions(ctx, id.Hex())
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(revs) != 2 || revs[0].Number != 1 || revs[1].Number != 2 {
+		t.Errorf("Expected revisions 1 and 2 of task %s, found %d revisions", id.Hex(), len(revs))
+		return
+	}
+	rev := revs[1]
+	if rev.TaskID != id.Hex() || rev.Action != model.RevisionUpdate || rev.ChangedBy != ownerId || !rev.Changed.Equal(changed) {
+		t.Errorf("Expected revision 2 to be the update by %d at %v, found %+v", ownerId, changed, rev)
+		return
+	}
+	if nil == rev.Task.ID || *rev.Task.ID != id || rev.Task.Title != "changed" || len(rev.Task.Labels) != 1 ||
+		rev.Task.Version != 2 {
+		t.Errorf("Expected revision 2 to hold the changed task, found %+v", rev.Task)
+		return
+	}
+}
+
+func testRevisionsUnknown(t *testing.T, hs data.HistoryStore) {
+	revs, err := hs.Revisions(ctx, primitive.NewObjectID().Hex())
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if nil != revs {
+		t.Errorf("Expected no revisions of an unknown task, found %d", len(revs))
+		return
+	}
+}
@@ -0,0 +1,136 @@
+package datastoretest
+
+import (
+	"gatso/data"
+	"gatso/model"
+	"testing"
+	"time"
+)
+
+// ListFactory creates a new, empty list store for each test in the suite.
+// The suite closes the store once each test completes.
+type ListFactory func() data.ListStore
+
+// RunListConformance runs the list conformance suite against the list stores created by the given factory.
+func RunListConformance(t *testing.T, factory ListFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, ls data.ListStore)
+	}{
+		{"Lists", testLists},
+		{"UpdateList", testUpdateList},
+		{"DeleteList", testDeleteList},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ls := factory()
+			defer ls.Close()
+			tt.test(t, ls)
+		})
+	}
+}
+
+func testLists(t *testing.T, ls data.ListStore) {
+	created := time.Now().Truncate(time.Millisecond)
+	var ids []string
+	for i, list := range []model.List{
+		{Owner: ownerId, Name: "first", Created: created},
+		{Owner: ownerId, Name: "second", Description: "the second list", Colour: "#ff8800", Archived: true,
+			Created: created.Add(time.Second)},
+		{Owner: otherOwnerId, Name: "other", Created: created},
+	} {
+		id, err := ls.AddList(ctx, list)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		if id == "" || id == model.DefaultList {
+			t.Errorf("Expected a new id for list %d, found %q", i, id)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	lists, err := ls.Lists(ctx, ownerId)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(lists) != 2 || lists[0].ID != ids[0] || lists[1].ID != ids[1] {
+		t.Errorf("Expected the 2 lists of owner %d, oldest first, found %d", ownerId, len(lists))
+		return
+	}
+	list := lists[1]
+	if list.Name != "second" || list.Description != "the second list" || list.Colour != "#ff8800" || !list.Archived ||
+		!list.Created.Equal(created.Add(time.Second)) {
+		t.Errorf("Expected the second list as added, found %+v", list)
+		return
+	}
+
+	list, err = ls.GetList(ctx, ids[2])
+	if nil != err || nil == list || list.Owner != otherOwnerId || list.Name != "other" {
+		t.Errorf("Expected list %s of owner %d, found %+v, %v", ids[2], otherOwnerId, list, err)
+		return
+	}
+	if list, err = ls.GetList(ctx, "madeup"); nil != err || nil != list {
+		t.Errorf("Expected no list for an unknown id, found %+v, %v", list, err)
+	}
+}
+
+func testUpdateList(t *testing.T, ls data.ListStore) {
+	created := time.Now().Truncate(time.Millisecond)
+	id, err := ls.AddList(ctx, model.List{Owner: ownerId, Name: "list", Colour: "#000000", Created: created})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+
+	change := model.List{ID: id, Owner: ownerId, Name: "renamed", Description: "described", Archived: true,
+		Created: created.Add(time.Hour)}
+	if updated, err := ls.UpdateList(ctx, otherOwnerId, change); nil != err || updated {
+		t.Errorf("Expected owner %d not to update the list of owner %d, found %v, %v", otherOwnerId, ownerId, updated, err)
+		return
+	}
+	if updated, err := ls.UpdateList(ctx, ownerId, change); nil != err || !updated {
+		t.Errorf("Expected owner %d to update their list, found %v, %v", ownerId, updated, err)
+		return
+	}
+	list, err := ls.GetList(ctx, id)
+	if nil != err || nil == list {
+		t.Errorf("Expected list %s, found %v", id, err)
+		return
+	}
+	if list.Name != "renamed" || list.Description != "described" || list.Colour != "" || !list.Archived ||
+		!list.Created.Equal(created) || list.Owner != ownerId {
+		t.Errorf("Expected the list updated, keeping its owner and when it was created, found %+v", list)
+		return
+	}
+
+	change.ID = "madeup"
+	if updated, err := ls.UpdateList(ctx, ownerId, change); nil != err || updated {
+		t.Errorf("Expected no update of an unknown list, found %v, %v", updated, err)
+	}
+}
+
+func testDeleteList(t *testing.T, ls data.ListStore) {
+	id, err := ls.AddList(ctx, model.List{Owner: ownerId, Name: "list", Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if deleted, err := ls.DeleteList(ctx, otherOwnerId, id); nil != err || deleted {
+		t.Errorf("Expected owner %d not to delete the list of owner %d, found %v, %v", otherOwnerId, ownerId, deleted, err)
+		return
+	}
+	if deleted, err := ls.DeleteList(ctx, ownerId, id); nil != err || !deleted {
+		t.Errorf("Expected owner %d to delete their list, found %v, %v", ownerId, deleted, err)
+		return
+	}
+	if deleted, err := ls.DeleteList(ctx, ownerId, id); nil != err || deleted {
+		t.Errorf("Expected the list to be deleted only once, found %v, %v", deleted, err)
+		return
+	}
+	if list, err := ls.GetList(ctx, id); nil != err || nil != list {
+		t.Errorf("Expected list %s to be gone, found %+v, %v", id, list, err)
+	}
+}
@@ -0,0 +1,151 @@
+package datastoretest
+
+import (
+	"gatso/data"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+// ReminderFactory creates a new, empty reminder store for each test in the suite.
+// The suite closes the store once each test completes.
+type ReminderFactory func() data.ReminderStore
+
+// RunReminderConformance runs the reminder conformance suite against the reminder stores created by the given factory.
+func RunReminderConformance(t *testing.T, factory ReminderFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, rs data.ReminderStore)
+	}{
+		{"DueReminders", testDueReminders},
+		{"Reschedule", testReschedule},
+		{"MarkSent", testMarkSent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := factory()
+			defer rs.Close()
+			tt.test(t, rs)
+		})
+	}
+}
+
+func testDueReminders(t *testing.T, rs data.ReminderStore) {
+	now := time.Now().Truncate(time.Millisecond)
+	id, otherId := primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()
+	if err := rs.ScheduleReminders(ctx, id, []model.Reminder{
+		{TaskID: id, Before: 24 * time.Hour, At: now.Add(-time.Minute)},
+		{TaskID: id, Before: 0, At: now.Add(24*time.Hour - time.Minute)},
+	}); nil != err {
+		t.Error(err)
+		return
+	}
+	if err := rs.ScheduleReminders(ctx, otherId, []model.Reminder{
+		{TaskID: otherId, Before: time.Hour, At: now.Add(-time.Hour)},
+	}); nil != err {
+		t.Error(err)
+		return
+	}
+
+	due, err := rs.DueReminders(ctx, now, 10)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(due) != 2 || due[0].TaskID != otherId || due[1].TaskID != id {
+		t.Errorf("Expected the 2 due reminders, earliest first, found %d", len(due))
+		return
+	}
+	if due[1].Before != 24*time.Hour || !due[1].At.Equal(now.Add(-time.Minute)) || nil != due[1].Sent {
+		t.Errorf("Expected the day before reminder of task %s, found %+v", id, due[1])
+		return
+	}
+	if due, err = rs.DueReminders(ctx, now.Add(48*time.Hour), 1); nil != err || len(due) != 1 {
+		t.Errorf("Expected the due reminders to be limited to 1, found %d, %v", len(due), err)
+		return
+	}
+}
+
+func testReschedule(t *testing.T, rs data.ReminderStore) {
+	now := time.Now().Truncate(time.Millisecond)
+	id := primitive.NewObjectID().Hex()
+	sent := model.Reminder{TaskID: id, Before: time.Hour, At: now.Add(-time.Hour)}
+	if err := rs.ScheduleReminders(ctx, id, []model.Reminder{sent,
+		{TaskID: id, Before: 0, At: now.Add(-time.Minute)},
+	}); nil != err {
+		t.Error(err)
+		return
+	}
+	if ok, err := rs.MarkSent(ctx, sent, &now); nil != err || !ok {
+		t.Errorf("Expected to mark the reminder sent, found %v, %v", ok, err)
+		return
+	}
+
+	// the sent reminder isn't scheduled again, the unsent one is replaced
+	if err := rs.ScheduleReminders(ctx, id, []model.Reminder{sent,
+		{TaskID: id, Before: 0, At: now.Add(-time.Second)},
+	}); nil != err {
+		t.Error(err)
+		return
+	}
+	due, err := rs.DueReminders(ctx, now, 10)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(due) != 1 || !due[0].At.Equal(now.Add(-time.Second)) {
+		t.Errorf("Expected only the rescheduled reminder to be due, found %d", len(due))
+		return
+	}
+
+	if err := rs.ScheduleReminders(ctx, id, nil); nil != err {
+		t.Error(err)
+		return
+	}
+	if due, err = rs.DueReminders(ctx, now, 10); nil != err || len(due) != 0 {
+		t.Errorf("Expected no reminders once unscheduled, found %d, %v", len(due), err)
+		return
+	}
+}
+
+func testMarkSent(t *testing.T, rs data.ReminderStore) {
+	now := time.Now().Truncate(time.Millisecond)
+	id := primitive.NewObjectID().Hex()
+	rem := model.Reminder{TaskID: id, Before: 0, At: now.Add(-time.Minute)}
+	if err := rs.ScheduleReminders(ctx, id, []model.Reminder{rem}); nil != err {
+		t.Error(err)
+		return
+	}
+
+	for _, tt := range []struct {
+		sent     *time.Time
+		expected bool
+	}{
+		{&now, true},
+		{&now, false}, // already sent
+		{nil, true},
+		{nil, false}, // already unsent
+		{&now, true},
+	} {
+		ok, err := rs.MarkSent(ctx, rem, tt.sent)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		if ok != tt.expected {
+			t.Errorf("Expected marking the reminder sent %v to return %v, found %v", nil != tt.sent, tt.expected, ok)
+			return
+		}
+	}
+	if due, err := rs.DueReminders(ctx, now, 10); nil != err || len(due) != 0 {
+		t.Errorf("Expected no reminders due once sent, found %d, %v", len(due), err)
+		return
+	}
+
+	unknown := model.Reminder{TaskID: primitive.NewObjectID().Hex(), At: now}
+	if ok, err := rs.MarkSent(ctx, unknown, &now); nil != err || ok {
+		t.Errorf("Expected an unknown reminder not to be marked, found %v, %v", ok, err)
+		return
+	}
+}
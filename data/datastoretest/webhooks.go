@@ -0,0 +1,212 @@
+package datastoretest
+
+import (
+	"gatso/data"
+	"gatso/model"
+	"testing"
+	"time"
+)
+
+// WebhookFactory creates a new, empty webhook store for each test in the suite.
+// The suite closes the store once each test completes.
+type WebhookFactory func() data.WebhookStore
+
+// RunWebhookConformance runs the webhook conformance suite against the webhook stores created by the given factory.
+func RunWebhookConformance(t *testing.T, factory WebhookFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, ws data.WebhookStore)
+	}{
+		{"Webhooks", testWebhooks},
+		{"DeleteWebhook", testDeleteWebhook},
+		{"DueDeliveries", testDueDeliveries},
+		{"Deliveries", testDeliveries},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := factory()
+			defer ws.Close()
+			tt.test(t, ws)
+		})
+	}
+}
+
+func testWebhooks(t *testing.T, ws data.WebhookStore) {
+	created := time.Now().Truncate(time.Millisecond)
+	var ids []string
+	for i, hook := range []model.Webhook{
+		{Owner: ownerId, URL: "http://localhost/first", Secret: "s1", Created: created},
+		{Owner: ownerId, URL: "http://localhost/second", Secret: "s2", Created: created.Add(time.Second),
+			Events: []string{model.EventCreated, model.EventDeleted}},
+		{Owner: otherOwnerId, URL: "http://localhost/other", Secret: "s3", Created: created},
+	} {
+		id, err := ws.AddWebhook(ctx, hook)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		if id == "" {
+			t.Errorf("Expected an id for webhook %d", i)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	hooks, err := ws.Webhooks(ctx, ownerId)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(hooks) != 2 || hooks[0].ID != ids[0] || hooks[1].ID != ids[1] {
+		t.Errorf("Expected the 2 webhooks of owner %d, oldest first, found %d", ownerId, len(hooks))
+		return
+	}
+	hook := hooks[1]
+	if hook.URL != "http://localhost/second" || hook.Secret != "s2" || !hook.Created.Equal(created.Add(time.Second)) ||
+		len(hook.Events) != 2 || hook.Events[1] != model.EventDeleted {
+		t.Errorf("Expected the second webhook as added, found %+v", hook)
+		return
+	}
+
+	hook, err = ws.GetWebhook(ctx, ids[2])
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if nil == hook || hook.Owner != otherOwnerId || len(hook.Events) != 0 {
+		t.Errorf("Expected webhook %s of owner %d, found %+v", ids[2], otherOwnerId, hook)
+		return
+	}
+	if hook, err = ws.GetWebhook(ctx, "madeup"); nil != err || nil != hook {
+		t.Errorf("Expected no webhook for an unknown id, found %+v, %v", hook, err)
+		return
+	}
+}
+
+func testDeleteWebhook(t *testing.T, ws data.WebhookStore) {
+	id, err := ws.AddWebhook(ctx, model.Webhook{Owner: ownerId, URL: "http://localhost/hook", Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if deleted, err := ws.DeleteWebhook(ctx, otherOwnerId, id); nil != err || deleted {
+		t.Errorf("Expected owner %d not to delete the webhook of owner %d, found %v, %v", otherOwnerId, ownerId, deleted, err)
+		return
+	}
+	if deleted, err := ws.DeleteWebhook(ctx, ownerId, id); nil != err || !deleted {
+		t.Errorf("Expected owner %d to delete their webhook, found %v, %v", ownerId, deleted, err)
+		return
+	}
+	if deleted, err := ws.DeleteWebhook(ctx, ownerId, id); nil != err || deleted {
+		t.Errorf("Expected the webhook to be deleted only once, found %v, %v", deleted, err)
+		return
+	}
+	if hook, err := ws.GetWebhook(ctx, id); nil != err || nil != hook {
+		t.Errorf("Expected the deleted webhook to be gone, found %+v, %v", hook, err)
+		return
+	}
+}
+
+func testDueDeliveries(t *testing.T, ws data.WebhookStore) {
+	now := time.Now().Truncate(time.Millisecond)
+	var ids []string
+	for _, d := range []model.Delivery{
+		{WebhookID: "w1", Owner: ownerId, Event: model.EventCreated, Payload: []byte(`{"n":1}`),
+			Status: model.DeliveryPending, NextAttempt: now.Add(-time.Second), Created: now},
+		{WebhookID: "w1", Owner: ownerId, Event: model.EventUpdated, Payload: []byte(`{"n":2}`),
+			Status: model.DeliveryPending, NextAttempt: now.Add(-time.Minute), Created: now},
+		{WebhookID: "w1", Owner: ownerId, Event: model.EventUpdated, Payload: []byte(`{"n":3}`),
+			Status: model.DeliveryPending, NextAttempt: now.Add(time.Minute), Created: now},
+	} {
+		id, err := ws.AddDelivery(ctx, d)
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	due, err := ws.DueDeliveries(ctx, now, 10)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(due) != 2 || due[0].ID != ids[1] || due[1].ID != ids[0] {
+		t.Errorf("Expected the 2 due deliveries, longest due first, found %d", len(due))
+		return
+	}
+	if string(due[0].Payload) != `{"n":2}` || !due[0].NextAttempt.Equal(now.Add(-time.Minute)) {
+		t.Errorf("Expected the delivery as added, found %+v", due[0])
+		return
+	}
+
+	delivered := now
+	d := *due[0]
+	d.Status = model.DeliveryDelivered
+	d.Attempts = 1
+	d.ResponseStatus = 204
+	d.Delivered = &delivered
+	if err := ws.UpdateDelivery(ctx, d); nil != err {
+		t.Error(err)
+		return
+	}
+	due, err = ws.DueDeliveries(ctx, now, 10)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(due) != 1 || due[0].ID != ids[0] {
+		t.Errorf("Expected only delivery %s to remain due, found %d", ids[0], len(due))
+		return
+	}
+	if due, err = ws.DueDeliveries(ctx, now.Add(time.Hour), 1); nil != err || len(due) != 1 {
+		t.Errorf("Expected the due deliveries to be limited to 1, found %d, %v", len(due), err)
+		return
+	}
+}
+
+func testDeliveries(t *testing.T, ws data.WebhookStore) {
+	now := time.Now().Truncate(time.Millisecond)
+	var ids []string
+	for i, d := range []model.Delivery{
+		{WebhookID: "w1", Owner: ownerId, Event: model.EventCreated, Payload: []byte(`{}`),
+			Status: model.DeliveryPending, NextAttempt: now, Created: now},
+		{WebhookID: "w2", Owner: ownerId, Event: model.EventCreated, Payload: []byte(`{}`),
+			Status: model.DeliveryPending, NextAttempt: now, Created: now.Add(time.Second)},
+		{WebhookID: "w1", Owner: ownerId, Event: model.EventUpdated, Payload: []byte(`{}`),
+			Status: model.DeliveryPending, NextAttempt: now, Created: now.Add(2 * time.Second)},
+		{WebhookID: "w3", Owner: otherOwnerId, Event: model.EventCreated, Payload: []byte(`{}`),
+			Status: model.DeliveryPending, NextAttempt: now, Created: now},
+	} {
+		id, err := ws.AddDelivery(ctx, d)
+		if nil != err {
+			t.Errorf("Failed to add delivery %d: %v", i, err)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	deliveries, err := ws.Deliveries(ctx, ownerId, "", 10)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(deliveries) != 3 || deliveries[0].ID != ids[2] || deliveries[2].ID != ids[0] {
+		t.Errorf("Expected the 3 deliveries of owner %d, newest first, found %d", ownerId, len(deliveries))
+		return
+	}
+	deliveries, err = ws.Deliveries(ctx, ownerId, "w1", 1)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(deliveries) != 1 || deliveries[0].ID != ids[2] {
+		t.Errorf("Expected the newest delivery to webhook w1, found %d", len(deliveries))
+		return
+	}
+	if deliveries, err = ws.Deliveries(ctx, ownerId, "w3", 10); nil != err || len(deliveries) != 0 {
+		t.Errorf("Expected no deliveries of owner %d to another owners webhook, found %d, %v",
+			ownerId, len(deliveries), err)
+		return
+	}
+}
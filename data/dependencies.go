@@ -0,0 +1,132 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gatso/model"
+	"strings"
+)
+
+// ErrInvalidDependency is returned when a task is given a blocker it can't depend on,
+// including one which would leave it depending on itself.
+var ErrInvalidDependency = errors.New("invalid task dependency")
+
+// checkDependencies checks the tasks blocking a task being saved are tasks of the same owner outside the trash,
+// and that none of them is, in turn, blocked by the task. get reads a stored task by its id.
+func checkDependencies(task *model.Task, get func(taskId string) *model.Task) error {
+	for _, id := range task.BlockedBy {
+		blocker := get(id)
+		if nil == blocker || nil != blocker.Deleted {
+			return fmt.Errorf("%w, task %s not found", ErrInvalidDependency, id)
+		}
+		if blocker.Owner != task.Owner {
+			return fmt.Errorf("%w, task %s is owned by %d, so can't block tasks of %d", ErrInvalidDependency, id,
+				blocker.Owner, task.Owner)
+		}
+	}
+	if nil == task.ID { // a new task isn't blocking anything yet
+		return nil
+	}
+
+	// follow the blockers of the blockers, noting which task led to each, to report the cycle found
+	via := map[string]string{}
+	pending := []string{}
+	for _, id := range task.BlockedBy {
+		if _, seen := via[id]; !seen {
+			via[id] = task.Id()
+			pending = append(pending, id)
+		}
+	}
+	for len(pending) > 0 {
+		id := pending[0]
+		pending = pending[1:]
+		if id == task.Id() {
+			cycle := []string{task.Id()}
+			for at := via[id]; at != task.Id(); at = via[at] {
+				cycle = append([]string{at}, cycle...)
+			}
+			cycle = append([]string{task.Id()}, cycle...)
+			return fmt.Errorf("%w, it would make a cycle, %s", ErrInvalidDependency, strings.Join(cycle, " is blocked by "))
+		}
+		blocker := get(id)
+		if nil == blocker {
+			continue
+		}
+		for _, next := range blocker.BlockedBy {
+			if _, seen := via[next]; !seen {
+				via[next] = id
+				pending = append(pending, next)
+			}
+		}
+	}
+	return nil
+}
+
+// GetDependencyGraph builds the dependency graph of the owners tasks outside the trash and archive.
+func GetDependencyGraph(ctx context.Context, ds Datastore, ownerId int) (model.DependencyGraph, error) {
+	tasks, err := allTasks(func(opts ListOptions) (model.TaskPage, error) {
+		return ds.GetTasks(ctx, ownerId, opts)
+	})
+	if nil != err {
+		return model.DependencyGraph{}, err
+	}
+	return model.NewDependencyGraph(tasks), nil
+}
+
+// removeDependencies removes the task from the tasks of the owner blocked by it, having been deleted,
+// retrying should one change while doing so. Those in the trash or the archive can't be changed, so keep it until
+// they are taken out, see DropRemovedBlockers.
+func removeDependencies(ctx context.Context, ds Datastore, ownerId int, taskId string) error {
+	dependents, err := allTasks(func(opts ListOptions) (model.TaskPage, error) {
+		return ds.GetDependents(ctx, taskId, opts)
+	})
+	if nil != err {
+		return err
+	}
+	for _, task := range dependents {
+		for nil != task && nil == task.Deleted && containsString(task.BlockedBy, taskId) {
+			var blockedBy []string
+			for _, id := range task.BlockedBy {
+				if id != taskId {
+					blockedBy = append(blockedBy, id)
+				}
+			}
+			task.BlockedBy = blockedBy
+			_, err := ds.UpdateTask(ctx, ownerId, *task)
+			if err != ErrVersionConflict {
+				if nil != err {
+					return err
+				}
+				break
+			}
+			task = ds.GetTask(ctx, task.Id())
+		}
+	}
+	return nil
+}
+
+// DropRemovedBlockers removes the blockers of the task which were deleted while it was in the trash or the archive,
+// so it can be saved again once taken out of them. They are removed on behalf of its owner, retrying should the task
+// change while doing so. A task still in the trash or the archive is left as it is.
+func DropRemovedBlockers(ctx context.Context, ds Datastore, taskId string) error {
+	task := ds.GetTask(ctx, taskId)
+	for nil != task && nil == task.Deleted && nil == task.Archived {
+		var kept []string
+		for _, id := range task.BlockedBy {
+			if blocker := ds.GetTask(ctx, id); nil != blocker && nil == blocker.Deleted {
+				kept = append(kept, id)
+			}
+		}
+		if len(kept) == len(task.BlockedBy) {
+			return nil
+		}
+		task.BlockedBy = kept
+		_, err := ds.UpdateTask(ctx, task.Owner, *task)
+		if err != ErrVersionConflict {
+			return err
+		}
+		task = ds.GetTask(ctx, taskId)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package data_test
+
+import (
+	"gatso/data"
+	"gatso/model"
+	"testing"
+	"time"
+)
+
+func TestDropRemovedBlockers(t *testing.T) {
+	ms := data.NewMemoryDataStore()
+	defer ms.Close()
+
+	blockerId, err := ms.AddTask(ctx, testOwnerId, model.Task{Owner: testOwnerId, Title: "Blocker"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	keptId, err := ms.AddTask(ctx, testOwnerId, model.Task{Owner: testOwnerId, Title: "Kept"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	id, err := ms.AddTask(ctx, testOwnerId, model.Task{Owner: testOwnerId, Title: "Test Task",
+		Expires: time.Now().Add(-time.Hour), BlockedBy: []string{blockerId, keptId}})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// the blocker is deleted while the task is in the archive, where it can't be changed
+	if n, err := ms.ArchiveTasks(ctx, time.Now()); nil != err || n != 1 {
+		t.Fatalf("Expected task %s to be archived, found %d, %v", id, n, err)
+	}
+	if deleted, err := data.DeleteTaskTree(ctx, ms, testOwnerId, blockerId, 0, false); nil != err || !deleted {
+		t.Fatalf("Expected task %s to be deleted, found %v", blockerId, err)
+	}
+	if err := data.DropRemovedBlockers(ctx, ms, id); nil != err {
+		t.Fatal(err)
+	}
+	if task := ms.GetTask(ctx, id); len(task.BlockedBy) != 2 {
+		t.Fatalf("Expected task %s to be left as it is while archived, found blockers %v", id, task.BlockedBy)
+	}
+
+	// once taken out, it no longer depends on the deleted task, so can be saved again
+	if unarchived, err := ms.UnarchiveTask(ctx, testOwnerId, id); nil != err || !unarchived {
+		t.Fatalf("Expected task %s to be unarchived, found %v", id, err)
+	}
+	if err := data.DropRemovedBlockers(ctx, ms, id); nil != err {
+		t.Fatal(err)
+	}
+	task := ms.GetTask(ctx, id)
+	if len(task.BlockedBy) != 1 || task.BlockedBy[0] != keptId {
+		t.Fatalf("Expected task %s to only be blocked by %s, found %v", id, keptId, task.BlockedBy)
+	}
+	task.Title = "changed"
+	if _, err := ms.UpdateTask(ctx, testOwnerId, *task); nil != err {
+		t.Errorf("Expected task %s to be saved once unarchived, found %v", id, err)
+	}
+}
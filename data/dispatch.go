@@ -0,0 +1,192 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gatso/model"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const HeaderWebhookSignature = "X-Todo-Signature"
+const HeaderWebhookEvent = "X-Todo-Event"
+const HeaderWebhookDelivery = "X-Todo-Delivery"
+
+const webhookMaxAttempts = 8              // attempts at a delivery before it fails for good
+const webhookDispatchBatch = 100          // deliveries attempted in each dispatch
+const webhookWorkers = 8                  // webhooks delivered to at once
+const webhookAttemptTimeout = time.Minute // longest an attempt at a delivery may take, however the client is set up
+
+// WebhookDispatcher is a background job, posting the queued deliveries to their webhooks.
+// A failed delivery is retried, waiting twice as long after each attempt, until it has failed webhookMaxAttempts times.
+type WebhookDispatcher struct {
+	webhooks WebhookStore
+	client   *http.Client
+	backoff  time.Duration // wait before the first retry
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// StartWebhookDispatcher dispatches the deliveries due in the given store every interval, until stopped.
+func StartWebhookDispatcher(webhooks WebhookStore, client *http.Client, interval time.Duration,
+	backoff time.Duration) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		webhooks: webhooks,
+		client:   client,
+		backoff:  backoff,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go d.run(interval)
+	return d
+}
+
+// Stop the dispatcher, waiting for any dispatch in progress to finish.
+func (d *WebhookDispatcher) Stop() {
+	close(d.stop)
+	<-d.done
+}
+
+// Dispatch attempts each delivery now due, returning the number delivered.
+// Each webhook is delivered to by its own worker, in the order its deliveries were due, so a slow webhook only holds
+// up its own deliveries. At most webhookWorkers webhooks are delivered to at once.
+func (d *WebhookDispatcher) Dispatch(ctx context.Context) (int, error) {
+	due, err := d.webhooks.DueDeliveries(ctx, time.Now(), webhookDispatchBatch)
+	if nil != err {
+		return 0, err
+	}
+	var hooks []string
+	byHook := map[string][]*model.Delivery{}
+	for _, delivery := range due {
+		if _, seen := byHook[delivery.WebhookID]; !seen {
+			hooks = append(hooks, delivery.WebhookID)
+		}
+		byHook[delivery.WebhookID] = append(byHook[delivery.WebhookID], delivery)
+	}
+
+	var mu sync.Mutex
+	delivered := 0
+	var failed error
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, webhookWorkers)
+	for _, hook := range hooks {
+		workers <- struct{}{}
+		wg.Add(1)
+		go func(deliveries []*model.Delivery) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			for _, delivery := range deliveries {
+				if nil != ctx.Err() {
+					return
+				}
+				d.attempt(ctx, delivery)
+				err := d.webhooks.UpdateDelivery(ctx, *delivery)
+				mu.Lock()
+				if nil == err && delivery.Status == model.DeliveryDelivered {
+					delivered++
+				}
+				if nil != err && nil == failed {
+					failed = err
+				}
+				mu.Unlock()
+				if nil != err {
+					return
+				}
+			}
+		}(byHook[hook])
+	}
+	wg.Wait()
+	if nil == failed {
+		failed = ctx.Err()
+	}
+	return delivered, failed
+}
+
+func (d *WebhookDispatcher) run(interval time.Duration) {
+	defer close(d.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			if _, err := d.Dispatch(context.Background()); nil != err {
+				log.Printf("Failed to dispatch webhook deliveries: %v", err)
+			}
+		}
+	}
+}
+
+// attempt the delivery, recording the outcome in it.
+func (d *WebhookDispatcher) attempt(ctx context.Context, delivery *model.Delivery) {
+	delivery.Attempts++
+	ctx, cancel := context.WithTimeout(ctx, webhookAttemptTimeout)
+	defer cancel()
+	status, err := d.post(ctx, delivery)
+	delivery.ResponseStatus = status
+	if nil == err {
+		now := time.Now()
+		delivery.Status = model.DeliveryDelivered
+		delivery.Delivered = &now
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= webhookMaxAttempts {
+		delivery.Status = model.DeliveryFailed
+		return
+	}
+	delivery.NextAttempt = time.Now().Add(d.backoff << uint(delivery.Attempts-1))
+}
+
+// post the delivery to its webhook, returning the response status.
+func (d *WebhookDispatcher) post(ctx context.Context, delivery *model.Delivery) (int, error) {
+	hook, err := d.webhooks.GetWebhook(ctx, delivery.WebhookID)
+	if nil != err {
+		return 0, err
+	}
+	if nil == hook {
+		// removed since the delivery was queued, it can never be made
+		delivery.Attempts = webhookMaxAttempts
+		return 0, fmt.Errorf("webhook %s no longer exists", delivery.WebhookID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if nil != err {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookEvent, delivery.Event)
+	req.Header.Set(HeaderWebhookDelivery, delivery.ID)
+	req.Header.Set(HeaderWebhookSignature, SignPayload(hook.Secret, delivery.Payload))
+	resp, err := d.client.Do(req)
+	if nil != err {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignPayload gives the signature of a webhook payload, sent in the X-Todo-Signature header.
+// It is the hex HMAC-SHA256 of the payload, keyed with the webhook secret, prefixed with "sha256=".
+func SignPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
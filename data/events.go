@@ -0,0 +1,111 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"sync"
+	"time"
+)
+
+// subscriptionBuffer is the number of events a subscriber may fall behind before it is dropped.
+const subscriptionBuffer = 64
+
+// EventBus passes task change events from the datastore to every subscriber in the process.
+// The latest events are kept, so a subscriber which reconnects can replay those it missed.
+type EventBus struct {
+	mu          sync.Mutex
+	lastId      uint64
+	replay      []model.Event // the latest events, oldest first
+	replaySize  int
+	subscribers map[*Subscription]bool
+}
+
+// Subscription receives each event published after it was made, until closed.
+// A subscriber which falls too far behind has its channel closed, and should subscribe again from the last
+// event it received.
+type Subscription struct {
+	C   <-chan model.Event
+	c   chan model.Event
+	bus *EventBus
+}
+
+// Create a new EventBus, keeping up to replaySize of the latest events to replay.
+func NewEventBus(replaySize int) *EventBus {
+	return &EventBus{replaySize: replaySize, subscribers: map[*Subscription]bool{}}
+}
+
+// Publish a change to the task to every subscriber.
+func (b *EventBus) Publish(eventType string, task model.Task) model.Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastId++
+	event := model.Event{ID: b.lastId, Type: eventType, Changed: time.Now(), Task: task}
+	if b.replaySize > 0 {
+		if len(b.replay) == b.replaySize {
+			b.replay = append(b.replay[:0], b.replay[1:]...)
+		}
+		b.replay = append(b.replay, event)
+	}
+	for s := range b.subscribers {
+		select {
+		case s.c <- event:
+		default: // too slow, drop it rather than hold up every other subscriber
+			delete(b.subscribers, s)
+			close(s.c)
+		}
+	}
+	return event
+}
+
+// Subscribe to the events published after the one with the given id, 0 for only those published from now.
+// The events already published after lastId are returned to be replayed first. If some of them are no longer
+// kept, or lastId is unknown, complete is false and the subscriber has missed changes.
+func (b *EventBus) Subscribe(lastId uint64) (s *Subscription, missed []model.Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := make(chan model.Event, subscriptionBuffer)
+	s = &Subscription{C: c, c: c, bus: b}
+	b.subscribers[s] = true
+
+	if lastId == 0 || lastId == b.lastId {
+		return s, nil, true
+	}
+	if lastId > b.lastId { // from before a restart
+		return s, nil, false
+	}
+	complete = len(b.replay) > 0 && b.replay[0].ID <= lastId+1
+	for _, event := range b.replay {
+		if event.ID > lastId {
+			missed = append(missed, event)
+		}
+	}
+	return s, missed, complete
+}
+
+// Close the subscription, no more events are received.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if s.bus.subscribers[s] {
+		delete(s.bus.subscribers, s)
+		close(s.c)
+	}
+}
+
+// EventDataStore publishes an event to the EventBus for every change made through the datastore it wraps.
+type EventDataStore struct {
+	changeNotifier
+	bus *EventBus
+}
+
+// Create a new EventDataStore publishing the changes made to the given datastore on the given bus.
+func NewEventDataStore(ds Datastore, bus *EventBus) *EventDataStore {
+	e := &EventDataStore{bus: bus}
+	e.changeNotifier = changeNotifier{Datastore: ds, notify: e.publish}
+	return e
+}
+
+// publish the task as the change stored it.
+func (e EventDataStore) publish(ctx context.Context, ownerId int, change string, task model.Task) {
+	e.bus.Publish(change, task)
+}
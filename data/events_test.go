@@ -0,0 +1,101 @@
+package data_test
+
+import (
+	"context"
+	"gatso/data"
+	"gatso/data/datastoretest"
+	"gatso/model"
+	"testing"
+)
+
+func TestEventDataStore_Conformance(t *testing.T) {
+	datastoretest.RunConformance(t, func() data.Datastore {
+		return data.NewEventDataStore(data.NewMemoryDataStore(), data.NewEventBus(10))
+	})
+}
+
+func TestEventDataStore_Publish(t *testing.T) {
+	bus := data.NewEventBus(10)
+	es := data.NewEventDataStore(data.NewMemoryDataStore(), bus)
+	defer es.Close()
+	sub, _, _ := bus.Subscribe(0)
+	defer sub.Close()
+
+	ctx := context.Background()
+	id, err := es.AddTask(ctx, 123, model.Task{Owner: 123, Title: "first"})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	task := es.GetTask(ctx, id)
+	task.Title = "second"
+	if _, err := es.UpdateTask(ctx, 123, *task); nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := es.DeleteTask(ctx, 123, id, 0); nil != err {
+		t.Error(err)
+		return
+	}
+
+	for i, expected := range []string{model.EventCreated, model.EventUpdated, model.EventDeleted} {
+		event := <-sub.C
+		if event.Type != expected || event.ID != uint64(i+1) || event.Task.Id() != id {
+			t.Errorf("Expected %s event %d for task %s, found %s event %d for task %s",
+				expected, i+1, id, event.Type, event.ID, event.Task.Id())
+			return
+		}
+	}
+	select {
+	case event := <-sub.C:
+		t.Errorf("Unexpected %s event %d", event.Type, event.ID)
+	default:
+	}
+}
+
+func TestEventBus_Replay(t *testing.T) {
+	bus := data.NewEventBus(3)
+	for i := 0; i < 5; i++ {
+		bus.Publish(model.EventCreated, model.Task{Owner: 123})
+	}
+
+	for _, tt := range []struct {
+		lastId   uint64
+		replayed int
+		complete bool
+	}{
+		{0, 0, true},
+		{5, 0, true},
+		{3, 2, true},
+		{2, 3, true},
+		{1, 3, false}, // event 2 is no longer kept
+		{9, 0, false}, // unknown, from before a restart
+	} {
+		sub, missed, complete := bus.Subscribe(tt.lastId)
+		sub.Close()
+		if len(missed) != tt.replayed || complete != tt.complete {
+			t.Errorf("Expected %d events replayed after %d, complete %v, found %d, complete %v",
+				tt.replayed, tt.lastId, tt.complete, len(missed), complete)
+		}
+		if len(missed) > 0 && missed[0].ID != tt.lastId+1 && tt.complete {
+			t.Errorf("Expected replay after %d to start at %d, found %d", tt.lastId, tt.lastId+1, missed[0].ID)
+		}
+	}
+}
+
+func TestEventBus_SlowSubscriber(t *testing.T) {
+	bus := data.NewEventBus(0)
+	sub, _, _ := bus.Subscribe(0)
+	defer sub.Close()
+
+	for i := 0; i < 1000; i++ {
+		bus.Publish(model.EventUpdated, model.Task{Owner: 123})
+	}
+	count := 0
+	for range sub.C {
+		count++
+	}
+	if count == 0 || count == 1000 {
+		t.Errorf("Expected a slow subscriber to be dropped after some events, received %d", count)
+	}
+}
@@ -0,0 +1,135 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
+	"time"
+)
+
+// apiKeyRecord is a line of the api key log, holding one of its fields.
+// A key record replaces any earlier record of the same key.
+type apiKeyRecord struct {
+	Key     *model.APIKey `json:"key,omitempty"`
+	Hash    string        `json:"hash,omitempty"` // hash of the key, left out of its json
+	Used    string        `json:"used,omitempty"` // id of a key used at the time given
+	At      *time.Time    `json:"at,omitempty"`
+	Revoked string        `json:"revoked,omitempty"` // id of a removed key
+}
+
+// FileAPIKeyStore holds the api keys in an append only log file, in the same format as the FileDataStore,
+// one change per line.  They are served from memory, loaded from the log when opened.
+type FileAPIKeyStore struct {
+	*MemoryAPIKeyStore
+	file *os.File
+	size int64 // length of the log, up to the end of the last complete record
+}
+
+// Create a new FileAPIKeyStore using the log file at the given path. The file is created if it doesn't exist.
+func NewFileAPIKeyStore(path string) (*FileAPIKeyStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no file path given for the api keys")
+	}
+	fk := &FileAPIKeyStore{MemoryAPIKeyStore: NewMemoryAPIKeyStore()}
+	_, size, err := replayLog(path, func(js []byte) error {
+		var rec apiKeyRecord
+		if err := json.Unmarshal(js, &rec); nil != err {
+			return err
+		}
+		fk.apply(&rec)
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	fk.size = size
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if nil != err {
+		return nil, err
+	}
+	fk.file = f
+	return fk, nil
+}
+
+// Close the log file. The keys remain in the file, to be loaded when next opened.
+func (fk *FileAPIKeyStore) Close() {
+	fk.mu.Lock()
+	defer fk.mu.Unlock()
+	fk.file.Close()
+}
+
+func (fk *FileAPIKeyStore) AddKey(ctx context.Context, key model.APIKey) (string, error) {
+	if err := ctx.Err(); nil != err {
+		return "", err
+	}
+	key.ID = primitive.NewObjectID().Hex()
+	key.Key = ""
+	fk.mu.Lock()
+	defer fk.mu.Unlock()
+	if err := fk.append(&apiKeyRecord{Key: &key, Hash: key.Hash}); nil != err {
+		return "", err
+	}
+	return key.ID, nil
+}
+
+func (fk *FileAPIKeyStore) KeyUsed(ctx context.Context, id string, at time.Time) error {
+	if err := ctx.Err(); nil != err {
+		return err
+	}
+	fk.mu.Lock()
+	defer fk.mu.Unlock()
+	if _, ok := fk.keys[id]; !ok {
+		return nil
+	}
+	return fk.append(&apiKeyRecord{Used: id, At: &at})
+}
+
+func (fk *FileAPIKeyStore) RevokeKey(ctx context.Context, ownerId int, id string) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	fk.mu.Lock()
+	defer fk.mu.Unlock()
+	if !fk.owned(ownerId, id) {
+		return false, nil
+	}
+	if err := fk.append(&apiKeyRecord{Revoked: id}); nil != err {
+		return false, err
+	}
+	return true, nil
+}
+
+// append writes the record to the log, then applies it. Caller must hold the write lock.
+func (fk *FileAPIKeyStore) append(rec *apiKeyRecord) error {
+	js, err := json.Marshal(rec)
+	if nil != err {
+		return err
+	}
+	line := checksumLine(js)
+	if err := appendLine(fk.file, fk.size, line); nil != err {
+		return err
+	}
+	fk.size += int64(len(line))
+	fk.apply(rec)
+	return nil
+}
+
+// apply the record to the keys held in memory. Caller must hold the write lock.
+func (fk *FileAPIKeyStore) apply(rec *apiKeyRecord) {
+	switch {
+	case nil != rec.Key:
+		rec.Key.Hash = rec.Hash
+		fk.putKey(rec.Key)
+	case rec.Used != "" && nil != rec.At:
+		if key, ok := fk.keys[rec.Used]; ok {
+			at := *rec.At
+			key.LastUsed = &at
+		}
+	case rec.Revoked != "":
+		fk.removeKey(rec.Revoked)
+	}
+}
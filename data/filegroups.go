@@ -0,0 +1,148 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
+)
+
+// groupRecord is a line of the group log, holding one of its fields.
+// A group record replaces any earlier record of the same group.
+type groupRecord struct {
+	Group   *model.Group `json:"group,omitempty"`
+	Deleted string       `json:"deleted,omitempty"` // id of a removed group
+}
+
+// FileGroupStore holds the groups in an append only log file, in the same format as the FileDataStore,
+// one change per line.  They are served from memory, loaded from the log when opened.
+type FileGroupStore struct {
+	*MemoryGroupStore
+	file *os.File
+	size int64 // length of the log, up to the end of the last complete record
+}
+
+// Create a new FileGroupStore using the log file at the given path. The file is created if it doesn't exist.
+func NewFileGroupStore(path string) (*FileGroupStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no file path given for the groups")
+	}
+	fg := &FileGroupStore{MemoryGroupStore: NewMemoryGroupStore()}
+	_, size, err := replayLog(path, func(js []byte) error {
+		var rec groupRecord
+		if err := json.Unmarshal(js, &rec); nil != err {
+			return err
+		}
+		fg.apply(&rec)
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	fg.size = size
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if nil != err {
+		return nil, err
+	}
+	fg.file = f
+	return fg, nil
+}
+
+// Close the log file. The groups remain in the file, to be loaded when next opened.
+func (fg *FileGroupStore) Close() {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fg.file.Close()
+}
+
+func (fg *FileGroupStore) AddGroup(ctx context.Context, group model.Group) (string, error) {
+	if err := ctx.Err(); nil != err {
+		return "", err
+	}
+	group.ID = primitive.NewObjectID().Hex()
+	group.Members = distinctMembers(group.Members)
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	if err := fg.append(&groupRecord{Group: &group}); nil != err {
+		return "", err
+	}
+	return group.ID, nil
+}
+
+func (fg *FileGroupStore) UpdateGroup(ctx context.Context, ownerId int, group model.Group) (bool, error) {
+	return fg.change(ctx, ownerId, group.ID, func(g *model.Group) {
+		g.Name = group.Name
+	})
+}
+
+func (fg *FileGroupStore) AddMember(ctx context.Context, ownerId int, groupId string, userId int) (bool, error) {
+	return fg.change(ctx, ownerId, groupId, func(g *model.Group) {
+		g.Members = distinctMembers(append(g.Members, userId))
+	})
+}
+
+func (fg *FileGroupStore) RemoveMember(ctx context.Context, ownerId int, groupId string, userId int) (bool, error) {
+	return fg.change(ctx, ownerId, groupId, func(g *model.Group) {
+		g.Members = withoutMember(g.Members, userId)
+	})
+}
+
+func (fg *FileGroupStore) DeleteGroup(ctx context.Context, ownerId int, id string) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	if nil == fg.owned(ownerId, id) {
+		return false, nil
+	}
+	if err := fg.append(&groupRecord{Deleted: id}); nil != err {
+		return false, err
+	}
+	return true, nil
+}
+
+// change logs the owners group as changed by the given func, returning false if the owner has no such group.
+func (fg *FileGroupStore) change(ctx context.Context, ownerId int, id string, change func(g *model.Group)) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	changed := fg.changed(ownerId, id, change)
+	if nil == changed {
+		return false, nil
+	}
+	if err := fg.append(&groupRecord{Group: changed}); nil != err {
+		return false, err
+	}
+	return true, nil
+}
+
+// append writes the record to the log, then applies it. Caller must hold the write lock.
+func (fg *FileGroupStore) append(rec *groupRecord) error {
+	js, err := json.Marshal(rec)
+	if nil != err {
+		return err
+	}
+	line := checksumLine(js)
+	if err := appendLine(fg.file, fg.size, line); nil != err {
+		return err
+	}
+	fg.size += int64(len(line))
+	fg.apply(rec)
+	return nil
+}
+
+// apply the record to the groups held in memory. Caller must hold the write lock.
+func (fg *FileGroupStore) apply(rec *groupRecord) {
+	switch {
+	case nil != rec.Group:
+		fg.putGroup(rec.Group)
+	case rec.Deleted != "":
+		delete(fg.groups, rec.Deleted)
+	}
+}
@@ -0,0 +1,71 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gatso/model"
+	"os"
+)
+
+// FileHistoryStore holds the revisions of every task in an append only log file, in the same format as the
+// FileDataStore, one revision per line.  The revisions are served from memory, loaded from the log when opened.
+type FileHistoryStore struct {
+	*MemoryHistoryStore
+	file *os.File
+	size int64 // length of the log, up to the end of the last complete revision
+}
+
+// Create a new FileHistoryStore using the log file at the given path. The file is created if it doesn't exist.
+func NewFileHistoryStore(path string) (*FileHistoryStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no file path given for the history")
+	}
+	fh := &FileHistoryStore{MemoryHistoryStore: NewMemoryHistoryStore()}
+	_, size, err := replayLog(path, func(js []byte) error {
+		var rev model.Revision
+		if err := json.Unmarshal(js, &rev); nil != err {
+			return err
+		}
+		fh.add(&rev)
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	fh.size = size
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if nil != err {
+		return nil, err
+	}
+	fh.file = f
+	return fh, nil
+}
+
+// Close the log file. The revisions remain in the file, to be loaded when next opened.
+func (fh *FileHistoryStore) Close() {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	fh.file.Close()
+}
+
+func (fh *FileHistoryStore) AddRevision(ctx context.Context, rev model.Revision) error {
+	if err := ctx.Err(); nil != err {
+		return err
+	}
+	js, err := json.Marshal(&rev)
+	if nil != err {
+		return err
+	}
+	line := checksumLine(js)
+
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if err := appendLine(fh.file, fh.size, line); nil != err {
+		return err
+	}
+	fh.size += int64(len(line))
+	fh.add(&rev)
+	return nil
+}
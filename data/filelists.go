@@ -0,0 +1,128 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
+)
+
+// listRecord is a line of the list log, holding one of its fields.
+// A list record replaces any earlier record of the same list.
+type listRecord struct {
+	List    *model.List `json:"list,omitempty"`
+	Deleted string      `json:"deleted,omitempty"` // id of a removed list
+}
+
+// FileListStore holds the lists in an append only log file, in the same format as the FileDataStore,
+// one change per line.  They are served from memory, loaded from the log when opened.
+type FileListStore struct {
+	*MemoryListStore
+	file *os.File
+	size int64 // length of the log, up to the end of the last complete record
+}
+
+// Create a new FileListStore using the log file at the given path. The file is created if it doesn't exist.
+func NewFileListStore(path string) (*FileListStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no file path given for the lists")
+	}
+	fl := &FileListStore{MemoryListStore: NewMemoryListStore()}
+	_, size, err := replayLog(path, func(js []byte) error {
+		var rec listRecord
+		if err := json.Unmarshal(js, &rec); nil != err {
+			return err
+		}
+		fl.apply(&rec)
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	fl.size = size
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if nil != err {
+		return nil, err
+	}
+	fl.file = f
+	return fl, nil
+}
+
+// Close the log file. The lists remain in the file, to be loaded when next opened.
+func (fl *FileListStore) Close() {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	fl.file.Close()
+}
+
+func (fl *FileListStore) AddList(ctx context.Context, list model.List) (string, error) {
+	if err := ctx.Err(); nil != err {
+		return "", err
+	}
+	list.ID = primitive.NewObjectID().Hex()
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	if err := fl.append(&listRecord{List: &list}); nil != err {
+		return "", err
+	}
+	return list.ID, nil
+}
+
+func (fl *FileListStore) UpdateList(ctx context.Context, ownerId int, list model.List) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	updated := fl.updated(ownerId, list)
+	if nil == updated {
+		return false, nil
+	}
+	if err := fl.append(&listRecord{List: updated}); nil != err {
+		return false, err
+	}
+	return true, nil
+}
+
+func (fl *FileListStore) DeleteList(ctx context.Context, ownerId int, id string) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	if !fl.ownsList(ownerId, id) {
+		return false, nil
+	}
+	if err := fl.append(&listRecord{Deleted: id}); nil != err {
+		return false, err
+	}
+	return true, nil
+}
+
+// append writes the record to the log, then applies it. Caller must hold the write lock.
+func (fl *FileListStore) append(rec *listRecord) error {
+	js, err := json.Marshal(rec)
+	if nil != err {
+		return err
+	}
+	line := checksumLine(js)
+	if err := appendLine(fl.file, fl.size, line); nil != err {
+		return err
+	}
+	fl.size += int64(len(line))
+	fl.apply(rec)
+	return nil
+}
+
+// apply the record to the lists held in memory. Caller must hold the write lock.
+func (fl *FileListStore) apply(rec *listRecord) {
+	switch {
+	case nil != rec.List:
+		fl.putList(rec.List)
+	case rec.Deleted != "":
+		delete(fl.lists, rec.Deleted)
+	}
+}
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gatso/model"
+	"os"
+	"time"
+)
+
+// reminderRecord is a line of the reminder log, holding one of its fields.
+type reminderRecord struct {
+	Schedule *reminderSchedule `json:"schedule,omitempty"`
+	Mark     *model.Reminder   `json:"mark,omitempty"` // the reminder, with Sent as it was marked
+}
+
+// reminderSchedule is the set of reminders scheduled for a task.
+type reminderSchedule struct {
+	TaskID    string           `json:"taskId"`
+	Reminders []model.Reminder `json:"reminders"`
+}
+
+// FileReminderStore holds the schedule of reminders in an append only log file, in the same format as the
+// FileDataStore, one change per line.  The schedule is served from memory, loaded from the log when opened.
+type FileReminderStore struct {
+	*MemoryReminderStore
+	file *os.File
+	size int64 // length of the log, up to the end of the last complete record
+}
+
+// Create a new FileReminderStore using the log file at the given path. The file is created if it doesn't exist.
+func NewFileReminderStore(path string) (*FileReminderStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no file path given for the reminders")
+	}
+	fr := &FileReminderStore{MemoryReminderStore: NewMemoryReminderStore()}
+	_, size, err := replayLog(path, func(js []byte) error {
+		var rec reminderRecord
+		if err := json.Unmarshal(js, &rec); nil != err {
+			return err
+		}
+		switch {
+		case nil != rec.Schedule:
+			fr.schedule(rec.Schedule.TaskID, rec.Schedule.Reminders)
+		case nil != rec.Mark:
+			fr.mark(rec.Mark, rec.Mark.Sent)
+		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	fr.size = size
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if nil != err {
+		return nil, err
+	}
+	fr.file = f
+	return fr, nil
+}
+
+// Close the log file. The reminders remain in the file, to be loaded when next opened.
+func (fr *FileReminderStore) Close() {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.file.Close()
+}
+
+func (fr *FileReminderStore) ScheduleReminders(ctx context.Context, taskId string, reminders []model.Reminder) error {
+	if err := ctx.Err(); nil != err {
+		return err
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if err := fr.append(&reminderRecord{Schedule: &reminderSchedule{TaskID: taskId, Reminders: reminders}}); nil != err {
+		return err
+	}
+	fr.schedule(taskId, reminders)
+	return nil
+}
+
+func (fr *FileReminderStore) MarkSent(ctx context.Context, reminder model.Reminder, sent *time.Time) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	r, ok := fr.reminders[reminderKey(&reminder)]
+	if !ok || (nil == sent) == (nil == r.Sent) {
+		return false, nil
+	}
+	reminder.Sent = sent
+	if err := fr.append(&reminderRecord{Mark: &reminder}); nil != err {
+		return false, err
+	}
+	return fr.mark(&reminder, sent), nil
+}
+
+// append writes the record to the log. Caller must hold the write lock.
+func (fr *FileReminderStore) append(rec *reminderRecord) error {
+	js, err := json.Marshal(rec)
+	if nil != err {
+		return err
+	}
+	line := checksumLine(js)
+	if err := appendLine(fr.file, fr.size, line); nil != err {
+		return err
+	}
+	fr.size += int64(len(line))
+	return nil
+}
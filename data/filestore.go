@@ -0,0 +1,307 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+const fileOpPut = "put"
+const fileOpDelete = "delete"
+const compactMinRecords = 1000 // smallest log worth compacting when opened
+
+// FileDataStore is an embedded datastore, holding its tasks in a single append only log file.
+// The tasks are served from memory, every change is appended and synced to the log before it is applied.
+// When opened, the log is replayed to rebuild the tasks, and compacted if mostly made up of old changes.
+// Each record in the log is a single line, a crc32 checksum of the record followed by its json:
+//
+//	<checksum hex> {"op":"put","task":{...}}
+//
+// A torn record at the end of the log, from a crash part way through a write, is discarded.
+type FileDataStore struct {
+	*MemoryDataStore
+	path    string
+	file    *os.File
+	records int   // number of records in the log
+	size    int64 // length of the log, up to the end of the last complete record
+}
+
+// fileRecord is a single change written to the log.
+type fileRecord struct {
+	Op   string              `json:"op"`
+	Task *model.Task         `json:"task,omitempty"`
+	ID   *primitive.ObjectID `json:"id,omitempty"`
+}
+
+// Create a new FileDataStore using the log file at the given path. The file is created if it doesn't exist.
+func NewFileDataStore(path string) (*FileDataStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no file path given for the datastore")
+	}
+	fs := &FileDataStore{
+		MemoryDataStore: NewMemoryDataStore(),
+		path:            path,
+	}
+	if err := fs.load(); nil != err {
+		return nil, err
+	}
+	if fs.records > compactMinRecords && fs.records > len(fs.index.tasks)*2 {
+		if err := fs.Compact(); nil != err {
+			return nil, err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if nil != err {
+		return nil, err
+	}
+	fs.file = f
+	fs.journal = fs
+	return fs, nil
+}
+
+// Drop will remove every task from the store and empty the log file. (Used for testing)
+func (fs *FileDataStore) Drop() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.file.Truncate(0); nil != err {
+		return err
+	}
+	fs.index = newTaskIndex()
+	fs.records = 0
+	fs.size = 0
+	return fs.file.Sync()
+}
+
+// Close the log file. The tasks remain in the file, to be loaded when next opened.
+func (fs *FileDataStore) Close() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.file.Close()
+}
+
+// Compact rewrites the log, so it only holds the current tasks.
+// The new log is written alongside the old one, then moved over it, so a crash leaves one or the other intact.
+func (fs *FileDataStore) Compact() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	tmpPath := fs.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if nil != err {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	w := bufio.NewWriter(tmp)
+	var records int
+	var size int64
+	for _, it := range sortedBySeq(fs.index.all()) {
+		by, err := encodeRecord(fileRecord{Op: fileOpPut, Task: it.task})
+		if nil != err {
+			tmp.Close()
+			return err
+		}
+		w.Write(by)
+		records++
+		size += int64(len(by))
+	}
+	if err := w.Flush(); nil != err {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); nil != err {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); nil != err {
+		return err
+	}
+	if err := os.Rename(tmpPath, fs.path); nil != err {
+		return err
+	}
+	if err := syncDir(filepath.Dir(fs.path)); nil != err {
+		return err
+	}
+	fs.records = records
+	fs.size = size
+
+	// reopen the log, as any open file still refers to the replaced one
+	if nil != fs.file {
+		fs.file.Close()
+		f, err := os.OpenFile(fs.path, os.O_WRONLY|os.O_APPEND, 0600)
+		if nil != err {
+			return err
+		}
+		fs.file = f
+	}
+	return nil
+}
+
+func (fs *FileDataStore) putTask(task *model.Task) error {
+	return fs.append(fileRecord{Op: fileOpPut, Task: task})
+}
+
+func (fs *FileDataStore) deleteTask(id primitive.ObjectID) error {
+	return fs.append(fileRecord{Op: fileOpDelete, ID: &id})
+}
+
+// append writes the record to the end of the log, returning once it is safely on disk.
+func (fs *FileDataStore) append(rec fileRecord) error {
+	by, err := encodeRecord(rec)
+	if nil != err {
+		return err
+	}
+	if err := appendLine(fs.file, fs.size, by); nil != err {
+		return err
+	}
+	fs.records++
+	fs.size += int64(len(by))
+	return nil
+}
+
+// load replays the log into memory.
+func (fs *FileDataStore) load() error {
+	records, size, err := replayLog(fs.path, func(js []byte) error {
+		var rec fileRecord
+		if err := json.Unmarshal(js, &rec); nil != err {
+			return err
+		}
+		return fs.apply(&rec)
+	})
+	fs.records = records
+	fs.size = size
+	return err
+}
+
+// apply replays a single record from the log into memory
+func (fs *FileDataStore) apply(rec *fileRecord) error {
+	switch rec.Op {
+	case fileOpPut:
+		if nil == rec.Task || nil == rec.Task.ID {
+			return fmt.Errorf("put record has no task")
+		}
+		fs.index.put(rec.Task)
+	case fileOpDelete:
+		if nil == rec.ID {
+			return fmt.Errorf("delete record has no id")
+		}
+		fs.index.remove(*rec.ID)
+	default:
+		return fmt.Errorf("unknown record operation %q", rec.Op)
+	}
+	return nil
+}
+
+// encodeRecord formats the record as a single, checksummed, line of the log.
+func encodeRecord(rec fileRecord) ([]byte, error) {
+	js, err := json.Marshal(&rec)
+	if nil != err {
+		return nil, err
+	}
+	return checksumLine(js), nil
+}
+
+// checksumLine formats the json as a single line of a log, prefixed with its checksum.
+func checksumLine(js []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("%08x ", crc32.ChecksumIEEE(js)))
+	buf.Write(js)
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// verifyLine checks a line of a log is complete and matches its checksum, returning its json.
+func verifyLine(line []byte) ([]byte, error) {
+	if len(line) < 10 || line[len(line)-1] != '\n' || line[8] != ' ' {
+		return nil, fmt.Errorf("incomplete record")
+	}
+	sum, err := strconv.ParseUint(string(line[:8]), 16, 32)
+	if nil != err {
+		return nil, fmt.Errorf("invalid checksum: %v", err)
+	}
+	js := line[9 : len(line)-1]
+	if crc32.ChecksumIEEE(js) != uint32(sum) {
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+	return js, nil
+}
+
+// replayLog passes the json of each line of the log at the given path to apply, in order, creating the log if missing.
+// A bad line at the end of the log is treated as a torn write and truncated, a bad line anywhere else is
+// reported as an error.  Returns the number of lines and the length of the log up to the end of the last one.
+func replayLog(path string, apply func(js []byte) error) (int, int64, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if nil != err {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var records int
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return records, offset, nil
+		}
+		if nil != err && err != io.EOF {
+			return records, offset, err
+		}
+
+		js, lineErr := verifyLine(line)
+		if nil != lineErr {
+			if _, err := r.Peek(1); err != io.EOF {
+				return records, offset, fmt.Errorf("datastore file %s is corrupt at offset %d: %v", path, offset, lineErr)
+			}
+			// torn write on the last record, drop it
+			if err := f.Truncate(offset); nil != err {
+				return records, offset, err
+			}
+			return records, offset, f.Sync()
+		}
+		if err := apply(js); nil != err {
+			return records, offset, fmt.Errorf("datastore file %s has an invalid record at offset %d: %v", path, offset, err)
+		}
+		records++
+		offset += int64(len(line))
+	}
+}
+
+// appendLine writes the line to the end of the log, returning once it is safely on disk.
+// size is the length of the log before the line, which it is cut back to if the write fails.
+func appendLine(f *os.File, size int64, line []byte) error {
+	if _, err := f.Write(line); nil != err {
+		// don't leave a partial record for later records to follow
+		f.Truncate(size)
+		return err
+	}
+	return f.Sync()
+}
+
+// sortedBySeq orders the indexed tasks by when they were first added.
+func sortedBySeq(items []*indexedTask) []*indexedTask {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].seq < items[j].seq
+	})
+	return items
+}
+
+// syncDir flushes a directory, so a file renamed into it survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if nil != err {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,282 @@
+package data_test
+
+import (
+	"fmt"
+	"gatso/data"
+	"gatso/data/datastoretest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tempStorePath creates a temporary directory for a test datastore file.  Call the returned func to remove it.
+func tempStorePath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "filestore")
+	if nil != err {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "todo.db"), func() { os.RemoveAll(dir) }
+}
+
+func TestFileDataStore_Conformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunConformance(t, func() data.Datastore {
+		count++
+		fs, err := data.NewFileDataStore(fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return fs
+	})
+}
+
+func TestFileDataStore_ChangeConformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunChangeConformance(t, func() data.Datastore {
+		count++
+		fs, err := data.NewFileDataStore(fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return fs
+	})
+}
+
+func TestFileDataStore_Reopen(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	fs, err := data.NewFileDataStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	task, err := createTestTask([]byte(`{ "owner": 123, "title": "Test Task", "readers": [456] }`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	keepId, err := fs.AddTask(ctx, testOwnerId, *task)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	dropId, err := fs.AddTask(ctx, testOwnerId, *task)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	task = fs.GetTask(ctx, keepId)
+	task.Title = "changed"
+	if _, err := fs.UpdateTask(ctx, testOwnerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+	if deleted, err := fs.DeleteTask(ctx, testOwnerId, dropId, 0); !deleted || nil != err {
+		t.Errorf("Expected delete of task %s to succeed", dropId)
+		return
+	}
+	fs.Close()
+
+	fs, err = data.NewFileDataStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	defer fs.Close()
+
+	tasks, err := pageTasks(fs.GetTasks(ctx, testOwnerId, data.ListOptions{}))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(tasks) != 1 || tasks[0].Id() != keepId || tasks[0].Title != "changed" {
+		t.Errorf("Expected reopened store to hold the updated task %s only, found %d tasks", keepId, len(tasks))
+		return
+	}
+	tasks, err = pageTasks(fs.GetOthersTasks(ctx, 456, data.ListOptions{}))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(tasks) != 1 || tasks[0].Id() != keepId {
+		t.Errorf("Expected reopened store to index who task %s is shared with", keepId)
+		return
+	}
+}
+
+func TestFileDataStore_TornWrite(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	fs, err := data.NewFileDataStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	task, err := createTestTask([]byte(`{ "owner": 123, "title": "Test Task" }`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	id, err := fs.AddTask(ctx, testOwnerId, *task)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	fs.Close()
+
+	// simulate a crash part way through writing another record
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	f.WriteString(`0badc0de {"op":"put","task":{"_id":"`)
+	f.Close()
+
+	fs, err = data.NewFileDataStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if !fs.Exists(ctx, id) || fs.CountTasks(ctx, testOwnerId) != 1 {
+		t.Errorf("Expected task %s to survive the torn write", id)
+		return
+	}
+	// and new records follow on cleanly from the good ones
+	if _, err := fs.AddTask(ctx, testOwnerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+	fs.Close()
+
+	fs, err = data.NewFileDataStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	defer fs.Close()
+	if c := fs.CountTasks(ctx, testOwnerId); c != 2 {
+		t.Errorf("Expected 2 tasks after reopening, found %d", c)
+		return
+	}
+}
+
+func TestFileDataStore_Corrupt(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	fs, err := data.NewFileDataStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	task, err := createTestTask([]byte(`{ "owner": 123, "title": "Test Task" }`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := fs.AddTask(ctx, testOwnerId, *task); nil != err {
+			t.Error(err)
+			return
+		}
+	}
+	fs.Close()
+
+	// alter the first record, so it no longer matches its checksum
+	by, err := ioutil.ReadFile(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	by[20] ^= 0xff
+	if err := ioutil.WriteFile(path, by, 0600); nil != err {
+		t.Error(err)
+		return
+	}
+
+	if _, err := data.NewFileDataStore(path); nil == err {
+		t.Errorf("Expected error opening a corrupt datastore file")
+		return
+	}
+}
+
+func TestFileDataStore_Compact(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	fs, err := data.NewFileDataStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	task, err := createTestTask([]byte(`{ "owner": 123, "title": "Test Task" }`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	id, err := fs.AddTask(ctx, testOwnerId, *task)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	task = fs.GetTask(ctx, id)
+	for i := 0; i < 10; i++ {
+		task.Notes = append(task.Notes, fmt.Sprintf("note %d", i))
+		if task.Version, err = fs.UpdateTask(ctx, testOwnerId, *task); nil != err {
+			t.Error(err)
+			return
+		}
+	}
+	before, err := os.Stat(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+
+	if err := fs.Compact(); nil != err {
+		t.Error(err)
+		return
+	}
+	after, err := os.Stat(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if after.Size() >= before.Size() {
+		t.Errorf("Expected compacted log to be smaller than %d bytes, found %d", before.Size(), after.Size())
+		return
+	}
+
+	// the store keeps writing to the compacted log
+	if _, err := fs.AddTask(ctx, testOwnerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+	fs.Close()
+
+	fs, err = data.NewFileDataStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	defer fs.Close()
+	task = fs.GetTask(ctx, id)
+	if nil == task || len(task.Notes) != 10 {
+		t.Errorf("Expected task %s to keep its notes after compaction", id)
+		return
+	}
+	if c := fs.CountTasks(ctx, testOwnerId); c != 2 {
+		t.Errorf("Expected 2 tasks after compaction, found %d", c)
+		return
+	}
+}
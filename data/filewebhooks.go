@@ -0,0 +1,137 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
+)
+
+// webhookRecord is a line of the webhook log, holding one of its fields.
+// A delivery record replaces any earlier record of the same delivery.
+type webhookRecord struct {
+	Webhook  *model.Webhook  `json:"webhook,omitempty"`
+	Deleted  string          `json:"deleted,omitempty"` // id of a removed webhook
+	Delivery *model.Delivery `json:"delivery,omitempty"`
+}
+
+// FileWebhookStore holds the webhooks and their deliveries in an append only log file, in the same format as the
+// FileDataStore, one change per line.  They are served from memory, loaded from the log when opened.
+type FileWebhookStore struct {
+	*MemoryWebhookStore
+	file *os.File
+	size int64 // length of the log, up to the end of the last complete record
+}
+
+// Create a new FileWebhookStore using the log file at the given path. The file is created if it doesn't exist.
+func NewFileWebhookStore(path string) (*FileWebhookStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no file path given for the webhooks")
+	}
+	fw := &FileWebhookStore{MemoryWebhookStore: NewMemoryWebhookStore()}
+	_, size, err := replayLog(path, func(js []byte) error {
+		var rec webhookRecord
+		if err := json.Unmarshal(js, &rec); nil != err {
+			return err
+		}
+		fw.apply(&rec)
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	fw.size = size
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if nil != err {
+		return nil, err
+	}
+	fw.file = f
+	return fw, nil
+}
+
+// Close the log file. The webhooks remain in the file, to be loaded when next opened.
+func (fw *FileWebhookStore) Close() {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.file.Close()
+}
+
+func (fw *FileWebhookStore) AddWebhook(ctx context.Context, hook model.Webhook) (string, error) {
+	if err := ctx.Err(); nil != err {
+		return "", err
+	}
+	hook.ID = primitive.NewObjectID().Hex()
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if err := fw.append(&webhookRecord{Webhook: &hook}); nil != err {
+		return "", err
+	}
+	return hook.ID, nil
+}
+
+func (fw *FileWebhookStore) DeleteWebhook(ctx context.Context, ownerId int, id string) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if !fw.ownsWebhook(ownerId, id) {
+		return false, nil
+	}
+	if err := fw.append(&webhookRecord{Deleted: id}); nil != err {
+		return false, err
+	}
+	return true, nil
+}
+
+func (fw *FileWebhookStore) AddDelivery(ctx context.Context, delivery model.Delivery) (string, error) {
+	if err := ctx.Err(); nil != err {
+		return "", err
+	}
+	delivery.ID = primitive.NewObjectID().Hex()
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if err := fw.append(&webhookRecord{Delivery: &delivery}); nil != err {
+		return "", err
+	}
+	return delivery.ID, nil
+}
+
+func (fw *FileWebhookStore) UpdateDelivery(ctx context.Context, delivery model.Delivery) error {
+	if err := ctx.Err(); nil != err {
+		return err
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.append(&webhookRecord{Delivery: &delivery})
+}
+
+// append writes the record to the log, then applies it. Caller must hold the write lock.
+func (fw *FileWebhookStore) append(rec *webhookRecord) error {
+	js, err := json.Marshal(rec)
+	if nil != err {
+		return err
+	}
+	line := checksumLine(js)
+	if err := appendLine(fw.file, fw.size, line); nil != err {
+		return err
+	}
+	fw.size += int64(len(line))
+	fw.apply(rec)
+	return nil
+}
+
+// apply the record to the webhooks held in memory. Caller must hold the write lock.
+func (fw *FileWebhookStore) apply(rec *webhookRecord) {
+	switch {
+	case nil != rec.Webhook:
+		fw.putWebhook(rec.Webhook)
+	case rec.Deleted != "":
+		delete(fw.webhooks, rec.Deleted)
+	case nil != rec.Delivery:
+		fw.putDelivery(rec.Delivery)
+	}
+}
@@ -0,0 +1,80 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gatso/model"
+)
+
+// ErrInvalidGroup is returned when a task is shared with a group which isn't known.
+var ErrInvalidGroup = errors.New("invalid group")
+
+// GroupStore holds the groups of users tasks may be shared with.
+type GroupStore interface {
+	// Add a new group, returning its id
+	AddGroup(ctx context.Context, group model.Group) (string, error)
+
+	// Retrieve a single group by its id, nil if it isn't known
+	GetGroup(ctx context.Context, id string) (*model.Group, error)
+
+	// Retrieve every group of the given owner, oldest first
+	Groups(ctx context.Context, ownerId int) ([]*model.Group, error)
+
+	// Retrieve every group the given user is a member of, oldest first
+	MemberOf(ctx context.Context, userId int) ([]*model.Group, error)
+
+	// Replace the name of the group with the same id, if owned by the given owner.
+	// Returns false if the owner has no such group.
+	UpdateGroup(ctx context.Context, ownerId int, group model.Group) (bool, error)
+
+	// Add the user to the members of the group, if owned by the given owner. Adding a member twice changes nothing.
+	// Returns false if the owner has no such group.
+	AddMember(ctx context.Context, ownerId int, groupId string, userId int) (bool, error)
+
+	// Remove the user from the members of the group, if owned by the given owner.
+	// Returns false if the owner has no such group.
+	RemoveMember(ctx context.Context, ownerId int, groupId string, userId int) (bool, error)
+
+	// Remove the group, if owned by the given owner. Returns false if the owner has no such group.
+	// Tasks shared with it are no longer shared with its members.
+	DeleteGroup(ctx context.Context, ownerId int, id string) (bool, error)
+
+	// Close the store and release any resources.
+	Close()
+}
+
+// MemberGroups gets the ids of the groups the user is a member of, as they are now,
+// to list the tasks shared with those groups.
+func MemberGroups(ctx context.Context, groups GroupStore, userId int) ([]string, error) {
+	member, err := groups.MemberOf(ctx, userId)
+	if nil != err {
+		return nil, err
+	}
+	var ids []string
+	for _, g := range member {
+		ids = append(ids, g.ID)
+	}
+	return ids, nil
+}
+
+// CheckGroups checks each group a task is shared with is known, and owned by the owner of the task or has them
+// as a member, so tasks can't be shared with the members of groups the owner has nothing to do with.
+func CheckGroups(ctx context.Context, groups GroupStore, task *model.Task) error {
+	for _, g := range task.ACL {
+		if g.Group == "" {
+			continue
+		}
+		group, err := groups.GetGroup(ctx, g.Group)
+		if nil != err {
+			return err
+		}
+		if nil == group {
+			return fmt.Errorf("%w, group %s not known", ErrInvalidGroup, g.Group)
+		}
+		if group.Owner != task.Owner && !group.HasMember(task.Owner) {
+			return fmt.Errorf("%w, owner %d neither owns nor is a member of group %s", ErrInvalidGroup, task.Owner, g.Group)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package data_test
+
+import (
+	"errors"
+	"fmt"
+	"gatso/data"
+	"gatso/data/datastoretest"
+	"gatso/model"
+	"testing"
+	"time"
+)
+
+func TestMemoryGroupStore_Conformance(t *testing.T) {
+	datastoretest.RunGroupConformance(t, func() data.GroupStore {
+		return data.NewMemoryGroupStore()
+	})
+}
+
+func TestFileGroupStore_Conformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunGroupConformance(t, func() data.GroupStore {
+		count++
+		fg, err := data.NewFileGroupStore(fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return fg
+	})
+}
+
+func TestFileGroupStore_Reopen(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	fg, err := data.NewFileGroupStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	kept, err := fg.AddGroup(ctx, model.Group{Owner: testOwnerId, Name: "kept", Members: []int{1}, Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	removed, err := fg.AddGroup(ctx, model.Group{Owner: testOwnerId, Name: "removed", Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := fg.AddMember(ctx, testOwnerId, kept, 2); nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := fg.RemoveMember(ctx, testOwnerId, kept, 1); nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := fg.DeleteGroup(ctx, testOwnerId, removed); nil != err {
+		t.Error(err)
+		return
+	}
+	fg.Close()
+
+	if fg, err = data.NewFileGroupStore(path); nil != err {
+		t.Error(err)
+		return
+	}
+	defer fg.Close()
+	groups, err := fg.MemberOf(ctx, 2)
+	if nil != err || len(groups) != 1 || groups[0].ID != kept || len(groups[0].Members) != 1 {
+		t.Errorf("Expected only group %s, with its one member, after reopening, found %d groups, %v", kept, len(groups), err)
+	}
+}
+
+func TestCheckGroups(t *testing.T) {
+	gs := data.NewMemoryGroupStore()
+	defer gs.Close()
+
+	groupId, err := gs.AddGroup(ctx, model.Group{Owner: 666, Name: "team", Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	ownedId, err := gs.AddGroup(ctx, model.Group{Owner: testOwnerId, Name: "own", Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	for _, tt := range []struct {
+		acl   []model.Grant
+		valid bool
+	}{
+		{nil, true},
+		{[]model.Grant{{User: 456, Role: model.RoleEditor}}, true},
+		{[]model.Grant{{Group: ownedId, Role: model.RoleReader}}, true},
+		{[]model.Grant{{Group: groupId, Role: model.RoleReader}}, false}, // neither owned nor a member
+		{[]model.Grant{{Group: "madeup", Role: model.RoleReader}}, false},
+	} {
+		task := model.Task{Owner: testOwnerId, ACL: tt.acl}
+		err := data.CheckGroups(ctx, gs, &task)
+		if tt.valid != (nil == err) || (nil != err && !errors.Is(err, data.ErrInvalidGroup)) {
+			t.Errorf("Expected a task shared as %+v to be valid %v, found %v", tt.acl, tt.valid, err)
+		}
+	}
+
+	// membership is read as it is now
+	if _, err := gs.AddMember(ctx, 666, groupId, testOwnerId); nil != err {
+		t.Error(err)
+		return
+	}
+	task := model.Task{Owner: testOwnerId, ACL: []model.Grant{{Group: groupId, Role: model.RoleReader}}}
+	if err := data.CheckGroups(ctx, gs, &task); nil != err {
+		t.Errorf("Expected a task shared with group %s, once its owner is a member, to be valid, found %v", groupId, err)
+	}
+	if groups, err := data.MemberGroups(ctx, gs, testOwnerId); nil != err || len(groups) != 1 || groups[0] != groupId {
+		t.Errorf("Expected owner %d a member of group %s, found %v, %v", testOwnerId, groupId, groups, err)
+	}
+}
@@ -0,0 +1,70 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"log"
+	"time"
+)
+
+// HistoryStore holds the revisions of every task, separate from the tasks themselves.
+// Revisions are never changed once added, they remain after their task is purged.
+type HistoryStore interface {
+	// Add a new revision of a task
+	AddRevision(ctx context.Context, rev model.Revision) error
+
+	// Retrieve every revision of the given task, oldest first.  if the task has no history, returns nil
+	Revisions(ctx context.Context, taskId string) ([]*model.Revision, error)
+
+	// Close the history and release any resources.
+	Close()
+}
+
+// HistoryDataStore records a revision in the HistoryStore for every change made through the datastore it wraps.
+// Each revision holds the task as it was stored by the change.
+type HistoryDataStore struct {
+	changeNotifier
+	history HistoryStore
+}
+
+// revisionActions gives the revision action recording each kind of change.
+var revisionActions = map[string]string{
+	model.EventCreated:  model.RevisionCreate,
+	model.EventUpdated:  model.RevisionUpdate,
+	model.EventDeleted:  model.RevisionDelete,
+	model.EventRestored: model.RevisionRestore,
+}
+
+// Create a new HistoryDataStore recording the changes made to the given datastore into the given history.
+func NewHistoryDataStore(ds Datastore, history HistoryStore) *HistoryDataStore {
+	h := &HistoryDataStore{history: history}
+	h.changeNotifier = changeNotifier{Datastore: ds, notify: h.record}
+	return h
+}
+
+// History gets the store the revisions are recorded in.
+func (h HistoryDataStore) History() HistoryStore {
+	return h.history
+}
+
+// Close the datastore and the history.
+func (h HistoryDataStore) Close() {
+	h.Datastore.Close()
+	h.history.Close()
+}
+
+// record adds a revision of the task as it is now stored.
+// The change has already been made, so a failure to record it is logged rather than returned.
+func (h HistoryDataStore) record(ctx context.Context, ownerId int, change string, task model.Task) {
+	rev := model.Revision{
+		TaskID:    task.Id(),
+		Number:    task.Version,
+		Action:    revisionActions[change],
+		Changed:   time.Now(),
+		ChangedBy: ownerId,
+		Task:      task,
+	}
+	if err := h.history.AddRevision(ctx, rev); nil != err {
+		log.Printf("Failed to record %s of task %s: %v", rev.Action, rev.TaskID, err)
+	}
+}
@@ -0,0 +1,98 @@
+package data_test
+
+import (
+	"fmt"
+	"gatso/data"
+	"gatso/data/datastoretest"
+	"gatso/model"
+	"testing"
+)
+
+func TestHistoryDataStore_Conformance(t *testing.T) {
+	datastoretest.RunConformance(t, func() data.Datastore {
+		return data.NewHistoryDataStore(data.NewMemoryDataStore(), data.NewMemoryHistoryStore())
+	})
+}
+
+func TestMemoryHistoryStore_Conformance(t *testing.T) {
+	datastoretest.RunHistoryConformance(t, func() data.HistoryStore {
+		return data.NewMemoryHistoryStore()
+	})
+}
+
+func TestFileHistoryStore_Conformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunHistoryConformance(t, func() data.HistoryStore {
+		count++
+		fh, err := data.NewFileHistoryStore(fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return fh
+	})
+}
+
+func TestHistoryDataStore_Record(t *testing.T) {
+	hs := data.NewHistoryDataStore(data.NewMemoryDataStore(), data.NewMemoryHistoryStore())
+	defer hs.Close()
+
+	id, err := hs.AddTask(ctx, testOwnerId, model.Task{Owner: testOwnerId, Title: "Test Task"})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	task := hs.GetTask(ctx, id)
+	task.Title = "changed"
+	if _, err := hs.UpdateTask(ctx, testOwnerId, *task); nil != err {
+		t.Error(err)
+		return
+	}
+	if deleted, err := hs.DeleteTask(ctx, testOwnerId, id, 0); !deleted || nil != err {
+		t.Errorf("Expected delete of task %s to succeed, found %v", id, err)
+		return
+	}
+	if restored, err := hs.RestoreTask(ctx, testOwnerId, id); !restored || nil != err {
+		t.Errorf("Expected restore of task %s to succeed, found %v", id, err)
+		return
+	}
+	// rejected changes are not recorded
+	if _, err := hs.UpdateTask(ctx, 666, *task); nil == err {
+		t.Errorf("Expected update of task %s by another owner to fail", id)
+		return
+	}
+
+	revs, err := hs.History().Revisions(ctx, id)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	actions := []string{model.RevisionCreate, model.RevisionUpdate, model.RevisionDelete, model.RevisionRestore}
+	if len(revs) != len(actions) {
+		t.Errorf("Expected %d revisions, found %d", len(actions), len(revs))
+		return
+	}
+	for i, rev := range revs {
+		if rev.Number != i+1 || rev.Action != actions[i] || rev.ChangedBy != testOwnerId || rev.Task.Version != i+1 {
+			t.Errorf("Expected revision %d to %s task %s, found %+v", i+1, actions[i], id, rev)
+			return
+		}
+	}
+	if revs[0].Task.Title != "Test Task" || revs[1].Task.Title != "changed" || nil == revs[2].Task.Deleted {
+		t.Errorf("Expected revisions to hold the task as it was changed")
+		return
+	}
+
+	changes, err := model.Diff(revs[0], revs[1])
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(changes) != 2 || changes[0].Field != "title" || changes[0].From != "Test Task" || changes[0].To != "changed" ||
+		changes[1].Field != "version" {
+		t.Errorf("Expected title and version to change between revisions 1 and 2, found %+v", changes)
+		return
+	}
+}
@@ -0,0 +1,102 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInternalAddress is returned when a webhook url is, or resolves to, an address internal to the network the
+// service runs in.
+var ErrInternalAddress = errors.New("internal webhook address")
+
+// sharedAddressSpace is the carrier grade NAT range, internal although not private.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// WebhookHosts decides the hosts webhooks may be delivered to. Loopback, link-local, private and other internal
+// addresses are refused, so webhooks can't be used to reach hosts only the service can, unless their host is allowed.
+type WebhookHosts struct {
+	allowed  map[string]bool // hosts allowed although internal, in lower case
+	resolver *net.Resolver
+}
+
+// Create a new WebhookHosts, allowing the given hosts, names or addresses, although internal.
+func NewWebhookHosts(allowed []string) *WebhookHosts {
+	h := &WebhookHosts{allowed: map[string]bool{}, resolver: net.DefaultResolver}
+	for _, host := range allowed {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			h.allowed[host] = true
+		}
+	}
+	return h
+}
+
+// CheckURL checks webhooks may be delivered to the host of the url, resolving it.
+func (h WebhookHosts) CheckURL(ctx context.Context, u *url.URL) error {
+	_, err := h.resolve(ctx, u.Hostname())
+	return err
+}
+
+// Client creates the http client delivering webhooks, giving up on each request after the timeout.
+// Hosts are checked as each connection is made, redirects included, and dialled at the addresses checked,
+// so a host can't be pointed at an internal address once its webhook is added.
+func (h WebhookHosts) Client(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // a proxy would make the connections in our place, unchecked
+	dialer := &net.Dialer{Timeout: timeout}
+	transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if nil != err {
+			return nil, err
+		}
+		ips, err := h.resolve(ctx, host)
+		if nil != err {
+			return nil, err
+		}
+		if len(ips) == 0 { // allowed, dialled as given
+			return dialer.DialContext(ctx, network, addr)
+		}
+		for _, ip := range ips {
+			var conn net.Conn
+			if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); nil == err {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+// resolve looks up the addresses of the host, refusing it if any of them is internal.
+// Allowed hosts aren't looked up, returning no addresses.
+func (h WebhookHosts) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	if h.allowed[strings.ToLower(host)] {
+		return nil, nil
+	}
+	addrs, err := h.resolver.LookupIPAddr(ctx, host)
+	if nil != err {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		if internalIP(addr.IP) {
+			return nil, fmt.Errorf("%w, %s is at %s", ErrInternalAddress, host, addr.IP)
+		}
+		ips = append(ips, addr.IP)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+	return ips, nil
+}
+
+// internalIP checks the address is only reachable within the network, or the host, the service runs in.
+func internalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip)
+}
@@ -0,0 +1,61 @@
+package data_test
+
+import (
+	"errors"
+	"gatso/data"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestWebhookHosts_CheckURL(t *testing.T) {
+	hosts := data.NewWebhookHosts([]string{"Intranet.example.com", " 10.1.2.3 "})
+	for _, tc := range []struct {
+		url      string
+		internal bool
+	}{
+		{"http://127.0.0.1/hook", true},
+		{"http://localhost:8080/hook", true},
+		{"http://10.0.0.1/hook", true},
+		{"http://192.168.1.1/hook", true},
+		{"http://169.254.169.254/latest/meta-data", true},
+		{"http://100.64.0.1/hook", true},
+		{"http://[::1]/hook", true},
+		{"http://[fe80::1]/hook", true},
+		{"http://0.0.0.0/hook", true},
+		{"https://93.184.216.34/hook", false},
+		{"https://intranet.example.com/hook", false},
+		{"http://10.1.2.3/hook", false},
+	} {
+		u, err := url.Parse(tc.url)
+		if nil != err {
+			t.Fatal(err)
+		}
+		err = hosts.CheckURL(ctx, u)
+		if tc.internal && !errors.Is(err, data.ErrInternalAddress) {
+			t.Errorf("Expected %s to be refused as internal, found %v", tc.url, err)
+		} else if !tc.internal && nil != err {
+			t.Errorf("Expected %s to be accepted, found %v", tc.url, err)
+		}
+	}
+}
+
+func TestWebhookHosts_Client(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	if res, err := data.NewWebhookHosts(nil).Client(time.Second).Get(srv.URL); nil == err {
+		res.Body.Close()
+		t.Fatalf("Expected the loopback server %s to be refused", srv.URL)
+	} else if !errors.Is(err, data.ErrInternalAddress) {
+		t.Fatalf("Expected the loopback server %s to be refused as internal, found %v", srv.URL, err)
+	}
+
+	res, err := data.NewWebhookHosts([]string{"127.0.0.1"}).Client(time.Second).Get(srv.URL)
+	if nil != err {
+		t.Fatalf("Expected the allowed server %s to be reached, found %v", srv.URL, err)
+	}
+	res.Body.Close()
+}
@@ -0,0 +1,80 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gatso/model"
+)
+
+// ErrInvalidList is returned when a task is put in a list it can't be in.
+var ErrInvalidList = errors.New("invalid list")
+
+// ErrListNotEmpty is returned when a list still holding tasks is deleted.
+var ErrListNotEmpty = errors.New("list still has tasks")
+
+// ListStore holds the named lists of every owner.
+// The default list of each owner isn't held in the store, every owner has it without creating it.
+type ListStore interface {
+	// Add a new list, returning its id
+	AddList(ctx context.Context, list model.List) (string, error)
+
+	// Retrieve a single list by its id, nil if it isn't known
+	GetList(ctx context.Context, id string) (*model.List, error)
+
+	// Retrieve every list of the given owner, oldest first
+	Lists(ctx context.Context, ownerId int) ([]*model.List, error)
+
+	// Replace the name, description, colour and archived flag of the list with the same id, if owned by the given owner.
+	// Returns false if the owner has no such list.
+	UpdateList(ctx context.Context, ownerId int, list model.List) (bool, error)
+
+	// Remove the list, if owned by the given owner. Returns false if the owner has no such list.
+	DeleteList(ctx context.Context, ownerId int, id string) (bool, error)
+
+	// Close the store and release any resources.
+	Close()
+}
+
+// CheckList checks the list a task is saved in is the default list, or one of the lists of its owner.
+// A new task can't be added to an archived list.
+func CheckList(ctx context.Context, lists ListStore, task *model.Task, adding bool) error {
+	id := model.ListOf(task)
+	if id == model.DefaultList {
+		return nil
+	}
+	list, err := lists.GetList(ctx, id)
+	if nil != err {
+		return err
+	}
+	if nil == list || list.Owner != task.Owner {
+		return fmt.Errorf("%w, owner %d has no list %s", ErrInvalidList, task.Owner, id)
+	}
+	if adding && list.Archived {
+		return fmt.Errorf("%w, list %s is archived, so takes no new tasks", ErrInvalidList, id)
+	}
+	return nil
+}
+
+// DeleteList removes the owners list, as ListStore.DeleteList, unless it still holds any tasks outside the trash,
+// when ErrListNotEmpty is returned. Tasks in the trash are left in the deleted list.
+// The default list can't be deleted.
+func DeleteList(ctx context.Context, ds Datastore, lists ListStore, ownerId int, listId string) (bool, error) {
+	if listId == model.DefaultList {
+		return false, nil
+	}
+	opts := ListOptions{Limit: 1, Status: model.Statuses, List: listId}
+	page, err := ds.GetTasks(ctx, ownerId, opts)
+	if nil != err {
+		return false, err
+	}
+	if len(page.Tasks) == 0 {
+		if page, err = ds.GetArchive(ctx, ownerId, model.Task{}, opts); nil != err {
+			return false, err
+		}
+	}
+	if len(page.Tasks) > 0 {
+		return false, fmt.Errorf("%w, move or delete the tasks in list %s first", ErrListNotEmpty, listId)
+	}
+	return lists.DeleteList(ctx, ownerId, listId)
+}
@@ -0,0 +1,132 @@
+package data_test
+
+import (
+	"errors"
+	"fmt"
+	"gatso/data"
+	"gatso/data/datastoretest"
+	"gatso/model"
+	"testing"
+	"time"
+)
+
+func TestMemoryListStore_Conformance(t *testing.T) {
+	datastoretest.RunListConformance(t, func() data.ListStore {
+		return data.NewMemoryListStore()
+	})
+}
+
+func TestFileListStore_Conformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunListConformance(t, func() data.ListStore {
+		count++
+		fl, err := data.NewFileListStore(fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return fl
+	})
+}
+
+func TestFileListStore_Reopen(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	fl, err := data.NewFileListStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	kept, err := fl.AddList(ctx, model.List{Owner: testOwnerId, Name: "kept", Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	removed, err := fl.AddList(ctx, model.List{Owner: testOwnerId, Name: "removed", Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := fl.UpdateList(ctx, testOwnerId, model.List{ID: kept, Name: "renamed", Archived: true}); nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := fl.DeleteList(ctx, testOwnerId, removed); nil != err {
+		t.Error(err)
+		return
+	}
+	fl.Close()
+
+	if fl, err = data.NewFileListStore(path); nil != err {
+		t.Error(err)
+		return
+	}
+	defer fl.Close()
+	lists, err := fl.Lists(ctx, testOwnerId)
+	if nil != err || len(lists) != 1 || lists[0].ID != kept || lists[0].Name != "renamed" || !lists[0].Archived {
+		t.Errorf("Expected only the renamed list %s after reopening, found %d lists, %v", kept, len(lists), err)
+	}
+}
+
+func TestDeleteList(t *testing.T) {
+	ms := data.NewMemoryDataStore()
+	defer ms.Close()
+	ls := data.NewMemoryListStore()
+	defer ls.Close()
+
+	listId, err := ls.AddList(ctx, model.List{Owner: testOwnerId, Name: "project", Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	archivedId, err := ls.AddList(ctx, model.List{Owner: testOwnerId, Name: "old", Archived: true, Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+
+	// tasks may only be put in the owners lists, and not added to archived lists
+	for _, tt := range []struct {
+		task   model.Task
+		adding bool
+		valid  bool
+	}{
+		{model.Task{Owner: testOwnerId}, true, true},
+		{model.Task{Owner: testOwnerId, List: model.DefaultList}, true, true},
+		{model.Task{Owner: testOwnerId, List: listId}, true, true},
+		{model.Task{Owner: 666, List: listId}, true, false},
+		{model.Task{Owner: testOwnerId, List: "madeup"}, false, false},
+		{model.Task{Owner: testOwnerId, List: archivedId}, true, false},
+		{model.Task{Owner: testOwnerId, List: archivedId}, false, true},
+	} {
+		err := data.CheckList(ctx, ls, &tt.task, tt.adding)
+		if tt.valid != (nil == err) || (nil != err && !errors.Is(err, data.ErrInvalidList)) {
+			t.Errorf("Expected task %+v in list %q to be valid %v, found %v", tt.task, tt.task.List, tt.valid, err)
+		}
+	}
+
+	taskId, err := ms.AddTask(ctx, testOwnerId, model.Task{Owner: testOwnerId, Title: "Test Task", List: listId,
+		Status: model.StatusDone})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if deleted, err := data.DeleteList(ctx, ms, ls, testOwnerId, listId); deleted || !errors.Is(err, data.ErrListNotEmpty) {
+		t.Errorf("Expected list %s holding a done task not to be deleted, found %v, %v", listId, deleted, err)
+		return
+	}
+	if _, err := ms.DeleteTask(ctx, testOwnerId, taskId, 0); nil != err {
+		t.Error(err)
+		return
+	}
+	if deleted, err := data.DeleteList(ctx, ms, ls, testOwnerId, listId); !deleted || nil != err {
+		t.Errorf("Expected list %s with its tasks in the trash to be deleted, found %v, %v", listId, deleted, err)
+		return
+	}
+	if deleted, err := data.DeleteList(ctx, ms, ls, testOwnerId, model.DefaultList); deleted || nil != err {
+		t.Errorf("Expected the default list not to be deleted, found %v, %v", deleted, err)
+	}
+}
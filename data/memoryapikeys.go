@@ -0,0 +1,130 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryAPIKeyStore holds the api keys in memory.
+type MemoryAPIKeyStore struct {
+	mu     sync.RWMutex
+	keys   map[string]*model.APIKey
+	byHash map[string]string // id of the key with each hash
+}
+
+// Create a new, empty MemoryAPIKeyStore
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: map[string]*model.APIKey{}, byHash: map[string]string{}}
+}
+
+// Close releases the keys held by the store.
+func (m *MemoryAPIKeyStore) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = map[string]*model.APIKey{}
+	m.byHash = map[string]string{}
+}
+
+func (m *MemoryAPIKeyStore) AddKey(ctx context.Context, key model.APIKey) (string, error) {
+	if err := ctx.Err(); nil != err {
+		return "", err
+	}
+	key.ID = primitive.NewObjectID().Hex()
+	key.Key = ""
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.putKey(&key)
+	return key.ID, nil
+}
+
+func (m *MemoryAPIKeyStore) Keys(ctx context.Context, ownerId int) ([]*model.APIKey, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var keys []*model.APIKey
+	for _, key := range m.keys {
+		if key.Owner == ownerId {
+			keys = append(keys, copyKey(key))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].Created.Equal(keys[j].Created) {
+			return keys[i].Created.Before(keys[j].Created)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+func (m *MemoryAPIKeyStore) KeyByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	id, ok := m.byHash[hash]
+	if !ok {
+		return nil, nil
+	}
+	return copyKey(m.keys[id]), nil
+}
+
+func (m *MemoryAPIKeyStore) KeyUsed(ctx context.Context, id string, at time.Time) error {
+	if err := ctx.Err(); nil != err {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if key, ok := m.keys[id]; ok {
+		key.LastUsed = &at
+	}
+	return nil
+}
+
+func (m *MemoryAPIKeyStore) RevokeKey(ctx context.Context, ownerId int, id string) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.owned(ownerId, id) {
+		return false, nil
+	}
+	m.removeKey(id)
+	return true, nil
+}
+
+// owned checks the key exists and belongs to the owner. Caller must hold the lock.
+func (m *MemoryAPIKeyStore) owned(ownerId int, id string) bool {
+	key, ok := m.keys[id]
+	return ok && key.Owner == ownerId
+}
+
+// putKey stores a copy of the key. Caller must hold the write lock.
+func (m *MemoryAPIKeyStore) putKey(key *model.APIKey) {
+	m.keys[key.ID] = copyKey(key)
+	m.byHash[key.Hash] = key.ID
+}
+
+// removeKey removes the key, if it exists. Caller must hold the write lock.
+func (m *MemoryAPIKeyStore) removeKey(id string) {
+	if key, ok := m.keys[id]; ok {
+		delete(m.byHash, key.Hash)
+		delete(m.keys, id)
+	}
+}
+
+func copyKey(k *model.APIKey) *model.APIKey {
+	c := *k
+	if nil != k.LastUsed {
+		used := *k.LastUsed
+		c.LastUsed = &used
+	}
+	return &c
+}
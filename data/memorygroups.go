@@ -0,0 +1,188 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"sync"
+)
+
+// MemoryGroupStore holds the groups in memory.
+type MemoryGroupStore struct {
+	mu     sync.RWMutex
+	groups map[string]*model.Group
+}
+
+// Create a new, empty MemoryGroupStore
+func NewMemoryGroupStore() *MemoryGroupStore {
+	return &MemoryGroupStore{groups: map[string]*model.Group{}}
+}
+
+// Close releases the groups held by the store.
+func (m *MemoryGroupStore) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.groups = map[string]*model.Group{}
+}
+
+func (m *MemoryGroupStore) AddGroup(ctx context.Context, group model.Group) (string, error) {
+	if err := ctx.Err(); nil != err {
+		return "", err
+	}
+	group.ID = primitive.NewObjectID().Hex()
+	group.Members = distinctMembers(group.Members)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.putGroup(&group)
+	return group.ID, nil
+}
+
+func (m *MemoryGroupStore) GetGroup(ctx context.Context, id string) (*model.Group, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	group, ok := m.groups[id]
+	if !ok {
+		return nil, nil
+	}
+	return copyGroup(group), nil
+}
+
+func (m *MemoryGroupStore) Groups(ctx context.Context, ownerId int) ([]*model.Group, error) {
+	return m.find(ctx, func(g *model.Group) bool {
+		return g.Owner == ownerId
+	})
+}
+
+func (m *MemoryGroupStore) MemberOf(ctx context.Context, userId int) ([]*model.Group, error) {
+	return m.find(ctx, func(g *model.Group) bool {
+		return g.HasMember(userId)
+	})
+}
+
+func (m *MemoryGroupStore) UpdateGroup(ctx context.Context, ownerId int, group model.Group) (bool, error) {
+	return m.change(ctx, ownerId, group.ID, func(g *model.Group) {
+		g.Name = group.Name
+	})
+}
+
+func (m *MemoryGroupStore) AddMember(ctx context.Context, ownerId int, groupId string, userId int) (bool, error) {
+	return m.change(ctx, ownerId, groupId, func(g *model.Group) {
+		g.Members = distinctMembers(append(g.Members, userId))
+	})
+}
+
+func (m *MemoryGroupStore) RemoveMember(ctx context.Context, ownerId int, groupId string, userId int) (bool, error) {
+	return m.change(ctx, ownerId, groupId, func(g *model.Group) {
+		g.Members = withoutMember(g.Members, userId)
+	})
+}
+
+func (m *MemoryGroupStore) DeleteGroup(ctx context.Context, ownerId int, id string) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if nil == m.owned(ownerId, id) {
+		return false, nil
+	}
+	delete(m.groups, id)
+	return true, nil
+}
+
+// find gets a copy of each group matching the filter, oldest first.
+func (m *MemoryGroupStore) find(ctx context.Context, filter func(g *model.Group) bool) ([]*model.Group, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var groups []*model.Group
+	for _, group := range m.groups {
+		if filter(group) {
+			groups = append(groups, copyGroup(group))
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if !groups[i].Created.Equal(groups[j].Created) {
+			return groups[i].Created.Before(groups[j].Created)
+		}
+		return groups[i].ID < groups[j].ID
+	})
+	return groups, nil
+}
+
+// change stores the owners group as changed by the given func, returning false if the owner has no such group.
+func (m *MemoryGroupStore) change(ctx context.Context, ownerId int, id string, change func(g *model.Group)) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed := m.changed(ownerId, id, change)
+	if nil == changed {
+		return false, nil
+	}
+	m.putGroup(changed)
+	return true, nil
+}
+
+// changed gets a copy of the owners group, as changed by the given func, nil if the owner has no such group.
+// Caller must hold the lock.
+func (m *MemoryGroupStore) changed(ownerId int, id string, change func(g *model.Group)) *model.Group {
+	group := m.owned(ownerId, id)
+	if nil != group {
+		change(group)
+	}
+	return group
+}
+
+// owned gets a copy of the stored group, nil if it doesn't exist or belongs to someone else.
+// Caller must hold the lock.
+func (m *MemoryGroupStore) owned(ownerId int, id string) *model.Group {
+	group, ok := m.groups[id]
+	if !ok || group.Owner != ownerId {
+		return nil
+	}
+	return copyGroup(group)
+}
+
+// putGroup stores a copy of the group. Caller must hold the write lock.
+func (m *MemoryGroupStore) putGroup(group *model.Group) {
+	m.groups[group.ID] = copyGroup(group)
+}
+
+func copyGroup(g *model.Group) *model.Group {
+	c := *g
+	c.Members = append([]int(nil), g.Members...)
+	return &c
+}
+
+// distinctMembers lists each of the members once, lowest first.
+func distinctMembers(members []int) []int {
+	seen := map[int]bool{}
+	var distinct []int
+	for _, m := range members {
+		if !seen[m] {
+			seen[m] = true
+			distinct = append(distinct, m)
+		}
+	}
+	sort.Ints(distinct)
+	return distinct
+}
+
+// withoutMember lists the members, leaving out the given user.
+func withoutMember(members []int, userId int) []int {
+	var kept []int
+	for _, m := range members {
+		if m != userId {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,62 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"sort"
+	"sync"
+)
+
+// MemoryHistoryStore holds the revisions of every task in memory.
+type MemoryHistoryStore struct {
+	mu        sync.RWMutex
+	revisions map[string][]*model.Revision
+}
+
+// Create a new, empty MemoryHistoryStore
+func NewMemoryHistoryStore() *MemoryHistoryStore {
+	return &MemoryHistoryStore{revisions: map[string][]*model.Revision{}}
+}
+
+// Close releases the revisions held by the store.
+func (m *MemoryHistoryStore) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revisions = map[string][]*model.Revision{}
+}
+
+func (m *MemoryHistoryStore) AddRevision(ctx context.Context, rev model.Revision) error {
+	if err := ctx.Err(); nil != err {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.add(&rev)
+	return nil
+}
+
+func (m *MemoryHistoryStore) Revisions(ctx context.Context, taskId string) ([]*model.Revision, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var revs []*model.Revision
+	for _, rev := range m.revisions[taskId] {
+		c := *rev
+		c.Task = *copyTask(&rev.Task)
+		revs = append(revs, &c)
+	}
+	return revs, nil
+}
+
+// add stores a copy of the revision, in order of its number. Caller must hold the write lock.
+func (m *MemoryHistoryStore) add(rev *model.Revision) {
+	c := *rev
+	c.Task = *copyTask(&rev.Task)
+	revs := append(m.revisions[rev.TaskID], &c)
+	sort.SliceStable(revs, func(i, j int) bool {
+		return revs[i].Number < revs[j].Number
+	})
+	m.revisions[rev.TaskID] = revs
+}
@@ -0,0 +1,127 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"sync"
+)
+
+// MemoryListStore holds the lists in memory.
+type MemoryListStore struct {
+	mu    sync.RWMutex
+	lists map[string]*model.List
+}
+
+// Create a new, empty MemoryListStore
+func NewMemoryListStore() *MemoryListStore {
+	return &MemoryListStore{lists: map[string]*model.List{}}
+}
+
+// Close releases the lists held by the store.
+func (m *MemoryListStore) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lists = map[string]*model.List{}
+}
+
+func (m *MemoryListStore) AddList(ctx context.Context, list model.List) (string, error) {
+	if err := ctx.Err(); nil != err {
+		return "", err
+	}
+	list.ID = primitive.NewObjectID().Hex()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.putList(&list)
+	return list.ID, nil
+}
+
+func (m *MemoryListStore) GetList(ctx context.Context, id string) (*model.List, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list, ok := m.lists[id]
+	if !ok {
+		return nil, nil
+	}
+	c := *list
+	return &c, nil
+}
+
+func (m *MemoryListStore) Lists(ctx context.Context, ownerId int) ([]*model.List, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var lists []*model.List
+	for _, list := range m.lists {
+		if list.Owner == ownerId {
+			c := *list
+			lists = append(lists, &c)
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		if !lists[i].Created.Equal(lists[j].Created) {
+			return lists[i].Created.Before(lists[j].Created)
+		}
+		return lists[i].ID < lists[j].ID
+	})
+	return lists, nil
+}
+
+func (m *MemoryListStore) UpdateList(ctx context.Context, ownerId int, list model.List) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	updated := m.updated(ownerId, list)
+	if nil == updated {
+		return false, nil
+	}
+	m.putList(updated)
+	return true, nil
+}
+
+func (m *MemoryListStore) DeleteList(ctx context.Context, ownerId int, id string) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.ownsList(ownerId, id) {
+		return false, nil
+	}
+	delete(m.lists, id)
+	return true, nil
+}
+
+// updated gets the stored list with the fields of the given list which may change, nil if the owner has no such list.
+// Caller must hold the lock.
+func (m *MemoryListStore) updated(ownerId int, list model.List) *model.List {
+	if !m.ownsList(ownerId, list.ID) {
+		return nil
+	}
+	c := *m.lists[list.ID]
+	c.Name = list.Name
+	c.Description = list.Description
+	c.Colour = list.Colour
+	c.Archived = list.Archived
+	return &c
+}
+
+// ownsList checks the list exists and belongs to the owner. Caller must hold the lock.
+func (m *MemoryListStore) ownsList(ownerId int, id string) bool {
+	list, ok := m.lists[id]
+	return ok && list.Owner == ownerId
+}
+
+// putList stores a copy of the list. Caller must hold the write lock.
+func (m *MemoryListStore) putList(list *model.List) {
+	c := *list
+	m.lists[list.ID] = &c
+}
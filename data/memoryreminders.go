@@ -0,0 +1,116 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"gatso/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryReminderStore holds the schedule of reminders in memory.
+type MemoryReminderStore struct {
+	mu        sync.RWMutex
+	reminders map[string]*model.Reminder // by reminderKey
+}
+
+// Create a new, empty MemoryReminderStore
+func NewMemoryReminderStore() *MemoryReminderStore {
+	return &MemoryReminderStore{reminders: map[string]*model.Reminder{}}
+}
+
+// Close releases the reminders held by the store.
+func (m *MemoryReminderStore) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reminders = map[string]*model.Reminder{}
+}
+
+func (m *MemoryReminderStore) ScheduleReminders(ctx context.Context, taskId string, reminders []model.Reminder) error {
+	if err := ctx.Err(); nil != err {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schedule(taskId, reminders)
+	return nil
+}
+
+func (m *MemoryReminderStore) DueReminders(ctx context.Context, due time.Time, limit int) ([]*model.Reminder, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var reminders []*model.Reminder
+	for _, r := range m.reminders {
+		if nil == r.Sent && !r.At.After(due) {
+			reminders = append(reminders, copyReminder(r))
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		if !reminders[i].At.Equal(reminders[j].At) {
+			return reminders[i].At.Before(reminders[j].At)
+		}
+		return reminderKey(reminders[i]) < reminderKey(reminders[j])
+	})
+	if len(reminders) > limit {
+		reminders = reminders[:limit]
+	}
+	return reminders, nil
+}
+
+func (m *MemoryReminderStore) MarkSent(ctx context.Context, reminder model.Reminder, sent *time.Time) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mark(&reminder, sent), nil
+}
+
+// schedule replaces the unsent reminders of the task. Caller must hold the write lock.
+func (m *MemoryReminderStore) schedule(taskId string, reminders []model.Reminder) {
+	for key, r := range m.reminders {
+		if r.TaskID == taskId && nil == r.Sent {
+			delete(m.reminders, key)
+		}
+	}
+	for i := range reminders {
+		r := &reminders[i]
+		key := reminderKey(r)
+		if _, ok := m.reminders[key]; !ok {
+			m.reminders[key] = &model.Reminder{TaskID: taskId, Before: r.Before, At: r.At}
+		}
+	}
+}
+
+// mark the reminder as sent, or unsent, if it isn't already. Caller must hold the write lock.
+func (m *MemoryReminderStore) mark(reminder *model.Reminder, sent *time.Time) bool {
+	r, ok := m.reminders[reminderKey(reminder)]
+	if !ok || (nil == sent) == (nil == r.Sent) {
+		return false
+	}
+	if nil == sent {
+		r.Sent = nil
+	} else {
+		t := *sent
+		r.Sent = &t
+	}
+	return true
+}
+
+// reminderKey identifies a reminder by its task, Before and At.
+func reminderKey(r *model.Reminder) string {
+	return fmt.Sprintf("%s/%d/%d", r.TaskID, r.Before, r.At.UnixNano())
+}
+
+func copyReminder(r *model.Reminder) *model.Reminder {
+	c := *r
+	if nil != r.Sent {
+		sent := *r.Sent
+		c.Sent = &sent
+	}
+	return &c
+}
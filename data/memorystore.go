@@ -0,0 +1,686 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryDataStore is an in-process implementation of the datastore, holding all tasks in memory.
+// Nothing is persisted, unless a journal is attached, so it is intended for testing and local development.
+type MemoryDataStore struct {
+	mu      sync.RWMutex
+	index   *taskIndex
+	journal journal
+}
+
+// journal records each change to the tasks before it is applied to the store.
+type journal interface {
+	putTask(task *model.Task) error
+	deleteTask(id primitive.ObjectID) error
+}
+
+// Create a new, empty MemoryDataStore
+func NewMemoryDataStore() *MemoryDataStore {
+	return &MemoryDataStore{index: newTaskIndex()}
+}
+
+// Drop will remove every task from the store. (Used for testing)
+func (m *MemoryDataStore) Drop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.index = newTaskIndex()
+	return nil
+}
+
+// Close releases the tasks held by the store.
+func (m *MemoryDataStore) Close() {
+	m.Drop()
+}
+
+func (m *MemoryDataStore) GetTasks(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error) {
+	return m.query(ctx, func(ix *taskIndex) []*indexedTask {
+		return ix.owned(ownerId)
+	}, listed, nil, opts)
+}
+
+func (m *MemoryDataStore) GetOthersTasks(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error) {
+	return m.query(ctx, func(ix *taskIndex) []*indexedTask {
+		return ix.shared(ownerId, opts.Groups)
+	}, listed, func(t *model.Task) bool {
+		return model.MemberRoleOf(t, ownerId, opts.Groups, time.Now()) != ""
+	}, opts)
+}
+
+func (m *MemoryDataStore) FindTasks(ctx context.Context, ownerId int, query model.Task, opts ListOptions) (model.TaskPage, error) {
+	return m.query(ctx, func(ix *taskIndex) []*indexedTask {
+		return ix.owned(ownerId)
+	}, listed, func(t *model.Task) bool {
+		return matchesQuery(t, query)
+	}, opts.withQueryStatus(query))
+}
+
+func (m *MemoryDataStore) GetSubtasks(ctx context.Context, taskId string, opts ListOptions) (model.TaskPage, error) {
+	return m.query(ctx, func(ix *taskIndex) []*indexedTask {
+		parent := m.lookup(taskId)
+		if nil == parent {
+			return nil
+		}
+		return ix.owned(parent.Owner) // subtasks are owned by the owner of their parent
+	}, listed, func(t *model.Task) bool {
+		return t.Parent == taskId
+	}, opts)
+}
+
+func (m *MemoryDataStore) GetDependents(ctx context.Context, taskId string, opts ListOptions) (model.TaskPage, error) {
+	return m.query(ctx, func(ix *taskIndex) []*indexedTask {
+		blocker := m.lookup(taskId)
+		if nil == blocker {
+			return nil
+		}
+		return ix.owned(blocker.Owner) // tasks are only blocked by tasks of their owner
+	}, listed, func(t *model.Task) bool {
+		return containsString(t.BlockedBy, taskId)
+	}, opts)
+}
+
+func (m *MemoryDataStore) GetTrash(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error) {
+	return m.query(ctx, func(ix *taskIndex) []*indexedTask {
+		return ix.owned(ownerId)
+	}, trashed, nil, opts)
+}
+
+func (m *MemoryDataStore) GetArchive(ctx context.Context, ownerId int, query model.Task, opts ListOptions) (model.TaskPage, error) {
+	return m.query(ctx, func(ix *taskIndex) []*indexedTask {
+		return ix.owned(ownerId)
+	}, archived, func(t *model.Task) bool {
+		return matchesQuery(t, query)
+	}, opts.withQueryStatus(query))
+}
+
+func (m *MemoryDataStore) AddTask(ctx context.Context, ownerId int, task model.Task) (string, error) {
+	if err := ctx.Err(); nil != err {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.add(ctx, ownerId, task)
+}
+
+func (m *MemoryDataStore) UpdateTask(ctx context.Context, ownerId int, task model.Task) (int, error) {
+	if err := ctx.Err(); nil != err {
+		return 0, err
+	}
+	if err := checkStatus(&task); nil != err {
+		return 0, err
+	}
+	if err := checkACL(&task); nil != err {
+		return 0, err
+	}
+	task.List = model.ListOf(&task)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var existing *model.Task
+	if nil != task.ID {
+		existing = m.index.get(*task.ID)
+	}
+	if nil == existing { // doesn't exist, treat as an Add
+		if task.Version != 0 {
+			return 0, ErrVersionConflict
+		}
+		if _, err := m.add(ctx, ownerId, task); nil != err {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	if err := checkAccess(existing, ownerId, neededRole(existing, &task)); nil != err {
+		return 0, err
+	}
+	if !updatable(existing, ownerId, task.Status, task.Version) {
+		return 0, updateRefused(existing, ownerId, task.Status)
+	}
+	if err := checkParent(&task, m.lookup); nil != err {
+		return 0, err
+	}
+	if err := checkDependencies(&task, m.lookup); nil != err {
+		return 0, err
+	}
+	task.Version = existing.Version + 1
+	task.Deleted = nil
+	task.Archived = nil
+	task.Unarchived = existing.Unarchived
+	task.Recurred = existing.Recurred
+	task.CompletedAt = completedAt(task.Status, existing)
+	task.Urgency = 0
+	if err := m.put(ctx, &task); nil != err {
+		return 0, err
+	}
+	return task.Version, nil
+}
+
+func (m *MemoryDataStore) SetTaskStatus(ctx context.Context, ownerId int, taskId string, status string, version int) (int, error) {
+	if err := ctx.Err(); nil != err {
+		return 0, err
+	}
+	docId, err := primitive.ObjectIDFromHex(taskId)
+	if nil != err {
+		return 0, nil
+	}
+	task := model.Task{Status: status}
+	if err := checkStatus(&task); nil != err {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing := m.index.get(docId)
+	if nil == existing || model.RoleOf(existing, ownerId, time.Now()) == "" || nil != existing.Deleted {
+		return 0, nil
+	}
+	if !updatable(existing, ownerId, task.Status, version) {
+		return 0, updateRefused(existing, ownerId, task.Status)
+	}
+	updated := copyTask(existing)
+	updated.Status = task.Status
+	updated.CompletedAt = completedAt(task.Status, existing)
+	updated.Version++
+	if err := m.put(ctx, updated); nil != err {
+		return 0, err
+	}
+	return updated.Version, nil
+}
+
+func (m *MemoryDataStore) DeleteTask(ctx context.Context, ownerId int, taskId string, version int) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	docId, err := primitive.ObjectIDFromHex(taskId)
+	if nil != err {
+		return false, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing := m.index.get(docId)
+	if nil == existing || !model.Allows(model.RoleOf(existing, ownerId, time.Now()), model.RoleAdmin) ||
+		nil != existing.Deleted {
+		return false, nil
+	}
+	if version != 0 && version != existing.Version {
+		return false, ErrVersionConflict
+	}
+	task := copyTask(existing)
+	now := time.Now()
+	task.Deleted = &now
+	task.Version++
+	if err := m.put(ctx, task); nil != err {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *MemoryDataStore) RestoreTask(ctx context.Context, ownerId int, taskId string) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	docId, err := primitive.ObjectIDFromHex(taskId)
+	if nil != err {
+		return false, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing := m.index.get(docId)
+	if nil == existing || !model.Allows(model.RoleOf(existing, ownerId, time.Now()), model.RoleAdmin) ||
+		nil == existing.Deleted {
+		return false, nil
+	}
+	task := copyTask(existing)
+	task.Deleted = nil
+	task.Version++
+	if err := m.put(ctx, task); nil != err {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *MemoryDataStore) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	if err := ctx.Err(); nil != err {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int
+	for _, it := range m.index.all() {
+		if nil == it.task.Deleted || !it.task.Deleted.Before(before) {
+			continue
+		}
+		if nil != m.journal {
+			if err := m.journal.deleteTask(*it.task.ID); nil != err {
+				return purged, err
+			}
+		}
+		m.index.remove(*it.task.ID)
+		purged++
+	}
+	return purged, nil
+}
+
+func (m *MemoryDataStore) UnarchiveTask(ctx context.Context, ownerId int, taskId string) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	docId, err := primitive.ObjectIDFromHex(taskId)
+	if nil != err {
+		return false, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing := m.index.get(docId)
+	if nil == existing || existing.Owner != ownerId || nil != existing.Deleted || nil == existing.Archived {
+		return false, nil
+	}
+	task := copyTask(existing)
+	now := time.Now()
+	task.Archived = nil
+	task.Unarchived = &now
+	task.Version++
+	if err := m.put(ctx, task); nil != err {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *MemoryDataStore) ArchiveTasks(ctx context.Context, expiredBefore time.Time) (int, error) {
+	if err := ctx.Err(); nil != err {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int
+	now := time.Now()
+	for _, it := range m.index.all() {
+		if !archivable(it.task, expiredBefore) {
+			continue
+		}
+		task := copyTask(it.task)
+		task.Archived = &now
+		task.Version++
+		if err := m.put(ctx, task); nil != err {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (m *MemoryDataStore) DueRecurrences(ctx context.Context, before time.Time, limit int) ([]*model.Task, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var due []*model.Task
+	for _, it := range m.index.all() {
+		if !listed.includes(it.task) || !recurrenceDue(it.task) || !it.task.Expires.Before(before) {
+			continue
+		}
+		due = append(due, it.task)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].Expires.Before(due[j].Expires)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	var tasks []*model.Task
+	for _, t := range due {
+		tasks = append(tasks, copyTask(t))
+	}
+	return tasks, nil
+}
+
+func (m *MemoryDataStore) MarkRecurred(ctx context.Context, taskId string, expires time.Time) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	docId, err := primitive.ObjectIDFromHex(taskId)
+	if nil != err {
+		return false, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing := m.index.get(docId)
+	if nil == existing {
+		return false, nil
+	}
+	task := copyTask(existing)
+	if expires.IsZero() {
+		task.Recurred = nil
+	} else {
+		if !existing.Expires.Equal(expires) || !recurrenceDue(existing) {
+			return false, nil
+		}
+		task.Recurred = &expires
+	}
+	if err := m.put(ctx, task); nil != err {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *MemoryDataStore) GetTask(ctx context.Context, taskId string) *model.Task {
+	if nil != ctx.Err() {
+		return nil
+	}
+	docId, err := primitive.ObjectIDFromHex(taskId)
+	if nil != err {
+		return nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	task := m.index.get(docId)
+	if nil == task {
+		return nil
+	}
+	return copyTask(task)
+}
+
+func (m *MemoryDataStore) CountTasks(ctx context.Context, ownerId int) int {
+	if nil != ctx.Err() {
+		return -1
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var count int
+	for _, it := range m.index.owned(ownerId) {
+		if listed.includes(it.task) {
+			count++
+		}
+	}
+	return count
+}
+
+func (m *MemoryDataStore) Exists(ctx context.Context, taskId string) bool {
+	return m.GetTask(ctx, taskId) != nil
+}
+
+func (m *MemoryDataStore) Users(ctx context.Context) ([]int, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.index.owners(), nil
+}
+
+// add stores the task as a new task of the owner. Caller must hold the write lock.
+func (m *MemoryDataStore) add(ctx context.Context, ownerId int, task model.Task) (string, error) {
+	if task.Owner != ownerId {
+		return "", fmt.Errorf("Owner %d does not own the given task to add", ownerId)
+	}
+
+	task.Created = time.Now()
+	oid := primitive.NewObjectID()
+	task.ID = &oid
+	task.Version = 1
+	task.Deleted = nil
+	task.Archived = nil
+	task.Unarchived = nil
+	task.Recurred = nil
+	task.Urgency = 0
+	if err := checkStatus(&task); nil != err {
+		return "", err
+	}
+	if err := checkACL(&task); nil != err {
+		return "", err
+	}
+	task.List = model.ListOf(&task)
+	if err := checkParent(&task, m.lookup); nil != err {
+		return "", err
+	}
+	if err := checkDependencies(&task, m.lookup); nil != err {
+		return "", err
+	}
+	task.CompletedAt = completedAt(task.Status, nil)
+
+	if err := m.put(ctx, &task); nil != err {
+		return "", err
+	}
+	return oid.Hex(), nil
+}
+
+// lookup returns the stored task with the given id, or nil if not known. Caller must hold the lock.
+func (m *MemoryDataStore) lookup(taskId string) *model.Task {
+	docId, err := primitive.ObjectIDFromHex(taskId)
+	if nil != err {
+		return nil
+	}
+	return m.index.get(docId)
+}
+
+// put journals a copy of the given task and stores it, recording it as written under the context.
+// Caller must hold the write lock.
+func (m *MemoryDataStore) put(ctx context.Context, task *model.Task) error {
+	task = copyTask(task)
+	if nil != m.journal {
+		if err := m.journal.putTask(task); nil != err {
+			return err
+		}
+	}
+	m.index.put(task)
+	wrote(ctx, task)
+	return nil
+}
+
+// listing selects which of the trash, the archive, or the remaining tasks a query collects from.
+type listing int
+
+const (
+	listed listing = iota
+	trashed
+	archived
+)
+
+// includes reports if the task is in the listing. Tasks in the trash are only in the trash, even when archived.
+func (l listing) includes(t *model.Task) bool {
+	switch l {
+	case trashed:
+		return nil != t.Deleted
+	case archived:
+		return nil == t.Deleted && nil != t.Archived
+	default:
+		return nil == t.Deleted && nil == t.Archived
+	}
+}
+
+// archivable reports if the task should be archived, having expired before the given time
+// and not been unarchived since it expired.
+func archivable(t *model.Task, expiredBefore time.Time) bool {
+	if nil != t.Deleted || nil != t.Archived || t.Expires.IsZero() || !t.Expires.Before(expiredBefore) {
+		return false
+	}
+	return nil == t.Unarchived || t.Unarchived.Before(t.Expires)
+}
+
+// updatable checks the user may edit the stored task, moving it to the given status,
+// and that it is at the given version, unless that is 0.
+func updatable(existing *model.Task, ownerId int, status string, version int) bool {
+	return model.Allows(model.RoleOf(existing, ownerId, time.Now()), model.RoleEditor) && nil == existing.Deleted && nil == existing.Archived &&
+		model.CanChangeStatus(model.StatusOf(existing), status) && (version == 0 || version == existing.Version)
+}
+
+// recurrenceDue reports if the task recurs, without its next occurrence being added since it last expired.
+func recurrenceDue(t *model.Task) bool {
+	return t.Recurrence != "" && !t.Expires.IsZero() && (nil == t.Recurred || t.Recurred.Before(t.Expires))
+}
+
+// query collects copies of the page of candidate tasks in the given listing which pass the given filter,
+// in the listing order.
+func (m *MemoryDataStore) query(ctx context.Context, candidates func(ix *taskIndex) []*indexedTask,
+	in listing, filter func(t *model.Task) bool, opts ListOptions) (model.TaskPage, error) {
+	if err := ctx.Err(); nil != err {
+		return model.TaskPage{}, err
+	}
+	byUrgency := opts.Sort.ByUrgency()
+	var after *model.Task
+	if !byUrgency { // every task is listed, for urgencyPage to score them
+		var err error
+		if after, err = opts.after(); nil != err {
+			return model.TaskPage{}, err
+		}
+	}
+
+	keys := opts.Sort.keys()
+	statuses := opts.statuses(in == listed)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var found []*model.Task
+	for _, it := range candidates(m.index) {
+		if !in.includes(it.task) {
+			continue
+		}
+		if len(statuses) > 0 && !containsString(statuses, model.StatusOf(it.task)) {
+			continue
+		}
+		if opts.List != "" && model.ListOf(it.task) != opts.List {
+			continue
+		}
+		if nil != after && !listedBefore(after, it.task, keys) {
+			continue
+		}
+		if nil == filter || filter(it.task) {
+			found = append(found, it.task)
+		}
+		if byUrgency && len(found) > MaxUrgencyTasks {
+			break
+		}
+	}
+	if byUrgency {
+		var tasks []*model.Task
+		for _, t := range found {
+			tasks = append(tasks, copyTask(t))
+		}
+		return urgencyPage(tasks, opts)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return listedBefore(found[i], found[j], keys)
+	})
+
+	limit := opts.limit()
+	if len(found) > limit+1 {
+		found = found[:limit+1]
+	}
+	var tasks []*model.Task
+	for _, t := range found {
+		tasks = append(tasks, copyTask(t))
+	}
+	return newPage(tasks, opts), nil
+}
+
+// matchesQuery applies the same criteria as MongoDataStore.FindTasks to the given task.
+// Title must be equal, expires before and created on or after the query values.
+// Array values must ALL be present in the corresponding task array.
+func matchesQuery(t *model.Task, query model.Task) bool {
+	if query.Title != "" && t.Title != query.Title {
+		return false
+	}
+	if !query.Expires.IsZero() && !t.Expires.Before(query.Expires) {
+		return false
+	}
+	if !query.Created.IsZero() && t.Created.Before(query.Created) {
+		return false
+	}
+	if query.List != "" && model.ListOf(t) != query.List {
+		return false
+	}
+	for _, g := range query.ACL {
+		if !sharedAs(t, g) {
+			return false
+		}
+	}
+	for _, l := range query.Labels {
+		if !containsString(t.Labels, l) {
+			return false
+		}
+	}
+	for _, n := range query.Notes {
+		if !containsString(t.Notes, n) {
+			return false
+		}
+	}
+	for _, b := range query.BlockedBy {
+		if !containsString(t.BlockedBy, b) {
+			return false
+		}
+	}
+	return true
+}
+
+// copyTask makes a deep copy of the task, so callers can't alter the stored tasks.
+func copyTask(t *model.Task) *model.Task {
+	c := *t
+	if nil != t.ID {
+		id := *t.ID
+		c.ID = &id
+	}
+	c.Labels = append([]string(nil), t.Labels...)
+	c.Notes = append([]string(nil), t.Notes...)
+	c.ACL = append([]model.Grant(nil), t.ACL...)
+	c.Reminders = append([]string(nil), t.Reminders...)
+	c.BlockedBy = append([]string(nil), t.BlockedBy...)
+	if nil != t.Deleted {
+		deleted := *t.Deleted
+		c.Deleted = &deleted
+	}
+	if nil != t.Archived {
+		archived := *t.Archived
+		c.Archived = &archived
+	}
+	if nil != t.Unarchived {
+		unarchived := *t.Unarchived
+		c.Unarchived = &unarchived
+	}
+	if nil != t.Recurred {
+		recurred := *t.Recurred
+		c.Recurred = &recurred
+	}
+	if nil != t.CompletedAt {
+		completed := *t.CompletedAt
+		c.CompletedAt = &completed
+	}
+	return &c
+}
+
+func containsInt(items []int, i int) bool {
+	for _, item := range items {
+		if item == i {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package data_test
+
+import (
+	"gatso/data"
+	"gatso/data/datastoretest"
+	"testing"
+)
+
+func TestMemoryDataStore_Conformance(t *testing.T) {
+	datastoretest.RunConformance(t, func() data.Datastore {
+		return data.NewMemoryDataStore()
+	})
+}
+
+func TestMemoryDataStore_ChangeConformance(t *testing.T) {
+	datastoretest.RunChangeConformance(t, func() data.Datastore {
+		return data.NewMemoryDataStore()
+	})
+}
+
+func TestMemoryDataStore_GetTask(t *testing.T) {
+	ms := data.NewMemoryDataStore()
+	defer ms.Close()
+
+	task, err := createTestTask([]byte(`{ "owner": 123, "title": "Test Task", "labels": ["myLabel"] }`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	id, err := ms.AddTask(ctx, testOwnerId, *task)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+
+	task = ms.GetTask(ctx, id)
+	if nil == task || task.Id() != id {
+		t.Errorf("Expected result from getTask to match task id %s", id)
+		return
+	}
+
+	// Changes to returned tasks must not alter the stored task
+	task.Title = "changed"
+	task.Labels[0] = "changed"
+	task = ms.GetTask(ctx, id)
+	if task.Title != "Test Task" || task.Labels[0] != "myLabel" {
+		t.Errorf("Expected stored task to be unchanged, found %q %v", task.Title, task.Labels)
+		return
+	}
+
+	if nil != ms.GetTask(ctx, "madeupid") {
+		t.Errorf("Expected result from getTask with invalid task id to be nil")
+		return
+	}
+	if ms.Exists(ctx, "") {
+		t.Errorf("Expected empty task id not to exist")
+		return
+	}
+}
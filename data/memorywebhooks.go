@@ -0,0 +1,189 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryWebhookStore holds the webhooks and their deliveries in memory.
+type MemoryWebhookStore struct {
+	mu         sync.RWMutex
+	webhooks   map[string]*model.Webhook
+	deliveries map[string]*model.Delivery
+}
+
+// Create a new, empty MemoryWebhookStore
+func NewMemoryWebhookStore() *MemoryWebhookStore {
+	return &MemoryWebhookStore{webhooks: map[string]*model.Webhook{}, deliveries: map[string]*model.Delivery{}}
+}
+
+// Close releases the webhooks and deliveries held by the store.
+func (m *MemoryWebhookStore) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhooks = map[string]*model.Webhook{}
+	m.deliveries = map[string]*model.Delivery{}
+}
+
+func (m *MemoryWebhookStore) AddWebhook(ctx context.Context, hook model.Webhook) (string, error) {
+	if err := ctx.Err(); nil != err {
+		return "", err
+	}
+	hook.ID = primitive.NewObjectID().Hex()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.putWebhook(&hook)
+	return hook.ID, nil
+}
+
+func (m *MemoryWebhookStore) GetWebhook(ctx context.Context, id string) (*model.Webhook, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	hook, ok := m.webhooks[id]
+	if !ok {
+		return nil, nil
+	}
+	return copyWebhook(hook), nil
+}
+
+func (m *MemoryWebhookStore) Webhooks(ctx context.Context, ownerId int) ([]*model.Webhook, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var hooks []*model.Webhook
+	for _, hook := range m.webhooks {
+		if hook.Owner == ownerId {
+			hooks = append(hooks, copyWebhook(hook))
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		if !hooks[i].Created.Equal(hooks[j].Created) {
+			return hooks[i].Created.Before(hooks[j].Created)
+		}
+		return hooks[i].ID < hooks[j].ID
+	})
+	return hooks, nil
+}
+
+func (m *MemoryWebhookStore) DeleteWebhook(ctx context.Context, ownerId int, id string) (bool, error) {
+	if err := ctx.Err(); nil != err {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.ownsWebhook(ownerId, id) {
+		return false, nil
+	}
+	delete(m.webhooks, id)
+	return true, nil
+}
+
+func (m *MemoryWebhookStore) AddDelivery(ctx context.Context, delivery model.Delivery) (string, error) {
+	if err := ctx.Err(); nil != err {
+		return "", err
+	}
+	delivery.ID = primitive.NewObjectID().Hex()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.putDelivery(&delivery)
+	return delivery.ID, nil
+}
+
+func (m *MemoryWebhookStore) UpdateDelivery(ctx context.Context, delivery model.Delivery) error {
+	if err := ctx.Err(); nil != err {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.putDelivery(&delivery)
+	return nil
+}
+
+func (m *MemoryWebhookStore) DueDeliveries(ctx context.Context, due time.Time, limit int) ([]*model.Delivery, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var deliveries []*model.Delivery
+	for _, d := range m.deliveries {
+		if d.Status == model.DeliveryPending && !d.NextAttempt.After(due) {
+			deliveries = append(deliveries, copyDelivery(d))
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].NextAttempt.Equal(deliveries[j].NextAttempt) {
+			return deliveries[i].NextAttempt.Before(deliveries[j].NextAttempt)
+		}
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (m *MemoryWebhookStore) Deliveries(ctx context.Context, ownerId int, webhookId string, limit int) ([]*model.Delivery, error) {
+	if err := ctx.Err(); nil != err {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var deliveries []*model.Delivery
+	for _, d := range m.deliveries {
+		if d.Owner == ownerId && (webhookId == "" || d.WebhookID == webhookId) {
+			deliveries = append(deliveries, copyDelivery(d))
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].Created.Equal(deliveries[j].Created) {
+			return deliveries[i].Created.After(deliveries[j].Created)
+		}
+		return deliveries[i].ID > deliveries[j].ID
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// ownsWebhook checks the webhook exists and belongs to the owner. Caller must hold the lock.
+func (m *MemoryWebhookStore) ownsWebhook(ownerId int, id string) bool {
+	hook, ok := m.webhooks[id]
+	return ok && hook.Owner == ownerId
+}
+
+// putWebhook stores a copy of the webhook. Caller must hold the write lock.
+func (m *MemoryWebhookStore) putWebhook(hook *model.Webhook) {
+	m.webhooks[hook.ID] = copyWebhook(hook)
+}
+
+// putDelivery stores a copy of the delivery, replacing any with the same id. Caller must hold the write lock.
+func (m *MemoryWebhookStore) putDelivery(delivery *model.Delivery) {
+	m.deliveries[delivery.ID] = copyDelivery(delivery)
+}
+
+func copyWebhook(hook *model.Webhook) *model.Webhook {
+	c := *hook
+	c.Events = append([]string(nil), hook.Events...)
+	return &c
+}
+
+func copyDelivery(delivery *model.Delivery) *model.Delivery {
+	c := *delivery
+	c.Payload = append([]byte(nil), delivery.Payload...)
+	if nil != delivery.Delivered {
+		t := *delivery.Delivered
+		c.Delivered = &t
+	}
+	return &c
+}
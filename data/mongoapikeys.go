@@ -0,0 +1,87 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const apiKeysCollectionSuffix = "_apikeys"
+
+// MongoAPIKeyStore holds the api keys in a collection alongside the tasks of a MongoDataStore.
+type MongoAPIKeyStore struct {
+	keys *mongo.Collection
+}
+
+// Create a new MongoAPIKeyStore in the database of the given datastore.
+func NewMongoAPIKeyStore(m *MongoDataStore) *MongoAPIKeyStore {
+	return &MongoAPIKeyStore{keys: m.db.Collection(m.collectionName + apiKeysCollectionSuffix)}
+}
+
+// Close does nothing, the connection is closed with its MongoDataStore.
+func (k MongoAPIKeyStore) Close() {
+}
+
+// Drop will delete every key in the collection. (Used for testing)
+func (k MongoAPIKeyStore) Drop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
+	defer cancel()
+	return k.keys.Drop(ctx)
+}
+
+func (k MongoAPIKeyStore) AddKey(ctx context.Context, key model.APIKey) (string, error) {
+	key.ID = primitive.NewObjectID().Hex()
+	if _, err := k.keys.InsertOne(ctx, &key); nil != err {
+		return "", err
+	}
+	return key.ID, nil
+}
+
+func (k MongoAPIKeyStore) Keys(ctx context.Context, ownerId int) ([]*model.APIKey, error) {
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{"created", 1}, {"_id", 1}})
+	cur, err := k.keys.Find(ctx, bson.D{{"owner", ownerId}}, findOptions)
+	if nil != err {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var keys []*model.APIKey
+	for cur.Next(ctx) {
+		var key model.APIKey
+		if err := cur.Decode(&key); nil != err {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+	return keys, cur.Err()
+}
+
+func (k MongoAPIKeyStore) KeyByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := k.keys.FindOne(ctx, bson.D{{"hash", hash}}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (k MongoAPIKeyStore) KeyUsed(ctx context.Context, id string, at time.Time) error {
+	_, err := k.keys.UpdateOne(ctx, bson.D{{"_id", id}}, bson.D{{"$set", bson.D{{"lastUsed", at}}}})
+	return err
+}
+
+func (k MongoAPIKeyStore) RevokeKey(ctx context.Context, ownerId int, id string) (bool, error) {
+	res, err := k.keys.DeleteOne(ctx, bson.D{{"_id", id}, {"owner", ownerId}})
+	if nil != err {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
@@ -0,0 +1,118 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const groupsCollectionSuffix = "_groups"
+
+// MongoGroupStore holds the groups in a collection alongside the tasks of a MongoDataStore.
+type MongoGroupStore struct {
+	groups *mongo.Collection
+}
+
+// Create a new MongoGroupStore in the database of the given datastore.
+func NewMongoGroupStore(m *MongoDataStore) *MongoGroupStore {
+	return &MongoGroupStore{groups: m.db.Collection(m.collectionName + groupsCollectionSuffix)}
+}
+
+// Close does nothing, the connection is closed with its MongoDataStore.
+func (g MongoGroupStore) Close() {
+}
+
+// Drop will delete every group in the collection. (Used for testing)
+func (g MongoGroupStore) Drop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
+	defer cancel()
+	return g.groups.Drop(ctx)
+}
+
+func (g MongoGroupStore) AddGroup(ctx context.Context, group model.Group) (string, error) {
+	group.ID = primitive.NewObjectID().Hex()
+	group.Members = distinctMembers(group.Members)
+	if nil == group.Members {
+		group.Members = []int{} // stored as an empty array, for $addToSet
+	}
+	if _, err := g.groups.InsertOne(ctx, &group); nil != err {
+		return "", err
+	}
+	return group.ID, nil
+}
+
+func (g MongoGroupStore) GetGroup(ctx context.Context, id string) (*model.Group, error) {
+	var group model.Group
+	err := g.groups.FindOne(ctx, bson.D{{"_id", id}}).Decode(&group)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+	group.Members = distinctMembers(group.Members)
+	return &group, nil
+}
+
+func (g MongoGroupStore) Groups(ctx context.Context, ownerId int) ([]*model.Group, error) {
+	return g.find(ctx, bson.D{{"owner", ownerId}})
+}
+
+func (g MongoGroupStore) MemberOf(ctx context.Context, userId int) ([]*model.Group, error) {
+	// single element query on an array returns any item with an array containing that value
+	return g.find(ctx, bson.D{{"members", userId}})
+}
+
+func (g MongoGroupStore) UpdateGroup(ctx context.Context, ownerId int, group model.Group) (bool, error) {
+	return g.update(ctx, ownerId, group.ID, bson.D{{"$set", bson.D{{"name", group.Name}}}})
+}
+
+func (g MongoGroupStore) AddMember(ctx context.Context, ownerId int, groupId string, userId int) (bool, error) {
+	return g.update(ctx, ownerId, groupId, bson.D{{"$addToSet", bson.D{{"members", userId}}}})
+}
+
+func (g MongoGroupStore) RemoveMember(ctx context.Context, ownerId int, groupId string, userId int) (bool, error) {
+	return g.update(ctx, ownerId, groupId, bson.D{{"$pull", bson.D{{"members", userId}}}})
+}
+
+func (g MongoGroupStore) DeleteGroup(ctx context.Context, ownerId int, id string) (bool, error) {
+	res, err := g.groups.DeleteOne(ctx, bson.D{{"_id", id}, {"owner", ownerId}})
+	if nil != err {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+// update applies the update to the owners group, returning false if the owner has no such group.
+func (g MongoGroupStore) update(ctx context.Context, ownerId int, id string, update bson.D) (bool, error) {
+	res, err := g.groups.UpdateOne(ctx, bson.D{{"_id", id}, {"owner", ownerId}}, update)
+	if nil != err {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// find reads the groups matching the filter, oldest first, each with its members lowest first.
+func (g MongoGroupStore) find(ctx context.Context, filter bson.D) ([]*model.Group, error) {
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{"created", 1}, {"_id", 1}})
+	cur, err := g.groups.Find(ctx, filter, findOptions)
+	if nil != err {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var groups []*model.Group
+	for cur.Next(ctx) {
+		var group model.Group
+		if err := cur.Decode(&group); nil != err {
+			return nil, err
+		}
+		group.Members = distinctMembers(group.Members)
+		groups = append(groups, &group)
+	}
+	return groups, cur.Err()
+}
@@ -0,0 +1,57 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const historyCollectionSuffix = "_history"
+
+// MongoHistoryStore holds the revisions of every task in a collection alongside the tasks of a MongoDataStore.
+type MongoHistoryStore struct {
+	collection *mongo.Collection
+}
+
+// Create a new MongoHistoryStore in the database of the given datastore.
+func NewMongoHistoryStore(m *MongoDataStore) *MongoHistoryStore {
+	return &MongoHistoryStore{collection: m.db.Collection(m.collectionName + historyCollectionSuffix)}
+}
+
+// Close does nothing, the connection is closed with its MongoDataStore.
+func (h MongoHistoryStore) Close() {
+}
+
+// Drop will delete every revision in the collection. (Used for testing)
+func (h MongoHistoryStore) Drop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
+	defer cancel()
+	return h.collection.Drop(ctx)
+}
+
+func (h MongoHistoryStore) AddRevision(ctx context.Context, rev model.Revision) error {
+	_, err := h.collection.InsertOne(ctx, &rev)
+	return err
+}
+
+func (h MongoHistoryStore) Revisions(ctx context.Context, taskId string) ([]*model.Revision, error) {
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{"revision", 1}})
+	cur, err := h.collection.Find(ctx, bson.D{{"taskId", taskId}}, findOptions)
+	if nil != err {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var revs []*model.Revision
+	for cur.Next(ctx) {
+		var rev model.Revision
+		if err := cur.Decode(&rev); nil != err {
+			return nil, err
+		}
+		revs = append(revs, &rev)
+	}
+	return revs, cur.Err()
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const listsCollectionSuffix = "_lists"
+
+// MongoListStore holds the lists in a collection alongside the tasks of a MongoDataStore.
+type MongoListStore struct {
+	lists *mongo.Collection
+}
+
+// Create a new MongoListStore in the database of the given datastore.
+func NewMongoListStore(m *MongoDataStore) *MongoListStore {
+	return &MongoListStore{lists: m.db.Collection(m.collectionName + listsCollectionSuffix)}
+}
+
+// Close does nothing, the connection is closed with its MongoDataStore.
+func (l MongoListStore) Close() {
+}
+
+// Drop will delete every list in the collection. (Used for testing)
+func (l MongoListStore) Drop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
+	defer cancel()
+	return l.lists.Drop(ctx)
+}
+
+func (l MongoListStore) AddList(ctx context.Context, list model.List) (string, error) {
+	list.ID = primitive.NewObjectID().Hex()
+	if _, err := l.lists.InsertOne(ctx, &list); nil != err {
+		return "", err
+	}
+	return list.ID, nil
+}
+
+func (l MongoListStore) GetList(ctx context.Context, id string) (*model.List, error) {
+	var list model.List
+	err := l.lists.FindOne(ctx, bson.D{{"_id", id}}).Decode(&list)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (l MongoListStore) Lists(ctx context.Context, ownerId int) ([]*model.List, error) {
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{"created", 1}, {"_id", 1}})
+	cur, err := l.lists.Find(ctx, bson.D{{"owner", ownerId}}, findOptions)
+	if nil != err {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var lists []*model.List
+	for cur.Next(ctx) {
+		var list model.List
+		if err := cur.Decode(&list); nil != err {
+			return nil, err
+		}
+		lists = append(lists, &list)
+	}
+	return lists, cur.Err()
+}
+
+func (l MongoListStore) UpdateList(ctx context.Context, ownerId int, list model.List) (bool, error) {
+	update := bson.D{{"$set", bson.D{
+		{"name", list.Name},
+		{"description", list.Description},
+		{"colour", list.Colour},
+		{"archived", list.Archived},
+	}}}
+	res, err := l.lists.UpdateOne(ctx, bson.D{{"_id", list.ID}, {"owner", ownerId}}, update)
+	if nil != err {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+func (l MongoListStore) DeleteList(ctx context.Context, ownerId int, id string) (bool, error) {
+	res, err := l.lists.DeleteOne(ctx, bson.D{{"_id", id}, {"owner", ownerId}})
+	if nil != err {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
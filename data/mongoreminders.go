@@ -0,0 +1,85 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const remindersCollectionSuffix = "_reminders"
+
+// MongoReminderStore holds the schedule of reminders in a collection alongside the tasks of a MongoDataStore.
+type MongoReminderStore struct {
+	collection *mongo.Collection
+}
+
+// Create a new MongoReminderStore in the database of the given datastore.
+func NewMongoReminderStore(m *MongoDataStore) *MongoReminderStore {
+	return &MongoReminderStore{collection: m.db.Collection(m.collectionName + remindersCollectionSuffix)}
+}
+
+// Close does nothing, the connection is closed with its MongoDataStore.
+func (r MongoReminderStore) Close() {
+}
+
+// Drop will delete every reminder in the collection. (Used for testing)
+func (r MongoReminderStore) Drop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
+	defer cancel()
+	return r.collection.Drop(ctx)
+}
+
+func (r MongoReminderStore) ScheduleReminders(ctx context.Context, taskId string, reminders []model.Reminder) error {
+	if _, err := r.collection.DeleteMany(ctx, bson.D{{"taskId", taskId}, {"sent", nil}}); nil != err {
+		return err
+	}
+	for _, rem := range reminders {
+		filter := bson.D{{"taskId", taskId}, {"before", rem.Before}, {"at", rem.At}}
+		update := bson.D{{"$setOnInsert", bson.D{{"taskId", taskId}, {"before", rem.Before}, {"at", rem.At}}}}
+		if _, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r MongoReminderStore) DueReminders(ctx context.Context, due time.Time, limit int) ([]*model.Reminder, error) {
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{"at", 1}, {"taskId", 1}})
+	findOptions.SetLimit(int64(limit))
+	cur, err := r.collection.Find(ctx, bson.D{{"sent", nil}, {"at", bson.D{{"$lte", due}}}}, findOptions)
+	if nil != err {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var reminders []*model.Reminder
+	for cur.Next(ctx) {
+		var rem model.Reminder
+		if err := cur.Decode(&rem); nil != err {
+			return nil, err
+		}
+		reminders = append(reminders, &rem)
+	}
+	return reminders, cur.Err()
+}
+
+func (r MongoReminderStore) MarkSent(ctx context.Context, reminder model.Reminder, sent *time.Time) (bool, error) {
+	filter := bson.D{{"taskId", reminder.TaskID}, {"before", reminder.Before}, {"at", reminder.At}}
+	var update bson.D
+	if nil == sent {
+		filter = append(filter, bson.E{"sent", bson.D{{"$ne", nil}}})
+		update = bson.D{{"$unset", bson.D{{"sent", ""}}}}
+	} else {
+		filter = append(filter, bson.E{"sent", nil})
+		update = bson.D{{"$set", bson.D{{"sent", *sent}}}}
+	}
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if nil != err {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
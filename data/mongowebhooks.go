@@ -0,0 +1,143 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const webhooksCollectionSuffix = "_webhooks"
+const deliveriesCollectionSuffix = "_deliveries"
+
+// MongoWebhookStore holds the webhooks and their deliveries in two collections alongside the tasks of a
+// MongoDataStore.
+type MongoWebhookStore struct {
+	webhooks   *mongo.Collection
+	deliveries *mongo.Collection
+}
+
+// Create a new MongoWebhookStore in the database of the given datastore.
+func NewMongoWebhookStore(m *MongoDataStore) *MongoWebhookStore {
+	return &MongoWebhookStore{
+		webhooks:   m.db.Collection(m.collectionName + webhooksCollectionSuffix),
+		deliveries: m.db.Collection(m.collectionName + deliveriesCollectionSuffix),
+	}
+}
+
+// Close does nothing, the connection is closed with its MongoDataStore.
+func (w MongoWebhookStore) Close() {
+}
+
+// Drop will delete every webhook and delivery in the collections. (Used for testing)
+func (w MongoWebhookStore) Drop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
+	defer cancel()
+	if err := w.webhooks.Drop(ctx); nil != err {
+		return err
+	}
+	return w.deliveries.Drop(ctx)
+}
+
+func (w MongoWebhookStore) AddWebhook(ctx context.Context, hook model.Webhook) (string, error) {
+	hook.ID = primitive.NewObjectID().Hex()
+	if _, err := w.webhooks.InsertOne(ctx, &hook); nil != err {
+		return "", err
+	}
+	return hook.ID, nil
+}
+
+func (w MongoWebhookStore) GetWebhook(ctx context.Context, id string) (*model.Webhook, error) {
+	var hook model.Webhook
+	err := w.webhooks.FindOne(ctx, bson.D{{"_id", id}}).Decode(&hook)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+func (w MongoWebhookStore) Webhooks(ctx context.Context, ownerId int) ([]*model.Webhook, error) {
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{"created", 1}, {"_id", 1}})
+	cur, err := w.webhooks.Find(ctx, bson.D{{"owner", ownerId}}, findOptions)
+	if nil != err {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var hooks []*model.Webhook
+	for cur.Next(ctx) {
+		var hook model.Webhook
+		if err := cur.Decode(&hook); nil != err {
+			return nil, err
+		}
+		hooks = append(hooks, &hook)
+	}
+	return hooks, cur.Err()
+}
+
+func (w MongoWebhookStore) DeleteWebhook(ctx context.Context, ownerId int, id string) (bool, error) {
+	res, err := w.webhooks.DeleteOne(ctx, bson.D{{"_id", id}, {"owner", ownerId}})
+	if nil != err {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+func (w MongoWebhookStore) AddDelivery(ctx context.Context, delivery model.Delivery) (string, error) {
+	delivery.ID = primitive.NewObjectID().Hex()
+	if _, err := w.deliveries.InsertOne(ctx, &delivery); nil != err {
+		return "", err
+	}
+	return delivery.ID, nil
+}
+
+func (w MongoWebhookStore) UpdateDelivery(ctx context.Context, delivery model.Delivery) error {
+	_, err := w.deliveries.ReplaceOne(ctx, bson.D{{"_id", delivery.ID}}, &delivery)
+	return err
+}
+
+func (w MongoWebhookStore) DueDeliveries(ctx context.Context, due time.Time, limit int) ([]*model.Delivery, error) {
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{"nextAttempt", 1}, {"_id", 1}})
+	findOptions.SetLimit(int64(limit))
+	return w.findDeliveries(ctx, bson.D{
+		{"status", model.DeliveryPending},
+		{"nextAttempt", bson.D{{"$lte", due}}},
+	}, findOptions)
+}
+
+func (w MongoWebhookStore) Deliveries(ctx context.Context, ownerId int, webhookId string, limit int) ([]*model.Delivery, error) {
+	filter := bson.D{{"owner", ownerId}}
+	if webhookId != "" {
+		filter = append(filter, bson.E{"webhookId", webhookId})
+	}
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{"created", -1}, {"_id", -1}})
+	findOptions.SetLimit(int64(limit))
+	return w.findDeliveries(ctx, filter, findOptions)
+}
+
+func (w MongoWebhookStore) findDeliveries(ctx context.Context, filter bson.D, findOptions *options.FindOptions) ([]*model.Delivery, error) {
+	cur, err := w.deliveries.Find(ctx, filter, findOptions)
+	if nil != err {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var deliveries []*model.Delivery
+	for cur.Next(ctx) {
+		var d model.Delivery
+		if err := cur.Decode(&d); nil != err {
+			return nil, err
+		}
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, cur.Err()
+}
@@ -0,0 +1,144 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a listing is given a cursor it did not create.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListOptions selects the page of a task listing to return.
+type ListOptions struct {
+	// Maximum number of tasks to return, zero (or more than maxTaskCount) returns up to maxTaskCount.
+	Limit int
+
+	// Cursor returned as the Next of the previous page, empty for the first page.
+	Cursor string
+
+	// Order of the listing, empty for expires, latest first. The cursor is only valid with the same sort.
+	Sort Sort
+
+	// Statuses of the tasks to list, empty for every status but done. The trash and archive list every status.
+	Status []string
+
+	// Id of the list the tasks are listed from, empty for every list.
+	List string
+
+	// Ids of the groups the user is a member of, listing the tasks shared with them along with those shared with
+	// the user. Only GetOthersTasks lists by group.
+	Groups []string
+
+	// Weights of the urgency each task is scored with, when sorted by urgency.
+	Urgency model.UrgencyWeights
+}
+
+// pageCursor marks the position of the last task on a page, holding its sortable fields.
+type pageCursor struct {
+	Sort     string             `json:"s,omitempty"`
+	Expires  time.Time          `json:"e"`
+	Created  time.Time          `json:"c"`
+	Owner    int                `json:"o,omitempty"`
+	Title    string             `json:"t,omitempty"`
+	Priority int                `json:"p,omitempty"`
+	At       time.Time          `json:"a,omitempty"` // when the urgency was scored, when sorted by urgency
+	Urgency  float64            `json:"u,omitempty"`
+	ID       primitive.ObjectID `json:"i"`
+}
+
+// statuses gets the statuses the listing is limited to, nil for every status.
+// Done tasks are left out of the lists of current tasks, unless statuses are given.
+func (o ListOptions) statuses(current bool) []string {
+	if len(o.Status) > 0 || !current {
+		return o.Status
+	}
+	var statuses []string
+	for _, s := range model.Statuses {
+		if s != model.StatusDone {
+			statuses = append(statuses, s)
+		}
+	}
+	return statuses
+}
+
+// withQueryStatus lists the status of the query task, in place of any statuses given.
+func (o ListOptions) withQueryStatus(query model.Task) ListOptions {
+	if query.Status != "" {
+		o.Status = []string{query.Status}
+	}
+	return o
+}
+
+// limit gets the number of tasks to return on the page.
+func (o ListOptions) limit() int {
+	if o.Limit <= 0 || o.Limit > maxTaskCount {
+		return maxTaskCount
+	}
+	return o.Limit
+}
+
+// after reads the cursor, returning nil for the first page.
+// The cursor is returned as the last task of the previous page, holding just its sortable fields.
+func (o ListOptions) after() (*model.Task, error) {
+	c, err := o.readCursor()
+	if nil == c || nil != err {
+		return nil, err
+	}
+	return &model.Task{ID: &c.ID, Created: c.Created, Owner: c.Owner, Title: c.Title, Expires: c.Expires,
+		Priority: c.Priority}, nil
+}
+
+// readCursor decodes the cursor, returning nil for the first page.
+func (o ListOptions) readCursor() (*pageCursor, error) {
+	if o.Cursor == "" {
+		return nil, nil
+	}
+	by, err := base64.RawURLEncoding.DecodeString(o.Cursor)
+	if nil != err {
+		return nil, ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(by, &c); nil != err {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != o.Sort.String() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// cursor creates the opaque cursor to the page following the given task.
+func (o ListOptions) cursor(last *model.Task) string {
+	return encodeCursor(pageCursor{
+		Sort:     o.Sort.String(),
+		Expires:  last.Expires,
+		Created:  last.Created,
+		Owner:    last.Owner,
+		Title:    last.Title,
+		Priority: last.Priority,
+		ID:       *last.ID,
+	})
+}
+
+func encodeCursor(c pageCursor) string {
+	by, _ := json.Marshal(&c)
+	return base64.RawURLEncoding.EncodeToString(by)
+}
+
+// newPage builds the page from the tasks found, which should be fetched with one more than the limit,
+// so a following page can be detected.
+func newPage(tasks []*model.Task, opts ListOptions) model.TaskPage {
+	limit := opts.limit()
+	if len(tasks) <= limit {
+		return model.TaskPage{Tasks: tasks}
+	}
+	tasks = tasks[:limit]
+	return model.TaskPage{
+		Tasks: tasks,
+		Next:  opts.cursor(tasks[limit-1]),
+	}
+}
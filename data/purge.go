@@ -0,0 +1,57 @@
+package data
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// TrashPurger is a background job, permanently removing tasks which have been in the trash longer than the retention.
+type TrashPurger struct {
+	ds        Datastore
+	retention time.Duration
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// StartTrashPurger purges the trash of the given datastore every interval, until stopped.
+func StartTrashPurger(ds Datastore, retention time.Duration, interval time.Duration) *TrashPurger {
+	p := &TrashPurger{
+		ds:        ds,
+		retention: retention,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go p.run(interval)
+	return p
+}
+
+// Stop the purger, waiting for any purge in progress to finish.
+func (p *TrashPurger) Stop() {
+	close(p.stop)
+	<-p.done
+}
+
+// Purge the trash now, returning the number of tasks removed.
+func (p *TrashPurger) Purge(ctx context.Context) (int, error) {
+	return p.ds.PurgeTrash(ctx, time.Now().Add(-p.retention))
+}
+
+func (p *TrashPurger) run(interval time.Duration) {
+	defer close(p.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			n, err := p.Purge(context.Background())
+			if nil != err {
+				log.Printf("Failed to purge the trash: %v", err)
+			} else if n > 0 {
+				log.Printf("Purged %d tasks from the trash", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package data_test
+
+import (
+	"gatso/data"
+	"gatso/model"
+	"testing"
+	"time"
+)
+
+func TestTrashPurger(t *testing.T) {
+	ms := data.NewMemoryDataStore()
+	defer ms.Close()
+
+	id, err := ms.AddTask(ctx, testOwnerId, model.Task{Owner: testOwnerId, Title: "Test Task"})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if deleted, err := ms.DeleteTask(ctx, testOwnerId, id, 0); !deleted || nil != err {
+		t.Errorf("Expected delete of task %s to succeed, found %v", id, err)
+		return
+	}
+
+	// a long retention keeps the task
+	purger := data.StartTrashPurger(ms, time.Hour, time.Hour)
+	n, err := purger.Purge(ctx)
+	purger.Stop()
+	if nil != err || n != 0 {
+		t.Errorf("Expected nothing to be purged, found %d, %v", n, err)
+		return
+	}
+
+	purger = data.StartTrashPurger(ms, 0, time.Millisecond*10)
+	defer purger.Stop()
+	for end := time.Now().Add(time.Second * 5); ms.Exists(ctx, id); {
+		if time.Now().After(end) {
+			t.Errorf("Expected task %s to be purged from the trash", id)
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
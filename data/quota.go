@@ -0,0 +1,101 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gatso/model"
+	"sync"
+)
+
+// ErrQuotaExceeded is returned when adding a task would take its owner over the most tasks they may have.
+var ErrQuotaExceeded = errors.New("task quota exceeded")
+
+// quotaLocks is the number of locks the owners are spread over, so owners adding tasks at once rarely wait on each other.
+const quotaLocks = 64
+
+// QuotaDataStore limits the number of tasks each owner may have in the datastore it wraps,
+// not counting those in the trash or archive. Tasks added, restored or unarchived beyond it are refused with
+// ErrQuotaExceeded.
+// Owners are only counted and added to one at a time within this process. Several instances sharing a Mongo or SQL
+// database each keep to the cap, but between them an owner adding tasks through more than one at once may pass it.
+type QuotaDataStore struct {
+	Datastore
+	maxTasks int
+	locks    *[quotaLocks]sync.Mutex // an owner is counted and added to under one of these, so the cap is never passed
+}
+
+// Create a new QuotaDataStore, allowing each owner up to maxTasks tasks in the given datastore.
+func NewQuotaDataStore(ds Datastore, maxTasks int) *QuotaDataStore {
+	return &QuotaDataStore{Datastore: ds, maxTasks: maxTasks, locks: &[quotaLocks]sync.Mutex{}}
+}
+
+func (q QuotaDataStore) AddTask(ctx context.Context, ownerId int, task model.Task) (string, error) {
+	unlock, err := q.reserve(ctx, ownerId)
+	if nil != err {
+		return "", err
+	}
+	defer unlock()
+	return q.Datastore.AddTask(ctx, ownerId, task)
+}
+
+// UpdateTask updates the task, or adds it if it doesn't exist yet, within the owners quota.
+func (q QuotaDataStore) UpdateTask(ctx context.Context, ownerId int, task model.Task) (int, error) {
+	if nil != task.ID && nil != q.Datastore.GetTask(ctx, task.Id()) {
+		return q.Datastore.UpdateTask(ctx, ownerId, task)
+	}
+	unlock, err := q.reserve(ctx, ownerId)
+	if nil != err {
+		return 0, err
+	}
+	defer unlock()
+	return q.Datastore.UpdateTask(ctx, ownerId, task)
+}
+
+// RestoreTask moves the task out of the trash, within the quota of its owner.
+func (q QuotaDataStore) RestoreTask(ctx context.Context, ownerId int, taskId string) (bool, error) {
+	task := q.Datastore.GetTask(ctx, taskId)
+	if nil == task || nil == task.Deleted || nil != task.Archived { // left in the archive, so takes no more room
+		return q.Datastore.RestoreTask(ctx, ownerId, taskId)
+	}
+	unlock, err := q.reserve(ctx, task.Owner)
+	if nil != err {
+		return false, err
+	}
+	defer unlock()
+	return q.Datastore.RestoreTask(ctx, ownerId, taskId)
+}
+
+// UnarchiveTask moves the task out of the archive, within the quota of its owner.
+func (q QuotaDataStore) UnarchiveTask(ctx context.Context, ownerId int, taskId string) (bool, error) {
+	task := q.Datastore.GetTask(ctx, taskId)
+	if nil == task || nil == task.Archived || nil != task.Deleted {
+		return q.Datastore.UnarchiveTask(ctx, ownerId, taskId)
+	}
+	unlock, err := q.reserve(ctx, task.Owner)
+	if nil != err {
+		return false, err
+	}
+	defer unlock()
+	return q.Datastore.UnarchiveTask(ctx, ownerId, taskId)
+}
+
+// reserve locks the owner while they have room for another task, returning the func to unlock them
+// once it has been added.
+func (q QuotaDataStore) reserve(ctx context.Context, ownerId int) (func(), error) {
+	lock := &q.locks[uint(ownerId)%quotaLocks]
+	lock.Lock()
+	count := q.Datastore.CountTasks(ctx, ownerId)
+	if count < 0 {
+		lock.Unlock()
+		if err := ctx.Err(); nil != err {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to count the tasks of owner %d", ownerId)
+	}
+	if count >= q.maxTasks {
+		lock.Unlock()
+		return nil, fmt.Errorf("%w, owner %d already has %d of %d tasks", ErrQuotaExceeded, ownerId, count, q.maxTasks)
+	}
+	return lock.Unlock, nil
+}
@@ -0,0 +1,90 @@
+package data_test
+
+import (
+	"errors"
+	"gatso/data"
+	"gatso/data/datastoretest"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestQuotaDataStore_Conformance(t *testing.T) {
+	datastoretest.RunConformance(t, func() data.Datastore {
+		return data.NewQuotaDataStore(data.NewMemoryDataStore(), 2*data.MaxUrgencyTasks) // room for every task the suite adds
+	})
+}
+
+func TestQuotaDataStore_Quota(t *testing.T) {
+	qs := data.NewQuotaDataStore(data.NewMemoryDataStore(), 2)
+	defer qs.Close()
+
+	var ids []string
+	for _, title := range []string{"first", "second"} {
+		id, err := qs.AddTask(ctx, testOwnerId, model.Task{Owner: testOwnerId, Title: title, Expires: time.Now().Add(time.Hour)})
+		if nil != err {
+			t.Error(err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	task := model.Task{Owner: testOwnerId, Title: "third", Expires: time.Now().Add(time.Hour)}
+	if _, err := qs.AddTask(ctx, testOwnerId, task); !errors.Is(err, data.ErrQuotaExceeded) {
+		t.Errorf("Expected a third task to exceed the quota, found %v", err)
+		return
+	}
+	newId := primitive.NewObjectID()
+	task.ID = &newId
+	if _, err := qs.UpdateTask(ctx, testOwnerId, task); !errors.Is(err, data.ErrQuotaExceeded) {
+		t.Errorf("Expected a third task added by updating it to exceed the quota, found %v", err)
+		return
+	}
+	if _, err := qs.AddTask(ctx, testOwnerId+1, model.Task{Owner: testOwnerId + 1, Title: "other"}); nil != err {
+		t.Errorf("Expected the quota of each owner to be kept apart, found %v", err)
+		return
+	}
+
+	// existing tasks may still be updated, and deleting one makes room for another
+	existing := qs.GetTask(ctx, ids[0])
+	existing.Title = "updated"
+	if _, err := qs.UpdateTask(ctx, testOwnerId, *existing); nil != err {
+		t.Errorf("Expected a task to be updated at the quota, found %v", err)
+		return
+	}
+	if _, err := qs.DeleteTask(ctx, testOwnerId, ids[1], 0); nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := qs.UpdateTask(ctx, testOwnerId, task); nil != err {
+		t.Errorf("Expected a task to be added once a task is in the trash, found %v", err)
+		return
+	}
+
+	// tasks moved out of the trash or archive count against it again
+	if _, err := qs.RestoreTask(ctx, testOwnerId, ids[1]); !errors.Is(err, data.ErrQuotaExceeded) {
+		t.Errorf("Expected restoring a task to exceed the quota, found %v", err)
+		return
+	}
+	otherId := testOwnerId + 1
+	expiredId, err := qs.AddTask(ctx, otherId, model.Task{Owner: otherId, Title: "expired", Expires: time.Now().Add(-time.Hour)})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := qs.ArchiveTasks(ctx, time.Now()); nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := qs.AddTask(ctx, otherId, model.Task{Owner: otherId, Title: "another"}); nil != err {
+		t.Errorf("Expected a task to be added once a task is in the archive, found %v", err)
+		return
+	}
+	if _, err := qs.UnarchiveTask(ctx, otherId, expiredId); !errors.Is(err, data.ErrQuotaExceeded) {
+		t.Errorf("Expected unarchiving a task to exceed the quota, found %v", err)
+		return
+	}
+	if c := qs.CountTasks(ctx, testOwnerId); c != 2 {
+		t.Errorf("Expected the owner to be left with 2 tasks, found %d", c)
+	}
+}
@@ -0,0 +1,104 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"log"
+	"time"
+)
+
+const recurrenceBatch = 100 // recurring tasks given their next occurrence in each run of the scheduler
+
+// RecurrenceScheduler is a background job, adding the next occurrence of each recurring task once it expires.
+type RecurrenceScheduler struct {
+	ds   Datastore
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartRecurrenceScheduler adds the next occurrences of the expired recurring tasks in the given datastore
+// every interval, until stopped.
+func StartRecurrenceScheduler(ds Datastore, interval time.Duration) *RecurrenceScheduler {
+	s := &RecurrenceScheduler{
+		ds:   ds,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go s.run(interval)
+	return s
+}
+
+// Stop the scheduler, waiting for any run in progress to finish.
+func (s *RecurrenceScheduler) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// Recur adds the next occurrences of the recurring tasks expired now, returning the number added.
+// A task whose rule can't be read is logged and left without a next occurrence, rather than retried.
+func (s *RecurrenceScheduler) Recur(ctx context.Context) (int, error) {
+	now := time.Now()
+	due, err := s.ds.DueRecurrences(ctx, now, recurrenceBatch)
+	if nil != err {
+		return 0, err
+	}
+	added := 0
+	for _, task := range due {
+		if _, err := model.ParseRecurrence(task.Recurrence); nil != err {
+			log.Printf("Task %s can't recur: %v", task.Id(), err)
+			if _, err := s.ds.MarkRecurred(ctx, task.Id(), task.Expires); nil != err {
+				return added, err
+			}
+			continue
+		}
+		id, err := AddNextOccurrence(ctx, s.ds, *task, now)
+		if nil != err {
+			return added, err
+		}
+		if id != "" {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// AddNextOccurrence adds the task next due after the given time, of the recurring task, returning its id.
+// The task is first marked as recurred, so its next occurrence is only added once, returning "" if it already was
+// or the rule has ended.
+func AddNextOccurrence(ctx context.Context, ds Datastore, task model.Task, after time.Time) (string, error) {
+	marked, err := ds.MarkRecurred(ctx, task.Id(), task.Expires)
+	if nil != err || !marked {
+		return "", err
+	}
+	next, err := model.NextOccurrence(task, after)
+	if nil != err || nil == next {
+		return "", err
+	}
+	id, err := ds.AddTask(ctx, task.Owner, *next)
+	if nil != err {
+		if _, clearErr := ds.MarkRecurred(ctx, task.Id(), time.Time{}); nil != clearErr {
+			log.Printf("Failed to clear the recurrence of task %s: %v", task.Id(), clearErr)
+		}
+		return "", err
+	}
+	return id, nil
+}
+
+func (s *RecurrenceScheduler) run(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			n, err := s.Recur(context.Background())
+			if nil != err {
+				log.Printf("Failed to add the next occurrences of recurring tasks: %v", err)
+			} else if n > 0 {
+				log.Printf("Added the next occurrence of %d recurring tasks", n)
+			}
+		}
+	}
+}
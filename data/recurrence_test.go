@@ -0,0 +1,76 @@
+package data_test
+
+import (
+	"gatso/data"
+	"gatso/model"
+	"testing"
+	"time"
+)
+
+func TestRecurrenceScheduler(t *testing.T) {
+	ms := data.NewMemoryDataStore()
+	defer ms.Close()
+
+	expires := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	id, err := ms.AddTask(ctx, testOwnerId, model.Task{Owner: testOwnerId, Title: "Bins out", Expires: expires,
+		Labels: []string{"chores"}, Notes: []string{"green bin"}, ACL: model.ReaderGrants([]int{456}),
+		Recurrence: "FREQ=WEEKLY;COUNT=3"})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	badId, err := ms.AddTask(ctx, testOwnerId, model.Task{Owner: testOwnerId, Title: "Bad rule", Expires: expires,
+		Recurrence: "FREQ=SOMETIMES"})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+
+	scheduler := data.StartRecurrenceScheduler(ms, time.Hour)
+	defer scheduler.Stop()
+	if n, err := scheduler.Recur(ctx); nil != err || n != 1 {
+		t.Errorf("Expected one next occurrence to be added, found %d, %v", n, err)
+		return
+	}
+	page, err := ms.FindTasks(ctx, testOwnerId, model.Task{Title: "Bins out"}, data.ListOptions{})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(page.Tasks) != 2 || page.Tasks[1].Id() != id {
+		t.Errorf("Expected the task and its next occurrence, found %d tasks", len(page.Tasks))
+		return
+	}
+	next := page.Tasks[0]
+	if !next.Expires.Equal(expires.AddDate(0, 0, 7)) || next.Recurrence != "FREQ=WEEKLY;COUNT=2" ||
+		len(next.Labels) != 1 || len(next.Notes) != 1 || len(next.ACL) != 1 || nil != next.Recurred {
+		t.Errorf("Expected the next occurrence a week later, with the rest of the series, found %+v", next)
+		return
+	}
+
+	// each is only added once, and a rule which can't be read is given up on
+	if n, err := scheduler.Recur(ctx); nil != err || n != 0 {
+		t.Errorf("Expected nothing more to be added, found %d, %v", n, err)
+		return
+	}
+	if task := ms.GetTask(ctx, badId); nil == task || nil == task.Recurred {
+		t.Errorf("Expected task %s, with a bad rule, to be marked as recurred", badId)
+		return
+	}
+
+	// completing a task early adds the occurrence after its expiry, the last in the series has none
+	last, err := data.AddNextOccurrence(ctx, ms, *next, time.Now())
+	if nil != err || last == "" {
+		t.Errorf("Expected the last occurrence to be added, found %v", err)
+		return
+	}
+	task := ms.GetTask(ctx, last)
+	if !task.Expires.Equal(expires.AddDate(0, 0, 14)) || task.Recurrence != "FREQ=WEEKLY;COUNT=1" {
+		t.Errorf("Expected the last occurrence two weeks later, found %+v", task)
+		return
+	}
+	if id, err := data.AddNextOccurrence(ctx, ms, *task, time.Now()); nil != err || id != "" {
+		t.Errorf("Expected no occurrence after the last, found %s, %v", id, err)
+		return
+	}
+}
@@ -0,0 +1,181 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"log"
+	"time"
+)
+
+const reminderBatch = 100                 // reminders sent in each run of the scheduler
+const reminderLateLimit = 24 * time.Hour  // reminders missed for longer, while the service was down, are not sent
+const reminderNotifyTimeout = time.Minute // time sending a single reminder may take before it is given up
+
+// ReminderStore holds the schedule of reminders for every task, indexed by when they are due.
+// A reminder is identified by its task, Before and At, and is kept once sent so it is never sent again.
+type ReminderStore interface {
+	// Replace the unsent reminders of the task with the given ones. Any of them already sent is not scheduled again.
+	ScheduleReminders(ctx context.Context, taskId string, reminders []model.Reminder) error
+
+	// Retrieve up to limit unsent reminders due by the given time, the earliest first
+	DueReminders(ctx context.Context, due time.Time, limit int) ([]*model.Reminder, error)
+
+	// Record the reminder as sent at the given time, or as unsent if nil.
+	// Returns false if the reminder isn't scheduled, or is already sent, or unsent, as given.
+	MarkSent(ctx context.Context, reminder model.Reminder, sent *time.Time) (bool, error)
+
+	// Close the store and release any resources.
+	Close()
+}
+
+// Notifier sends the owner of a task a reminder that it is falling due.
+type Notifier interface {
+	Notify(ctx context.Context, task model.Task, reminder model.Reminder) error
+}
+
+// ReminderDataStore schedules the reminders of each task added, updated or restored through the datastore it
+// wraps, and unschedules those of deleted tasks.
+type ReminderDataStore struct {
+	changeNotifier
+	reminders ReminderStore
+}
+
+// Create a new ReminderDataStore scheduling the reminders of the tasks in the given datastore into the given store.
+func NewReminderDataStore(ds Datastore, reminders ReminderStore) *ReminderDataStore {
+	r := &ReminderDataStore{reminders: reminders}
+	r.changeNotifier = changeNotifier{Datastore: ds, notify: r.schedule}
+	return r
+}
+
+// Close the datastore and the reminders.
+func (r ReminderDataStore) Close() {
+	r.Datastore.Close()
+	r.reminders.Close()
+}
+
+// schedule the reminders of the task as the change stored it.
+// The change has already been made, so a failure to schedule them is logged rather than returned.
+func (r ReminderDataStore) schedule(ctx context.Context, ownerId int, change string, task model.Task) {
+	var reminders []model.Reminder
+	if change != model.EventDeleted {
+		var err error
+		if reminders, err = model.TaskReminders(&task); nil != err {
+			log.Printf("Failed to schedule the reminders of task %s: %v", task.Id(), err)
+			return
+		}
+	}
+	if err := r.reminders.ScheduleReminders(ctx, task.Id(), reminders); nil != err {
+		log.Printf("Failed to schedule the reminders of task %s: %v", task.Id(), err)
+	}
+}
+
+// ReminderScheduler is a background job, sending each reminder to the owner of its task once it falls due.
+// A reminder is marked as sent before it is sent, and unmarked if it fails, so it is sent at most once however
+// many schedulers share the store, or however often they are restarted.
+type ReminderScheduler struct {
+	ds        Datastore
+	reminders ReminderStore
+	notifier  Notifier
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// StartReminderScheduler sends the reminders due in the given store every interval, until stopped or the
+// context is done.
+func StartReminderScheduler(ctx context.Context, ds Datastore, reminders ReminderStore, notifier Notifier,
+	interval time.Duration) *ReminderScheduler {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &ReminderScheduler{
+		ds:        ds,
+		reminders: reminders,
+		notifier:  notifier,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	go s.run(ctx, interval)
+	return s
+}
+
+// Stop the scheduler, giving up any reminders being sent, so they are sent again once restarted.
+func (s *ReminderScheduler) Stop() {
+	s.cancel()
+	<-s.done
+}
+
+// Remind sends each reminder now due, returning the number sent. Each is given up if not sent in time.
+func (s *ReminderScheduler) Remind(ctx context.Context) (int, error) {
+	now := time.Now()
+	due, err := s.reminders.DueReminders(ctx, now, reminderBatch)
+	if nil != err {
+		return 0, err
+	}
+	sent := 0
+	for _, reminder := range due {
+		claimed, err := s.reminders.MarkSent(ctx, *reminder, &now)
+		if nil != err {
+			return sent, err
+		}
+		if !claimed { // sent by another scheduler
+			continue
+		}
+
+		task := s.ds.GetTask(ctx, reminder.TaskID)
+		if !wanted(task, reminder, now) {
+			continue
+		}
+		if err := s.notify(ctx, task, reminder); nil != err {
+			log.Printf("Failed to send the %v reminder of task %s: %v", reminder.Before, reminder.TaskID, err)
+			// unmarked even when given up as the scheduler stops, to be sent once restarted
+			if _, err := s.reminders.MarkSent(context.WithoutCancel(ctx), *reminder, nil); nil != err {
+				return sent, err
+			}
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// notify sends the reminder, giving it up after reminderNotifyTimeout.
+func (s *ReminderScheduler) notify(ctx context.Context, task *model.Task, reminder *model.Reminder) error {
+	ctx, cancel := context.WithTimeout(ctx, reminderNotifyTimeout)
+	defer cancel()
+	return s.notifier.Notify(ctx, *task, *reminder)
+}
+
+func (s *ReminderScheduler) run(ctx context.Context, interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Remind(ctx); nil != err {
+				log.Printf("Failed to send reminders: %v", err)
+			}
+		}
+	}
+}
+
+// wanted checks the reminder is still one of those of the task, and isn't too late to send.
+// Tasks done or cancelled need no reminding.
+func wanted(task *model.Task, reminder *model.Reminder, now time.Time) bool {
+	if nil == task || nil != task.Deleted || now.Sub(reminder.At) > reminderLateLimit {
+		return false
+	}
+	if status := model.StatusOf(task); status == model.StatusDone || status == model.StatusCancelled {
+		return false
+	}
+	reminders, err := model.TaskReminders(task)
+	if nil != err {
+		return false
+	}
+	for _, r := range reminders {
+		if r.Before == reminder.Before && r.At.Equal(reminder.At) {
+			return true
+		}
+	}
+	return false
+}
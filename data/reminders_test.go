@@ -0,0 +1,172 @@
+package data_test
+
+import (
+	"context"
+	"fmt"
+	"gatso/data"
+	"gatso/data/datastoretest"
+	"gatso/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReminderDataStore_Conformance(t *testing.T) {
+	datastoretest.RunConformance(t, func() data.Datastore {
+		return data.NewReminderDataStore(data.NewMemoryDataStore(), data.NewMemoryReminderStore())
+	})
+}
+
+func TestMemoryReminderStore_Conformance(t *testing.T) {
+	datastoretest.RunReminderConformance(t, func() data.ReminderStore {
+		return data.NewMemoryReminderStore()
+	})
+}
+
+func TestFileReminderStore_Conformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunReminderConformance(t, func() data.ReminderStore {
+		count++
+		fr, err := data.NewFileReminderStore(fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return fr
+	})
+}
+
+// recorder is a Notifier, recording the reminders sent, failing while fail is set.
+type recorder struct {
+	mu   sync.Mutex
+	fail bool
+	sent []model.Reminder
+}
+
+func (rc *recorder) Notify(ctx context.Context, task model.Task, reminder model.Reminder) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.fail {
+		return fmt.Errorf("failed to send")
+	}
+	rc.sent = append(rc.sent, reminder)
+	return nil
+}
+
+func TestReminderScheduler_Remind(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	reminders, err := data.NewFileReminderStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	ds := data.NewReminderDataStore(data.NewMemoryDataStore(), reminders)
+	expires := time.Now().Add(30 * time.Minute)
+	id, err := ds.AddTask(ctx, 123, model.Task{Owner: 123, Title: "Test Task", Expires: expires,
+		Reminders: []string{"1h", "2h", "0"}})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := ds.AddTask(ctx, 123, model.Task{Owner: 123, Title: "Other Task", Expires: expires,
+		Reminders: []string{"1h"}}); nil != err {
+		t.Error(err)
+		return
+	}
+	deletedId, err := ds.AddTask(ctx, 123, model.Task{Owner: 123, Title: "Deleted", Expires: expires,
+		Reminders: []string{"1h"}})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := ds.DeleteTask(ctx, 123, deletedId, 0); nil != err {
+		t.Error(err)
+		return
+	}
+
+	rc := &recorder{fail: true}
+	scheduler := data.StartReminderScheduler(ctx, ds, reminders, rc, time.Hour)
+	if sent, err := scheduler.Remind(ctx); nil != err || sent != 0 {
+		t.Errorf("Expected no reminders to be sent while notifying fails, found %d, %v", sent, err)
+		return
+	}
+
+	rc.fail = false
+	if sent, err := scheduler.Remind(ctx); nil != err || sent != 3 {
+		t.Errorf("Expected the 3 due reminders to be sent once notifying succeeds, found %d, %v", sent, err)
+		return
+	}
+	scheduler.Stop()
+
+	// restarting doesn't send them again
+	reminders.Close()
+	reminders, err = data.NewFileReminderStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	defer reminders.Close()
+	scheduler = data.StartReminderScheduler(ctx, ds, reminders, rc, time.Hour)
+	defer scheduler.Stop()
+	if sent, err := scheduler.Remind(ctx); nil != err || sent != 0 {
+		t.Errorf("Expected no reminders to be sent again after a restart, found %d, %v", sent, err)
+		return
+	}
+	for _, r := range rc.sent {
+		if r.TaskID == deletedId {
+			t.Errorf("Expected no reminder of the deleted task %s", deletedId)
+		}
+		if r.TaskID == id && r.Before == 0 {
+			t.Errorf("Expected the reminder at expiry not to be sent before the task expires")
+		}
+	}
+}
+
+// blocker is a Notifier which never sends a reminder, waiting until given up.
+type blocker struct {
+	started chan struct{}
+}
+
+func (b blocker) Notify(ctx context.Context, task model.Task, reminder model.Reminder) error {
+	close(b.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestReminderScheduler_Stop(t *testing.T) {
+	ctx := context.Background()
+	reminders := data.NewMemoryReminderStore()
+	ds := data.NewReminderDataStore(data.NewMemoryDataStore(), reminders)
+	if _, err := ds.AddTask(ctx, 123, model.Task{Owner: 123, Title: "Test Task", Expires: time.Now().Add(30 * time.Minute),
+		Reminders: []string{"1h"}}); nil != err {
+		t.Fatal(err)
+	}
+
+	b := blocker{started: make(chan struct{})}
+	scheduler := data.StartReminderScheduler(ctx, ds, reminders, b, 10*time.Millisecond)
+	select {
+	case <-b.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the due reminder to be sent")
+	}
+	stopped := make(chan struct{})
+	go func() {
+		scheduler.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the scheduler to stop, giving up the reminder being sent")
+	}
+
+	// given up, so sent again once restarted
+	if due, err := reminders.DueReminders(ctx, time.Now(), 10); nil != err || len(due) != 1 {
+		t.Errorf("Expected the reminder given up to be due again, found %d, %v", len(due), err)
+	}
+}
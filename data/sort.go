@@ -0,0 +1,159 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"gatso/model"
+	"strings"
+	"time"
+)
+
+// SortKey orders a task listing by one of the task fields.
+type SortKey struct {
+	Field string // json name of the task field
+	Desc  bool
+}
+
+// Sort orders a task listing by each of its keys in turn. Empty sorts by expires, latest first.
+// Tasks with equal keys are always ordered by their id, so the order is stable across pages.
+type Sort []SortKey
+
+// sortField describes a task field a listing can be sorted by.
+type sortField struct {
+	bson    string // name in mongo documents
+	column  string // name in the sql tasks table
+	compare func(a, b *model.Task) int
+	value   func(t *model.Task) interface{}
+}
+
+// sortFields are the sortable model.Task fields, by their json name. Array fields can't be sorted.
+var sortFields = map[string]sortField{
+	"_id": {"_id", "id", func(a, b *model.Task) int {
+		return bytes.Compare(a.ID[:], b.ID[:])
+	}, func(t *model.Task) interface{} {
+		return *t.ID
+	}},
+	"created": {"created", "created", func(a, b *model.Task) int {
+		return compareTimes(a.Created, b.Created)
+	}, func(t *model.Task) interface{} {
+		return t.Created
+	}},
+	"owner": {"owner", "owner", func(a, b *model.Task) int {
+		return a.Owner - b.Owner
+	}, func(t *model.Task) interface{} {
+		return t.Owner
+	}},
+	"title": {"title", "title", func(a, b *model.Task) int {
+		return strings.Compare(a.Title, b.Title)
+	}, func(t *model.Task) interface{} {
+		return t.Title
+	}},
+	"expires": {"expires", "expires", func(a, b *model.Task) int {
+		return compareTimes(a.Expires, b.Expires)
+	}, func(t *model.Task) interface{} {
+		return t.Expires
+	}},
+	"priority": {"priority", "priority", func(a, b *model.Task) int {
+		return a.Priority - b.Priority
+	}, func(t *model.Task) interface{} {
+		return t.Priority
+	}},
+}
+
+// sortUrgency orders a listing by the urgency of each task, the most urgent first.
+// Urgency changes with time so it isn't stored, it is computed for every task listed instead.
+const sortUrgency = "urgency"
+
+var defaultSort = Sort{{Field: "expires", Desc: true}}
+
+// ParseSort reads a comma separated list of task field names, each prefixed with '-' to sort descending.
+// e.g. "-created,title"
+// "urgency" sorts the most urgent tasks first, and can't be reversed or combined with other fields.
+func ParseSort(s string) (Sort, error) {
+	var sort Sort
+	if s == "" {
+		return sort, nil
+	}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		key := SortKey{Field: strings.TrimSpace(name)}
+		if strings.HasPrefix(key.Field, "-") {
+			key.Field = key.Field[1:]
+			key.Desc = true
+		} else if strings.HasPrefix(key.Field, "+") {
+			key.Field = key.Field[1:]
+		}
+		if key.Field == sortUrgency {
+			if key.Desc {
+				return nil, fmt.Errorf("Sort by %s lists the most urgent first, and can't be reversed", sortUrgency)
+			}
+		} else if _, ok := sortFields[key.Field]; !ok {
+			return nil, fmt.Errorf("Cannot sort tasks by %q", key.Field)
+		}
+		if seen[key.Field] {
+			return nil, fmt.Errorf("Sort field %q given more than once", key.Field)
+		}
+		seen[key.Field] = true
+		sort = append(sort, key)
+	}
+	if seen[sortUrgency] && len(sort) > 1 {
+		return nil, fmt.Errorf("Sort by %s can't be combined with other fields", sortUrgency)
+	}
+	return sort, nil
+}
+
+// ByUrgency checks the sort orders a listing by urgency, rather than by task fields.
+func (s Sort) ByUrgency() bool {
+	return len(s) == 1 && s[0].Field == sortUrgency
+}
+
+// String formats the sort as ParseSort reads it.
+func (s Sort) String() string {
+	var names []string
+	for _, key := range s {
+		if key.Desc {
+			names = append(names, "-"+key.Field)
+		} else {
+			names = append(names, key.Field)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// keys gets the full sort order, the default if none is given, ending with the id to break ties.
+func (s Sort) keys() Sort {
+	keys := s
+	if len(keys) == 0 {
+		keys = defaultSort
+	}
+	for _, key := range keys {
+		if key.Field == "_id" {
+			return keys
+		}
+	}
+	return append(keys[:len(keys):len(keys)], SortKey{Field: "_id"})
+}
+
+// listedBefore checks if task a comes before task b in the order of the given keys.
+func listedBefore(a, b *model.Task, keys Sort) bool {
+	for _, key := range keys {
+		c := sortFields[key.Field].compare(a, b)
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+	}
+	return false
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,90 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// SQLAPIKeyStore holds the api keys in the api_keys table, alongside the tasks of an SQLDataStore.
+type SQLAPIKeyStore struct {
+	db *sql.DB
+}
+
+// Create a new SQLAPIKeyStore in the database of the given datastore.
+func NewSQLAPIKeyStore(s *SQLDataStore) *SQLAPIKeyStore {
+	return &SQLAPIKeyStore{db: s.db}
+}
+
+// Close does nothing, the database is closed with its SQLDataStore.
+func (k SQLAPIKeyStore) Close() {
+}
+
+func (k SQLAPIKeyStore) AddKey(ctx context.Context, key model.APIKey) (string, error) {
+	id := primitive.NewObjectID().Hex()
+	_, err := k.db.ExecContext(ctx,
+		"INSERT INTO api_keys (id, owner, name, scope, prefix, hash, created) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		id, key.Owner, key.Name, key.Scope, key.Prefix, key.Hash, formatSQLTime(key.Created))
+	if nil != err {
+		return "", err
+	}
+	return id, nil
+}
+
+func (k SQLAPIKeyStore) Keys(ctx context.Context, ownerId int) ([]*model.APIKey, error) {
+	return k.queryKeys(ctx, "owner = ?", ownerId)
+}
+
+func (k SQLAPIKeyStore) KeyByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	keys, err := k.queryKeys(ctx, "hash = ?", hash)
+	if nil != err || len(keys) == 0 {
+		return nil, err
+	}
+	return keys[0], nil
+}
+
+func (k SQLAPIKeyStore) KeyUsed(ctx context.Context, id string, at time.Time) error {
+	_, err := k.db.ExecContext(ctx, "UPDATE api_keys SET last_used = ? WHERE id = ?", formatSQLTime(at), id)
+	return err
+}
+
+func (k SQLAPIKeyStore) RevokeKey(ctx context.Context, ownerId int, id string) (bool, error) {
+	res, err := k.db.ExecContext(ctx, "DELETE FROM api_keys WHERE id = ? AND owner = ?", id, ownerId)
+	if nil != err {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// queryKeys reads the keys matching the where clause, oldest first.
+func (k SQLAPIKeyStore) queryKeys(ctx context.Context, where string, args ...interface{}) ([]*model.APIKey, error) {
+	rows, err := k.db.QueryContext(ctx,
+		"SELECT id, owner, name, scope, prefix, hash, created, last_used FROM api_keys WHERE "+where+
+			" ORDER BY created, id", args...)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*model.APIKey
+	for rows.Next() {
+		var key model.APIKey
+		var created string
+		var lastUsed sql.NullString
+		if err := rows.Scan(&key.ID, &key.Owner, &key.Name, &key.Scope, &key.Prefix, &key.Hash, &created,
+			&lastUsed); nil != err {
+			return nil, err
+		}
+		if key.Created, err = parseSQLTime(created); nil != err {
+			return nil, err
+		}
+		if key.LastUsed, err = parseNullSQLTime(lastUsed); nil != err {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+	return keys, rows.Err()
+}
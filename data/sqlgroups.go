@@ -0,0 +1,181 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SQLGroupStore holds the groups in the user_groups table, and their members in the group_members table,
+// one row per member, alongside the tasks of an SQLDataStore.
+type SQLGroupStore struct {
+	ds *SQLDataStore
+}
+
+// Create a new SQLGroupStore in the database of the given datastore.
+func NewSQLGroupStore(s *SQLDataStore) *SQLGroupStore {
+	return &SQLGroupStore{ds: s}
+}
+
+// Close does nothing, the database is closed with its SQLDataStore.
+func (g SQLGroupStore) Close() {
+}
+
+func (g SQLGroupStore) AddGroup(ctx context.Context, group model.Group) (string, error) {
+	id := primitive.NewObjectID().Hex()
+	err := g.ds.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO user_groups (id, owner, name, created) VALUES (?, ?, ?, ?)",
+			id, group.Owner, group.Name, formatSQLTime(group.Created))
+		if nil != err {
+			return err
+		}
+		for _, m := range distinctMembers(group.Members) {
+			if err := insertMember(ctx, tx, id, m); nil != err {
+				return err
+			}
+		}
+		return nil
+	})
+	if nil != err {
+		return "", err
+	}
+	return id, nil
+}
+
+func (g SQLGroupStore) GetGroup(ctx context.Context, id string) (*model.Group, error) {
+	groups, err := g.queryGroups(ctx, "id = ?", id)
+	if nil != err || len(groups) == 0 {
+		return nil, err
+	}
+	return groups[0], nil
+}
+
+func (g SQLGroupStore) Groups(ctx context.Context, ownerId int) ([]*model.Group, error) {
+	return g.queryGroups(ctx, "owner = ?", ownerId)
+}
+
+func (g SQLGroupStore) MemberOf(ctx context.Context, userId int) ([]*model.Group, error) {
+	return g.queryGroups(ctx, "id IN (SELECT group_id FROM group_members WHERE member = ?)", userId)
+}
+
+func (g SQLGroupStore) UpdateGroup(ctx context.Context, ownerId int, group model.Group) (bool, error) {
+	res, err := g.ds.db.ExecContext(ctx, "UPDATE user_groups SET name = ? WHERE id = ? AND owner = ?",
+		group.Name, group.ID, ownerId)
+	if nil != err {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (g SQLGroupStore) AddMember(ctx context.Context, ownerId int, groupId string, userId int) (bool, error) {
+	return g.changeMembers(ctx, ownerId, groupId, func(tx *sql.Tx) error {
+		return insertMember(ctx, tx, groupId, userId)
+	})
+}
+
+func (g SQLGroupStore) RemoveMember(ctx context.Context, ownerId int, groupId string, userId int) (bool, error) {
+	return g.changeMembers(ctx, ownerId, groupId, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM group_members WHERE group_id = ? AND member = ?", groupId, userId)
+		return err
+	})
+}
+
+func (g SQLGroupStore) DeleteGroup(ctx context.Context, ownerId int, id string) (bool, error) {
+	var deleted bool
+	err := g.ds.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "DELETE FROM user_groups WHERE id = ? AND owner = ?", id, ownerId)
+		if nil != err {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if nil != err || n == 0 {
+			return err
+		}
+		deleted = true
+		_, err = tx.ExecContext(ctx, "DELETE FROM group_members WHERE group_id = ?", id)
+		return err
+	})
+	return deleted && nil == err, err
+}
+
+// changeMembers changes the members of the owners group with the given func, returning false if the owner has no
+// such group.
+func (g SQLGroupStore) changeMembers(ctx context.Context, ownerId int, groupId string, change func(tx *sql.Tx) error) (bool, error) {
+	var owned bool
+	err := g.ds.inTx(ctx, func(tx *sql.Tx) error {
+		var n int
+		err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM user_groups WHERE id = ? AND owner = ?",
+			groupId, ownerId).Scan(&n)
+		if nil != err || n == 0 {
+			return err
+		}
+		owned = true
+		return change(tx)
+	})
+	return owned && nil == err, err
+}
+
+// insertMember adds the user to the members of the group, unless they already are one.
+func insertMember(ctx context.Context, tx *sql.Tx, groupId string, userId int) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO group_members (group_id, member) SELECT ?, ? "+
+		"WHERE NOT EXISTS (SELECT 1 FROM group_members WHERE group_id = ? AND member = ?)",
+		groupId, userId, groupId, userId)
+	return err
+}
+
+// queryGroups reads the groups matching the where clause, with their members, oldest first.
+func (g SQLGroupStore) queryGroups(ctx context.Context, where string, args ...interface{}) ([]*model.Group, error) {
+	rows, err := g.ds.db.QueryContext(ctx,
+		"SELECT id, owner, name, created FROM user_groups WHERE "+where+" ORDER BY created, id", args...)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []*model.Group
+	byId := map[string]*model.Group{}
+	for rows.Next() {
+		var group model.Group
+		var created string
+		if err := rows.Scan(&group.ID, &group.Owner, &group.Name, &created); nil != err {
+			return nil, err
+		}
+		if group.Created, err = parseSQLTime(created); nil != err {
+			return nil, err
+		}
+		groups = append(groups, &group)
+		byId[group.ID] = &group
+	}
+	if err := rows.Err(); nil != err || len(groups) == 0 {
+		return groups, err
+	}
+	return groups, g.readMembers(ctx, byId)
+}
+
+// readMembers reads the members of the given groups, lowest first.
+func (g SQLGroupStore) readMembers(ctx context.Context, byId map[string]*model.Group) error {
+	ids := make([]interface{}, 0, len(byId))
+	for id := range byId {
+		ids = append(ids, id)
+	}
+	rows, err := g.ds.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT group_id, member FROM group_members WHERE group_id IN (%s) ORDER BY group_id, member",
+		placeholders(len(ids))), ids...)
+	if nil != err {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var member int
+		if err := rows.Scan(&id, &member); nil != err {
+			return err
+		}
+		byId[id].Members = append(byId[id].Members, member)
+	}
+	return rows.Err()
+}
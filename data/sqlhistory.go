@@ -0,0 +1,61 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"gatso/model"
+)
+
+// SQLHistoryStore holds the revisions of every task in the task_history table, alongside the tasks of an SQLDataStore.
+// Each revision is a row, holding the task as json.
+type SQLHistoryStore struct {
+	db *sql.DB
+}
+
+// Create a new SQLHistoryStore in the database of the given datastore.
+func NewSQLHistoryStore(s *SQLDataStore) *SQLHistoryStore {
+	return &SQLHistoryStore{db: s.db}
+}
+
+// Close does nothing, the database is closed with its SQLDataStore.
+func (h SQLHistoryStore) Close() {
+}
+
+func (h SQLHistoryStore) AddRevision(ctx context.Context, rev model.Revision) error {
+	js, err := json.Marshal(&rev.Task)
+	if nil != err {
+		return err
+	}
+	_, err = h.db.ExecContext(ctx,
+		"INSERT INTO task_history (task_id, revision, action, changed, changed_by, task) VALUES (?, ?, ?, ?, ?, ?)",
+		rev.TaskID, rev.Number, rev.Action, formatSQLTime(rev.Changed), rev.ChangedBy, string(js))
+	return err
+}
+
+func (h SQLHistoryStore) Revisions(ctx context.Context, taskId string) ([]*model.Revision, error) {
+	rows, err := h.db.QueryContext(ctx,
+		"SELECT revision, action, changed, changed_by, task FROM task_history WHERE task_id = ? ORDER BY revision",
+		taskId)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revs []*model.Revision
+	for rows.Next() {
+		rev := model.Revision{TaskID: taskId}
+		var changed, js string
+		if err := rows.Scan(&rev.Number, &rev.Action, &changed, &rev.ChangedBy, &js); nil != err {
+			return nil, err
+		}
+		if rev.Changed, err = parseSQLTime(changed); nil != err {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(js), &rev.Task); nil != err {
+			return nil, err
+		}
+		revs = append(revs, &rev)
+	}
+	return revs, rows.Err()
+}
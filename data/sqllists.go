@@ -0,0 +1,91 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SQLListStore holds the lists in the lists table, alongside the tasks of an SQLDataStore.
+type SQLListStore struct {
+	db *sql.DB
+}
+
+// Create a new SQLListStore in the database of the given datastore.
+func NewSQLListStore(s *SQLDataStore) *SQLListStore {
+	return &SQLListStore{db: s.db}
+}
+
+// Close does nothing, the database is closed with its SQLDataStore.
+func (l SQLListStore) Close() {
+}
+
+func (l SQLListStore) AddList(ctx context.Context, list model.List) (string, error) {
+	id := primitive.NewObjectID().Hex()
+	_, err := l.db.ExecContext(ctx,
+		"INSERT INTO lists (id, owner, name, description, colour, archived, created) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		id, list.Owner, list.Name, list.Description, list.Colour, list.Archived, formatSQLTime(list.Created))
+	if nil != err {
+		return "", err
+	}
+	return id, nil
+}
+
+func (l SQLListStore) GetList(ctx context.Context, id string) (*model.List, error) {
+	lists, err := l.queryLists(ctx, "id = ?", id)
+	if nil != err || len(lists) == 0 {
+		return nil, err
+	}
+	return lists[0], nil
+}
+
+func (l SQLListStore) Lists(ctx context.Context, ownerId int) ([]*model.List, error) {
+	return l.queryLists(ctx, "owner = ?", ownerId)
+}
+
+func (l SQLListStore) UpdateList(ctx context.Context, ownerId int, list model.List) (bool, error) {
+	res, err := l.db.ExecContext(ctx,
+		"UPDATE lists SET name = ?, description = ?, colour = ?, archived = ? WHERE id = ? AND owner = ?",
+		list.Name, list.Description, list.Colour, list.Archived, list.ID, ownerId)
+	if nil != err {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (l SQLListStore) DeleteList(ctx context.Context, ownerId int, id string) (bool, error) {
+	res, err := l.db.ExecContext(ctx, "DELETE FROM lists WHERE id = ? AND owner = ?", id, ownerId)
+	if nil != err {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// queryLists reads the lists matching the where clause, oldest first.
+func (l SQLListStore) queryLists(ctx context.Context, where string, args ...interface{}) ([]*model.List, error) {
+	rows, err := l.db.QueryContext(ctx,
+		"SELECT id, owner, name, description, colour, archived, created FROM lists WHERE "+where+" ORDER BY created, id",
+		args...)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lists []*model.List
+	for rows.Next() {
+		var list model.List
+		var created string
+		if err := rows.Scan(&list.ID, &list.Owner, &list.Name, &list.Description, &list.Colour, &list.Archived,
+			&created); nil != err {
+			return nil, err
+		}
+		if list.Created, err = parseSQLTime(created); nil != err {
+			return nil, err
+		}
+		lists = append(lists, &list)
+	}
+	return lists, rows.Err()
+}
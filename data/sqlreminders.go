@@ -0,0 +1,88 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"gatso/model"
+	"time"
+)
+
+// SQLReminderStore holds the schedule of reminders in the reminders table, alongside the tasks of an SQLDataStore.
+// Unsent reminders are indexed by when they are due.
+type SQLReminderStore struct {
+	db *sql.DB
+}
+
+// Create a new SQLReminderStore in the database of the given datastore.
+func NewSQLReminderStore(s *SQLDataStore) *SQLReminderStore {
+	return &SQLReminderStore{db: s.db}
+}
+
+// Close does nothing, the database is closed with its SQLDataStore.
+func (r SQLReminderStore) Close() {
+}
+
+func (r SQLReminderStore) ScheduleReminders(ctx context.Context, taskId string, reminders []model.Reminder) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if nil != err {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM reminders WHERE task_id = ? AND sent IS NULL", taskId); nil != err {
+		tx.Rollback()
+		return err
+	}
+	for _, rem := range reminders {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO reminders (task_id, before_due, at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+			taskId, int64(rem.Before), formatSQLTime(rem.At)); nil != err {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r SQLReminderStore) DueReminders(ctx context.Context, due time.Time, limit int) ([]*model.Reminder, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT task_id, before_due, at FROM reminders WHERE sent IS NULL AND at <= ? ORDER BY at, task_id LIMIT ?",
+		formatSQLTime(due), limit)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []*model.Reminder
+	for rows.Next() {
+		var rem model.Reminder
+		var before int64
+		var at string
+		if err := rows.Scan(&rem.TaskID, &before, &at); nil != err {
+			return nil, err
+		}
+		rem.Before = time.Duration(before)
+		if rem.At, err = parseSQLTime(at); nil != err {
+			return nil, err
+		}
+		reminders = append(reminders, &rem)
+	}
+	return reminders, rows.Err()
+}
+
+func (r SQLReminderStore) MarkSent(ctx context.Context, reminder model.Reminder, sent *time.Time) (bool, error) {
+	var res sql.Result
+	var err error
+	if nil == sent {
+		res, err = r.db.ExecContext(ctx,
+			"UPDATE reminders SET sent = NULL WHERE task_id = ? AND before_due = ? AND at = ? AND sent IS NOT NULL",
+			reminder.TaskID, int64(reminder.Before), formatSQLTime(reminder.At))
+	} else {
+		res, err = r.db.ExecContext(ctx,
+			"UPDATE reminders SET sent = ? WHERE task_id = ? AND before_due = ? AND at = ? AND sent IS NULL",
+			formatSQLTime(*sent), reminder.TaskID, int64(reminder.Before), formatSQLTime(reminder.At))
+	}
+	if nil != err {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
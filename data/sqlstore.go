@@ -0,0 +1,1137 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
+
+const sqlTimeFormat = "2006-01-02T15:04:05.000000000Z" // fixed width, so times in UTC sort as text
+
+// sqlMigrations create and update the schema. Each is applied once, in order, recording its position
+// in the schema_version table. Only ever append to the list.
+var sqlMigrations = []string{
+	`CREATE TABLE tasks (
+		seq INTEGER PRIMARY KEY,
+		id TEXT NOT NULL UNIQUE,
+		owner INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		created TEXT NOT NULL,
+		expires TEXT NOT NULL
+	)`,
+	`CREATE INDEX tasks_owner ON tasks (owner, expires)`,
+	`CREATE TABLE task_labels (
+		task_id TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		label TEXT NOT NULL,
+		PRIMARY KEY (task_id, position)
+	)`,
+	`CREATE INDEX task_labels_label ON task_labels (label)`,
+	`CREATE TABLE task_notes (
+		task_id TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		note TEXT NOT NULL,
+		PRIMARY KEY (task_id, position)
+	)`,
+	`CREATE TABLE task_readers (
+		task_id TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		reader INTEGER NOT NULL,
+		PRIMARY KEY (task_id, position)
+	)`,
+	`CREATE INDEX task_readers_reader ON task_readers (reader)`,
+	`ALTER TABLE tasks ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+	`ALTER TABLE tasks ADD COLUMN deleted TEXT`,
+	`CREATE INDEX tasks_deleted ON tasks (deleted)`,
+	`CREATE TABLE task_history (
+		task_id TEXT NOT NULL,
+		revision INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		changed TEXT NOT NULL,
+		changed_by INTEGER NOT NULL,
+		task TEXT NOT NULL,
+		PRIMARY KEY (task_id, revision)
+	)`,
+	`CREATE TABLE webhooks (
+		id TEXT PRIMARY KEY,
+		owner INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL,
+		created TEXT NOT NULL
+	)`,
+	`CREATE INDEX webhooks_owner ON webhooks (owner)`,
+	`CREATE TABLE webhook_deliveries (
+		id TEXT PRIMARY KEY,
+		webhook_id TEXT NOT NULL,
+		owner INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL,
+		next_attempt TEXT NOT NULL,
+		last_error TEXT NOT NULL,
+		response_status INTEGER NOT NULL,
+		created TEXT NOT NULL,
+		delivered TEXT
+	)`,
+	`CREATE INDEX webhook_deliveries_due ON webhook_deliveries (status, next_attempt)`,
+	`CREATE INDEX webhook_deliveries_owner ON webhook_deliveries (owner, created)`,
+	`CREATE TABLE task_reminders (
+		task_id TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		reminder TEXT NOT NULL,
+		PRIMARY KEY (task_id, position)
+	)`,
+	`CREATE TABLE reminders (
+		task_id TEXT NOT NULL,
+		before_due INTEGER NOT NULL,
+		at TEXT NOT NULL,
+		sent TEXT,
+		PRIMARY KEY (task_id, before_due, at)
+	)`,
+	`CREATE INDEX reminders_due ON reminders (at) WHERE sent IS NULL`,
+	`ALTER TABLE tasks ADD COLUMN archived TEXT`,
+	`ALTER TABLE tasks ADD COLUMN unarchived TEXT`,
+	`CREATE INDEX tasks_archived ON tasks (archived)`,
+	`ALTER TABLE tasks ADD COLUMN recurrence TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE tasks ADD COLUMN recurred TEXT`,
+	`CREATE INDEX tasks_recurring ON tasks (expires) WHERE recurrence != ''`,
+	`ALTER TABLE tasks ADD COLUMN status TEXT NOT NULL DEFAULT 'open'`,
+	`ALTER TABLE tasks ADD COLUMN completed_at TEXT`,
+	`CREATE INDEX tasks_status ON tasks (owner, status)`,
+	`ALTER TABLE tasks ADD COLUMN priority INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE tasks ADD COLUMN parent TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX tasks_parent ON tasks (parent) WHERE parent != ''`,
+	`CREATE TABLE task_dependencies (
+		task_id TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		blocked_by TEXT NOT NULL,
+		PRIMARY KEY (task_id, position)
+	)`,
+	`CREATE INDEX task_dependencies_blocked_by ON task_dependencies (blocked_by)`,
+	`ALTER TABLE tasks ADD COLUMN list TEXT NOT NULL DEFAULT 'default'`,
+	`CREATE INDEX tasks_list ON tasks (owner, list)`,
+	`CREATE TABLE lists (
+		id TEXT PRIMARY KEY,
+		owner INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL,
+		colour TEXT NOT NULL,
+		archived INTEGER NOT NULL,
+		created TEXT NOT NULL
+	)`,
+	`CREATE INDEX lists_owner ON lists (owner)`,
+	`CREATE TABLE task_acl (
+		task_id TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		grantee INTEGER NOT NULL,
+		role TEXT NOT NULL,
+		expires TEXT,
+		PRIMARY KEY (task_id, position)
+	)`,
+	`CREATE INDEX task_acl_grantee ON task_acl (grantee)`,
+	`INSERT INTO task_acl (task_id, position, grantee, role) SELECT task_id, position, reader, 'reader' FROM task_readers`,
+	`DROP TABLE task_readers`,
+	`ALTER TABLE task_acl ADD COLUMN group_id TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX task_acl_group ON task_acl (group_id) WHERE group_id != ''`,
+	`CREATE TABLE user_groups (
+		id TEXT PRIMARY KEY,
+		owner INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		created TEXT NOT NULL
+	)`,
+	`CREATE INDEX user_groups_owner ON user_groups (owner)`,
+	`CREATE TABLE group_members (
+		group_id TEXT NOT NULL,
+		member INTEGER NOT NULL,
+		PRIMARY KEY (group_id, member)
+	)`,
+	`CREATE INDEX group_members_member ON group_members (member)`,
+	`CREATE TABLE api_keys (
+		id TEXT PRIMARY KEY,
+		owner INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		scope TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		hash TEXT NOT NULL UNIQUE,
+		created TEXT NOT NULL,
+		last_used TEXT
+	)`,
+	`CREATE INDEX api_keys_owner ON api_keys (owner)`,
+}
+
+// sqlChildTable describes a table holding one of the array fields of a task, one row per element.
+type sqlChildTable struct {
+	table  string
+	column string
+	values func(t *model.Task) []interface{} // the elements of the tasks field
+	add    func(t *model.Task, value string) error
+}
+
+var labelsTable = sqlChildTable{
+	table:  "task_labels",
+	column: "label",
+	values: func(t *model.Task) []interface{} {
+		var values []interface{}
+		for _, l := range t.Labels {
+			values = append(values, l)
+		}
+		return values
+	},
+	add: func(t *model.Task, value string) error {
+		t.Labels = append(t.Labels, value)
+		return nil
+	},
+}
+
+var notesTable = sqlChildTable{
+	table:  "task_notes",
+	column: "note",
+	values: func(t *model.Task) []interface{} {
+		var values []interface{}
+		for _, n := range t.Notes {
+			values = append(values, n)
+		}
+		return values
+	},
+	add: func(t *model.Task, value string) error {
+		t.Notes = append(t.Notes, value)
+		return nil
+	},
+}
+
+var remindersTable = sqlChildTable{
+	table:  "task_reminders",
+	column: "reminder",
+	values: func(t *model.Task) []interface{} {
+		var values []interface{}
+		for _, r := range t.Reminders {
+			values = append(values, r)
+		}
+		return values
+	},
+	add: func(t *model.Task, value string) error {
+		t.Reminders = append(t.Reminders, value)
+		return nil
+	},
+}
+
+var dependenciesTable = sqlChildTable{
+	table:  "task_dependencies",
+	column: "blocked_by",
+	values: func(t *model.Task) []interface{} {
+		var values []interface{}
+		for _, id := range t.BlockedBy {
+			values = append(values, id)
+		}
+		return values
+	},
+	add: func(t *model.Task, value string) error {
+		t.BlockedBy = append(t.BlockedBy, value)
+		return nil
+	},
+}
+
+var sqlChildTables = []sqlChildTable{labelsTable, notesTable, remindersTable, dependenciesTable}
+
+// SQLDataStore is a database/sql implementation of the datastore.
+// Labels, notes, reminders and dependencies are held in child tables, one row per element, so they can be queried in SQL.
+// The ACL is held in the task_acl table, one row per grant, to a user or a group.
+// Queries use '?' placeholders, as used by the bundled sqlite driver.
+type SQLDataStore struct {
+	db *sql.DB
+}
+
+// Create a new SQLDataStore, opening the database with the given driver and data source name.
+// The schema is created, or brought up to date, before the store is returned.
+func NewSQLDataStore(driverName string, dsn string) (*SQLDataStore, error) {
+	db, err := sql.Open(driverName, dsn)
+	if nil != err {
+		return nil, err
+	}
+	if driverName == "sqlite" {
+		// sqlite only allows one writer, let the pool queue them rather than fail with busy errors.
+		db.SetMaxOpenConns(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); nil != err {
+		db.Close()
+		return nil, err
+	}
+
+	s := &SQLDataStore{db: db}
+	if err := s.migrate(ctx); nil != err {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Drop will delete every task from the database. (Used for testing)
+func (s SQLDataStore) Drop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
+	defer cancel()
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM tasks"); nil != err {
+		return err
+	}
+	for _, child := range sqlChildTables {
+		if _, err := s.db.ExecContext(ctx, "DELETE FROM "+child.table); nil != err {
+			return err
+		}
+	}
+	_, err := s.db.ExecContext(ctx, "DELETE FROM task_acl")
+	return err
+}
+
+// Close the database and release the connections.
+func (s SQLDataStore) Close() {
+	s.db.Close()
+}
+
+func (s SQLDataStore) GetTasks(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error) {
+	where, args := statusWhere("owner = ? AND deleted IS NULL AND archived IS NULL", []interface{}{ownerId},
+		opts.statuses(true))
+	return s.page(ctx, opts, where, args...)
+}
+
+func (s SQLDataStore) GetOthersTasks(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error) {
+	grantee := "(group_id = '' AND grantee = ?)"
+	args := []interface{}{ownerId}
+	if len(opts.Groups) > 0 {
+		grantee = "(" + grantee + " OR group_id IN (" + placeholders(len(opts.Groups)) + "))"
+		for _, g := range opts.Groups {
+			args = append(args, g)
+		}
+	}
+	where, args := statusWhere(
+		"id IN (SELECT task_id FROM task_acl WHERE "+grantee+" AND (expires IS NULL OR expires > ?)) "+
+			"AND deleted IS NULL AND archived IS NULL",
+		append(args, formatSQLTime(time.Now())), opts.statuses(true))
+	return s.page(ctx, opts, where, args...)
+}
+
+func (s SQLDataStore) FindTasks(ctx context.Context, ownerId int, query model.Task, opts ListOptions) (model.TaskPage, error) {
+	clauses, args := findWhere(ownerId, query)
+	where, args := statusWhere(strings.Join(append(clauses, "archived IS NULL"), " AND "), args,
+		opts.withQueryStatus(query).statuses(true))
+	return s.page(ctx, opts, where, args...)
+}
+
+// statusWhere limits the where clause, and its args, to the tasks with the given statuses, unless nil.
+func statusWhere(where string, args []interface{}, statuses []string) (string, []interface{}) {
+	if len(statuses) == 0 {
+		return where, args
+	}
+	for _, status := range statuses {
+		args = append(args, status)
+	}
+	return where + " AND status IN (" + placeholders(len(statuses)) + ")", args
+}
+
+// findWhere builds the clauses, and their args, selecting the owners tasks outside the trash matching the query task.
+func findWhere(ownerId int, query model.Task) ([]string, []interface{}) {
+	where := []string{"owner = ?", "deleted IS NULL"}
+	args := []interface{}{ownerId}
+
+	if query.Title != "" {
+		where = append(where, "title = ?")
+		args = append(args, query.Title)
+	}
+	if !query.Expires.IsZero() {
+		where = append(where, "expires < ?")
+		args = append(args, formatSQLTime(query.Expires))
+	}
+	if !query.Created.IsZero() {
+		where = append(where, "created >= ?")
+		args = append(args, formatSQLTime(query.Created))
+	}
+	if query.List != "" {
+		where = append(where, "list = ?")
+		args = append(args, query.List)
+	}
+
+	for _, child := range sqlChildTables {
+		values := child.values(&query)
+		if len(values) == 0 {
+			continue
+		}
+		clause, clauseArgs := child.containsAll(values)
+		where = append(where, clause)
+		args = append(args, clauseArgs...)
+	}
+	for _, g := range query.ACL {
+		if g.Role == "" {
+			where = append(where, "id IN (SELECT task_id FROM task_acl WHERE grantee = ? AND group_id = ?)")
+			args = append(args, g.User, g.Group)
+		} else {
+			where = append(where, "id IN (SELECT task_id FROM task_acl WHERE grantee = ? AND group_id = ? AND role = ?)")
+			args = append(args, g.User, g.Group, g.Role)
+		}
+	}
+	return where, args
+}
+
+// accessWhere builds the clause, and its args, selecting the tasks the user has at least the needed role on.
+func accessWhere(userId int, needed string) (string, []interface{}) {
+	roles := model.RolesAllowing(needed)
+	if len(roles) == 0 {
+		return "owner = ?", []interface{}{userId}
+	}
+	args := []interface{}{userId, userId}
+	for _, role := range roles {
+		args = append(args, role)
+	}
+	args = append(args, formatSQLTime(time.Now()))
+	return "(owner = ? OR id IN (SELECT task_id FROM task_acl WHERE grantee = ? AND group_id = '' AND role IN (" + placeholders(len(roles)) +
+		") AND (expires IS NULL OR expires > ?)))", args
+}
+
+func (s SQLDataStore) AddTask(ctx context.Context, ownerId int, task model.Task) (string, error) {
+	if task.Owner != ownerId {
+		return "", fmt.Errorf("Owner %d does not own the given task to add", ownerId)
+	}
+
+	if err := checkStatus(&task); nil != err {
+		return "", err
+	}
+	if err := checkACL(&task); nil != err {
+		return "", err
+	}
+	task.List = model.ListOf(&task)
+
+	task.Created = time.Now()
+	oid := primitive.NewObjectID()
+	task.ID = &oid
+	task.Version = 1
+	var completed interface{}
+	if at := completedAt(task.Status, nil); nil != at {
+		completed = formatSQLTime(*at)
+	}
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := checkTree(ctx, tx, &task); nil != err {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO tasks (id, owner, title, created, expires, version, recurrence, status, completed_at, priority, "+
+				"parent, list) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			oid.Hex(), task.Owner, task.Title, formatSQLTime(task.Created), formatSQLTime(task.Expires), task.Version,
+			task.Recurrence, task.Status, completed, task.Priority, task.Parent, task.List)
+		if nil != err {
+			return err
+		}
+		if err := insertChildren(ctx, tx, &task); nil != err {
+			return err
+		}
+		if err := insertACL(ctx, tx, &task); nil != err {
+			return err
+		}
+		return recordWritten(ctx, tx, oid.Hex())
+	})
+	if nil != err {
+		return "", err
+	}
+	return oid.Hex(), nil
+}
+
+func (s SQLDataStore) UpdateTask(ctx context.Context, ownerId int, task model.Task) (int, error) {
+	if nil == task.ID { // no id, treat as an Add
+		return s.upsertMissing(ctx, ownerId, task)
+	}
+	if err := checkStatus(&task); nil != err {
+		return 0, err
+	}
+	if err := checkACL(&task); nil != err {
+		return 0, err
+	}
+	task.List = model.ListOf(&task)
+
+	existing := s.GetTask(ctx, task.Id())
+	if nil == existing { // doesn't exist, treat as an Add
+		return s.upsertMissing(ctx, ownerId, task)
+	}
+	needed := neededRole(existing, &task)
+	if err := checkAccess(existing, ownerId, needed); nil != err {
+		return 0, err
+	}
+
+	// version, status and access are checked as part of the update, so a concurrent change can't slip in between.
+	where, args := updateWhere(task.Id(), ownerId, needed, task.Status, task.Version)
+	args = append([]interface{}{task.Owner, task.Title, formatSQLTime(task.Created), formatSQLTime(task.Expires),
+		task.Recurrence, task.Priority, task.Parent, task.List, task.Status, task.Status, formatSQLTime(time.Now())}, args...)
+
+	var version int
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := checkTree(ctx, tx, &task); nil != err {
+			return err
+		}
+		result, err := tx.ExecContext(ctx,
+			"UPDATE tasks SET owner = ?, title = ?, created = ?, expires = ?, recurrence = ?, priority = ?, parent = ?, "+
+				"list = ?, status = ?, "+completedAtSet+", version = version + 1 WHERE "+where,
+			args...)
+		if nil != err {
+			return err
+		}
+		if n, err := result.RowsAffected(); nil != err || n == 0 {
+			return err
+		}
+		if err := tx.QueryRowContext(ctx, "SELECT version FROM tasks WHERE id = ?", task.Id()).Scan(&version); nil != err {
+			return err
+		}
+		if err := deleteChildren(ctx, tx, task.Id()); nil != err {
+			return err
+		}
+		if err := insertChildren(ctx, tx, &task); nil != err {
+			return err
+		}
+		if needed != model.RoleEditor { // else unchanged, so left as stored in case it has just been changed
+			if _, err := tx.ExecContext(ctx, "DELETE FROM task_acl WHERE task_id = ?", task.Id()); nil != err {
+				return err
+			}
+			if err := insertACL(ctx, tx, &task); nil != err {
+				return err
+			}
+		}
+		return recordWritten(ctx, tx, task.Id())
+	})
+	if nil != err || version != 0 {
+		return version, err
+	}
+
+	// nothing updated, find out why
+	existing = s.GetTask(ctx, task.Id())
+	if nil == existing { // doesn't exist, treat as an Add
+		return s.upsertMissing(ctx, ownerId, task)
+	}
+	if err := checkAccess(existing, ownerId, needed); nil != err {
+		return 0, err
+	}
+	return 0, updateRefused(existing, ownerId, task.Status)
+}
+
+// checkTree checks the parent and blockers of the task being saved, reading them in the transaction saving it.
+// sqlite runs one transaction at a time, so two changes can't each pass the check and together make a cycle.
+func checkTree(ctx context.Context, tx *sql.Tx, task *model.Task) error {
+	get := func(id string) *model.Task {
+		if _, err := primitive.ObjectIDFromHex(id); nil != err {
+			return nil
+		}
+		tasks, err := queryTasks(ctx, tx, "id = ?", "id", 1, id)
+		if nil != err || len(tasks) == 0 {
+			return nil
+		}
+		return tasks[0]
+	}
+	if err := checkParent(task, get); nil != err {
+		return err
+	}
+	return checkDependencies(task, get)
+}
+
+// completedAtSet sets when a task was done, given its new status twice then the current time.
+// A task already done keeps the time it was done at.
+const completedAtSet = "completed_at = CASE WHEN ? = 'done' THEN COALESCE(completed_at, ?) ELSE NULL END"
+
+// updateWhere builds the clause, and its args, selecting the task to update if the user has the needed role on it,
+// it can move to the given status, and is at the given version, unless that is 0.
+func updateWhere(taskId string, userId int, needed string, status string, version int) (string, []interface{}) {
+	before := model.StatusesBefore(status)
+	access, accessArgs := accessWhere(userId, needed)
+	where := "id = ? AND " + access + " AND deleted IS NULL AND archived IS NULL AND status IN (" +
+		placeholders(len(before)) + ")"
+	args := append([]interface{}{taskId}, accessArgs...)
+	for _, s := range before {
+		args = append(args, s)
+	}
+	if version != 0 {
+		where += " AND version = ?"
+		args = append(args, version)
+	}
+	return where, args
+}
+
+func (s SQLDataStore) SetTaskStatus(ctx context.Context, ownerId int, taskId string, status string, version int) (int, error) {
+	task := model.Task{Status: status}
+	if err := checkStatus(&task); nil != err {
+		return 0, err
+	}
+	where, args := updateWhere(taskId, ownerId, model.RoleEditor, task.Status, version)
+	args = append([]interface{}{task.Status, task.Status, formatSQLTime(time.Now())}, args...)
+
+	var updated int
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			"UPDATE tasks SET status = ?, "+completedAtSet+", version = version + 1 WHERE "+where, args...)
+		if nil != err {
+			return err
+		}
+		if n, err := result.RowsAffected(); nil != err || n == 0 {
+			return err
+		}
+		if err := tx.QueryRowContext(ctx, "SELECT version FROM tasks WHERE id = ?", taskId).Scan(&updated); nil != err {
+			return err
+		}
+		return recordWritten(ctx, tx, taskId)
+	})
+	if nil != err || updated != 0 {
+		return updated, err
+	}
+
+	// nothing updated, find out why
+	existing := s.GetTask(ctx, taskId)
+	if nil == existing || model.RoleOf(existing, ownerId, time.Now()) == "" || nil != existing.Deleted {
+		return 0, nil
+	}
+	return 0, updateRefused(existing, ownerId, task.Status)
+}
+
+// upsertMissing adds a task given to update, which doesn't exist. It has no version to match.
+func (s SQLDataStore) upsertMissing(ctx context.Context, ownerId int, task model.Task) (int, error) {
+	if task.Version != 0 {
+		return 0, ErrVersionConflict
+	}
+	if _, err := s.AddTask(ctx, ownerId, task); nil != err {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (s SQLDataStore) DeleteTask(ctx context.Context, ownerId int, taskId string, version int) (bool, error) {
+	access, accessArgs := accessWhere(ownerId, model.RoleAdmin)
+	where := "id = ? AND " + access + " AND deleted IS NULL"
+	args := append([]interface{}{formatSQLTime(time.Now()), taskId}, accessArgs...)
+	if version != 0 {
+		where += " AND version = ?"
+		args = append(args, version)
+	}
+
+	deleted, err := s.updateOne(ctx, taskId, "UPDATE tasks SET deleted = ?, version = version + 1 WHERE "+where, args...)
+	if nil != err {
+		return false, err
+	}
+	if !deleted && version != 0 {
+		existing := s.GetTask(ctx, taskId)
+		if nil != existing && model.Allows(model.RoleOf(existing, ownerId, time.Now()), model.RoleAdmin) &&
+			nil == existing.Deleted {
+			return false, ErrVersionConflict
+		}
+	}
+	return deleted, nil
+}
+
+func (s SQLDataStore) GetSubtasks(ctx context.Context, taskId string, opts ListOptions) (model.TaskPage, error) {
+	where, args := statusWhere("parent = ? AND deleted IS NULL AND archived IS NULL", []interface{}{taskId},
+		opts.statuses(true))
+	return s.page(ctx, opts, where, args...)
+}
+
+func (s SQLDataStore) GetDependents(ctx context.Context, taskId string, opts ListOptions) (model.TaskPage, error) {
+	where, args := statusWhere(
+		"id IN (SELECT task_id FROM task_dependencies WHERE blocked_by = ?) AND deleted IS NULL AND archived IS NULL",
+		[]interface{}{taskId}, opts.statuses(true))
+	return s.page(ctx, opts, where, args...)
+}
+
+func (s SQLDataStore) GetTrash(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error) {
+	where, args := statusWhere("owner = ? AND deleted IS NOT NULL", []interface{}{ownerId}, opts.statuses(false))
+	return s.page(ctx, opts, where, args...)
+}
+
+func (s SQLDataStore) RestoreTask(ctx context.Context, ownerId int, taskId string) (bool, error) {
+	access, args := accessWhere(ownerId, model.RoleAdmin)
+	return s.updateOne(ctx, taskId,
+		"UPDATE tasks SET deleted = NULL, version = version + 1 WHERE id = ? AND "+access+" AND deleted IS NOT NULL",
+		append([]interface{}{taskId}, args...)...)
+}
+
+func (s SQLDataStore) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	var purged int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, child := range sqlChildTables {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+				"DELETE FROM %s WHERE task_id IN (SELECT id FROM tasks WHERE deleted < ?)", child.table),
+				formatSQLTime(before)); nil != err {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM task_acl WHERE task_id IN (SELECT id FROM tasks WHERE deleted < ?)",
+			formatSQLTime(before)); nil != err {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, "DELETE FROM tasks WHERE deleted < ?", formatSQLTime(before))
+		if nil != err {
+			return err
+		}
+		purged, err = result.RowsAffected()
+		return err
+	})
+	if nil != err {
+		return 0, err
+	}
+	return int(purged), nil
+}
+
+func (s SQLDataStore) GetArchive(ctx context.Context, ownerId int, query model.Task, opts ListOptions) (model.TaskPage, error) {
+	clauses, args := findWhere(ownerId, query)
+	where, args := statusWhere(strings.Join(append(clauses, "archived IS NOT NULL"), " AND "), args,
+		opts.withQueryStatus(query).statuses(false))
+	return s.page(ctx, opts, where, args...)
+}
+
+func (s SQLDataStore) UnarchiveTask(ctx context.Context, ownerId int, taskId string) (bool, error) {
+	return s.updateOne(ctx, taskId,
+		"UPDATE tasks SET archived = NULL, unarchived = ?, version = version + 1 "+
+			"WHERE id = ? AND owner = ? AND deleted IS NULL AND archived IS NOT NULL",
+		formatSQLTime(time.Now()), taskId, ownerId)
+}
+
+// updateOne runs the update of the task with the given id, reporting if it was updated.
+// The task is read back in the same transaction, to record it as written.
+func (s SQLDataStore) updateOne(ctx context.Context, taskId string, update string, args ...interface{}) (bool, error) {
+	var updated bool
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, update, args...)
+		if nil != err {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if nil != err || n == 0 {
+			return err
+		}
+		updated = true
+		return recordWritten(ctx, tx, taskId)
+	})
+	if nil != err {
+		return false, err
+	}
+	return updated, nil
+}
+
+// recordWritten reads back the task written in the transaction, to record it as written, when the context is
+// recording writes.
+func recordWritten(ctx context.Context, tx *sql.Tx, taskId string) error {
+	if !recordingWrites(ctx) {
+		return nil
+	}
+	tasks, err := queryTasks(ctx, tx, "id = ?", "id", 1, taskId)
+	if nil != err {
+		return err
+	}
+	if len(tasks) > 0 {
+		wrote(ctx, tasks[0])
+	}
+	return nil
+}
+
+func (s SQLDataStore) ArchiveTasks(ctx context.Context, expiredBefore time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE tasks SET archived = ?, version = version + 1 "+
+			"WHERE deleted IS NULL AND archived IS NULL AND expires > ? AND expires < ? "+
+			"AND (unarchived IS NULL OR unarchived < expires)",
+		formatSQLTime(time.Now()), formatSQLTime(time.Time{}), formatSQLTime(expiredBefore))
+	if nil != err {
+		return 0, err
+	}
+	archived, err := result.RowsAffected()
+	if nil != err {
+		return 0, err
+	}
+	return int(archived), nil
+}
+
+func (s SQLDataStore) DueRecurrences(ctx context.Context, before time.Time, limit int) ([]*model.Task, error) {
+	return s.query(ctx, "recurrence != '' AND deleted IS NULL AND archived IS NULL AND expires > ? AND expires < ? "+
+		"AND (recurred IS NULL OR recurred < expires)", "expires", limit,
+		formatSQLTime(time.Time{}), formatSQLTime(before))
+}
+
+func (s SQLDataStore) MarkRecurred(ctx context.Context, taskId string, expires time.Time) (bool, error) {
+	var result sql.Result
+	var err error
+	if expires.IsZero() {
+		result, err = s.db.ExecContext(ctx, "UPDATE tasks SET recurred = NULL WHERE id = ?", taskId)
+	} else {
+		result, err = s.db.ExecContext(ctx,
+			"UPDATE tasks SET recurred = expires WHERE id = ? AND recurrence != '' AND expires = ? "+
+				"AND (recurred IS NULL OR recurred < expires)",
+			taskId, formatSQLTime(expires))
+	}
+	if nil != err {
+		return false, err
+	}
+	marked, err := result.RowsAffected()
+	if nil != err {
+		return false, err
+	}
+	return marked > 0, nil
+}
+
+func (s SQLDataStore) GetTask(ctx context.Context, taskId string) *model.Task {
+	if _, err := primitive.ObjectIDFromHex(taskId); nil != err {
+		return nil
+	}
+	tasks, err := s.query(ctx, "id = ?", "id", 1, taskId)
+	if nil != err || len(tasks) == 0 {
+		return nil
+	}
+	return tasks[0]
+}
+
+func (s SQLDataStore) CountTasks(ctx context.Context, ownerId int) int {
+	var c int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tasks WHERE owner = ? AND deleted IS NULL AND archived IS NULL", ownerId).Scan(&c); nil != err {
+		return -1
+	}
+	return c
+}
+
+func (s SQLDataStore) Exists(ctx context.Context, taskId string) bool {
+	return s.GetTask(ctx, taskId) != nil
+}
+
+func (s SQLDataStore) Users(ctx context.Context) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT DISTINCT owner FROM tasks ORDER BY owner")
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var owners []int
+	for rows.Next() {
+		var o int
+		if err := rows.Scan(&o); nil != err {
+			return nil, err
+		}
+		owners = append(owners, o)
+	}
+	return owners, rows.Err()
+}
+
+// migrate applies any of the sqlMigrations not yet applied to the database.
+func (s SQLDataStore) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)"); nil != err {
+		return err
+	}
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		var version int
+		if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version); nil != err {
+			return err
+		}
+		for i := version; i < len(sqlMigrations); i++ {
+			if _, err := tx.ExecContext(ctx, sqlMigrations[i]); nil != err {
+				return fmt.Errorf("failed to apply schema migration %d: %v", i+1, err)
+			}
+		}
+		if version >= len(sqlMigrations) {
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM schema_version"); nil != err {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO schema_version (version) VALUES (?)", len(sqlMigrations))
+		return err
+	})
+}
+
+// inTx runs the given func in a transaction, committing if it succeeds, rolling back if not.
+func (s SQLDataStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if nil != err {
+		return err
+	}
+	if err := fn(tx); nil != err {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// page reads the page of tasks matching the given where clause, in the listing order, starting after the cursor.
+func (s SQLDataStore) page(ctx context.Context, opts ListOptions, where string, args ...interface{}) (model.TaskPage, error) {
+	if opts.List != "" {
+		where = "(" + where + ") AND list = ?"
+		args = append(args, opts.List)
+	}
+	if opts.Sort.ByUrgency() {
+		tasks, err := s.query(ctx, where, "id", MaxUrgencyTasks+1, args...) // every task, for urgencyPage to score them
+		if nil != err {
+			return model.TaskPage{}, err
+		}
+		return urgencyPage(tasks, opts)
+	}
+	after, err := opts.after()
+	if nil != err {
+		return model.TaskPage{}, err
+	}
+	keys := opts.Sort.keys()
+	if nil != after {
+		// tasks following the cursor, differ from it at one of the sort keys, being equal on all before it.
+		var following []string
+		for i, key := range keys {
+			var terms []string
+			for _, k := range keys[:i] {
+				terms = append(terms, sortFields[k.Field].column+" = ?")
+				args = append(args, sqlSortValue(k, after))
+			}
+			op := " > ?"
+			if key.Desc {
+				op = " < ?"
+			}
+			terms = append(terms, sortFields[key.Field].column+op)
+			args = append(args, sqlSortValue(key, after))
+			following = append(following, "("+strings.Join(terms, " AND ")+")")
+		}
+		where = "(" + where + ") AND (" + strings.Join(following, " OR ") + ")"
+	}
+
+	var order []string
+	for _, key := range keys {
+		if key.Desc {
+			order = append(order, sortFields[key.Field].column+" DESC")
+		} else {
+			order = append(order, sortFields[key.Field].column)
+		}
+	}
+
+	tasks, err := s.query(ctx, where, strings.Join(order, ", "), opts.limit()+1, args...)
+	if nil != err {
+		return model.TaskPage{}, err
+	}
+	return newPage(tasks, opts), nil
+}
+
+// sqlQuerier is the database, or a transaction on it, for tasks to be read within a write.
+type sqlQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// query reads up to limit tasks matching the given where clause, in the given order. A negative limit reads them all.
+func (s SQLDataStore) query(ctx context.Context, where string, orderBy string, limit int, args ...interface{}) ([]*model.Task, error) {
+	return queryTasks(ctx, s.db, where, orderBy, limit, args...)
+}
+
+// queryTasks reads the tasks as query does, with the given querier.
+func queryTasks(ctx context.Context, q sqlQuerier, where string, orderBy string, limit int, args ...interface{}) ([]*model.Task, error) {
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(
+		"SELECT id, owner, title, created, expires, version, deleted, archived, unarchived, recurrence, recurred, "+
+			"status, completed_at, priority, parent, list "+
+			"FROM tasks WHERE %s ORDER BY %s LIMIT %d",
+		where, orderBy, limit), args...)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []*model.Task
+	byId := map[string]*model.Task{}
+	for rows.Next() {
+		var task model.Task
+		var id, created, expires string
+		var deleted, archived, unarchived, recurred, completed sql.NullString
+		if err := rows.Scan(&id, &task.Owner, &task.Title, &created, &expires, &task.Version,
+			&deleted, &archived, &unarchived, &task.Recurrence, &recurred, &task.Status, &completed,
+			&task.Priority, &task.Parent, &task.List); nil != err {
+			return nil, err
+		}
+		var err error
+		if task.Deleted, err = parseNullSQLTime(deleted); nil != err {
+			return nil, err
+		}
+		if task.Archived, err = parseNullSQLTime(archived); nil != err {
+			return nil, err
+		}
+		if task.Unarchived, err = parseNullSQLTime(unarchived); nil != err {
+			return nil, err
+		}
+		if task.Recurred, err = parseNullSQLTime(recurred); nil != err {
+			return nil, err
+		}
+		if task.CompletedAt, err = parseNullSQLTime(completed); nil != err {
+			return nil, err
+		}
+		oid, err := primitive.ObjectIDFromHex(id)
+		if nil != err {
+			return nil, err
+		}
+		task.ID = &oid
+		if task.Created, err = parseSQLTime(created); nil != err {
+			return nil, err
+		}
+		if task.Expires, err = parseSQLTime(expires); nil != err {
+			return nil, err
+		}
+		tasks = append(tasks, &task)
+		byId[id] = &task
+	}
+	if err := rows.Err(); nil != err {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return tasks, nil
+	}
+
+	// Fill in the array fields from the child tables
+	for _, child := range sqlChildTables {
+		if err := readChildren(ctx, q, child, byId); nil != err {
+			return nil, err
+		}
+	}
+	if err := readACL(ctx, q, byId); nil != err {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// readChildren reads the rows of the child table belonging to the given tasks into their array field.
+func readChildren(ctx context.Context, q sqlQuerier, child sqlChildTable, byId map[string]*model.Task) error {
+	ids := make([]interface{}, 0, len(byId))
+	for id := range byId {
+		ids = append(ids, id)
+	}
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(
+		"SELECT task_id, %s FROM %s WHERE task_id IN (%s) ORDER BY task_id, position",
+		child.column, child.table, placeholders(len(ids))), ids...)
+	if nil != err {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, value string
+		if err := rows.Scan(&id, &value); nil != err {
+			return err
+		}
+		if err := child.add(byId[id], value); nil != err {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// readACL reads the grants of the given tasks into their ACL.
+func readACL(ctx context.Context, q sqlQuerier, byId map[string]*model.Task) error {
+	ids := make([]interface{}, 0, len(byId))
+	for id := range byId {
+		ids = append(ids, id)
+	}
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(
+		"SELECT task_id, grantee, group_id, role, expires FROM task_acl WHERE task_id IN (%s) ORDER BY task_id, position",
+		placeholders(len(ids))), ids...)
+	if nil != err {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var g model.Grant
+		var expires sql.NullString
+		if err := rows.Scan(&id, &g.User, &g.Group, &g.Role, &expires); nil != err {
+			return err
+		}
+		if g.Expires, err = parseNullSQLTime(expires); nil != err {
+			return err
+		}
+		byId[id].ACL = append(byId[id].ACL, g)
+	}
+	return rows.Err()
+}
+
+// containsAll builds a where clause matching tasks with ALL of the given values in the child table.
+func (c sqlChildTable) containsAll(values []interface{}) (string, []interface{}) {
+	distinct := map[interface{}]bool{}
+	var args []interface{}
+	for _, v := range values {
+		if !distinct[v] {
+			distinct[v] = true
+			args = append(args, v)
+		}
+	}
+	clause := fmt.Sprintf("(SELECT COUNT(DISTINCT %s) FROM %s WHERE task_id = tasks.id AND %s IN (%s)) = %d",
+		c.column, c.table, c.column, placeholders(len(args)), len(args))
+	return clause, args
+}
+
+// insertChildren writes the array fields of the task into their child tables.
+func insertChildren(ctx context.Context, tx *sql.Tx, task *model.Task) error {
+	for _, child := range sqlChildTables {
+		for i, v := range child.values(task) {
+			_, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (task_id, position, %s) VALUES (?, ?, ?)",
+				child.table, child.column), task.Id(), i, v)
+			if nil != err {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// insertACL writes the grants of the task into the task_acl table.
+func insertACL(ctx context.Context, tx *sql.Tx, task *model.Task) error {
+	for i, g := range task.ACL {
+		var expires interface{}
+		if nil != g.Expires {
+			expires = formatSQLTime(*g.Expires)
+		}
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO task_acl (task_id, position, grantee, group_id, role, expires) VALUES (?, ?, ?, ?, ?, ?)",
+			task.Id(), i, g.User, g.Group, g.Role, expires)
+		if nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteChildren removes all the child table rows of the given task.
+func deleteChildren(ctx context.Context, tx *sql.Tx, taskId string) error {
+	for _, child := range sqlChildTables {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE task_id = ?", child.table), taskId); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// sqlSortValue gets the value of the tasks sort key, as it is held in the tasks table.
+func sqlSortValue(key SortKey, t *model.Task) interface{} {
+	switch v := sortFields[key.Field].value(t).(type) {
+	case time.Time:
+		return formatSQLTime(v)
+	case primitive.ObjectID:
+		return v.Hex()
+	default:
+		return v
+	}
+}
+
+func formatSQLTime(t time.Time) string {
+	return t.UTC().Format(sqlTimeFormat)
+}
+
+func parseSQLTime(s string) (time.Time, error) {
+	return time.Parse(sqlTimeFormat, s)
+}
+
+// parseNullSQLTime parses an optional time column, nil when it is NULL.
+func parseNullSQLTime(ns sql.NullString) (*time.Time, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	t, err := parseSQLTime(ns.String)
+	if nil != err {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,252 @@
+package data_test
+
+import (
+	"fmt"
+	"gatso/data"
+	"gatso/data/datastoretest"
+	"gatso/model"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLDataStore_Conformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunConformance(t, func() data.Datastore {
+		count++
+		s, err := data.NewSQLDataStore("sqlite", fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+func TestSQLDataStore_ChangeConformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunChangeConformance(t, func() data.Datastore {
+		count++
+		s, err := data.NewSQLDataStore("sqlite", fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+func TestSQLHistoryStore_Conformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunHistoryConformance(t, func() data.HistoryStore {
+		count++
+		s, err := data.NewSQLDataStore("sqlite", fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return sqlHistory{data.NewSQLHistoryStore(s), s}
+	})
+}
+
+// sqlHistory closes the datastore holding the history along with it.
+type sqlHistory struct {
+	*data.SQLHistoryStore
+	ds *data.SQLDataStore
+}
+
+func (h sqlHistory) Close() {
+	h.ds.Close()
+}
+
+func TestSQLWebhookStore_Conformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunWebhookConformance(t, func() data.WebhookStore {
+		count++
+		s, err := data.NewSQLDataStore("sqlite", fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return sqlWebhooks{data.NewSQLWebhookStore(s), s}
+	})
+}
+
+// sqlWebhooks closes the datastore holding the webhooks along with them.
+type sqlWebhooks struct {
+	*data.SQLWebhookStore
+	ds *data.SQLDataStore
+}
+
+func (w sqlWebhooks) Close() {
+	w.ds.Close()
+}
+
+func TestSQLListStore_Conformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunListConformance(t, func() data.ListStore {
+		count++
+		s, err := data.NewSQLDataStore("sqlite", fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return sqlLists{data.NewSQLListStore(s), s}
+	})
+}
+
+// sqlLists closes the datastore holding the lists along with them.
+type sqlLists struct {
+	*data.SQLListStore
+	ds *data.SQLDataStore
+}
+
+func (l sqlLists) Close() {
+	l.ds.Close()
+}
+
+func TestSQLGroupStore_Conformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunGroupConformance(t, func() data.GroupStore {
+		count++
+		s, err := data.NewSQLDataStore("sqlite", fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return sqlGroups{data.NewSQLGroupStore(s), s}
+	})
+}
+
+// sqlGroups closes the datastore holding the groups along with them.
+type sqlGroups struct {
+	*data.SQLGroupStore
+	ds *data.SQLDataStore
+}
+
+func (g sqlGroups) Close() {
+	g.ds.Close()
+}
+
+func TestSQLAPIKeyStore_Conformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunAPIKeyConformance(t, func() data.APIKeyStore {
+		count++
+		s, err := data.NewSQLDataStore("sqlite", fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return sqlAPIKeys{data.NewSQLAPIKeyStore(s), s}
+	})
+}
+
+// sqlAPIKeys closes the datastore holding the api keys along with them.
+type sqlAPIKeys struct {
+	*data.SQLAPIKeyStore
+	ds *data.SQLDataStore
+}
+
+func (k sqlAPIKeys) Close() {
+	k.ds.Close()
+}
+
+func TestSQLReminderStore_Conformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunReminderConformance(t, func() data.ReminderStore {
+		count++
+		s, err := data.NewSQLDataStore("sqlite", fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return sqlReminders{data.NewSQLReminderStore(s), s}
+	})
+}
+
+// sqlReminders closes the datastore holding the reminders along with them.
+type sqlReminders struct {
+	*data.SQLReminderStore
+	ds *data.SQLDataStore
+}
+
+func (r sqlReminders) Close() {
+	r.ds.Close()
+}
+
+func TestSQLDataStore_Reopen(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	s, err := data.NewSQLDataStore("sqlite", path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	task, err := createTestTask([]byte(`{ "owner": 123, "title": "Test Task",
+		"labels": ["b", "a"], "notes": ["a note"],
+		"acl": [{"user": 456, "role": "reader"}, {"user": 789, "role": "editor", "expires": "2100-01-01T00:00:00Z"}] }`))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	id, err := s.AddTask(ctx, testOwnerId, *task)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	s.Close()
+
+	// Reopening an existing database must not reapply the schema
+	s, err = data.NewSQLDataStore("sqlite", path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	defer s.Close()
+
+	task = s.GetTask(ctx, id)
+	if nil == task {
+		t.Errorf("Expected task %s to exist after reopening", id)
+		return
+	}
+	if len(task.Labels) != 2 || task.Labels[0] != "b" || task.Labels[1] != "a" {
+		t.Errorf("Expected labels to keep their order, found %v", task.Labels)
+		return
+	}
+	if len(task.ACL) != 2 || task.ACL[0].User != 456 || nil != task.ACL[0].Expires || task.ACL[1].Role != model.RoleEditor ||
+		nil == task.ACL[1].Expires || task.ACL[1].Expires.Year() != 2100 {
+		t.Errorf("Expected the task shared with 456 as a reader and 789 as an editor until 2100, found %+v", task.ACL)
+		return
+	}
+
+	if deleted, err := s.DeleteTask(ctx, testOwnerId, id, 0); !deleted || nil != err {
+		t.Errorf("Expected delete of task %s to succeed", id)
+		return
+	}
+	tasks, err := pageTasks(s.GetOthersTasks(ctx, 456, data.ListOptions{}))
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(tasks) != 0 {
+		t.Errorf("Expected deleted task to no longer be visible to the users it is shared with, found %d", len(tasks))
+		return
+	}
+}
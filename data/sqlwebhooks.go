@@ -0,0 +1,166 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
+
+const sqlDeliveryColumns = "id, webhook_id, owner, event, payload, status, attempts, next_attempt, last_error, " +
+	"response_status, created, delivered"
+
+// SQLWebhookStore holds the webhooks and their deliveries in the webhooks and webhook_deliveries tables,
+// alongside the tasks of an SQLDataStore. The event types of a webhook are held as a comma separated list.
+type SQLWebhookStore struct {
+	db *sql.DB
+}
+
+// Create a new SQLWebhookStore in the database of the given datastore.
+func NewSQLWebhookStore(s *SQLDataStore) *SQLWebhookStore {
+	return &SQLWebhookStore{db: s.db}
+}
+
+// Close does nothing, the database is closed with its SQLDataStore.
+func (w SQLWebhookStore) Close() {
+}
+
+func (w SQLWebhookStore) AddWebhook(ctx context.Context, hook model.Webhook) (string, error) {
+	id := primitive.NewObjectID().Hex()
+	_, err := w.db.ExecContext(ctx,
+		"INSERT INTO webhooks (id, owner, url, secret, events, created) VALUES (?, ?, ?, ?, ?, ?)",
+		id, hook.Owner, hook.URL, hook.Secret, strings.Join(hook.Events, ","), formatSQLTime(hook.Created))
+	if nil != err {
+		return "", err
+	}
+	return id, nil
+}
+
+func (w SQLWebhookStore) GetWebhook(ctx context.Context, id string) (*model.Webhook, error) {
+	hooks, err := w.queryWebhooks(ctx, "id = ?", id)
+	if nil != err || len(hooks) == 0 {
+		return nil, err
+	}
+	return hooks[0], nil
+}
+
+func (w SQLWebhookStore) Webhooks(ctx context.Context, ownerId int) ([]*model.Webhook, error) {
+	return w.queryWebhooks(ctx, "owner = ?", ownerId)
+}
+
+func (w SQLWebhookStore) DeleteWebhook(ctx context.Context, ownerId int, id string) (bool, error) {
+	res, err := w.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = ? AND owner = ?", id, ownerId)
+	if nil != err {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (w SQLWebhookStore) AddDelivery(ctx context.Context, delivery model.Delivery) (string, error) {
+	delivery.ID = primitive.NewObjectID().Hex()
+	_, err := w.db.ExecContext(ctx,
+		"INSERT INTO webhook_deliveries ("+sqlDeliveryColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		deliveryValues(&delivery)...)
+	if nil != err {
+		return "", err
+	}
+	return delivery.ID, nil
+}
+
+func (w SQLWebhookStore) UpdateDelivery(ctx context.Context, delivery model.Delivery) error {
+	values := deliveryValues(&delivery)
+	_, err := w.db.ExecContext(ctx,
+		"UPDATE webhook_deliveries SET webhook_id = ?, owner = ?, event = ?, payload = ?, status = ?, attempts = ?, "+
+			"next_attempt = ?, last_error = ?, response_status = ?, created = ?, delivered = ? WHERE id = ?",
+		append(values[1:], values[0])...)
+	return err
+}
+
+func (w SQLWebhookStore) DueDeliveries(ctx context.Context, due time.Time, limit int) ([]*model.Delivery, error) {
+	return w.queryDeliveries(ctx, "status = ? AND next_attempt <= ? ORDER BY next_attempt, id LIMIT ?",
+		model.DeliveryPending, formatSQLTime(due), limit)
+}
+
+func (w SQLWebhookStore) Deliveries(ctx context.Context, ownerId int, webhookId string, limit int) ([]*model.Delivery, error) {
+	if webhookId == "" {
+		return w.queryDeliveries(ctx, "owner = ? ORDER BY created DESC, id DESC LIMIT ?", ownerId, limit)
+	}
+	return w.queryDeliveries(ctx, "owner = ? AND webhook_id = ? ORDER BY created DESC, id DESC LIMIT ?",
+		ownerId, webhookId, limit)
+}
+
+// queryWebhooks reads the webhooks matching the where clause, oldest first.
+func (w SQLWebhookStore) queryWebhooks(ctx context.Context, where string, args ...interface{}) ([]*model.Webhook, error) {
+	rows, err := w.db.QueryContext(ctx,
+		"SELECT id, owner, url, secret, events, created FROM webhooks WHERE "+where+" ORDER BY created, id", args...)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []*model.Webhook
+	for rows.Next() {
+		var hook model.Webhook
+		var events, created string
+		if err := rows.Scan(&hook.ID, &hook.Owner, &hook.URL, &hook.Secret, &events, &created); nil != err {
+			return nil, err
+		}
+		if events != "" {
+			hook.Events = strings.Split(events, ",")
+		}
+		if hook.Created, err = parseSQLTime(created); nil != err {
+			return nil, err
+		}
+		hooks = append(hooks, &hook)
+	}
+	return hooks, rows.Err()
+}
+
+// queryDeliveries reads the deliveries matching the where clause, which also gives their order and limit.
+func (w SQLWebhookStore) queryDeliveries(ctx context.Context, where string, args ...interface{}) ([]*model.Delivery, error) {
+	rows, err := w.db.QueryContext(ctx, "SELECT "+sqlDeliveryColumns+" FROM webhook_deliveries WHERE "+where, args...)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*model.Delivery
+	for rows.Next() {
+		var d model.Delivery
+		var payload, next, created string
+		var delivered sql.NullString
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Owner, &d.Event, &payload, &d.Status, &d.Attempts, &next,
+			&d.LastError, &d.ResponseStatus, &created, &delivered); nil != err {
+			return nil, err
+		}
+		d.Payload = []byte(payload)
+		if d.NextAttempt, err = parseSQLTime(next); nil != err {
+			return nil, err
+		}
+		if d.Created, err = parseSQLTime(created); nil != err {
+			return nil, err
+		}
+		if delivered.Valid {
+			t, err := parseSQLTime(delivered.String)
+			if nil != err {
+				return nil, err
+			}
+			d.Delivered = &t
+		}
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, rows.Err()
+}
+
+// deliveryValues gives the values of the sqlDeliveryColumns of the delivery.
+func deliveryValues(d *model.Delivery) []interface{} {
+	var delivered interface{}
+	if nil != d.Delivered {
+		delivered = formatSQLTime(*d.Delivered)
+	}
+	return []interface{}{d.ID, d.WebhookID, d.Owner, d.Event, string(d.Payload), d.Status, d.Attempts,
+		formatSQLTime(d.NextAttempt), d.LastError, d.ResponseStatus, formatSQLTime(d.Created), delivered}
+}
@@ -0,0 +1,51 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"gatso/model"
+	"strings"
+	"time"
+)
+
+// ErrStatusChange is returned when a task is moved to a status it can't move to from its current status.
+var ErrStatusChange = errors.New("task can't move to that status")
+
+// checkStatus checks the status of a task being saved is one of the model statuses, defaulting it to open.
+func checkStatus(task *model.Task) error {
+	task.Status = model.StatusOf(task)
+	if !model.ValidStatus(task.Status) {
+		return fmt.Errorf("Unknown status %q, expected one of %s", task.Status, strings.Join(model.Statuses, ", "))
+	}
+	return nil
+}
+
+// completedAt gets when a task moving to the given status was done, keeping the time of a task already done.
+func completedAt(status string, existing *model.Task) *time.Time {
+	if status != model.StatusDone {
+		return nil
+	}
+	if nil != existing && nil != existing.CompletedAt {
+		return existing.CompletedAt
+	}
+	now := time.Now()
+	return &now
+}
+
+// updateRefused explains why the owners change of a task, moving it to the given status, was refused,
+// from the task as it is stored now.
+func updateRefused(existing *model.Task, ownerId int, status string) error {
+	if err := checkAccess(existing, ownerId, model.RoleEditor); nil != err {
+		return err
+	}
+	if nil != existing.Deleted {
+		return fmt.Errorf("Task %s is in the trash, restore it to update it", existing.Id())
+	}
+	if nil != existing.Archived {
+		return fmt.Errorf("Task %s is archived, unarchive it to update it", existing.Id())
+	}
+	if from := model.StatusOf(existing); !model.CanChangeStatus(from, status) {
+		return fmt.Errorf("%w, task %s is %s so can't be %s", ErrStatusChange, existing.Id(), from, status)
+	}
+	return ErrVersionConflict
+}
@@ -0,0 +1,145 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gatso/model"
+)
+
+const maxSubtaskDepth = 10 // levels of subtasks below a top level task
+
+// ErrInvalidParent is returned when a task is given a parent it can't be a subtask of.
+var ErrInvalidParent = errors.New("invalid parent task")
+
+// checkParent checks the parent of a task being saved is a task of the same owner, outside the trash and archive,
+// and isn't one of its own subtasks. get reads a stored task by its id.
+func checkParent(task *model.Task, get func(taskId string) *model.Task) error {
+	if task.Parent == "" {
+		return nil
+	}
+	parent := get(task.Parent)
+	if nil == parent || nil != parent.Deleted || nil != parent.Archived {
+		return fmt.Errorf("%w, task %s not found", ErrInvalidParent, task.Parent)
+	}
+	if parent.Owner != task.Owner {
+		return fmt.Errorf("%w, task %s is owned by %d, so its subtasks must be", ErrInvalidParent, task.Parent, parent.Owner)
+	}
+	for depth := 1; nil != parent; depth++ {
+		if nil != task.ID && parent.Id() == task.Id() {
+			return fmt.Errorf("%w, task %s can't be a subtask of itself", ErrInvalidParent, task.Id())
+		}
+		if depth > maxSubtaskDepth {
+			return fmt.Errorf("%w, subtasks can be at most %d deep", ErrInvalidParent, maxSubtaskDepth)
+		}
+		if parent.Parent == "" {
+			return nil
+		}
+		parent = get(parent.Parent)
+	}
+	return nil
+}
+
+// GetTaskTree reads the task with the given id along with all its subtasks, outside the trash and archive,
+// with the progress of each task set. Returns nil if the task isn't found.
+func GetTaskTree(ctx context.Context, ds Datastore, taskId string) (*model.TaskTree, error) {
+	task := ds.GetTask(ctx, taskId)
+	if nil == task {
+		return nil, nil
+	}
+	tree := &model.TaskTree{Task: task}
+	if err := addSubtasks(ctx, ds, tree, 0); nil != err {
+		return nil, err
+	}
+	tree.SetProgress()
+	return tree, nil
+}
+
+// addSubtasks reads the subtasks of each task in the tree, of any status.
+func addSubtasks(ctx context.Context, ds Datastore, tree *model.TaskTree, depth int) error {
+	if depth > maxSubtaskDepth {
+		return nil
+	}
+	subtasks, err := allTasks(func(opts ListOptions) (model.TaskPage, error) {
+		return ds.GetSubtasks(ctx, tree.Task.Id(), opts)
+	})
+	if nil != err {
+		return err
+	}
+	for _, task := range subtasks {
+		sub := &model.TaskTree{Task: task}
+		if err := addSubtasks(ctx, ds, sub, depth+1); nil != err {
+			return err
+		}
+		tree.Subtasks = append(tree.Subtasks, sub)
+	}
+	return nil
+}
+
+// allTasks reads every page of the given listing, of tasks of any status.
+func allTasks(list func(opts ListOptions) (model.TaskPage, error)) ([]*model.Task, error) {
+	var tasks []*model.Task
+	opts := ListOptions{Status: model.Statuses}
+	for {
+		page, err := list(opts)
+		if nil != err {
+			return nil, err
+		}
+		tasks = append(tasks, page.Tasks...)
+		if page.Next == "" {
+			return tasks, nil
+		}
+		opts.Cursor = page.Next
+	}
+}
+
+// DeleteTaskTree moves the task to the trash, as DeleteTask, along with what becomes of its subtasks.
+// Its subtasks, at every depth, are moved to the trash with it, unless orphaned, when its direct subtasks are
+// left as top level tasks. Each task deleted no longer blocks the tasks which depended on it, even if restored.
+// Once the user may delete the task, its subtasks and the tasks it blocked are changed on behalf of its owner.
+func DeleteTaskTree(ctx context.Context, ds Datastore, ownerId int, taskId string, version int, orphan bool) (bool, error) {
+	task := ds.GetTask(ctx, taskId)
+	if nil == task {
+		return false, nil
+	}
+	subtasks, err := allTasks(func(opts ListOptions) (model.TaskPage, error) {
+		return ds.GetSubtasks(ctx, taskId, opts)
+	})
+	if nil != err {
+		return false, err
+	}
+	deleted, err := ds.DeleteTask(ctx, ownerId, taskId, version)
+	if nil != err || !deleted {
+		return deleted, err
+	}
+	ownerId = task.Owner
+	if err := removeDependencies(ctx, ds, ownerId, taskId); nil != err {
+		return true, err
+	}
+	for _, sub := range subtasks {
+		if orphan {
+			err = orphanTask(ctx, ds, ownerId, taskId, sub)
+		} else {
+			_, err = DeleteTaskTree(ctx, ds, ownerId, sub.Id(), 0, false)
+		}
+		if nil != err {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// orphanTask makes the subtask of the given parent a top level task, retrying should it change while doing so.
+func orphanTask(ctx context.Context, ds Datastore, ownerId int, parentId string, task *model.Task) error {
+	for {
+		task.Parent = ""
+		_, err := ds.UpdateTask(ctx, ownerId, *task)
+		if err != ErrVersionConflict {
+			return err
+		}
+		task = ds.GetTask(ctx, task.Id())
+		if nil == task || nil != task.Deleted || task.Parent != parentId {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package data_test
+
+import (
+	"gatso/data"
+	"gatso/model"
+	"testing"
+)
+
+func TestTaskTree(t *testing.T) {
+	ms := data.NewMemoryDataStore()
+	defer ms.Close()
+
+	add := func(parent string, status string) string {
+		id, err := ms.AddTask(ctx, testOwnerId, model.Task{Owner: testOwnerId, Title: "Test Task", Parent: parent,
+			Status: status})
+		if nil != err {
+			t.Fatal(err)
+		}
+		return id
+	}
+	rootId := add("", model.StatusOpen)
+	firstId := add(rootId, model.StatusOpen)
+	add(firstId, model.StatusDone)
+	add(firstId, model.StatusOpen)
+	add(rootId, model.StatusDone)
+	add(rootId, model.StatusCancelled)
+	leafId := add(rootId, model.StatusOpen)
+
+	// the root has 2 of its 5 subtasks done, not counting the one cancelled
+	tree, err := data.GetTaskTree(ctx, ms, rootId)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if nil == tree || len(tree.Subtasks) != 4 || nil == tree.Task.Progress || *tree.Task.Progress != 40 {
+		t.Errorf("Expected task %s with 4 subtasks, 40%% done, found %+v", rootId, tree)
+		return
+	}
+	for _, sub := range tree.Subtasks {
+		switch sub.Task.Id() {
+		case firstId:
+			if len(sub.Subtasks) != 2 || nil == sub.Task.Progress || *sub.Task.Progress != 50 {
+				t.Errorf("Expected subtask %s with 2 subtasks, 50%% done, found %+v", firstId, sub)
+			}
+		default:
+			if len(sub.Subtasks) != 0 || nil != sub.Task.Progress {
+				t.Errorf("Expected subtask %s without subtasks or progress, found %+v", sub.Task.Id(), sub)
+			}
+		}
+	}
+
+	// orphaned subtasks are left as top level tasks, the rest are deleted with their parent
+	if deleted, err := data.DeleteTaskTree(ctx, ms, testOwnerId, firstId, 0, true); !deleted || nil != err {
+		t.Errorf("Expected subtask %s to be deleted, found %v", firstId, err)
+		return
+	}
+	page, err := ms.GetTasks(ctx, testOwnerId, data.ListOptions{Status: model.Statuses})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	orphans := 0
+	for _, task := range page.Tasks {
+		if task.Parent == firstId {
+			t.Errorf("Expected subtask %s to be orphaned", task.Id())
+		} else if task.Parent == "" && task.Id() != rootId {
+			orphans++
+		}
+	}
+	if orphans != 2 {
+		t.Errorf("Expected the 2 subtasks of %s to be orphaned, found %d", firstId, orphans)
+	}
+
+	if deleted, err := data.DeleteTaskTree(ctx, ms, testOwnerId, rootId, 0, false); !deleted || nil != err {
+		t.Errorf("Expected task %s to be deleted, found %v", rootId, err)
+		return
+	}
+	if task := ms.GetTask(ctx, leafId); nil == task || nil == task.Deleted {
+		t.Errorf("Expected subtask %s to be deleted with its parent", leafId)
+		return
+	}
+	if page, err = ms.GetTasks(ctx, testOwnerId, data.ListOptions{Status: model.Statuses}); nil != err || len(page.Tasks) != 2 {
+		t.Errorf("Expected only the 2 orphans left, found %d tasks, %v", len(page.Tasks), err)
+	}
+}
@@ -0,0 +1,152 @@
+package data
+
+import (
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+)
+
+// taskIndex holds tasks in memory, indexed by their id, their owner and the users and groups they are shared with.
+// It is not safe for concurrent use, callers must provide their own locking.
+type taskIndex struct {
+	tasks     map[primitive.ObjectID]*indexedTask
+	byOwner   map[int]map[primitive.ObjectID]*indexedTask
+	byGrantee map[int]map[primitive.ObjectID]*indexedTask
+	byGroup   map[string]map[primitive.ObjectID]*indexedTask
+	seq       uint64
+}
+
+// indexedTask is a task with the sequence number of when it was first added to the index
+type indexedTask struct {
+	task *model.Task
+	seq  uint64
+}
+
+func newTaskIndex() *taskIndex {
+	return &taskIndex{
+		tasks:     map[primitive.ObjectID]*indexedTask{},
+		byOwner:   map[int]map[primitive.ObjectID]*indexedTask{},
+		byGrantee: map[int]map[primitive.ObjectID]*indexedTask{},
+		byGroup:   map[string]map[primitive.ObjectID]*indexedTask{},
+	}
+}
+
+// get returns the stored task with the given id, or nil if not known.
+func (ix *taskIndex) get(id primitive.ObjectID) *model.Task {
+	it, ok := ix.tasks[id]
+	if !ok {
+		return nil
+	}
+	return it.task
+}
+
+// put adds the task to the index, replacing any existing task with the same id.
+// A replaced task keeps its original place in the insertion order.
+func (ix *taskIndex) put(task *model.Task) {
+	it, ok := ix.tasks[*task.ID]
+	if ok {
+		ix.unlink(it)
+		it.task = task
+	} else {
+		ix.seq++
+		it = &indexedTask{task: task, seq: ix.seq}
+		ix.tasks[*task.ID] = it
+	}
+
+	addToIndex(ix.byOwner, task.Owner, it)
+	for _, g := range task.ACL {
+		if g.Group != "" {
+			addToIndex(ix.byGroup, g.Group, it)
+		} else {
+			addToIndex(ix.byGrantee, g.User, it)
+		}
+	}
+}
+
+// remove deletes the task with the given id, returning false if it was not known.
+func (ix *taskIndex) remove(id primitive.ObjectID) bool {
+	it, ok := ix.tasks[id]
+	if !ok {
+		return false
+	}
+	ix.unlink(it)
+	delete(ix.tasks, id)
+	return true
+}
+
+// owned returns the tasks belonging to the given owner, in no particular order.
+func (ix *taskIndex) owned(ownerId int) []*indexedTask {
+	return values(ix.byOwner[ownerId])
+}
+
+// shared returns the tasks shared with the given user, or any of the given groups, whether or not their grant has
+// expired, in no particular order.
+func (ix *taskIndex) shared(userId int, groups []string) []*indexedTask {
+	if len(groups) == 0 {
+		return values(ix.byGrantee[userId])
+	}
+	found := map[primitive.ObjectID]*indexedTask{}
+	for id, it := range ix.byGrantee[userId] {
+		found[id] = it
+	}
+	for _, group := range groups {
+		for id, it := range ix.byGroup[group] {
+			found[id] = it
+		}
+	}
+	return values(found)
+}
+
+// all returns every task in the index, in no particular order.
+func (ix *taskIndex) all() []*indexedTask {
+	return values(ix.tasks)
+}
+
+// owners lists the ids of everyone owning a task, lowest first.
+func (ix *taskIndex) owners() []int {
+	var owners []int
+	for o := range ix.byOwner {
+		owners = append(owners, o)
+	}
+	sort.Ints(owners)
+	return owners
+}
+
+func (ix *taskIndex) unlink(it *indexedTask) {
+	removeFromIndex(ix.byOwner, it.task.Owner, *it.task.ID)
+	for _, g := range it.task.ACL {
+		if g.Group != "" {
+			removeFromIndex(ix.byGroup, g.Group, *it.task.ID)
+		} else {
+			removeFromIndex(ix.byGrantee, g.User, *it.task.ID)
+		}
+	}
+}
+
+func addToIndex[K comparable](index map[K]map[primitive.ObjectID]*indexedTask, key K, it *indexedTask) {
+	m, ok := index[key]
+	if !ok {
+		m = map[primitive.ObjectID]*indexedTask{}
+		index[key] = m
+	}
+	m[*it.task.ID] = it
+}
+
+func removeFromIndex[K comparable](index map[K]map[primitive.ObjectID]*indexedTask, key K, id primitive.ObjectID) {
+	m, ok := index[key]
+	if !ok {
+		return
+	}
+	delete(m, id)
+	if len(m) == 0 {
+		delete(index, key)
+	}
+}
+
+func values(m map[primitive.ObjectID]*indexedTask) []*indexedTask {
+	items := make([]*indexedTask, 0, len(m))
+	for _, it := range m {
+		items = append(items, it)
+	}
+	return items
+}
@@ -0,0 +1,139 @@
+package data
+
+import (
+	"context"
+	"gatso/model"
+	"time"
+)
+
+// TimeoutDataStore limits how long each operation on the datastore it wraps may take.
+// An operation is abandoned when either its timeout passes or the callers context is cancelled.
+type TimeoutDataStore struct {
+	Datastore
+	timeout time.Duration
+}
+
+// Create a new TimeoutDataStore, giving each operation on the given datastore up to the given timeout.
+func NewTimeoutDataStore(ds Datastore, timeout time.Duration) *TimeoutDataStore {
+	return &TimeoutDataStore{Datastore: ds, timeout: timeout}
+}
+
+func (t TimeoutDataStore) GetTasks(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.GetTasks(ctx, ownerId, opts)
+}
+
+func (t TimeoutDataStore) GetOthersTasks(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.GetOthersTasks(ctx, ownerId, opts)
+}
+
+func (t TimeoutDataStore) FindTasks(ctx context.Context, ownerId int, query model.Task, opts ListOptions) (model.TaskPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.FindTasks(ctx, ownerId, query, opts)
+}
+
+func (t TimeoutDataStore) CountTasks(ctx context.Context, ownerId int) int {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.CountTasks(ctx, ownerId)
+}
+
+func (t TimeoutDataStore) AddTask(ctx context.Context, ownerId int, task model.Task) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.AddTask(ctx, ownerId, task)
+}
+
+func (t TimeoutDataStore) UpdateTask(ctx context.Context, ownerId int, task model.Task) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.UpdateTask(ctx, ownerId, task)
+}
+
+func (t TimeoutDataStore) DeleteTask(ctx context.Context, ownerId int, taskId string, version int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.DeleteTask(ctx, ownerId, taskId, version)
+}
+
+func (t TimeoutDataStore) SetTaskStatus(ctx context.Context, ownerId int, taskId string, status string, version int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.SetTaskStatus(ctx, ownerId, taskId, status, version)
+}
+
+func (t TimeoutDataStore) GetTask(ctx context.Context, taskId string) *model.Task {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.GetTask(ctx, taskId)
+}
+
+func (t TimeoutDataStore) Users(ctx context.Context) ([]int, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.Users(ctx)
+}
+
+func (t TimeoutDataStore) GetSubtasks(ctx context.Context, taskId string, opts ListOptions) (model.TaskPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.GetSubtasks(ctx, taskId, opts)
+}
+
+func (t TimeoutDataStore) GetDependents(ctx context.Context, taskId string, opts ListOptions) (model.TaskPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.GetDependents(ctx, taskId, opts)
+}
+
+func (t TimeoutDataStore) GetTrash(ctx context.Context, ownerId int, opts ListOptions) (model.TaskPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.GetTrash(ctx, ownerId, opts)
+}
+
+func (t TimeoutDataStore) RestoreTask(ctx context.Context, ownerId int, taskId string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.RestoreTask(ctx, ownerId, taskId)
+}
+
+func (t TimeoutDataStore) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.PurgeTrash(ctx, before)
+}
+
+func (t TimeoutDataStore) GetArchive(ctx context.Context, ownerId int, query model.Task, opts ListOptions) (model.TaskPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.GetArchive(ctx, ownerId, query, opts)
+}
+
+func (t TimeoutDataStore) UnarchiveTask(ctx context.Context, ownerId int, taskId string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.UnarchiveTask(ctx, ownerId, taskId)
+}
+
+func (t TimeoutDataStore) ArchiveTasks(ctx context.Context, expiredBefore time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.ArchiveTasks(ctx, expiredBefore)
+}
+
+func (t TimeoutDataStore) DueRecurrences(ctx context.Context, before time.Time, limit int) ([]*model.Task, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.DueRecurrences(ctx, before, limit)
+}
+
+func (t TimeoutDataStore) MarkRecurred(ctx context.Context, taskId string, expires time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Datastore.MarkRecurred(ctx, taskId, expires)
+}
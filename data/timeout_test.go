@@ -0,0 +1,46 @@
+package data_test
+
+import (
+	"context"
+	"gatso/data"
+	"gatso/data/datastoretest"
+	"gatso/model"
+	"testing"
+	"time"
+)
+
+// slowDataStore never completes a GetTasks, until its context is done.
+type slowDataStore struct {
+	data.Datastore
+}
+
+func (s slowDataStore) GetTasks(ctx context.Context, ownerId int, opts data.ListOptions) (model.TaskPage, error) {
+	<-ctx.Done()
+	return model.TaskPage{}, ctx.Err()
+}
+
+func TestTimeoutDataStore_Conformance(t *testing.T) {
+	datastoretest.RunConformance(t, func() data.Datastore {
+		return data.NewTimeoutDataStore(data.NewMemoryDataStore(), time.Minute)
+	})
+}
+
+func TestTimeoutDataStore_Timeout(t *testing.T) {
+	ts := data.NewTimeoutDataStore(slowDataStore{data.NewMemoryDataStore()}, time.Millisecond*10)
+	defer ts.Close()
+
+	done := make(chan error)
+	go func() {
+		_, err := ts.GetTasks(ctx, testOwnerId, data.ListOptions{})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Errorf("Expected deadline exceeded error, found %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Errorf("Expected GetTasks to be abandoned after its timeout")
+	}
+}
@@ -0,0 +1,72 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"time"
+)
+
+// MaxUrgencyTasks is the most tasks a listing sorted by urgency can hold. Urgency changes with time, so it can't be
+// indexed, every task in the listing is read and scored for each page. Larger listings are refused, to be narrowed
+// by list or status.
+const MaxUrgencyTasks = 5000
+
+// ErrTooManyToSort is returned when a listing sorted by urgency holds more than MaxUrgencyTasks tasks.
+var ErrTooManyToSort = errors.New("too many tasks to sort by urgency")
+
+// urgencyPage builds the page of the listing sorted by urgency, from every task in the listing.
+// Urgency changes with time, so every task is scored as of the time the first page was listed, held in the cursor,
+// keeping the order the same across pages. The tasks are read up to one more than MaxUrgencyTasks, to tell if
+// the listing holds too many.
+func urgencyPage(tasks []*model.Task, opts ListOptions) (model.TaskPage, error) {
+	if len(tasks) > MaxUrgencyTasks {
+		return model.TaskPage{}, fmt.Errorf("%w, a listing holds at most %d, narrow it by list or status",
+			ErrTooManyToSort, MaxUrgencyTasks)
+	}
+	after, err := opts.readCursor()
+	if nil != err {
+		return model.TaskPage{}, err
+	}
+	at := time.Now()
+	if nil != after {
+		at = after.At
+	}
+	for _, t := range tasks {
+		t.Urgency = opts.Urgency.Urgency(t, at)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return moreUrgent(tasks[i], tasks[j].Urgency, *tasks[j].ID)
+	})
+	if nil != after {
+		following := sort.Search(len(tasks), func(i int) bool {
+			return !moreUrgent(tasks[i], after.Urgency, after.ID)
+		})
+		tasks = tasks[following:]
+		if len(tasks) > 0 && *tasks[0].ID == after.ID {
+			tasks = tasks[1:]
+		}
+	}
+
+	limit := opts.limit()
+	if len(tasks) <= limit {
+		return model.TaskPage{Tasks: tasks}, nil
+	}
+	tasks = tasks[:limit]
+	last := tasks[limit-1]
+	return model.TaskPage{
+		Tasks: tasks,
+		Next:  encodeCursor(pageCursor{Sort: opts.Sort.String(), At: at, Urgency: last.Urgency, ID: *last.ID}),
+	}, nil
+}
+
+// moreUrgent checks the task is listed before the task of the given urgency and id, ties listed in id order.
+func moreUrgent(t *model.Task, urgency float64, id primitive.ObjectID) bool {
+	if t.Urgency != urgency {
+		return t.Urgency > urgency
+	}
+	return bytes.Compare(t.ID[:], id[:]) < 0
+}
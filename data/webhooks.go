@@ -0,0 +1,136 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"gatso/model"
+	"log"
+	"time"
+)
+
+// WebhookStore holds the webhooks of every owner and the queue of deliveries to them.
+// Deliveries are kept once made, as a log of what was sent.
+type WebhookStore interface {
+	// Add a new webhook, returning its id
+	AddWebhook(ctx context.Context, hook model.Webhook) (string, error)
+
+	// Retrieve a single webhook by its id, nil if it isn't known
+	GetWebhook(ctx context.Context, id string) (*model.Webhook, error)
+
+	// Retrieve every webhook of the given owner, oldest first
+	Webhooks(ctx context.Context, ownerId int) ([]*model.Webhook, error)
+
+	// Remove the webhook, if owned by the given owner. Its deliveries are kept.
+	DeleteWebhook(ctx context.Context, ownerId int, id string) (bool, error)
+
+	// Queue a new delivery, returning its id
+	AddDelivery(ctx context.Context, delivery model.Delivery) (string, error)
+
+	// Replace the delivery having the same id, recording an attempt to make it
+	UpdateDelivery(ctx context.Context, delivery model.Delivery) error
+
+	// Retrieve up to limit pending deliveries due to be attempted by the given time, the longest due first
+	DueDeliveries(ctx context.Context, due time.Time, limit int) ([]*model.Delivery, error)
+
+	// Retrieve up to limit of the owners deliveries, newest first. Only those to the given webhook, if not empty
+	Deliveries(ctx context.Context, ownerId int, webhookId string, limit int) ([]*model.Delivery, error)
+
+	// Close the store and release any resources.
+	Close()
+}
+
+// webhookPayload is the json body delivered to a webhook.
+type webhookPayload struct {
+	Event     string     `json:"event"`
+	WebhookID string     `json:"webhookId"`
+	Changed   time.Time  `json:"changed"`
+	Task      model.Task `json:"task"`
+}
+
+// WebhookDataStore queues a delivery to each subscribed webhook for every change made through the datastore
+// it wraps. Webhooks of the task owner and of each user it is shared with, directly or through a group, or through
+// one of the tasks it is a subtask of, are delivered to.
+type WebhookDataStore struct {
+	changeNotifier
+	webhooks WebhookStore
+	groups   GroupStore
+}
+
+// Create a new WebhookDataStore queueing deliveries of the changes made to the given datastore in the given store.
+// The members of the groups tasks are shared with are read from the given group store.
+func NewWebhookDataStore(ds Datastore, webhooks WebhookStore, groups GroupStore) *WebhookDataStore {
+	w := &WebhookDataStore{changeNotifier: changeNotifier{Datastore: ds}, webhooks: webhooks, groups: groups}
+	w.notify = w.enqueue // bound once the datastore is set, to read the parents of subtasks from
+	return w
+}
+
+// Close the datastore and the webhooks.
+func (w WebhookDataStore) Close() {
+	w.Datastore.Close()
+	w.webhooks.Close()
+}
+
+// enqueue a delivery of the change to each webhook subscribed to it.
+// The change has already been made, so a failure to queue a delivery is logged rather than returned.
+func (w WebhookDataStore) enqueue(ctx context.Context, ownerId int, change string, task model.Task) {
+	now := time.Now()
+	seen := map[int]bool{}
+	for _, owner := range w.recipients(ctx, &task, now) {
+		if seen[owner] {
+			continue
+		}
+		seen[owner] = true
+		hooks, err := w.webhooks.Webhooks(ctx, owner)
+		if nil != err {
+			log.Printf("Failed to read the webhooks of owner %d: %v", owner, err)
+			continue
+		}
+		for _, hook := range hooks {
+			if !hook.Subscribed(change) {
+				continue
+			}
+			payload, err := json.Marshal(&webhookPayload{Event: change, WebhookID: hook.ID, Changed: now, Task: task})
+			if nil != err {
+				log.Printf("Failed to queue %s event of task %s for webhook %s: %v", change, task.Id(), hook.ID, err)
+				continue
+			}
+			_, err = w.webhooks.AddDelivery(ctx, model.Delivery{
+				WebhookID:   hook.ID,
+				Owner:       hook.Owner,
+				Event:       change,
+				Payload:     payload,
+				Status:      model.DeliveryPending,
+				NextAttempt: now,
+				Created:     now,
+			})
+			if nil != err {
+				log.Printf("Failed to queue %s event of task %s for webhook %s: %v", change, task.Id(), hook.ID, err)
+			}
+		}
+	}
+}
+
+// recipients lists the owner of the task and each user it is shared with at the given time, including the members of
+// the groups it is shared with, as they are now. Those of each task it is a subtask of are included, as subtasks are
+// readable by everyone their parents are shared with.
+func (w WebhookDataStore) recipients(ctx context.Context, task *model.Task, at time.Time) []int {
+	var users []int
+	upTree(ctx, w.Datastore, task, func(t *model.Task) bool {
+		users = append(append(users, t.Owner), model.Grantees(t, at)...)
+		for _, g := range t.ACL {
+			if g.Group == "" || !g.Active(at) {
+				continue
+			}
+			group, err := w.groups.GetGroup(ctx, g.Group)
+			if nil != err {
+				log.Printf("Failed to read the members of group %s: %v", g.Group, err)
+				continue
+			}
+			if nil != group {
+				users = append(users, group.Members...)
+			}
+		}
+		return true
+	})
+	return users
+}
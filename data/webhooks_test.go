@@ -0,0 +1,333 @@
+package data_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gatso/data"
+	"gatso/data/datastoretest"
+	"gatso/model"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookDataStore_Conformance(t *testing.T) {
+	datastoretest.RunConformance(t, func() data.Datastore {
+		return data.NewWebhookDataStore(data.NewMemoryDataStore(), data.NewMemoryWebhookStore(),
+			data.NewMemoryGroupStore())
+	})
+}
+
+func TestMemoryWebhookStore_Conformance(t *testing.T) {
+	datastoretest.RunWebhookConformance(t, func() data.WebhookStore {
+		return data.NewMemoryWebhookStore()
+	})
+}
+
+func TestFileWebhookStore_Conformance(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	var count int
+	datastoretest.RunWebhookConformance(t, func() data.WebhookStore {
+		count++
+		fw, err := data.NewFileWebhookStore(fmt.Sprintf("%s.%d", path, count))
+		if nil != err {
+			t.Fatal(err)
+		}
+		return fw
+	})
+}
+
+func TestFileWebhookStore_Reopen(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	fw, err := data.NewFileWebhookStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	kept, err := fw.AddWebhook(ctx, model.Webhook{Owner: 123, URL: "http://localhost/kept", Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	removed, err := fw.AddWebhook(ctx, model.Webhook{Owner: 123, URL: "http://localhost/removed", Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := fw.DeleteWebhook(ctx, 123, removed); nil != err {
+		t.Error(err)
+		return
+	}
+	id, err := fw.AddDelivery(ctx, model.Delivery{WebhookID: kept, Owner: 123, Payload: []byte(`{}`),
+		Status: model.DeliveryPending, NextAttempt: time.Now(), Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if err := fw.UpdateDelivery(ctx, model.Delivery{ID: id, WebhookID: kept, Owner: 123, Payload: []byte(`{}`),
+		Status: model.DeliveryFailed, Attempts: 8, NextAttempt: time.Now(), Created: time.Now()}); nil != err {
+		t.Error(err)
+		return
+	}
+	fw.Close()
+
+	fw, err = data.NewFileWebhookStore(path)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	defer fw.Close()
+	hooks, err := fw.Webhooks(ctx, 123)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(hooks) != 1 || hooks[0].ID != kept {
+		t.Errorf("Expected only webhook %s to be reloaded, found %d webhooks", kept, len(hooks))
+		return
+	}
+	deliveries, err := fw.Deliveries(ctx, 123, "", 10)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != model.DeliveryFailed || deliveries[0].Attempts != 8 {
+		t.Errorf("Expected the updated delivery to be reloaded, found %d deliveries", len(deliveries))
+		return
+	}
+}
+
+// receiver is a webhook endpoint, failing the first failures requests made to it.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	by, _ := ioutil.ReadAll(r.Body)
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, by)
+	if len(rc.requests) <= rc.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestWebhookDispatcher_Dispatch(t *testing.T) {
+	rc := &receiver{failures: 1}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	ctx := context.Background()
+	webhooks := data.NewMemoryWebhookStore()
+	ds := data.NewWebhookDataStore(data.NewMemoryDataStore(), webhooks, data.NewMemoryGroupStore())
+	defer ds.Close()
+	hookId, err := webhooks.AddWebhook(ctx, model.Webhook{Owner: 456, URL: srv.URL, Secret: "shh",
+		Events: []string{model.EventCreated}, Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if _, err := webhooks.AddWebhook(ctx, model.Webhook{Owner: 456, URL: srv.URL, Secret: "shh",
+		Events: []string{model.EventDeleted}, Created: time.Now()}); nil != err {
+		t.Error(err)
+		return
+	}
+
+	// delivered to the webhooks of the users the task is shared with, as well as the owner
+	taskId, err := ds.AddTask(ctx, 123, model.Task{Owner: 123, Title: "Test Task", ACL: model.ReaderGrants([]int{456})})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+
+	dispatcher := data.StartWebhookDispatcher(webhooks, srv.Client(), time.Hour, 10*time.Millisecond)
+	defer dispatcher.Stop()
+	if delivered, err := dispatcher.Dispatch(ctx); nil != err || delivered != 0 {
+		t.Errorf("Expected the first attempt to fail, found %d delivered, %v", delivered, err)
+		return
+	}
+	deliveries, err := webhooks.Deliveries(ctx, 456, hookId, 10)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != model.DeliveryPending || deliveries[0].Attempts != 1 ||
+		deliveries[0].ResponseStatus != http.StatusServiceUnavailable || deliveries[0].LastError == "" {
+		t.Errorf("Expected one delivery pending a retry, found %d", len(deliveries))
+		return
+	}
+	if delivered, err := dispatcher.Dispatch(ctx); nil != err || delivered != 0 {
+		t.Errorf("Expected the retry to wait for its backoff, found %d delivered, %v", delivered, err)
+		return
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if delivered, err := dispatcher.Dispatch(ctx); nil != err || delivered != 1 {
+		t.Errorf("Expected the retry to be delivered, found %d delivered, %v", delivered, err)
+		return
+	}
+	deliveries, err = webhooks.Deliveries(ctx, 456, hookId, 10)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if deliveries[0].Status != model.DeliveryDelivered || deliveries[0].Attempts != 2 || nil == deliveries[0].Delivered {
+		t.Errorf("Expected the delivery to be delivered on attempt 2, found %+v", deliveries[0])
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.requests) != 2 {
+		t.Errorf("Expected 2 requests to the webhook, found %d", len(rc.requests))
+		return
+	}
+	req, body := rc.requests[1], rc.bodies[1]
+	if req.Header.Get(data.HeaderWebhookSignature) != data.SignPayload("shh", body) ||
+		req.Header.Get(data.HeaderWebhookEvent) != model.EventCreated ||
+		req.Header.Get(data.HeaderWebhookDelivery) != deliveries[0].ID {
+		t.Errorf("Expected a signed delivery of event %s, found headers %v", model.EventCreated, req.Header)
+		return
+	}
+	var payload struct {
+		Event string     `json:"event"`
+		Task  model.Task `json:"task"`
+	}
+	if err := json.Unmarshal(body, &payload); nil != err {
+		t.Error(err)
+		return
+	}
+	if payload.Event != model.EventCreated || payload.Task.Id() != taskId {
+		t.Errorf("Expected the created task %s to be delivered, found %s of %s", taskId, payload.Event, payload.Task.Id())
+		return
+	}
+}
+
+func TestWebhookDataStore_Groups(t *testing.T) {
+	ctx := context.Background()
+	webhooks := data.NewMemoryWebhookStore()
+	groups := data.NewMemoryGroupStore()
+	ds := data.NewWebhookDataStore(data.NewMemoryDataStore(), webhooks, groups)
+	defer ds.Close()
+
+	groupId, err := groups.AddGroup(ctx, model.Group{Owner: 123, Name: "team", Members: []int{456, 789}})
+	if nil != err {
+		t.Fatal(err)
+	}
+	var hookIds []string
+	for _, owner := range []int{456, 789} {
+		id, err := webhooks.AddWebhook(ctx, model.Webhook{Owner: owner, URL: "http://localhost/hook",
+			Events: []string{model.EventCreated}, Created: time.Now()})
+		if nil != err {
+			t.Fatal(err)
+		}
+		hookIds = append(hookIds, id)
+	}
+
+	// delivered to the webhooks of the members of the groups the task is shared with, once each
+	_, err = ds.AddTask(ctx, 123, model.Task{Owner: 123, Title: "Test Task", ACL: []model.Grant{
+		{Group: groupId, Role: model.RoleReader},
+		{User: 456, Role: model.RoleReader},
+	}})
+	if nil != err {
+		t.Fatal(err)
+	}
+	for i, owner := range []int{456, 789} {
+		deliveries, err := webhooks.Deliveries(ctx, owner, hookIds[i], 10)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 1 || deliveries[0].Event != model.EventCreated {
+			t.Errorf("Expected one delivery to the webhook of member %d, found %d", owner, len(deliveries))
+		}
+	}
+
+	// and not to those of users no longer sharing it through a group
+	if _, err := groups.RemoveMember(ctx, 123, groupId, 789); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := ds.AddTask(ctx, 123, model.Task{Owner: 123, Title: "Another", ACL: []model.Grant{
+		{Group: groupId, Role: model.RoleReader}}}); nil != err {
+		t.Fatal(err)
+	}
+	if deliveries, err := webhooks.Deliveries(ctx, 789, hookIds[1], 10); nil != err || len(deliveries) != 1 {
+		t.Errorf("Expected no delivery to the webhook of a former member, found %d, %v", len(deliveries)-1, err)
+	}
+}
+
+func TestWebhookDataStore_Subtasks(t *testing.T) {
+	ctx := context.Background()
+	webhooks := data.NewMemoryWebhookStore()
+	ds := data.NewWebhookDataStore(data.NewMemoryDataStore(), webhooks, data.NewMemoryGroupStore())
+	defer ds.Close()
+
+	hookId, err := webhooks.AddWebhook(ctx, model.Webhook{Owner: 456, URL: "http://localhost/hook",
+		Events: []string{model.EventCreated}, Created: time.Now()})
+	if nil != err {
+		t.Fatal(err)
+	}
+	parentId, err := ds.AddTask(ctx, 123, model.Task{Owner: 123, Title: "Test Task",
+		ACL: model.ReaderGrants([]int{456})})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// delivered to the users the parent is shared with, as they can read its subtasks
+	subtaskId, err := ds.AddTask(ctx, 123, model.Task{Owner: 123, Title: "Subtask", Parent: parentId})
+	if nil != err {
+		t.Fatal(err)
+	}
+	deliveries, err := webhooks.Deliveries(ctx, 456, hookId, 10)
+	if nil != err {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Task model.Task `json:"task"`
+	}
+	if len(deliveries) != 2 || nil != json.Unmarshal(deliveries[0].Payload, &payload) || payload.Task.Id() != subtaskId {
+		t.Errorf("Expected the subtask %s to be delivered to the reader of its parent, found %d deliveries",
+			subtaskId, len(deliveries))
+	}
+}
+
+func TestWebhookDispatcher_DeletedWebhook(t *testing.T) {
+	ctx := context.Background()
+	webhooks := data.NewMemoryWebhookStore()
+	id, err := webhooks.AddDelivery(ctx, model.Delivery{WebhookID: "gone", Owner: 123, Payload: []byte(`{}`),
+		Status: model.DeliveryPending, NextAttempt: time.Now(), Created: time.Now()})
+	if nil != err {
+		t.Error(err)
+		return
+	}
+
+	dispatcher := data.StartWebhookDispatcher(webhooks, http.DefaultClient, time.Hour, time.Millisecond)
+	defer dispatcher.Stop()
+	if _, err := dispatcher.Dispatch(ctx); nil != err {
+		t.Error(err)
+		return
+	}
+	deliveries, err := webhooks.Deliveries(ctx, 123, "", 10)
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if len(deliveries) != 1 || deliveries[0].ID != id || deliveries[0].Status != model.DeliveryFailed {
+		t.Errorf("Expected the delivery to a deleted webhook to fail without retrying, found %+v", deliveries)
+		return
+	}
+}
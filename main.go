@@ -2,49 +2,340 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"gatso/auth"
 	"gatso/controllers"
 	"gatso/data"
+	"gatso/model"
+	"gatso/notify"
+	"gatso/ratelimit"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	_ "modernc.org/sqlite"
 )
 
 const configDBConnection = "database"
 const configPort = "port"
+const configTimeout = "timeout"
+const configTrashRetention = "trashRetention"
+const configArchiveAfter = "archiveAfter"
+const configReminderNotifier = "reminderNotifier"
+const configSMTPServer = "smtpServer"
+const configSMTPFrom = "smtpFrom"
+const configSMTPRecipient = "smtpRecipient"
+const configSMTPUsername = "smtpUsername"
+const configSMTPPassword = "smtpPassword"
+const configReminderWebhook = "reminderWebhook"
+const configReminderWebhookSecret = "reminderWebhookSecret"
+const configUrgencyPriority = "urgencyPriority"
+const configUrgencyDue = "urgencyDue"
+const configUrgencyAge = "urgencyAge"
+const configJWTSecret = "jwtSecret"
+const configJWKS = "jwks"
+const configJWTOwnerClaim = "jwtOwnerClaim"
+const configJWTIssuer = "jwtIssuer"
+const configJWTAudience = "jwtAudience"
+const configInsecureDevMode = "insecureDevMode"
+const configRateLimit = "rateLimit"
+const configRateBurst = "rateBurst"
+const configMaxTasks = "maxTasks"
+const configWebhookAllowedHosts = "webhookAllowedHosts"
 const defaultPort = 8008
+const defaultTimeout = 120        // seconds a database operation may take
+const defaultTrashRetention = 720 // hours a deleted task is kept in the trash
+const trashPurgeInterval = time.Hour
+const defaultArchiveAfter = 90 // days after a task expires it is moved to the archive
+const archiveInterval = time.Hour
+const eventReplaySize = 1000 // latest task events kept for reconnecting clients
+const webhookDispatchInterval = 10 * time.Second
+const webhookBackoff = time.Minute // wait before retrying a failed webhook delivery, doubled for each further retry
+const webhookTimeout = 30 * time.Second
+const reminderInterval = time.Minute
+const shutdownTimeout = 30 * time.Second // time requests in flight have to finish once the service is stopped
+const recurrenceInterval = time.Minute
+const defaultOwnerClaim = "sub"
+const keySetTimeout = 30 * time.Second
+const defaultRateLimit = 10 // requests a second each owner may make, on average
+const defaultRateBurst = 50 // requests each owner may make at once
+const defaultMaxTasks = 0   // most tasks an owner may have, 0 for no limit
 
 func main() {
 	cf, err := Newconfig()
 	if nil != err {
 		panic(err)
 	}
+	// done once the service is told to stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	store, err := data.NewMongoDataStore(cf.ReadString(configDBConnection, ""))
+	st, err := openDatastore(cf.ReadString(configDBConnection, ""))
 	if nil != err {
 		panic(err)
 	}
+	bus := data.NewEventBus(eventReplaySize)
+	var ds data.Datastore = data.NewHistoryDataStore(st.tasks, st.history)
+	ds = data.NewEventDataStore(ds, bus)
+	ds = data.NewWebhookDataStore(ds, st.webhooks, st.groups)
+	ds = data.NewReminderDataStore(ds, st.reminders)
+	if maxTasks := cf.ReadInt(configMaxTasks, defaultMaxTasks); maxTasks > 0 {
+		ds = data.NewQuotaDataStore(ds, maxTasks)
+	}
+	store := data.NewTimeoutDataStore(ds, time.Duration(cf.ReadInt(configTimeout, defaultTimeout))*time.Second)
 
-	listCtrl := controllers.NewTaskController(store)
+	purger := data.StartTrashPurger(store, time.Duration(cf.ReadInt(configTrashRetention, defaultTrashRetention))*time.Hour,
+		trashPurgeInterval)
+	webhookHosts := data.NewWebhookHosts(strings.Split(cf.ReadString(configWebhookAllowedHosts, ""), ","))
+	dispatcher := data.StartWebhookDispatcher(st.webhooks, webhookHosts.Client(webhookTimeout),
+		webhookDispatchInterval, webhookBackoff)
+	notifier, err := newNotifier(cf)
+	if nil != err {
+		panic(err)
+	}
+	scheduler := data.StartReminderScheduler(ctx, store, st.reminders, notifier, reminderInterval)
+	recurrences := data.StartRecurrenceScheduler(store, recurrenceInterval)
+	var archiver *data.TaskArchiver
+	if days := cf.ReadInt(configArchiveAfter, defaultArchiveAfter); days > 0 {
+		archiver = data.StartTaskArchiver(store, time.Duration(days)*24*time.Hour, archiveInterval)
+	}
 
-	http.HandleFunc("/todo", listCtrl.Tasks)
-	http.HandleFunc("/todo/others", listCtrl.OthersTasks)
-	http.HandleFunc("/todo/find", listCtrl.Find)
+	authn, err := newAuthenticator(cf, st.apiKeys)
+	if nil != err {
+		panic(err)
+	}
+
+	listCtrl := controllers.NewTaskController(store, st.lists, st.groups, model.UrgencyWeights{
+		Priority: cf.ReadFloat(configUrgencyPriority, model.DefaultUrgencyWeights.Priority),
+		Due:      cf.ReadFloat(configUrgencyDue, model.DefaultUrgencyWeights.Due),
+		Age:      cf.ReadFloat(configUrgencyAge, model.DefaultUrgencyWeights.Age),
+	})
+	historyCtrl := controllers.NewHistoryController(st.history, store, st.groups)
+	webhooksCtrl := controllers.NewWebhooksController(st.webhooks, webhookHosts)
+	eventsCtrl := controllers.NewEventsController(bus, store, st.groups)
+	listsCtrl := controllers.NewListsController(st.lists, store)
+	groupsCtrl := controllers.NewGroupsController(st.groups)
+	apiKeysCtrl := controllers.NewAPIKeysController(st.apiKeys)
+
+	api := http.NewServeMux()
+	api.HandleFunc("/todo", listCtrl.Tasks)
+	api.HandleFunc("/todo/others", listCtrl.OthersTasks)
+	api.HandleFunc("/todo/find", listCtrl.Find)
+	api.HandleFunc("/todo/trash", listCtrl.Trash)
+	api.HandleFunc("/todo/trash/restore", listCtrl.Restore)
+	api.HandleFunc("/todo/archive", listCtrl.Archive)
+	api.HandleFunc("/todo/archive/restore", listCtrl.Unarchive)
+	api.HandleFunc("/todo/occurrences", listCtrl.Occurrences)
+	api.HandleFunc("/todo/complete", listCtrl.Complete)
+	api.HandleFunc("/todo/subtasks", listCtrl.Subtasks)
+	api.HandleFunc("/todo/tree", listCtrl.Tree)
+	api.HandleFunc("/todo/dependencies", listCtrl.Dependencies)
+	api.HandleFunc("/todo/lists", listsCtrl.Lists)
+	api.HandleFunc("/todo/groups", groupsCtrl.Groups)
+	api.HandleFunc("/todo/groups/members", groupsCtrl.Members)
+	api.HandleFunc("/todo/apikeys", apiKeysCtrl.Keys)
+	api.HandleFunc("/todo/history", historyCtrl.History)
+	api.HandleFunc("/todo/events", eventsCtrl.Events)
+	api.HandleFunc("/todo/webhooks", webhooksCtrl.Webhooks)
+	api.HandleFunc("/todo/webhooks/deliveries", webhooksCtrl.Deliveries)
+
+	// Helper mapping for testing (Shouldn't be exposed on a production service)
+	api.HandleFunc("/todo/users", listCtrl.Users)
+
+	// every api request is authenticated, the help and health checks are left open.
+	// Requests are rate limited by their owner, or by their address when they can't be authenticated.
+	handler := auth.Require(api)
+	if rate := cf.ReadFloat(configRateLimit, defaultRateLimit); rate > 0 {
+		handler = ratelimit.NewLimiter(rate, cf.ReadInt(configRateBurst, defaultRateBurst)).Handler(handler)
+	}
+	handler = authn.Identify(handler)
+	http.Handle("/todo", handler)
+	http.Handle("/todo/", handler)
 	http.HandleFunc("/todo/help", showApi)
 	http.HandleFunc("/health", heartBeatHandler)
 	http.HandleFunc("/readiness", heartBeatHandler)
 
-	// Helper mapping for testing (Shouldn't be exposed on a production service)
-	http.HandleFunc("/todo/users", listCtrl.Users)
-
 	port := cf.ReadInt(configPort, defaultPort)
 
 	fmt.Printf("Starting todolist on localhost, port %d\n", port)
 
-	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), nil); nil != err {
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", port)}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); nil != err {
+			fmt.Printf("Failed to stop todolist gracefully: %v\n", err)
+		}
+	}()
+	if err := srv.ListenAndServe(); nil != err && err != http.ErrServerClosed {
 		panic(err)
 	}
+	<-stopped // the requests in flight are done with the store
 
+	if nil != archiver {
+		archiver.Stop()
+	}
+	recurrences.Stop()
+	scheduler.Stop()
+	dispatcher.Stop()
+	purger.Stop()
 	store.Close()
 }
+
+// stores are the datastore of the tasks, along with the stores kept in the same database.
+type stores struct {
+	tasks     data.Datastore
+	history   data.HistoryStore
+	webhooks  data.WebhookStore
+	reminders data.ReminderStore
+	lists     data.ListStore
+	groups    data.GroupStore
+	apiKeys   data.APIKeyStore
+}
+
+// openDatastore creates the datastore identified by the scheme of the given database url, along with the
+// history store holding the revisions of its tasks, the store of webhooks, the schedule of reminders
+// the lists the tasks are kept in, the groups of users they are shared with and the api keys of the owners.
+// "memory://" selects an in memory store, "file:///path/to/todo.db" an embedded store in the given file
+// and "sqlite:///path/to/todo.sqlite" an sql store in the given sqlite database.
+// Anything else is treated as a mongodb connection string.
+func openDatastore(uri string) (*stores, error) {
+	u, err := url.Parse(uri)
+	if nil != err {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "memory":
+		return &stores{data.NewMemoryDataStore(), data.NewMemoryHistoryStore(), data.NewMemoryWebhookStore(),
+			data.NewMemoryReminderStore(), data.NewMemoryListStore(), data.NewMemoryGroupStore(),
+			data.NewMemoryAPIKeyStore()}, nil
+	case "file":
+		path := u.Host + u.Path
+		fs, err := data.NewFileDataStore(path)
+		if nil != err {
+			return nil, err
+		}
+		fh, err := data.NewFileHistoryStore(path + ".history")
+		if nil != err {
+			fs.Close()
+			return nil, err
+		}
+		fw, err := data.NewFileWebhookStore(path + ".webhooks")
+		if nil != err {
+			fs.Close()
+			fh.Close()
+			return nil, err
+		}
+		fr, err := data.NewFileReminderStore(path + ".reminders")
+		if nil != err {
+			fs.Close()
+			fh.Close()
+			fw.Close()
+			return nil, err
+		}
+		fl, err := data.NewFileListStore(path + ".lists")
+		if nil != err {
+			fs.Close()
+			fh.Close()
+			fw.Close()
+			fr.Close()
+			return nil, err
+		}
+		fg, err := data.NewFileGroupStore(path + ".groups")
+		if nil != err {
+			fs.Close()
+			fh.Close()
+			fw.Close()
+			fr.Close()
+			fl.Close()
+			return nil, err
+		}
+		fk, err := data.NewFileAPIKeyStore(path + ".apikeys")
+		if nil != err {
+			fs.Close()
+			fh.Close()
+			fw.Close()
+			fr.Close()
+			fl.Close()
+			fg.Close()
+			return nil, err
+		}
+		return &stores{fs, fh, fw, fr, fl, fg, fk}, nil
+	case "sqlite":
+		s, err := data.NewSQLDataStore("sqlite", u.Host+u.Path)
+		if nil != err {
+			return nil, err
+		}
+		return &stores{s, data.NewSQLHistoryStore(s), data.NewSQLWebhookStore(s), data.NewSQLReminderStore(s),
+			data.NewSQLListStore(s), data.NewSQLGroupStore(s), data.NewSQLAPIKeyStore(s)}, nil
+	default:
+		ms, err := data.NewMongoDataStore(uri)
+		if nil != err {
+			return nil, err
+		}
+		return &stores{ms, data.NewMongoHistoryStore(ms), data.NewMongoWebhookStore(ms), data.NewMongoReminderStore(ms),
+			data.NewMongoListStore(ms), data.NewMongoGroupStore(ms), data.NewMongoAPIKeyStore(ms)}, nil
+	}
+}
+
+// newAuthenticator creates the middleware authenticating api requests, verifying their bearer tokens with the
+// jwtSecret HMAC secret, or the RSA and ECDSA keys of the jwks file or url, and reading the owner from the jwtOwnerClaim.
+// Requests made with an api key are looked up in the given key store.
+// Only with insecureDevMode on are requests without a token trusted to give their owner parameter.
+func newAuthenticator(cf Config, keys data.APIKeyStore) (*auth.Authenticator, error) {
+	secret := cf.ReadString(configJWTSecret, "")
+	var keySet *auth.KeySet
+	if source := cf.ReadString(configJWKS, ""); source != "" {
+		var err error
+		if keySet, err = auth.NewKeySet(source, &http.Client{Timeout: keySetTimeout}); nil != err {
+			return nil, fmt.Errorf("Failed to read %s %s %v", configJWKS, source, err)
+		}
+	}
+	insecure := cf.ReadBool(configInsecureDevMode, false)
+
+	var verifier *auth.Verifier
+	if secret != "" || nil != keySet {
+		verifier = auth.NewVerifier(secret, keySet, cf.ReadString(configJWTIssuer, ""), cf.ReadString(configJWTAudience, ""))
+	} else if !insecure {
+		return nil, fmt.Errorf("No %s or %s given to verify tokens with", configJWTSecret, configJWKS)
+	}
+	if insecure {
+		fmt.Printf("WARNING: %s is on, requests without a token are trusted to give their %s\n",
+			configInsecureDevMode, auth.ParamOwnerId)
+	}
+	return auth.NewAuthenticator(verifier, keys, cf.ReadString(configJWTOwnerClaim, defaultOwnerClaim), insecure), nil
+}
+
+// newNotifier creates the notifier sending reminders, selected by the reminderNotifier property.
+// "log" writes them to the service log, "smtp" emails them and "webhook" posts them to the reminderWebhook url.
+func newNotifier(cf Config) (data.Notifier, error) {
+	switch kind := cf.ReadString(configReminderNotifier, "log"); kind {
+	case "log":
+		return notify.NewLogNotifier(nil), nil
+	case "smtp":
+		return notify.NewSMTPNotifier(cf.ReadString(configSMTPServer, ""), cf.ReadString(configSMTPFrom, ""),
+			cf.ReadString(configSMTPRecipient, ""), cf.ReadString(configSMTPUsername, ""),
+			cf.ReadString(configSMTPPassword, ""))
+	case "webhook":
+		u := cf.ReadUrl(configReminderWebhook)
+		if nil == u {
+			return nil, fmt.Errorf("No %s url given for the reminder webhook", configReminderWebhook)
+		}
+		return notify.NewWebhookNotifier(u.String(), cf.ReadString(configReminderWebhookSecret, ""),
+			&http.Client{Timeout: webhookTimeout}), nil
+	default:
+		return nil, fmt.Errorf("Unknown %s %q, expected log, smtp or webhook", configReminderNotifier, kind)
+	}
+}
+
 func showApi(w http.ResponseWriter, r *http.Request) {
 	w.Write(helpText())
 	w.WriteHeader(http.StatusOK)
@@ -56,27 +347,139 @@ func heartBeatHandler(w http.ResponseWriter, r *http.Request) {
 func helpText() []byte {
 	var by bytes.Buffer
 	by.WriteString("Todo API helper\n")
+	by.WriteString("\tEvery request must have an \"Authorization: Bearer\" JWT, the owner is read from its claims\n")
+	by.WriteString("\t\t\"owner=nn\" is only read from requests without a token, when insecureDevMode is on\n")
+	by.WriteString("\tRequests are rate limited, given in the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers\n")
+	by.WriteString("\t\tReturns 429 Too Many Requests beyond it, with the Retry-After seconds to wait\n")
 	by.WriteString("\t./todo?owner=nn\n")
 	by.WriteString("\t\tGET Gets the todo list for the identified ownerid\n")
 	by.WriteString("\t\t    Returns json of all tasks for the given user\n")
+	by.WriteString("\t\tGET \"taskid=ssss\" Gets a single task the owner owns or reads, with its version as the ETag header\n")
 
 	by.WriteString("\t\tPOST Creates a new task in the owners todo list\t<body must have json of task to create by>\n")
 	by.WriteString("\t\t     Returns the new task id as the body\n")
 
 	by.WriteString("\t\tPUT \"taskid=ssss\" Update a task in the owners todo list\t<body must have json of task properties to update by>\n")
-	by.WriteString("\t\tDELETE \"taskid=ssss\" Delete a task in the owners todo list\n")
+	by.WriteString("\t\tDELETE \"taskid=ssss\" Delete a task in the owners todo list, moving it to the trash\n")
+	by.WriteString("\t\t       Its subtasks are deleted with it, unless \"subtasks=orphan\" leaves them as top level tasks\n")
 	by.WriteString("\t\t       statusOK if delete was carried out.\n")
+	by.WriteString("\t\tPUT and DELETE with an \"If-Match\" header of a task ETag only change the task if it is still that version.\n")
+	by.WriteString("\t\t    Returns 412 Precondition Failed if the task has changed since.  PUT returns the new ETag.\n")
+	by.WriteString("\t\tA task may have \"reminders\": [\"1d\", \"2h30m\", \"0\"], how long before it expires to remind the owner\n")
+	by.WriteString("\t\tA task may have a \"recurrence\": \"FREQ=WEEKLY;BYDAY=MO\", an RFC 5545 RRULE evaluated in UTC from its expiry\n")
+	by.WriteString("\t\t    Once it expires, the next occurrence is added with the same title, labels, notes, acl, reminders, priority and list\n")
+	by.WriteString("\t\tA task has a \"status\" of open, in-progress, blocked, done or cancelled, with \"completedAt\" set once done\n")
+	by.WriteString("\t\t    A blocked task is unblocked before it is done, done and cancelled tasks are reopened to change status\n")
+	by.WriteString("\t\t    Returns 409 Conflict if the task can't move to the status given\n")
+	by.WriteString("\t\tLists leave out done tasks, \"status=done,cancelled\" lists those statuses, \"status=all\" every status\n")
+	by.WriteString("\t\tA task may have a \"priority\" of 0 (none), 1 (low), 2 (medium) or 3 (high)\n")
+	by.WriteString("\t\t    Each task listed has an \"urgency\", scored from its priority, how soon it expires and how old it is\n")
+	by.WriteString("\t\t\"sort=urgency\" Lists the most urgent tasks first, it can't be combined with other fields\n")
+	by.WriteString(fmt.Sprintf("\t\t    Returns 400 Bad Request for a listing of more than %d tasks, narrow it by list or status\n",
+		data.MaxUrgencyTasks))
+
+	by.WriteString("\t\tA task is kept in a \"list\", the owners \"default\" list unless given the id of one of their lists\n")
+	by.WriteString("\t\t    Returns 422 if the list isn't known, or a new task is put in an archived list\n")
+	by.WriteString("\t\t\"list=ssss\" Lists only the tasks in the given list\n")
+
+	by.WriteString("\t\tA task may have a \"parent\" task id, making it a subtask owned by the owner of its parent\n")
+	by.WriteString("\t\t    The users a task is shared with may read all its subtasks, and a task with subtasks has a \"progress\" percentage done\n")
+	by.WriteString("\t\tA task may be \"blockedBy\" a list of task ids, of the owners tasks to be done before it can start\n")
+	by.WriteString("\t\t    Returns 422 if a blocker isn't known, or the task would end up blocked by itself\n")
+	by.WriteString("\t\t    Deleting a task removes it from the tasks it was blocking, once out of the trash or archive\n")
+
+	by.WriteString("\t./todo/subtasks?owner=nn&taskid=ssss\n")
+	by.WriteString("\t\tGET Gets the subtasks of the task, listed as the todo list is\n")
+	by.WriteString("\t./todo/tree?owner=nn&taskid=ssss\n")
+	by.WriteString("\t\tGET Gets the task with all its subtasks, as {\"task\": {...}, \"subtasks\": [{\"task\": {...}, \"subtasks\": [...]}]}\n")
+
+	by.WriteString("\t./todo/dependencies?owner=nn\n")
+	by.WriteString("\t\tGET Gets the tasks not yet done, each after the tasks blocking it, as \"tasks\", their \"dependencies\",\n")
+	by.WriteString("\t\t    and the tasks which can be started now as \"actionable\"\n")
+
+	by.WriteString("\t./todo/complete?owner=nn&taskid=ssss\n")
+	by.WriteString("\t\tPOST Marks the task done, adding its next occurrence straight away if it recurs\n")
+	by.WriteString("\t\t     Returns json of the done task, and the id of any next occurrence as \"next\"\n")
+
+	by.WriteString("\t./todo/occurrences?owner=nn&taskid=ssss[&limit=nn]\n")
+	by.WriteString("\t\tGET Previews the upcoming times the recurring task is due, (default 10, maximum 100)\n")
+	by.WriteString("\t\t\"recurrence=<url encoded RRULE>[&start=2024-01-01T09:00:00Z]\" Previews a rule instead, from now if no start is given\n")
 
 	by.WriteString("\t./todo/others?owner=nn\n")
 	by.WriteString("\t\tGET Gets the tasks from other users todo lists the owner has access to\n")
-	by.WriteString("\t\t    Returns json of all tasks not owned by owner, where owner is a Reader of the task\n")
+	by.WriteString("\t\t    Returns json of all tasks not owned by owner, shared with them, each with the \"role\" they have on it\n")
+
+	by.WriteString("\t./todo/trash?owner=nn\n")
+	by.WriteString("\t\tGET Gets the owners deleted tasks, kept in the trash until purged\n")
+	by.WriteString("\t./todo/trash/restore?owner=nn&taskid=ssss\n")
+	by.WriteString("\t\tPOST Restores the deleted task from the trash to the owners todo list\n")
+
+	by.WriteString("\t./todo/archive?owner=nn\t<body may have json of task properties to search by>\n")
+	by.WriteString("\t\tGET Gets the owners tasks archived, having expired longer ago than the archiveAfter days\n")
+	by.WriteString("\t\t    Archived tasks are left out of every other list. The query task is matched as by find\n")
+	by.WriteString("\t./todo/archive/restore?owner=nn&taskid=ssss\n")
+	by.WriteString("\t\tPOST Unarchives the task back to the owners todo list, it isn't archived again unless it expires again\n")
+
+	by.WriteString("\t./todo/lists?owner=nn\n")
+	by.WriteString("\t\tGET Gets the owners lists, the default list first. Archived lists are left out unless \"archived=true\"\n")
+	by.WriteString("\t\tGET \"listid=ssss\" Gets a single list\n")
+	by.WriteString("\t\tPOST Creates a new list\t<body must have json of the list, {\"name\", \"description\", \"colour\": \"#rrggbb\"}>\n")
+	by.WriteString("\t\t     Returns the new list, with its id\n")
+	by.WriteString("\t\tPUT Updates the list with the id given\t<body must have json of the list, with \"id\" and \"archived\">\n")
+	by.WriteString("\t\tDELETE \"listid=ssss\" Removes the list. Returns 409 Conflict while it holds any tasks outside the trash\n")
+	by.WriteString("\t\tThe default list can't be changed or deleted\n")
+
+	by.WriteString("\t./todo/groups?owner=nn\n")
+	by.WriteString("\t\tGET Gets the owners groups, or \"member=true\" the groups the owner is a member of\n")
+	by.WriteString("\t\tGET \"groupId=ssss\" Gets a single group the owner owns or is a member of\n")
+	by.WriteString("\t\tPOST Creates a new group\t<body must have json of the group, {\"name\", \"members\": [nn]}>\n")
+	by.WriteString("\t\t     Returns the new group, with its id\n")
+	by.WriteString("\t\tPUT Renames the group with the id given\t<body must have json of the group, with \"id\">\n")
+	by.WriteString("\t\tDELETE \"groupId=ssss\" Removes the group, its tasks are no longer shared with its members\n")
+	by.WriteString("\t./todo/groups/members?owner=nn&groupId=ssss&member=nn\n")
+	by.WriteString("\t\tPOST Adds the member to the owners group, DELETE removes them\n")
+	by.WriteString("\t\t    Tasks shared with the group are shared with its members as they are at the time\n")
+	by.WriteString("\t\t    Tasks may only be shared with groups their owner owns or is a member of\n")
+
+	by.WriteString("\t./todo/apikeys\n")
+	by.WriteString("\t\tGET Gets the owners api keys, with when each was last used. Only with a token, or an admin key\n")
+	by.WriteString("\t\tPOST Creates a new api key\t<body must have json of the key, {\"name\", \"scope\": \"read\"|\"read-write\"|\"admin\"}>\n")
+	by.WriteString("\t\t     Returns the new key, with the \"key\" to send as \"Authorization: Bearer todo_...\", which isn't shown again\n")
+	by.WriteString("\t\tDELETE \"keyId=ssss\" Revokes the key\n")
+
+	by.WriteString("\t./todo/history?owner=nn&taskid=ssss\n")
+	by.WriteString("\t\tGET Gets every revision of the task, a snapshot taken each time it was created, updated, deleted or restored\n")
+	by.WriteString("\t\t    Only the owner of the task, and the users it is shared with, may see its history\n")
+	by.WriteString("\t\t\"from=n&to=n\" Gets the fields of the task changed between the two revisions instead\n")
+
+	by.WriteString("\t./todo/events?owner=nn\n")
+	by.WriteString("\t\tGET Streams Server-Sent Events as tasks the owner owns or reads are created, updated, deleted or restored\n")
+	by.WriteString("\t\t    Reconnect with the Last-Event-ID header, or \"lastEventId=n\", to receive the events missed first\n")
+	by.WriteString("\t\t    A reset event means some were missed for good, and the tasks should be fetched again\n")
+
+	by.WriteString("\t./todo/webhooks?owner=nn\n")
+	by.WriteString("\t\tGET Gets the webhooks of the owner\n")
+	by.WriteString("\t\tPOST Adds a webhook, the body holding {\"url\": \"...\", \"secret\": \"...\", \"events\": [\"created\", ...]}\n")
+	by.WriteString("\t\t    Changes to tasks the owner owns or reads are posted to the url, signed in the X-Todo-Signature header\n")
+	by.WriteString("\t\t    as sha256=<hex HMAC-SHA256 of the body with the secret>. Every event is sent if none are given\n")
+	by.WriteString("\t\t    A secret is generated if none is given, it is only returned here\n")
+	by.WriteString("\t\t    Urls to internal addresses are refused, unless their host is in the webhookAllowedHosts property\n")
+	by.WriteString("\t\tDELETE \"webhookid=ssss\" Removes the webhook\n")
+	by.WriteString("\t./todo/webhooks/deliveries?owner=nn[&webhookid=ssss][&limit=nn]\n")
+	by.WriteString("\t\tGET Gets the latest deliveries to the owners webhooks, with the outcome of each. Failed deliveries are retried\n")
 
 	by.WriteString("\t./todo/find?owner=nn\t<body must have json of task properties to search by>\n")
 	by.WriteString("\t\tGET Searches the owners tasks for tasks matching the values given in the query task to\n")
 	by.WriteString("\t\t    Body should contain a single task json object containing the values to search for\n")
 	by.WriteString("\t\t    String value look for exactly match. Created date will return all tasks create on or after that date.\n")
 	by.WriteString("\t\t    Expires date will return all tasks create before that date.\n")
-	by.WriteString("\t\t    Array value, notes, labels, readers will match tasks will ALL the given elements of the array in the corrisponding array.\n")
+	by.WriteString("\t\t    Array value, notes, labels, acl will match tasks will ALL the given elements of the array in the corrisponding array.\n")
+
+	by.WriteString("\tTask lists are returned a page at a time, as a json array of tasks, latest expiring first\n")
+	by.WriteString("\t\tThe cursor to the following page is given in the X-Next-Cursor header, and its url as the next Link\n")
+	by.WriteString("\t\t\"limit=nn\" Maximum number of tasks in the page, (default and maximum 500)\n")
+	by.WriteString("\t\t\"cursor=ssss\" Gets the page following the one which gave that cursor. No cursor is given with the last page\n")
+	by.WriteString("\t\t\"sort=-created,title\" Orders the tasks by the given fields, '-' for descending. (_id, created, owner, title, expires, priority)\n")
 
 	return by.Bytes()
 }
@@ -0,0 +1,179 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The roles a task may be shared with, each allowing everything the roles before it do.
+const (
+	RoleReader = "reader" // may read the task
+	RoleEditor = "editor" // may also update it, but not delete it or change who it is shared with
+	RoleAdmin  = "admin"  // a co-owner, who may also delete it and change who it is shared with
+)
+
+// RoleOwner is the role of the owner of a task, allowing everything, including giving it to someone else.
+// It is never granted.
+const RoleOwner = "owner"
+
+// Roles lists the roles a task may be shared with, least allowed first.
+var Roles = []string{RoleReader, RoleEditor, RoleAdmin}
+
+// Grant gives a user, or every member of a group, a role on a task, until it expires, if it ever does.
+// Groups may only be given the reader role.
+type Grant struct {
+	User    int        `json:"user,omitempty" bson:"user"`
+	Group   string     `json:"group,omitempty" bson:"group,omitempty"` // id of the group, in place of a user
+	Role    string     `json:"role" bson:"role"`
+	Expires *time.Time `json:"expires,omitempty" bson:"expires,omitempty"`
+}
+
+// Active checks the grant hasn't expired by the given time.
+func (g Grant) Active(at time.Time) bool {
+	return nil == g.Expires || g.Expires.After(at)
+}
+
+// ValidRole checks the role is one of the Roles a task may be shared with.
+func ValidRole(role string) bool {
+	return roleRank(role) > 0 && role != RoleOwner
+}
+
+// RoleOf gets the role the user has on the task at the given time, not counting the groups they are a member of.
+// RoleOwner for its owner, empty if the task isn't shared with them, or their grant has expired.
+func RoleOf(t *Task, userId int, at time.Time) string {
+	if t.Owner == userId {
+		return RoleOwner
+	}
+	for _, g := range t.ACL {
+		if g.Group == "" && g.User == userId && g.Active(at) {
+			return g.Role
+		}
+	}
+	return ""
+}
+
+// MemberRoleOf gets the role the user has on the task at the given time, as RoleOf, or as a member of one of the
+// given groups. Empty if it is shared with neither them, nor any of their groups.
+func MemberRoleOf(t *Task, userId int, groups []string, at time.Time) string {
+	if role := RoleOf(t, userId, at); role != "" {
+		return role
+	}
+	for _, g := range t.ACL {
+		if g.Group != "" && g.Active(at) && containsString(groups, g.Group) {
+			return g.Role
+		}
+	}
+	return ""
+}
+
+// SharedWithGroups checks the task is shared with any group, so the groups of a user are needed to find their role.
+func SharedWithGroups(t *Task) bool {
+	for _, g := range t.ACL {
+		if g.Group != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows checks the role allows everything the needed role does.
+func Allows(role string, needed string) bool {
+	return roleRank(role) > 0 && roleRank(role) >= roleRank(needed)
+}
+
+// RolesAllowing lists the roles a task may be shared with which allow everything the needed role does.
+// None allow everything the owner may do.
+func RolesAllowing(needed string) []string {
+	var roles []string
+	for _, role := range Roles {
+		if Allows(role, needed) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Grantees lists the users the task is shared with, whose grants are active at the given time.
+// The members of the groups it is shared with aren't listed.
+func Grantees(t *Task, at time.Time) []int {
+	var users []int
+	for _, g := range t.ACL {
+		if g.Group == "" && g.Active(at) {
+			users = append(users, g.User)
+		}
+	}
+	return users
+}
+
+// SameACL checks both lists grant the same roles to the same users and groups, expiring at the same times, in any order.
+func SameACL(a []Grant, b []Grant) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	type grantee struct {
+		user  int
+		group string
+	}
+	grants := map[grantee]Grant{}
+	for _, g := range a {
+		grants[grantee{g.User, g.Group}] = g
+	}
+	for _, g := range b {
+		other, ok := grants[grantee{g.User, g.Group}]
+		if !ok || other.Role != g.Role || (nil == other.Expires) != (nil == g.Expires) ||
+			(nil != g.Expires && !g.Expires.Equal(*other.Expires)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReaderGrants grants each of the readers the reader role, without expiring.
+func ReaderGrants(readers []int) []Grant {
+	var grants []Grant
+	for _, r := range readers {
+		grants = append(grants, Grant{User: r, Role: RoleReader})
+	}
+	return grants
+}
+
+// UnmarshalJSON reads a task, granting the reader role to each of the "readers" given to tasks
+// before they were shared through their ACL, unless it has one.
+func (t *Task) UnmarshalJSON(by []byte) error {
+	type task Task // without this method, so it is read as usual
+	var legacy struct {
+		task
+		Readers []int `json:"readers"`
+	}
+	if err := json.Unmarshal(by, &legacy); nil != err {
+		return err
+	}
+	*t = Task(legacy.task)
+	if len(t.ACL) == 0 {
+		t.ACL = ReaderGrants(legacy.Readers)
+	}
+	return nil
+}
+
+func roleRank(role string) int {
+	switch role {
+	case RoleReader:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleAdmin:
+		return 3
+	case RoleOwner:
+		return 4
+	}
+	return 0
+}
+
+func containsString(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package model_test
+
+import (
+	"encoding/json"
+	"gatso/model"
+	"testing"
+	"time"
+)
+
+func TestRoleOf(t *testing.T) {
+	now := time.Now()
+	yesterday := now.Add(-24 * time.Hour)
+	tomorrow := now.Add(24 * time.Hour)
+	task := &model.Task{Owner: 1, ACL: []model.Grant{
+		{User: 2, Role: model.RoleReader},
+		{User: 3, Role: model.RoleEditor, Expires: &tomorrow},
+		{User: 4, Role: model.RoleAdmin, Expires: &yesterday},
+	}}
+
+	tests := []struct {
+		user   int
+		role   string
+		edits  bool
+		admins bool
+	}{
+		{1, model.RoleOwner, true, true},
+		{2, model.RoleReader, false, false},
+		{3, model.RoleEditor, true, false},
+		{4, "", false, false},
+		{5, "", false, false},
+	}
+	for _, tt := range tests {
+		role := model.RoleOf(task, tt.user, now)
+		if role != tt.role {
+			t.Errorf("Expected user %d to have role %q, found %q", tt.user, tt.role, role)
+		}
+		if model.Allows(role, model.RoleEditor) != tt.edits || model.Allows(role, model.RoleAdmin) != tt.admins {
+			t.Errorf("Expected role %q to allow editing %v, and admin %v", role, tt.edits, tt.admins)
+		}
+	}
+	if users := model.Grantees(task, now); len(users) != 2 || users[0] != 2 || users[1] != 3 {
+		t.Errorf("Expected the grants of 2 and 3 to be active, found %v", users)
+	}
+}
+
+func TestTaskUnmarshalReaders(t *testing.T) {
+	var task model.Task
+	if err := json.Unmarshal([]byte(`{"owner": 1, "title": "Test Task", "readers": [2, 3]}`), &task); nil != err {
+		t.Error(err)
+		return
+	}
+	if task.Title != "Test Task" || !model.SameACL(task.ACL, model.ReaderGrants([]int{3, 2})) {
+		t.Errorf("Expected the readers to be shared the task as readers, found %+v", task)
+	}
+}
@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// The scopes an api key may be given, each allowing everything the scopes before it do.
+const (
+	ScopeRead      = "read"       // may only read, making GET requests
+	ScopeReadWrite = "read-write" // may also change tasks, lists and groups
+	ScopeAdmin     = "admin"      // may also manage the api keys of its owner
+)
+
+// Scopes lists the scopes an api key may be given, least allowed first.
+var Scopes = []string{ScopeRead, ScopeReadWrite, ScopeAdmin}
+
+// APIKey is a long lived credential of an owner, for scripts and jobs to make requests as them, within its scope.
+// Only the hash of the key is kept, the key itself is only given when it is created.
+type APIKey struct {
+	ID       string     `json:"id" bson:"_id"`
+	Owner    int        `json:"owner" bson:"owner"`
+	Name     string     `json:"name" bson:"name"`
+	Scope    string     `json:"scope" bson:"scope"`
+	Prefix   string     `json:"prefix" bson:"prefix"` // first characters of the key, to tell keys apart
+	Hash     string     `json:"-" bson:"hash"`
+	Created  time.Time  `json:"created" bson:"created"`
+	LastUsed *time.Time `json:"lastUsed,omitempty" bson:"lastUsed,omitempty"`
+	Key      string     `json:"key,omitempty" bson:"-"` // the key itself, only when it is created
+}
+
+// ValidScope checks the scope is one of the Scopes a key may be given.
+func ValidScope(scope string) bool {
+	return scopeRank(scope) > 0
+}
+
+// ScopeAllows checks the scope allows everything the needed scope does.
+func ScopeAllows(scope string, needed string) bool {
+	rank := scopeRank(scope)
+	return rank > 0 && rank >= scopeRank(needed)
+}
+
+// scopeRank orders the scopes, from 1 for ScopeRead. 0 for an unknown scope.
+func scopeRank(scope string) int {
+	for i, s := range Scopes {
+		if s == scope {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package model
+
+import "sort"
+
+// Dependency is one edge of a dependency graph, the task being blocked by another task.
+type Dependency struct {
+	Task      string `json:"task"`
+	BlockedBy string `json:"blockedBy"`
+}
+
+// DependencyGraph is the graph of the unresolved tasks of an owner, and the dependencies between them.
+type DependencyGraph struct {
+	Tasks        []*Task      `json:"tasks"` // each listed after every task blocking it
+	Dependencies []Dependency `json:"dependencies"`
+	Actionable   []*Task      `json:"actionable"` // tasks which can be worked on now, in the order of Tasks
+}
+
+// Resolved reports if the task no longer blocks the tasks depending on it, being done or cancelled.
+func Resolved(t *Task) bool {
+	s := StatusOf(t)
+	return s == StatusDone || s == StatusCancelled
+}
+
+// NewDependencyGraph builds the graph of those of the given tasks which are unresolved. Blockers which are resolved,
+// or aren't among the tasks, no longer block. Tasks are sorted topologically, keeping the order they are given in
+// where their dependencies allow. A task is actionable once none of its blockers are unresolved, unless its status
+// is blocked. Any tasks caught in a cycle are listed last, and are never actionable.
+func NewDependencyGraph(tasks []*Task) DependencyGraph {
+	graph := DependencyGraph{Tasks: []*Task{}, Dependencies: []Dependency{}, Actionable: []*Task{}}
+
+	var unresolved []*Task
+	position := map[string]int{}
+	for _, t := range tasks {
+		if !Resolved(t) {
+			position[t.Id()] = len(unresolved)
+			unresolved = append(unresolved, t)
+		}
+	}
+
+	waiting := make([]int, len(unresolved)) // how many unresolved blockers each task is waiting on
+	blocking := make([][]int, len(unresolved))
+	for i, t := range unresolved {
+		seen := map[string]bool{}
+		for _, id := range t.BlockedBy {
+			j, ok := position[id]
+			if !ok || seen[id] {
+				continue
+			}
+			seen[id] = true
+			waiting[i]++
+			blocking[j] = append(blocking[j], i)
+			graph.Dependencies = append(graph.Dependencies, Dependency{Task: t.Id(), BlockedBy: id})
+		}
+	}
+
+	// tasks ready to be listed, in the order given
+	var ready []int
+	for i := range unresolved {
+		if waiting[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	listed := make([]bool, len(unresolved))
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		listed[i] = true
+		graph.Tasks = append(graph.Tasks, unresolved[i])
+		for _, j := range blocking[i] {
+			if waiting[j]--; waiting[j] == 0 {
+				at := sort.SearchInts(ready, j)
+				ready = append(ready[:at], append([]int{j}, ready[at:]...)...)
+			}
+		}
+	}
+	for i, t := range unresolved {
+		if !listed[i] { // caught in a cycle
+			graph.Tasks = append(graph.Tasks, t)
+		}
+	}
+
+	for _, t := range graph.Tasks {
+		if StatusOf(t) != StatusBlocked && !hasUnresolved(t, position) {
+			graph.Actionable = append(graph.Actionable, t)
+		}
+	}
+	return graph
+}
+
+// hasUnresolved reports if the task is blocked by any of the unresolved tasks at the given positions.
+func hasUnresolved(t *Task, position map[string]int) bool {
+	for _, id := range t.BlockedBy {
+		if _, ok := position[id]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package model_test
+
+import (
+	"fmt"
+	"gatso/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	tasks := map[string]*model.Task{}
+	var list []*model.Task
+	add := func(name string, status string, blockedBy ...string) {
+		id := primitive.NewObjectID()
+		task := &model.Task{ID: &id, Title: name, Status: status}
+		for _, b := range blockedBy {
+			task.BlockedBy = append(task.BlockedBy, tasks[b].Id())
+		}
+		tasks[name] = task
+		list = append(list, task)
+	}
+	titles := func(tasks []*model.Task) string {
+		var names []string
+		for _, t := range tasks {
+			names = append(names, t.Title)
+		}
+		return fmt.Sprint(names)
+	}
+
+	add("done", model.StatusDone)
+	add("a", model.StatusOpen)
+	add("b", model.StatusOpen, "a", "done")
+	add("c", model.StatusInProgress, "done")
+	add("d", model.StatusBlocked)
+	add("e", model.StatusOpen, "b", "c")
+	add("cancelled", model.StatusCancelled, "a")
+	// listed out of order, after the tasks it blocks
+	add("f", model.StatusOpen)
+	tasks["a"].BlockedBy = []string{tasks["f"].Id()}
+
+	graph := model.NewDependencyGraph(list)
+	if got := titles(graph.Tasks); got != "[c d f a b e]" {
+		t.Errorf("Expected tasks sorted after their blockers, found %s", got)
+	}
+	if got := titles(graph.Actionable); got != "[c f]" {
+		t.Errorf("Expected only unblocked tasks to be actionable, found %s", got)
+	}
+	if len(graph.Dependencies) != 4 {
+		t.Errorf("Expected the 4 dependencies between unresolved tasks, found %v", graph.Dependencies)
+	}
+
+	// tasks caught in a cycle are listed last, never actionable
+	tasks["f"].BlockedBy = []string{tasks["e"].Id()}
+	graph = model.NewDependencyGraph(list)
+	if got := titles(graph.Tasks); got != "[c d a b e f]" {
+		t.Errorf("Expected tasks caught in a cycle listed last, found %s", got)
+	}
+	if got := titles(graph.Actionable); got != "[c]" {
+		t.Errorf("Expected tasks caught in a cycle not to be actionable, found %s", got)
+	}
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+const EventCreated = "created"
+const EventUpdated = "updated"
+const EventDeleted = "deleted"
+const EventRestored = "restored"
+
+// Event announces a change to a task, holding the task as it was stored by the change.
+// Events are numbered in the order they happened, starting from 1 each time the service starts.
+type Event struct {
+	ID      uint64    `json:"id"`
+	Type    string    `json:"type"`
+	Changed time.Time `json:"changed"`
+	Task    Task      `json:"task"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Group is a named group of users, created by its owner, which tasks may be shared with as readers.
+// Its owner isn't one of its members, unless they add themselves.
+type Group struct {
+	ID      string    `json:"id" bson:"_id"`
+	Owner   int       `json:"owner" bson:"owner"`
+	Name    string    `json:"name" bson:"name"`
+	Members []int     `json:"members" bson:"members"`
+	Created time.Time `json:"created" bson:"created"`
+}
+
+// HasMember checks the user is one of the members of the group.
+func (g Group) HasMember(userId int) bool {
+	return containsInt(g.Members, userId)
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"regexp"
+	"time"
+)
+
+// DefaultList is the id of the list every owner has without creating it, holding every task not put in another list,
+// including the tasks saved before there were lists.
+const DefaultList = "default"
+
+// DefaultListName is the name of the default list.
+const DefaultListName = "Tasks"
+
+// colourPattern matches a colour given as a css hex colour, #rrggbb.
+var colourPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// List is one of the named lists of tasks of an owner.
+type List struct {
+	ID          string    `json:"id" bson:"_id"`
+	Owner       int       `json:"owner" bson:"owner"`
+	Name        string    `json:"name" bson:"name"`
+	Description string    `json:"description,omitempty" bson:"description,omitempty"`
+	Colour      string    `json:"colour,omitempty" bson:"colour,omitempty"` // css hex colour, #rrggbb
+	Archived    bool      `json:"archived" bson:"archived"`                 // hidden from the lists, and takes no new tasks
+	Created     time.Time `json:"created" bson:"created"`
+}
+
+// OwnersDefaultList gets the default list of the owner.
+func OwnersDefaultList(ownerId int) List {
+	return List{ID: DefaultList, Owner: ownerId, Name: DefaultListName}
+}
+
+// ValidColour checks the colour is a css hex colour, or not given.
+func ValidColour(colour string) bool {
+	return colour == "" || colourPattern.MatchString(colour)
+}
+
+// ListOf gets the id of the list the task is in, the default list if it has none.
+func ListOf(t *Task) string {
+	if t.List == "" {
+		return DefaultList
+	}
+	return t.List
+}
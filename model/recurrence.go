@@ -0,0 +1,500 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The frequencies a recurrence rule may repeat at.
+const (
+	FreqHourly  = "HOURLY"
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// maxRecurrenceGap ends a series finding no further occurrence this long after its last, so rules which can
+// never match again, such as the 30th of February, don't search forever.
+const maxRecurrenceGap = 10 * 366 * 24 * time.Hour
+
+const untilFormat = "20060102T150405Z"
+
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Recurrence is an RFC 5545 recurrence rule, the RRULE of a recurring task. e.g. "FREQ=WEEKLY;BYDAY=MO,TH"
+// The series starts at the tasks expiry and is evaluated in UTC.
+// FREQ (HOURLY to YEARLY), INTERVAL, COUNT, UNTIL, BYMONTH, BYMONTHDAY, BYDAY, BYHOUR, BYMINUTE and WKST are supported.
+type Recurrence struct {
+	Freq       string
+	Interval   int
+	Count      int       // number of occurrences in the series, including its start, 0 for no limit
+	Until      time.Time // last time the series may occur, zero for no limit
+	ByMonth    []int
+	ByMonthDay []int // negative days count back from the end of the month
+	ByDay      []RecurrenceDay
+	ByHour     []int
+	ByMinute   []int
+	WeekStart  time.Weekday
+}
+
+// RecurrenceDay is a BYDAY entry, a weekday which may be limited to the Nth of its month or year.
+// A negative N counts back from the end, 0 is every such weekday.
+type RecurrenceDay struct {
+	N       int
+	Weekday time.Weekday
+}
+
+// ParseRecurrence reads an RRULE, with or without the "RRULE:" prefix.
+func ParseRecurrence(rule string) (*Recurrence, error) {
+	r := &Recurrence{Interval: 1, WeekStart: time.Monday}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:"), ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid recurrence rule %q, expected NAME=VALUE parts such as FREQ=WEEKLY", rule)
+		}
+		name, value := strings.ToUpper(strings.TrimSpace(kv[0])), strings.ToUpper(strings.TrimSpace(kv[1]))
+		if seen[name] {
+			return nil, fmt.Errorf("Invalid recurrence rule %q, %s is given more than once", rule, name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			switch value {
+			case FreqHourly, FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				r.Freq = value
+			default:
+				err = fmt.Errorf("unsupported frequency %s", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = parsePositive(value)
+		case "COUNT":
+			r.Count, err = parsePositive(value)
+		case "UNTIL":
+			r.Until, err = parseUntil(value)
+		case "BYMONTH":
+			r.ByMonth, err = parseInts(value, 1, 12)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseInts(value, -31, 31)
+		case "BYDAY":
+			r.ByDay, err = parseDays(value)
+		case "BYHOUR":
+			r.ByHour, err = parseInts(value, 0, 23)
+		case "BYMINUTE":
+			r.ByMinute, err = parseInts(value, 0, 59)
+		case "WKST":
+			r.WeekStart, err = parseWeekday(value)
+		default:
+			err = fmt.Errorf("%s is not supported", name)
+		}
+		if nil != err {
+			return nil, fmt.Errorf("Invalid recurrence rule %q, %v", rule, err)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("Invalid recurrence rule %q, FREQ is required", rule)
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("Invalid recurrence rule %q, COUNT and UNTIL may not both be given", rule)
+	}
+	if r.Freq == FreqWeekly && len(r.ByMonthDay) > 0 {
+		return nil, fmt.Errorf("Invalid recurrence rule %q, BYMONTHDAY may not be given with FREQ=WEEKLY", rule)
+	}
+	for _, d := range r.ByDay {
+		if d.N != 0 && r.Freq != FreqMonthly && r.Freq != FreqYearly {
+			return nil, fmt.Errorf("Invalid recurrence rule %q, BYDAY may only be numbered with FREQ=MONTHLY or YEARLY", rule)
+		}
+		if d.N != 0 && (r.Freq == FreqMonthly || len(r.ByMonth) > 0) && (d.N > 5 || d.N < -5) {
+			return nil, fmt.Errorf("Invalid recurrence rule %q, a month has no more than 5 of each weekday", rule)
+		}
+	}
+	return r, nil
+}
+
+// String formats the rule as an RRULE, without the "RRULE:" prefix.
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilFormat))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+formatInts(r.ByMonth))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+formatInts(r.ByMonthDay))
+	}
+	if len(r.ByDay) > 0 {
+		var days []string
+		for _, d := range r.ByDay {
+			day := weekdayNames[d.Weekday]
+			if d.N != 0 {
+				day = strconv.Itoa(d.N) + day
+			}
+			days = append(days, day)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByHour) > 0 {
+		parts = append(parts, "BYHOUR="+formatInts(r.ByHour))
+	}
+	if len(r.ByMinute) > 0 {
+		parts = append(parts, "BYMINUTE="+formatInts(r.ByMinute))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrences gets up to n times the series starting at dtstart occurs after the given time.
+func (r Recurrence) Occurrences(dtstart time.Time, after time.Time, n int) []time.Time {
+	var times []time.Time
+	if n <= 0 {
+		return times
+	}
+	r.iterate(dtstart, after, func(t time.Time) bool {
+		if t.After(after) {
+			times = append(times, t)
+		}
+		return len(times) < n
+	})
+	return times
+}
+
+// TaskRecurrence gets the rule the task recurs by, nil if it doesn't recur.
+// A rule which can't be read, or a recurring task without an expiry to start the series from, is returned as an error.
+func TaskRecurrence(t *Task) (*Recurrence, error) {
+	if t.Recurrence == "" {
+		return nil, nil
+	}
+	r, err := ParseRecurrence(t.Recurrence)
+	if nil != err {
+		return nil, err
+	}
+	if t.Expires.IsZero() {
+		return nil, fmt.Errorf("A recurring task must expire, its recurrence starting from its expiry")
+	}
+	return r, nil
+}
+
+// NextOccurrence makes the task due the next time the recurring task recurs after both its expiry and the given time.
+// It carries over the title, labels, notes, ACL, reminders, priority and list, counting down any COUNT in its rule.
+// Returns nil if the task doesn't recur, or its rule has ended.
+func NextOccurrence(t Task, after time.Time) (*Task, error) {
+	r, err := TaskRecurrence(&t)
+	if nil != err || nil == r {
+		return nil, err
+	}
+
+	var next time.Time
+	var position int // of the next occurrence in the series, the task being the first
+	r.iterate(t.Expires, after, func(at time.Time) bool {
+		if at.After(after) && at.After(t.Expires) {
+			next = at
+			return false
+		}
+		position++
+		return true
+	})
+	if next.IsZero() {
+		return nil, nil
+	}
+	if r.Count > 0 {
+		r.Count -= position
+	}
+
+	return &Task{
+		Owner:      t.Owner,
+		Title:      t.Title,
+		Expires:    next,
+		Labels:     append([]string(nil), t.Labels...),
+		Notes:      append([]string(nil), t.Notes...),
+		ACL:        append([]Grant(nil), t.ACL...),
+		Reminders:  append([]string(nil), t.Reminders...),
+		Recurrence: r.String(),
+		Priority:   t.Priority,
+		List:       t.List,
+	}, nil
+}
+
+// iterate calls fn with each time in the series starting at dtstart, in order, until it returns false or the series ends.
+// dtstart is always the first, even if it doesn't match the rule. Without a COUNT, the times before from may be skipped.
+func (r Recurrence) iterate(dtstart time.Time, from time.Time, fn func(t time.Time) bool) {
+	dtstart = dtstart.UTC().Truncate(time.Second)
+	if !fn(dtstart) {
+		return
+	}
+	count := 1
+	last := dtstart
+	skip := r.skip(dtstart, from)
+	if skip > 0 {
+		last = r.period(dtstart, skip)
+	}
+	for i := skip; ; i++ {
+		start := r.period(dtstart, i)
+		if start.Sub(last) > maxRecurrenceGap || (!r.Until.IsZero() && start.After(r.Until)) {
+			return
+		}
+		for _, t := range r.candidates(dtstart, start) {
+			if !t.After(dtstart) {
+				continue
+			}
+			if (!r.Until.IsZero() && t.After(r.Until)) || (r.Count > 0 && count >= r.Count) {
+				return
+			}
+			if !fn(t) {
+				return
+			}
+			count++
+			last = t
+		}
+	}
+}
+
+// skip gets how many periods of the series can be passed over, ending before the given time.
+// None can be when the occurrences are counted.
+func (r Recurrence) skip(dtstart time.Time, from time.Time) int {
+	first := r.period(dtstart, 0)
+	if r.Count > 0 || !from.After(first) {
+		return 0
+	}
+	var periods int
+	switch r.Freq {
+	case FreqHourly:
+		periods = int(from.Sub(first) / time.Hour)
+	case FreqDaily:
+		periods = int(from.Sub(first) / (24 * time.Hour))
+	case FreqWeekly:
+		periods = int(from.Sub(first) / (7 * 24 * time.Hour))
+	case FreqMonthly:
+		from = from.UTC()
+		periods = (from.Year()-first.Year())*12 + int(from.Month()) - int(first.Month())
+	default:
+		periods = from.UTC().Year() - first.Year()
+	}
+	if skip := periods/r.Interval - 1; skip > 0 {
+		return skip
+	}
+	return 0
+}
+
+// period gets the start of the ith period of the series, an hour, day, week, month or year by its frequency.
+func (r Recurrence) period(dtstart time.Time, i int) time.Time {
+	day := time.Date(dtstart.Year(), dtstart.Month(), dtstart.Day(), 0, 0, 0, 0, time.UTC)
+	n := i * r.Interval
+	switch r.Freq {
+	case FreqHourly:
+		return dtstart.Truncate(time.Hour).Add(time.Duration(n) * time.Hour)
+	case FreqDaily:
+		return day.AddDate(0, 0, n)
+	case FreqWeekly:
+		return day.AddDate(0, 0, 7*n-(7+int(day.Weekday())-int(r.WeekStart))%7)
+	case FreqMonthly:
+		return time.Date(dtstart.Year(), dtstart.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(dtstart.Year()+n, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// candidates gets the times in the period starting at start matching the rule, in order.
+func (r Recurrence) candidates(dtstart time.Time, start time.Time) []time.Time {
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	end := day.AddDate(0, 0, 1)
+	switch r.Freq {
+	case FreqWeekly:
+		end = day.AddDate(0, 0, 7)
+	case FreqMonthly:
+		end = day.AddDate(0, 1, 0)
+	case FreqYearly:
+		end = day.AddDate(1, 0, 0)
+	}
+
+	hours := r.ByHour
+	if len(hours) == 0 {
+		hours = []int{dtstart.Hour()}
+	}
+	minutes := r.ByMinute
+	if len(minutes) == 0 {
+		minutes = []int{dtstart.Minute()}
+	}
+	seconds := time.Duration(dtstart.Second()) * time.Second
+
+	var times []time.Time
+	for ; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !r.matchesDay(dtstart, day) {
+			continue
+		}
+		if r.Freq == FreqHourly {
+			if len(r.ByHour) > 0 && !containsInt(r.ByHour, start.Hour()) {
+				continue
+			}
+			for _, m := range minutes {
+				times = append(times, start.Add(time.Duration(m)*time.Minute+seconds))
+			}
+			continue
+		}
+		for _, h := range hours {
+			for _, m := range minutes {
+				times = append(times, day.Add(time.Duration(h)*time.Hour+time.Duration(m)*time.Minute+seconds))
+			}
+		}
+	}
+	return times
+}
+
+// matchesDay checks the day is one the rule occurs on.
+// Without BYDAY or BYMONTHDAY, a weekly rule occurs on the weekday of dtstart, and a monthly or yearly
+// rule on its day of the month, in the month of dtstart too unless given BYMONTH.
+func (r Recurrence) matchesDay(dtstart time.Time, day time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(day.Month())) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && !r.matchesMonthDay(day) {
+		return false
+	}
+	if len(r.ByDay) > 0 {
+		return r.matchesWeekday(day)
+	}
+	if len(r.ByMonthDay) > 0 {
+		return true
+	}
+	switch r.Freq {
+	case FreqWeekly:
+		return day.Weekday() == dtstart.Weekday()
+	case FreqMonthly:
+		return day.Day() == dtstart.Day()
+	case FreqYearly:
+		return day.Day() == dtstart.Day() && (len(r.ByMonth) > 0 || day.Month() == dtstart.Month())
+	}
+	return true
+}
+
+func (r Recurrence) matchesMonthDay(day time.Time) bool {
+	days := daysInMonth(day)
+	for _, d := range r.ByMonthDay {
+		if d == day.Day() || days+d+1 == day.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWeekday checks the day is one of the BYDAY weekdays. A numbered weekday is counted within the month
+// for a monthly rule, or one given BYMONTH, otherwise within the year.
+func (r Recurrence) matchesWeekday(day time.Time) bool {
+	for _, d := range r.ByDay {
+		if d.Weekday != day.Weekday() {
+			continue
+		}
+		if d.N == 0 {
+			return true
+		}
+		index, total := day.YearDay(), time.Date(day.Year(), time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+		if r.Freq == FreqMonthly || len(r.ByMonth) > 0 {
+			index, total = day.Day(), daysInMonth(day)
+		}
+		if (d.N > 0 && (index-1)/7+1 == d.N) || (d.N < 0 && (total-index)/7+1 == -d.N) {
+			return true
+		}
+	}
+	return false
+}
+
+func daysInMonth(day time.Time) int {
+	return time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func parsePositive(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if nil != err || n < 1 {
+		return 0, fmt.Errorf("expected a positive number, found %s", s)
+	}
+	return n, nil
+}
+
+// parseUntil reads an UTC date-time, or a date. A time without the Z is taken to be UTC.
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range []string{untilFormat, "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, s); nil == err {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected UNTIL as a date, 20060102, or UTC time, 20060102T150405Z, found %s", s)
+}
+
+// parseInts reads a list of numbers between min and max, other than 0, sorted without repeats.
+func parseInts(s string, min int, max int) ([]int, error) {
+	var items []int
+	for _, item := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimPrefix(item, "+"))
+		if nil != err || n < min || n > max || (n == 0 && min < 0) {
+			return nil, fmt.Errorf("expected numbers from %d to %d, found %s", min, max, item)
+		}
+		if !containsInt(items, n) {
+			items = append(items, n)
+		}
+	}
+	sort.Ints(items)
+	return items, nil
+}
+
+func formatInts(items []int) string {
+	var s []string
+	for _, item := range items {
+		s = append(s, strconv.Itoa(item))
+	}
+	return strings.Join(s, ",")
+}
+
+// parseDays reads a BYDAY list, such as MO,WE or 1MO,-1FR
+func parseDays(s string) ([]RecurrenceDay, error) {
+	var days []RecurrenceDay
+	for _, item := range strings.Split(s, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("expected weekdays such as MO or -1FR, found %s", item)
+		}
+		weekday, err := parseWeekday(item[len(item)-2:])
+		if nil != err {
+			return nil, err
+		}
+		var n int
+		if prefix := strings.TrimPrefix(item[:len(item)-2], "+"); prefix != "" {
+			if n, err = strconv.Atoi(prefix); nil != err || n == 0 || n > 53 || n < -53 {
+				return nil, fmt.Errorf("expected weekdays such as MO or -1FR, found %s", item)
+			}
+		}
+		days = append(days, RecurrenceDay{N: n, Weekday: weekday})
+	}
+	return days, nil
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	for i, name := range weekdayNames {
+		if name == s {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, fmt.Errorf("expected a weekday, SU, MO, TU, WE, TH, FR or SA, found %s", s)
+}
+
+func containsInt(items []int, i int) bool {
+	for _, item := range items {
+		if item == i {
+			return true
+		}
+	}
+	return false
+}
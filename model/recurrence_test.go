@@ -0,0 +1,107 @@
+package model_test
+
+import (
+	"gatso/model"
+	"testing"
+	"time"
+)
+
+func TestRecurrenceOccurrences(t *testing.T) {
+	at := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02T15:04", s)
+		if nil != err {
+			panic(err)
+		}
+		return t
+	}
+	tests := []struct {
+		rule    string
+		dtstart string
+		after   string
+		want    []string
+		ends    bool // no occurrences follow those wanted
+	}{
+		{"FREQ=DAILY;INTERVAL=2", "2024-01-01T09:00", "2024-01-01T09:00", []string{"2024-01-03T09:00", "2024-01-05T09:00"}, false},
+		{"FREQ=WEEKLY;BYDAY=MO,TH", "2024-01-03T10:00", "2024-01-03T10:00",
+			[]string{"2024-01-04T10:00", "2024-01-08T10:00", "2024-01-11T10:00"}, false},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU", "2024-01-01T08:00", "2024-01-01T08:00", []string{"2024-01-02T08:00", "2024-01-16T08:00"}, false},
+		{"FREQ=MONTHLY;BYDAY=-1FR", "2024-01-01T17:00", "2024-01-01T17:00",
+			[]string{"2024-01-26T17:00", "2024-02-23T17:00", "2024-03-29T17:00"}, false},
+		{"FREQ=MONTHLY", "2024-01-31T12:00", "2024-01-31T12:00", []string{"2024-03-31T12:00", "2024-05-31T12:00"}, false},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", "2024-01-15T12:00", "2024-01-15T12:00",
+			[]string{"2024-01-31T12:00", "2024-02-29T12:00", "2024-03-31T12:00"}, false},
+		{"FREQ=YEARLY", "2024-02-29T00:00", "2024-02-29T00:00", []string{"2028-02-29T00:00"}, false},
+		{"FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", "2024-01-01T00:00", "2024-01-01T00:00", []string{"2024-11-28T00:00", "2025-11-27T00:00"}, false},
+		{"FREQ=YEARLY;BYDAY=1MO", "2024-01-01T00:00", "2024-01-01T00:00", []string{"2025-01-06T00:00"}, false},
+		{"FREQ=DAILY;BYHOUR=9,17;BYMINUTE=30", "2024-01-01T12:00", "2024-01-01T12:00",
+			[]string{"2024-01-01T17:30", "2024-01-02T09:30", "2024-01-02T17:30"}, false},
+		{"FREQ=HOURLY;INTERVAL=6", "2024-01-01T01:15", "2024-01-01T01:15", []string{"2024-01-01T07:15", "2024-01-01T13:15"}, false},
+		{"FREQ=DAILY;COUNT=3", "2024-01-01T09:00", "2024-01-01T09:00", []string{"2024-01-02T09:00", "2024-01-03T09:00"}, true},
+		{"FREQ=DAILY;UNTIL=20240103", "2024-01-01T09:00", "2024-01-01T09:00", []string{"2024-01-02T09:00"}, true},
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", "2024-01-01T09:00", "2024-01-01T09:00", nil, true},
+		{"FREQ=WEEKLY", "2000-01-03T09:00", "2024-01-02T00:00", []string{"2024-01-08T09:00", "2024-01-15T09:00"}, false},
+		{"RRULE:FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=1", "2024-01-01T09:00", "2030-01-01T09:00", []string{"2030-04-01T09:00"}, false},
+	}
+	for _, tt := range tests {
+		r, err := model.ParseRecurrence(tt.rule)
+		if nil != err {
+			t.Errorf("Expected rule %s to be read, found %v", tt.rule, err)
+			continue
+		}
+		found := r.Occurrences(at(tt.dtstart), at(tt.after), len(tt.want)+1)
+		matched := len(found) == len(tt.want)+1 || (tt.ends && len(found) == len(tt.want))
+		for i := 0; matched && i < len(tt.want); i++ {
+			matched = found[i].Equal(at(tt.want[i]))
+		}
+		if !matched {
+			t.Errorf("Expected rule %s from %s to occur at %v, found %v", tt.rule, tt.dtstart, tt.want, found)
+		}
+	}
+}
+
+func TestNextOccurrence(t *testing.T) {
+	expires := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	task := model.Task{Owner: 123, Title: "Bins out", Expires: expires, Labels: []string{"chores"},
+		Recurrence: "FREQ=WEEKLY;COUNT=3", Priority: model.PriorityHigh, List: "home"}
+	next, err := model.NextOccurrence(task, expires)
+	if nil != err || nil == next {
+		t.Fatalf("Expected the next occurrence, found %v", err)
+	}
+	if !next.Expires.Equal(expires.AddDate(0, 0, 7)) || next.Recurrence != "FREQ=WEEKLY;COUNT=2" ||
+		next.Title != task.Title || len(next.Labels) != 1 || next.Priority != task.Priority ||
+		next.List != task.List {
+		t.Errorf("Expected the next occurrence a week later, of the same priority in the same list, found %+v", next)
+	}
+}
+
+func TestParseRecurrence(t *testing.T) {
+	r, err := model.ParseRecurrence("RRULE:freq=monthly;byday=mo,-1fr;interval=2;wkst=su")
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	if s := r.String(); s != "FREQ=MONTHLY;INTERVAL=2;BYDAY=MO,-1FR;WKST=SU" {
+		t.Errorf("Expected the rule to be formatted in order, found %s", s)
+	}
+
+	for _, rule := range []string{
+		"",
+		"WEEKLY",
+		"FREQ=MINUTELY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20250101",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;BYHOUR=24",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=DAILY;BYSETPOS=1",
+	} {
+		if _, err := model.ParseRecurrence(rule); nil == err {
+			t.Errorf("Expected rule %q to be rejected", rule)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reminder is a scheduled reminder that a task is falling due, At its expiry less Before.
+// Each reminder is sent at most once.
+type Reminder struct {
+	TaskID string        `json:"taskId" bson:"taskId"`
+	Before time.Duration `json:"before" bson:"before"`
+	At     time.Time     `json:"at" bson:"at"`
+	Sent   *time.Time    `json:"sent,omitempty" bson:"sent,omitempty"` // when sent, or found to be no longer wanted
+}
+
+// ParseReminder reads how long before a task expires to remind its owner. e.g. "1d", "2h30m" or "0" for when
+// it expires. Takes any time.ParseDuration string, optionally starting with a number of days.
+func ParseReminder(s string) (time.Duration, error) {
+	rest := strings.TrimSpace(s)
+	var before time.Duration
+	if i := strings.Index(rest, "d"); i > 0 {
+		days, err := strconv.Atoi(rest[:i])
+		if nil != err {
+			return 0, fmt.Errorf("Invalid reminder %q, expected a duration such as 1d or 2h30m", s)
+		}
+		before = time.Duration(days) * 24 * time.Hour
+		rest = rest[i+1:]
+	}
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if nil != err {
+			return 0, fmt.Errorf("Invalid reminder %q, expected a duration such as 1d or 2h30m", s)
+		}
+		before += d
+	}
+	if before < 0 {
+		return 0, fmt.Errorf("Invalid reminder %q, reminders must be before the task expires", s)
+	}
+	return before, nil
+}
+
+// TaskReminders gets the reminders of the task, scheduled from its expiry.
+// A task without an expiry has no reminders. Any reminder which can't be read is returned as an error.
+func TaskReminders(t *Task) ([]Reminder, error) {
+	var reminders []Reminder
+	for _, s := range t.Reminders {
+		before, err := ParseReminder(s)
+		if nil != err {
+			return nil, err
+		}
+		if !t.Expires.IsZero() {
+			reminders = append(reminders, Reminder{Before: before, At: t.Expires.Add(-before)})
+		}
+	}
+	if nil != t.ID {
+		for i := range reminders {
+			reminders[i].TaskID = t.Id()
+		}
+	}
+	return reminders, nil
+}
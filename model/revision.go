@@ -0,0 +1,75 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const RevisionCreate = "create"
+const RevisionUpdate = "update"
+const RevisionDelete = "delete"
+const RevisionRestore = "restore"
+
+// Revision is an immutable snapshot of a task, taken each time it changes.
+// Revisions of a task are numbered by the version of the task they hold.
+type Revision struct {
+	TaskID    string    `json:"taskId" bson:"taskId"`
+	Number    int       `json:"revision" bson:"revision"`
+	Action    string    `json:"action" bson:"action"`
+	Changed   time.Time `json:"changed" bson:"changed"`
+	ChangedBy int       `json:"changedBy" bson:"changedBy"`
+	Task      Task      `json:"task" bson:"task"`
+}
+
+// FieldChange is the change to a single task field between two revisions.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// Diff lists the fields of the task which differ between the two revisions, by their json names.
+func Diff(from, to *Revision) ([]FieldChange, error) {
+	a, err := taskFields(from.Task)
+	if nil != err {
+		return nil, err
+	}
+	b, err := taskFields(to.Task)
+	if nil != err {
+		return nil, err
+	}
+
+	var names []string
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []FieldChange{}
+	for _, name := range names {
+		if !reflect.DeepEqual(a[name], b[name]) {
+			changes = append(changes, FieldChange{Field: name, From: a[name], To: b[name]})
+		}
+	}
+	return changes, nil
+}
+
+// taskFields gets the json values of the task fields, by name.
+func taskFields(t Task) (map[string]interface{}, error) {
+	by, err := json.Marshal(&t)
+	if nil != err {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(by, &fields); nil != err {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,61 @@
+package model
+
+// The statuses a task moves through, from open to done or cancelled.
+const (
+	StatusOpen       = "open"
+	StatusInProgress = "in-progress"
+	StatusBlocked    = "blocked"
+	StatusDone       = "done"
+	StatusCancelled  = "cancelled"
+)
+
+// Statuses are every status a task may have.
+var Statuses = []string{StatusOpen, StatusInProgress, StatusBlocked, StatusDone, StatusCancelled}
+
+// statusChanges are the statuses a task may move to from each status. A task may always keep its status.
+// A blocked task must be unblocked before it is done, and a done or cancelled task is reopened to change it again.
+var statusChanges = map[string][]string{
+	StatusOpen:       {StatusInProgress, StatusBlocked, StatusDone, StatusCancelled},
+	StatusInProgress: {StatusOpen, StatusBlocked, StatusDone, StatusCancelled},
+	StatusBlocked:    {StatusOpen, StatusInProgress, StatusCancelled},
+	StatusDone:       {StatusOpen},
+	StatusCancelled:  {StatusOpen},
+}
+
+// ValidStatus checks the status is one of the Statuses.
+func ValidStatus(status string) bool {
+	_, ok := statusChanges[status]
+	return ok
+}
+
+// StatusOf gets the status of the task, open if it has none.
+func StatusOf(t *Task) string {
+	if t.Status == "" {
+		return StatusOpen
+	}
+	return t.Status
+}
+
+// CanChangeStatus checks a task may move from one status to the other.
+func CanChangeStatus(from string, to string) bool {
+	if from == to {
+		return ValidStatus(to)
+	}
+	for _, s := range statusChanges[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusesBefore gets the statuses a task may move to the given status from, including the status itself.
+func StatusesBefore(to string) []string {
+	var from []string
+	for _, s := range Statuses {
+		if CanChangeStatus(s, to) {
+			from = append(from, s)
+		}
+	}
+	return from
+}
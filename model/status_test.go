@@ -0,0 +1,38 @@
+package model_test
+
+import (
+	"fmt"
+	"gatso/model"
+	"testing"
+)
+
+func TestCanChangeStatus(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		want bool
+	}{
+		{model.StatusOpen, model.StatusDone, true},
+		{model.StatusInProgress, model.StatusBlocked, true},
+		{model.StatusBlocked, model.StatusDone, false},
+		{model.StatusBlocked, model.StatusInProgress, true},
+		{model.StatusDone, model.StatusDone, true},
+		{model.StatusDone, model.StatusInProgress, false},
+		{model.StatusCancelled, model.StatusOpen, true},
+		{model.StatusOpen, "unknown", false},
+		{"unknown", "unknown", false},
+	}
+	for _, tt := range tests {
+		if got := model.CanChangeStatus(tt.from, tt.to); got != tt.want {
+			t.Errorf("Expected change from %s to %s to be %v, found %v", tt.from, tt.to, tt.want, got)
+		}
+	}
+
+	before := model.StatusesBefore(model.StatusDone)
+	if fmt.Sprint(before) != fmt.Sprint([]string{model.StatusOpen, model.StatusInProgress, model.StatusDone}) {
+		t.Errorf("Expected open, in-progress and done tasks to be able to be done, found %v", before)
+	}
+	if s := model.StatusOf(&model.Task{}); s != model.StatusOpen {
+		t.Errorf("Expected a task without a status to be open, found %s", s)
+	}
+}
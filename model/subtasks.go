@@ -0,0 +1,36 @@
+package model
+
+// TaskTree is a task along with its subtasks, each with their own subtasks in turn.
+type TaskTree struct {
+	Task     *Task       `json:"task"`
+	Subtasks []*TaskTree `json:"subtasks,omitempty"`
+}
+
+// SetProgress sets the Progress of every task in the tree with subtasks, as the percentage of its subtasks done,
+// at every depth. Cancelled subtasks aren't counted.
+func (tree *TaskTree) SetProgress() {
+	tree.progress()
+}
+
+// progress sets the progress of the tree, returning the number of tasks in the tree counted and done.
+func (tree *TaskTree) progress() (counted int, done int) {
+	for _, sub := range tree.Subtasks {
+		c, d := sub.progress()
+		counted += c
+		done += d
+	}
+	if counted > 0 {
+		percent := done * 100 / counted
+		tree.Task.Progress = &percent
+	}
+
+	switch StatusOf(tree.Task) {
+	case StatusCancelled:
+	case StatusDone:
+		counted++
+		done++
+	default:
+		counted++
+	}
+	return counted, done
+}
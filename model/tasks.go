@@ -6,16 +6,39 @@ import (
 )
 
 type Task struct {
-	ID      *primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
-	Created time.Time           `json:"created"`
-	Owner   int                 `json:"owner"`
-	Title   string              `json:"title"`
-	Expires time.Time           `json:"expires"`
-	Labels  []string            `json:"labels"`
-	Notes   []string            `json:"notes"`
-	Readers []int               `json:"readers"`
+	ID          *primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Created     time.Time           `json:"created"`
+	Owner       int                 `json:"owner"`
+	Title       string              `json:"title"`
+	Expires     time.Time           `json:"expires"`
+	Labels      []string            `json:"labels"`
+	Notes       []string            `json:"notes"`
+	ACL         []Grant             `json:"acl,omitempty" bson:"acl"`                           // who the task is shared with, and in what role
+	Role        string              `json:"role,omitempty" bson:"-"`                            // the role of the user it was listed for, never stored
+	Reminders   []string            `json:"reminders,omitempty" bson:"reminders,omitempty"`     // how long before expiry to remind the owner
+	Recurrence  string              `json:"recurrence,omitempty" bson:"recurrence,omitempty"`   // RRULE the task recurs by, from its expiry
+	Recurred    *time.Time          `json:"recurred,omitempty" bson:"recurred,omitempty"`       // the expiry the next occurrence was added for
+	List        string              `json:"list" bson:"list,omitempty"`                         // id of the owners list the task is in, the DefaultList if empty
+	Status      string              `json:"status" bson:"status,omitempty"`                     // one of the Statuses, open if empty
+	CompletedAt *time.Time          `json:"completedAt,omitempty" bson:"completedAt,omitempty"` // when it was done
+	Priority    int                 `json:"priority" bson:"priority"`                           // one of the priority levels, PriorityNone to PriorityHigh
+	Urgency     float64             `json:"urgency,omitempty" bson:"-"`                         // computed as the task is listed, never stored
+	Parent      string              `json:"parent,omitempty" bson:"parent"`                     // id of the task this is a subtask of
+	Progress    *int                `json:"progress,omitempty" bson:"-"`                        // percentage of its subtasks done, computed, never stored
+	BlockedBy   []string            `json:"blockedBy,omitempty" bson:"blockedBy"`               // ids of the owners tasks to be done before this can start
+	Version     int                 `json:"version" bson:"version,omitempty"`                   // incremented on every update
+	Deleted     *time.Time          `json:"deleted,omitempty" bson:"deleted,omitempty"`         // when moved to the trash
+	Archived    *time.Time          `json:"archived,omitempty" bson:"archived,omitempty"`       // when moved to the archive, having long expired
+	Unarchived  *time.Time          `json:"unarchived,omitempty" bson:"unarchived,omitempty"`   // when last taken out of the archive
 }
 
 func (t Task) Id() string {
 	return t.ID.Hex()
 }
+
+// TaskPage is one page of a task listing.
+// Next is the cursor to request the following page with, empty when there are no more tasks.
+type TaskPage struct {
+	Tasks []*Task `json:"tasks"`
+	Next  string  `json:"next,omitempty"`
+}
@@ -0,0 +1,73 @@
+package model
+
+import "time"
+
+// The priority levels of a task, a task without a priority has PriorityNone.
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+const urgencyOverdue = 7 * 24 * time.Hour  // overdue by this long, a task is as due as it gets
+const urgencyDueSoon = 14 * 24 * time.Hour // due further off than this, a task is as little due as it gets
+const urgencyLeastDue = 0.2                // due part of the urgency of a task with an expiry, however far off
+const urgencyMaxAge = 365 * 24 * time.Hour // this old, a task is as aged as it gets
+
+// ValidPriority checks the priority is one of the priority levels.
+func ValidPriority(priority int) bool {
+	return priority >= PriorityNone && priority <= PriorityHigh
+}
+
+// UrgencyWeights scale the parts the urgency of a task is made of. Each part is between 0 and 1,
+// so each weight is the most its part adds to the urgency.
+type UrgencyWeights struct {
+	Priority float64 // all of it for high priority, nothing for none
+	Due      float64 // all of it for a week overdue, falling to a fifth for due in two weeks or more
+	Age      float64 // all of it for a year old, nothing for new
+}
+
+// DefaultUrgencyWeights favour the tasks falling due, then those of high priority, with old tasks slowly rising.
+var DefaultUrgencyWeights = UrgencyWeights{Priority: 6, Due: 12, Age: 2}
+
+// Urgency scores how soon the task should be worked on, at the given time, the most urgent scoring highest.
+// Tasks without an expiry add nothing for being due, and tasks done or cancelled have no urgency.
+func (w UrgencyWeights) Urgency(t *Task, now time.Time) float64 {
+	if status := StatusOf(t); status == StatusDone || status == StatusCancelled {
+		return 0
+	}
+	return w.Priority*priorityPart(t.Priority) + w.Due*duePart(t.Expires, now) + w.Age*agePart(t.Created, now)
+}
+
+func priorityPart(priority int) float64 {
+	if !ValidPriority(priority) {
+		return 0
+	}
+	return float64(priority) / PriorityHigh
+}
+
+func duePart(expires time.Time, now time.Time) float64 {
+	if expires.IsZero() {
+		return 0
+	}
+	until := expires.Sub(now)
+	switch {
+	case until <= -urgencyOverdue:
+		return 1
+	case until >= urgencyDueSoon:
+		return urgencyLeastDue
+	}
+	return urgencyLeastDue + (1-urgencyLeastDue)*float64(urgencyDueSoon-until)/float64(urgencyDueSoon+urgencyOverdue)
+}
+
+func agePart(created time.Time, now time.Time) float64 {
+	age := now.Sub(created)
+	switch {
+	case created.IsZero() || age <= 0:
+		return 0
+	case age >= urgencyMaxAge:
+		return 1
+	}
+	return float64(age) / float64(urgencyMaxAge)
+}
@@ -0,0 +1,40 @@
+package model_test
+
+import (
+	"gatso/model"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestUrgency(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tests := []struct {
+		task model.Task
+		want float64
+	}{
+		{model.Task{Created: now}, 0},
+		{model.Task{Created: now, Priority: model.PriorityHigh}, 6},
+		{model.Task{Created: now, Priority: model.PriorityLow}, 2},
+		{model.Task{Created: now, Expires: now.Add(-8 * day)}, 12},
+		{model.Task{Created: now, Expires: now.Add(30 * day)}, 2.4},
+		{model.Task{Created: now, Expires: now.Add(14 * day)}, 2.4},
+		{model.Task{Created: now, Expires: now}, 12 * (0.2 + 0.8*14/21)},
+		{model.Task{Created: now.Add(-2 * 365 * day)}, 2},
+		{model.Task{Created: now.Add(-365 * day / 2)}, 1},
+		{model.Task{Created: now.Add(-365 * day), Expires: now.Add(-7 * day), Priority: model.PriorityHigh}, 20},
+		{model.Task{Created: now, Expires: now.Add(-7 * day), Priority: model.PriorityHigh, Status: model.StatusDone}, 0},
+	}
+	for _, tt := range tests {
+		if got := model.DefaultUrgencyWeights.Urgency(&tt.task, now); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Expected urgency %v of task %+v, found %v", tt.want, tt.task, got)
+		}
+	}
+
+	weights := model.UrgencyWeights{Priority: 1}
+	task := model.Task{Created: now.Add(-365 * day), Expires: now, Priority: model.PriorityMedium}
+	if got := weights.Urgency(&task, now); math.Abs(got-2.0/3) > 1e-9 {
+		t.Errorf("Expected only the priority to count with weights %+v, found %v", weights, got)
+	}
+}
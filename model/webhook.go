@@ -0,0 +1,50 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const DeliveryPending = "pending"
+const DeliveryDelivered = "delivered"
+const DeliveryFailed = "failed"
+
+// Webhook subscribes a url to changes to the tasks an owner owns or reads.
+// Each delivery is signed with the secret, which is only given back when the webhook is created.
+type Webhook struct {
+	ID      string    `json:"id" bson:"_id"`
+	Owner   int       `json:"owner" bson:"owner"`
+	URL     string    `json:"url" bson:"url"`
+	Secret  string    `json:"secret,omitempty" bson:"secret"`
+	Events  []string  `json:"events,omitempty" bson:"events,omitempty"` // Event types to deliver, every type if empty
+	Created time.Time `json:"created" bson:"created"`
+}
+
+// Subscribed checks if events of the given type are delivered to the webhook.
+func (w Webhook) Subscribed(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is a single event sent, or to be sent, to a webhook, along with the outcome of each attempt.
+type Delivery struct {
+	ID             string          `json:"id" bson:"_id"`
+	WebhookID      string          `json:"webhookId" bson:"webhookId"`
+	Owner          int             `json:"owner" bson:"owner"`
+	Event          string          `json:"event" bson:"event"`
+	Payload        json.RawMessage `json:"payload" bson:"payload"`
+	Status         string          `json:"status" bson:"status"`
+	Attempts       int             `json:"attempts" bson:"attempts"`
+	NextAttempt    time.Time       `json:"nextAttempt" bson:"nextAttempt"`
+	LastError      string          `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	ResponseStatus int             `json:"responseStatus,omitempty" bson:"responseStatus,omitempty"`
+	Created        time.Time       `json:"created" bson:"created"`
+	Delivered      *time.Time      `json:"delivered,omitempty" bson:"delivered,omitempty"`
+}
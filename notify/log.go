@@ -0,0 +1,28 @@
+// Package notify sends the reminders of tasks falling due, each Notifier by a different means.
+package notify
+
+import (
+	"context"
+	"gatso/model"
+	"log"
+	"time"
+)
+
+// LogNotifier writes each reminder to the service log.
+type LogNotifier struct {
+	logger *log.Logger
+}
+
+// Create a new LogNotifier writing to the given logger, or the standard logger if nil.
+func NewLogNotifier(logger *log.Logger) *LogNotifier {
+	if nil == logger {
+		logger = log.New(log.Writer(), "", log.LstdFlags)
+	}
+	return &LogNotifier{logger: logger}
+}
+
+func (n LogNotifier) Notify(ctx context.Context, task model.Task, reminder model.Reminder) error {
+	n.logger.Printf("Reminder for owner %d: task %s %q is due %s", task.Owner, reminder.TaskID, task.Title,
+		task.Expires.Format(time.RFC1123))
+	return nil
+}
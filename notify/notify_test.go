@@ -0,0 +1,247 @@
+package notify_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"gatso/data"
+	"gatso/model"
+	"gatso/notify"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testTaskId = primitive.NewObjectID()
+var testTask = model.Task{ID: &testTaskId, Owner: 123, Title: "Test Task", Expires: time.Now().Add(time.Hour),
+	Notes: []string{"a note"}, Reminders: []string{"1h"}}
+var testReminder = model.Reminder{TaskID: testTaskId.Hex(), Before: time.Hour, At: time.Now()}
+
+// fakeSMTP is an SMTP server accepting every message sent to it.
+type fakeSMTP struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	from     []string
+	to       []string
+	messages []string
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if nil != err {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+	reply("220 localhost fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if nil != err {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = append(s.from, strings.TrimPrefix(line, "MAIL FROM:"))
+			s.mu.Unlock()
+			reply("250 OK")
+		case "RCPT":
+			s.mu.Lock()
+			s.to = append(s.to, strings.TrimPrefix(line, "RCPT TO:"))
+			s.mu.Unlock()
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var msg bytes.Buffer
+			for {
+				l, err := r.ReadString('\n')
+				if nil != err {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				msg.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, msg.String())
+			s.mu.Unlock()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPNotifier_Notify(t *testing.T) {
+	s := startFakeSMTP(t)
+	defer s.ln.Close()
+
+	n, err := notify.NewSMTPNotifier(s.ln.Addr().String(), "todo@example.com", "todo+%d@example.com", "", "")
+	if nil != err {
+		t.Error(err)
+		return
+	}
+	task := testTask
+	task.Title = "Sneaky\r\nBcc: someone@example.com"
+	task.Notes = []string{"a note", "first line\r\nsecond line\rthird line\nfourth line"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Notify(ctx, task, testReminder); nil != err {
+		t.Error(err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.messages) != 1 || len(s.to) != 1 || s.to[0] != "<todo+123@example.com>" {
+		t.Errorf("Expected one message to the owner, found %d to %v", len(s.messages), s.to)
+		return
+	}
+	msg := s.messages[0]
+	if !strings.Contains(msg, "Subject: Reminder: Sneaky  Bcc: someone@example.com is due") ||
+		strings.Contains(msg, "\r\nBcc:") || !strings.Contains(msg, "a note") {
+		t.Errorf("Expected the reminder message, with the title kept in the subject, found %s", msg)
+		return
+	}
+	if !strings.Contains(msg, "first line\r\nsecond line\r\nthird line\r\nfourth line\r\n") ||
+		strings.Contains(msg, "\r\r") {
+		t.Errorf("Expected each line of the notes ended with CRLF, found %q", msg)
+	}
+}
+
+func TestSMTPNotifier_NotifyCancelled(t *testing.T) {
+	// a server which accepts connections, but never answers
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if nil != err {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	n, err := notify.NewSMTPNotifier(ln.Addr().String(), "todo@example.com", "todo+%d@example.com", "", "")
+	if nil != err {
+		t.Fatal(err)
+	}
+	// cancelled without a deadline, as the service stops
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	if err := n.Notify(ctx, testTask, testReminder); nil == err {
+		t.Error("Expected the reminder to fail once cancelled")
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("Expected the reminder to be given up once cancelled, took %v", took)
+	}
+}
+
+func TestNewSMTPNotifier(t *testing.T) {
+	for _, tt := range []struct {
+		addr      string
+		recipient string
+		valid     bool
+	}{
+		{"localhost:25", "todo+%d@example.com", true},
+		{"localhost", "todo+%d@example.com", false},
+		{"localhost:25", "todo@example.com", false},
+	} {
+		if _, err := notify.NewSMTPNotifier(tt.addr, "todo@example.com", tt.recipient, "", ""); (nil == err) != tt.valid {
+			t.Errorf("Expected a notifier for %s to %s to be valid %v, found %v", tt.addr, tt.recipient, tt.valid, err)
+		}
+	}
+}
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		header = r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := notify.NewWebhookNotifier(srv.URL, "shh", srv.Client())
+	if err := n.Notify(context.Background(), testTask, testReminder); nil != err {
+		t.Error(err)
+		return
+	}
+	if header.Get(data.HeaderWebhookSignature) != data.SignPayload("shh", body) {
+		t.Errorf("Expected the reminder to be signed, found %q", header.Get(data.HeaderWebhookSignature))
+		return
+	}
+	var payload struct {
+		Event string     `json:"event"`
+		Owner int        `json:"owner"`
+		Task  model.Task `json:"task"`
+	}
+	if err := json.Unmarshal(body, &payload); nil != err {
+		t.Error(err)
+		return
+	}
+	if payload.Event != "reminder" || payload.Owner != 123 || payload.Task.Title != testTask.Title {
+		t.Errorf("Expected the reminder of the task for owner 123, found %s", body)
+		return
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := notify.NewWebhookNotifier(failing.URL, "shh", failing.Client()).Notify(context.Background(),
+		testTask, testReminder); nil == err {
+		t.Errorf("Expected an error when the webhook fails")
+	}
+}
+
+func TestLogNotifier_Notify(t *testing.T) {
+	var buf bytes.Buffer
+	n := notify.NewLogNotifier(log.New(&buf, "", 0))
+	if err := n.Notify(context.Background(), testTask, testReminder); nil != err {
+		t.Error(err)
+		return
+	}
+	if !strings.Contains(buf.String(), `owner 123: task`) || !strings.Contains(buf.String(), `"Test Task"`) {
+		t.Errorf("Expected the reminder to be logged, found %q", buf.String())
+	}
+}
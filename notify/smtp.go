@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"gatso/model"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const smtpTimeout = time.Minute // time connecting to the server, and sending each message, may take
+
+// SMTPNotifier emails each reminder to the owner of the task, through an SMTP server.
+// Owners are only known by their id, so the address of each is made from a format holding a single %d for the id.
+// e.g. "todo+%d@example.com"
+type SMTPNotifier struct {
+	addr      string // host:port of the server
+	from      string
+	recipient string
+	auth      smtp.Auth
+}
+
+// Create a new SMTPNotifier sending through the server at the given address, authenticating with the username
+// and password if a username is given.  STARTTLS is used whenever the server offers it.
+func NewSMTPNotifier(addr string, from string, recipient string, username string, password string) (*SMTPNotifier, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if nil != err {
+		return nil, err
+	}
+	if strings.Count(recipient, "%d") != 1 {
+		return nil, fmt.Errorf("Recipient %q must hold a single %%d for the owner id", recipient)
+	}
+	n := &SMTPNotifier{addr: addr, from: from, recipient: recipient}
+	if username != "" {
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return n, nil
+}
+
+// Notify emails the reminder, giving up after smtpTimeout, or sooner if the context is done.
+func (n SMTPNotifier) Notify(ctx context.Context, task model.Task, reminder model.Reminder) error {
+	to := fmt.Sprintf(n.recipient, task.Owner)
+	host, _, _ := net.SplitHostPort(n.addr)
+
+	d := net.Dialer{Timeout: smtpTimeout}
+	conn, err := d.DialContext(ctx, "tcp", n.addr)
+	if nil != err {
+		return err
+	}
+	deadline := time.Now().Add(smtpTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	// the smtp client doesn't take a context, so the connection is cut short when it is done
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	c, err := smtp.NewClient(conn, host)
+	if nil != err {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); nil != err {
+			return err
+		}
+	}
+	if nil != n.auth {
+		if err := c.Auth(n.auth); nil != err {
+			return err
+		}
+	}
+	if err := c.Mail(n.from); nil != err {
+		return err
+	}
+	if err := c.Rcpt(to); nil != err {
+		return err
+	}
+	w, err := c.Data()
+	if nil != err {
+		return err
+	}
+	if _, err := w.Write(n.message(to, task)); nil != err {
+		return err
+	}
+	if err := w.Close(); nil != err {
+		return err
+	}
+	return c.Quit()
+}
+
+// message formats the reminder email.
+func (n SMTPNotifier) message(to string, task model.Task) []byte {
+	// keep the title from breaking out of the subject header
+	title := strings.NewReplacer("\r", " ", "\n", " ").Replace(task.Title)
+	due := task.Expires.Format(time.RFC1123)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", n.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: Reminder: %s is due %s\r\n", title, due)
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	fmt.Fprintf(&buf, "Your task %q is due %s.\r\n", title, due)
+	// notes may break lines with any of CRLF, LF or CR, each line is ended with CRLF
+	lines := strings.NewReplacer("\r\n", "\n", "\r", "\n")
+	for _, note := range task.Notes {
+		fmt.Fprintf(&buf, "\r\n%s\r\n", strings.ReplaceAll(lines.Replace(note), "\n", "\r\n"))
+	}
+	return buf.Bytes()
+}
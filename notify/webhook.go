@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gatso/data"
+	"gatso/model"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+const eventReminder = "reminder"
+
+// reminderPayload is the json body posted for each reminder.
+type reminderPayload struct {
+	Event    string         `json:"event"`
+	Owner    int            `json:"owner"`
+	Reminder model.Reminder `json:"reminder"`
+	Task     model.Task     `json:"task"`
+}
+
+// WebhookNotifier posts each reminder to a url, signed with a secret as webhook deliveries are.
+type WebhookNotifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// Create a new WebhookNotifier posting to the given url with the given client.
+func NewWebhookNotifier(url string, secret string, client *http.Client) *WebhookNotifier {
+	return &WebhookNotifier{url: url, secret: secret, client: client}
+}
+
+func (n WebhookNotifier) Notify(ctx context.Context, task model.Task, reminder model.Reminder) error {
+	payload, err := json.Marshal(&reminderPayload{Event: eventReminder, Owner: task.Owner, Reminder: reminder, Task: task})
+	if nil != err {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(payload))
+	if nil != err {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(data.HeaderWebhookEvent, eventReminder)
+	req.Header.Set(data.HeaderWebhookSignature, data.SignPayload(n.secret, payload))
+	resp, err := n.client.Do(req)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("reminder webhook responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Package ratelimit limits how often each owner, or each remote address making requests without one, may make requests.
+package ratelimit
+
+import (
+	"fmt"
+	"gatso/auth"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const headerLimit = "RateLimit-Limit"
+const headerRemaining = "RateLimit-Remaining"
+const headerReset = "RateLimit-Reset"
+const headerRetryAfter = "Retry-After"
+
+// sweepInterval is how often the buckets which have filled back up are dropped, so idle clients are forgotten.
+const sweepInterval = time.Minute
+
+// bucket holds the tokens left to one client, as of the time it was last taken from.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is the middleware giving each owner a token bucket, refilled at a steady rate up to its burst.
+// Each request takes a token, those finding the bucket empty are refused as 429 Too Many Requests.
+// Requests which aren't authenticated are limited by their remote address instead.
+type Limiter struct {
+	rate    float64 // tokens added each second
+	burst   int     // most tokens a bucket holds
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// NewLimiter creates a Limiter allowing each client the given number of requests a second, on average,
+// and up to burst requests at once. The rate must be above zero.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{rate: rate, burst: burst, buckets: map[string]*bucket{}, swept: time.Now()}
+}
+
+// Handler takes a token for each request before passing it to the next handler, giving the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers of its bucket. Requests refused for an empty bucket are given the
+// Retry-After seconds until it has a token again.
+// The owner of a request is read from its context, so the Limiter must come after [auth.Authenticator.Identify].
+func (l *Limiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, remaining, wait := l.take(clientOf(r), time.Now())
+
+		h := w.Header()
+		h.Set(headerLimit, strconv.Itoa(l.burst))
+		h.Set(headerRemaining, strconv.Itoa(int(remaining)))
+		h.Set(headerReset, strconv.Itoa(seconds(float64(l.burst)-remaining, l.rate)))
+		if !allowed {
+			h.Set(headerRetryAfter, strconv.Itoa(seconds(wait, l.rate)))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// take a token from the bucket of the client, as it is at the given time, reporting if there was one,
+// the tokens left in it, and the tokens it is short when it was empty.
+func (l *Limiter) take(client string, at time.Time) (bool, float64, float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if at.Sub(l.swept) >= sweepInterval {
+		l.sweep(at)
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: at}
+		l.buckets[client] = b
+	}
+	b.tokens = l.refilled(b, at)
+	b.last = at
+	if b.tokens < 1 {
+		return false, b.tokens, 1 - b.tokens
+	}
+	b.tokens--
+	return true, b.tokens, 0
+}
+
+// refilled gets the tokens in the bucket at the given time, having been refilled since it was last taken from.
+func (l *Limiter) refilled(b *bucket, at time.Time) float64 {
+	return math.Min(float64(l.burst), b.tokens+at.Sub(b.last).Seconds()*l.rate)
+}
+
+// sweep drops the buckets which have filled back up by the given time, as a new bucket is full.
+// Caller must hold the lock.
+func (l *Limiter) sweep(at time.Time) {
+	for client, b := range l.buckets {
+		if l.refilled(b, at) >= float64(l.burst) {
+			delete(l.buckets, client)
+		}
+	}
+	l.swept = at
+}
+
+// clientOf identifies who the request is limited as, its owner when it was authenticated,
+// otherwise the address it was made from.
+func clientOf(r *http.Request) string {
+	if ownerId, ok := auth.OwnerId(r.Context()); ok {
+		return fmt.Sprintf("owner:%d", ownerId)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if nil != err {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds gets the whole seconds, rounded up, it takes to add the given tokens at the given rate.
+func seconds(tokens float64, rate float64) int {
+	if tokens <= 0 {
+		return 0
+	}
+	return int(math.Ceil(tokens / rate))
+}
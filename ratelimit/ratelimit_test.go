@@ -0,0 +1,52 @@
+package ratelimit_test
+
+import (
+	"gatso/auth"
+	"gatso/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLimiterHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	// requests are authenticated by their owner parameter, those without one are limited by address
+	handler := auth.NewAuthenticator(nil, nil, "sub", true).Identify(ratelimit.NewLimiter(0.5, 2).Handler(ok))
+
+	tests := []struct {
+		url       string
+		addr      string
+		status    int
+		remaining string
+	}{
+		{"/todo?owner=123", "10.0.0.1:1234", http.StatusOK, "1"},
+		{"/todo?owner=123", "10.0.0.2:1234", http.StatusOK, "0"},
+		{"/todo?owner=123", "10.0.0.1:1234", http.StatusTooManyRequests, "0"},
+		{"/todo?owner=456", "10.0.0.1:1234", http.StatusOK, "1"},
+		{"/todo", "10.0.0.1:1234", http.StatusOK, "1"},
+		{"/todo", "10.0.0.1:5678", http.StatusOK, "0"},
+		{"/todo", "10.0.0.1:1234", http.StatusTooManyRequests, "0"},
+		{"/todo", "10.0.0.2:1234", http.StatusOK, "1"},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		req.RemoteAddr = tt.addr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("Expected request %d to %s from %s to respond %d, found %d", i, tt.url, tt.addr, tt.status, rec.Code)
+			continue
+		}
+		h := rec.Header()
+		if h.Get("RateLimit-Limit") != "2" || h.Get("RateLimit-Remaining") != tt.remaining {
+			t.Errorf("Expected request %d to have a limit of 2 with %s remaining, found %s with %s", i, tt.remaining,
+				h.Get("RateLimit-Limit"), h.Get("RateLimit-Remaining"))
+		}
+		if tt.status == http.StatusTooManyRequests && (h.Get("Retry-After") != "2" || h.Get("RateLimit-Reset") != "4") {
+			t.Errorf("Expected request %d to be retried after 2 seconds and reset after 4, found %s and %s", i,
+				h.Get("Retry-After"), h.Get("RateLimit-Reset"))
+		}
+	}
+}